@@ -3,23 +3,54 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/google/uuid"
 	"golang.org/x/sync/errgroup"
 
 	"doc-agents/internal/app"
+	"doc-agents/internal/events"
 	"doc-agents/internal/httputil"
+	"doc-agents/internal/progress"
 	"doc-agents/internal/queue"
 	"doc-agents/internal/store"
+	"doc-agents/internal/webhook"
+)
+
+// Analysis stages a task can be limited to when reprocessing.
+const (
+	stageSummarize = string(store.StageSummarize)
+	stageEmbed     = string(store.StageEmbed)
 )
 
 type analyzeTaskPayload struct {
 	DocumentID string      `json:"document_id"`
 	ChunkIDs   []uuid.UUID `json:"chunk_ids"`
+
+	// Stages limits the work to a subset of summarize and embed; empty runs both.
+	Stages []string `json:"stages,omitempty"`
+	// Staged means the parser re-chunked the document for reprocessing: the
+	// staged chunks are embedded and then swapped in for the live ones.
+	Staged bool `json:"staged,omitempty"`
+	// Version is a newly uploaded version of the document, which becomes the
+	// live one once embedded; zero means the live version itself.
+	Version int `json:"version,omitempty"`
+	// Filename is that of the parsed file. A new version's differs from the
+	// document's until the version is published.
+	Filename string `json:"filename,omitempty"`
+}
+
+// runs reports whether the task includes stage.
+func (p analyzeTaskPayload) runs(stage string) bool {
+	if len(p.Stages) == 0 || (p.Staged && stage == stageEmbed) {
+		return true
+	}
+	return slices.Contains(p.Stages, stage)
 }
 
 func main() {
@@ -30,68 +61,150 @@ func main() {
 	}
 	deps.Log.Info("analysis worker starting")
 
-	g, ctx := errgroup.WithContext(context.Background())
-
-	// Run queue worker
-	g.Go(func() error {
-		return deps.Queue.Worker(ctx, queue.TaskTypeAnalyze, func(ctx context.Context, task queue.Task) error {
-			var payload analyzeTaskPayload
-			if err := json.Unmarshal(task.Payload, &payload); err != nil {
-				return err
-			}
-			return handleAnalyze(ctx, deps, payload)
+	err = app.Run(deps.BaseDeps, func(ctx context.Context, g *errgroup.Group) {
+		// Run queue worker
+		g.Go(func() error {
+			return deps.Queue.Worker(ctx, queue.TaskTypeAnalyze, func(ctx context.Context, task queue.Task) error {
+				var payload analyzeTaskPayload
+				if err := json.Unmarshal(task.Payload, &payload); err != nil {
+					return err
+				}
+				return handleAnalyze(ctx, deps, task, payload)
+			})
 		})
-	})
 
-	// Run health check server
-	g.Go(func() error {
-		return httputil.ServeHealth(deps, "analysis")
+		// Run health check server
+		g.Go(func() error {
+			return httputil.ServeHealth(ctx, deps, "analysis")
+		})
 	})
-
-	// Wait for either to fail
-	if err := g.Wait(); err != nil {
+	if err != nil {
 		deps.Log.Error("analysis service stopped", "err", err)
 	}
 }
 
-func handleAnalyze(ctx context.Context, deps app.AnalysisDeps, payload analyzeTaskPayload) error {
+func handleAnalyze(ctx context.Context, deps app.AnalysisDeps, task queue.Task, payload analyzeTaskPayload) error {
 	// Parse and fetch chunks
 	docID, err := uuid.Parse(payload.DocumentID)
 	if err != nil {
 		return err
 	}
 
-	chunks, err := deps.Store.ListChunks(ctx, docID)
+	// Skip documents deleted while the task was queued, so no summary or
+	// embeddings are generated (and paid for) for them.
+	doc, err := deps.Store.GetDocument(ctx, docID)
+	if errors.Is(err, store.ErrDocumentNotFound) {
+		deps.Log.Info("document deleted, skipping analysis", "document_id", docID)
+		return nil
+	}
 	if err != nil {
+		return fmt.Errorf("failed to get document: %w", err)
+	}
+
+	if payload.Version != 0 && payload.Filename != "" {
+		// Embeddings are enriched with the filename of the version they belong to
+		doc.Filename = payload.Filename
+	}
+
+	listChunks := func(ctx context.Context, docID uuid.UUID) ([]store.Chunk, error) {
+		return deps.Store.ListChunks(ctx, docID, payload.Version)
+	}
+	if payload.Staged {
+		listChunks = deps.Store.ListStagedChunks
+	}
+	// Chunks are loaded by whichever stage runs first, so a failure to load
+	// them is attributed to that stage.
+	var chunks []store.Chunk
+	loadChunks := func() error {
+		if chunks != nil {
+			return nil
+		}
+		chunks, err = listChunks(ctx, docID)
 		return err
 	}
 
-	// Generate and save summary
+	tracker := progress.New(deps.Store, deps.Events, deps.Webhooks, deps.Log, doc, task).ForVersion(payload.Version)
+	if payload.runs(stageSummarize) {
+		if err := tracker.Run(ctx, store.StageSummarize, func() error {
+			if err := loadChunks(); err != nil {
+				return err
+			}
+			return summarize(ctx, deps, docID, payload.Version, chunks)
+		}); err != nil {
+			return err
+		}
+	}
+	if payload.runs(stageEmbed) {
+		if err := tracker.Run(ctx, store.StageEmbed, func() error {
+			if err := loadChunks(); err != nil {
+				return err
+			}
+			if err := embed(ctx, deps, doc, chunks); err != nil {
+				return err
+			}
+			if payload.Staged {
+				// Live chunks and embeddings are replaced in one transaction, so
+				// queries see either the old or the new version, never a mix.
+				if err := deps.Store.PromoteStagedChunks(ctx, docID); err != nil {
+					return err
+				}
+			}
+			if payload.Version != 0 {
+				// Queries switch to the new version's chunks and summary at once
+				if err := deps.Store.PublishVersion(ctx, docID, payload.Version); err != nil {
+					return err
+				}
+			}
+			if payload.Staged || payload.Version != 0 {
+				// Answers cached while the run was in progress cite the old chunks
+				if err := deps.Cache.InvalidateDocument(ctx, docID.String()); err != nil {
+					deps.Log.Error("failed to invalidate cached queries", "document_id", docID, "err", err)
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	// Mark document ready; a delete that raced with this task leaves nothing to update
+	err = deps.Store.UpdateDocumentStatus(ctx, docID, store.StatusReady)
+	if errors.Is(err, store.ErrDocumentNotFound) {
+		deps.Log.Info("document deleted during analysis", "document_id", docID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	tracker.Publish(ctx, events.Event{Type: events.TypeReady})
+	doc.Status = store.StatusReady
+	deps.Webhooks.Notify(ctx, webhook.EventDocumentReady, doc, "")
+	return nil
+}
+
+// summarize generates and saves the summary of a version of the document.
+func summarize(ctx context.Context, deps app.AnalysisDeps, docID uuid.UUID, version int, chunks []store.Chunk) error {
 	text := concatenateChunks(chunks)
 	summaryText, keyPoints, err := deps.LLM.Summarize(ctx, text)
 	if err != nil {
 		return err
 	}
-	if err := deps.Store.SaveSummary(ctx, docID, store.Summary{
+	return deps.Store.SaveSummary(ctx, docID, store.Summary{
+		Version:   version,
 		Summary:   summaryText,
 		KeyPoints: keyPoints,
-	}); err != nil {
-		return err
-	}
-
-	// Generate and save embeddings with contextual enrichment
-	// Get document for contextual enrichment
-	doc, err := deps.Store.GetDocument(ctx, docID)
-	if err != nil {
-		return fmt.Errorf("failed to get document: %w", err)
-	}
+	})
+}
 
+// embed generates and saves chunk embeddings with contextual enrichment.
+// Existing vectors are overwritten in a single statement.
+func embed(ctx context.Context, deps app.AnalysisDeps, doc store.Document, chunks []store.Chunk) error {
 	texts := make([]string, len(chunks))
 	for i, c := range chunks {
 		// Enrich chunk with document context for better embeddings
 		texts[i] = fmt.Sprintf("Document: %s\n\n%s", doc.Filename, c.Text)
 	}
-	vectors, err := deps.Embedder.EmbedBatch(texts)
+	vectors, err := deps.Embedder.EmbedBatch(ctx, texts)
 	if err != nil {
 		return fmt.Errorf("failed to generate embeddings: %w", err)
 	}
@@ -103,12 +216,7 @@ func handleAnalyze(ctx context.Context, deps app.AnalysisDeps, payload analyzeTa
 			Model:   deps.Config.EmbeddingModel,
 		}
 	}
-	if err := deps.Store.SaveEmbeddings(ctx, embeddings); err != nil {
-		return err
-	}
-
-	// Mark document ready
-	return deps.Store.UpdateDocumentStatus(ctx, docID, store.StatusReady)
+	return deps.Store.SaveEmbeddings(ctx, embeddings)
 }
 
 // concatenateChunks combines all chunk texts into a single string for summarization.
@@ -11,10 +11,14 @@ import (
 	"github.com/stretchr/testify/mock"
 
 	"doc-agents/internal/app"
+	"doc-agents/internal/cache"
 	"doc-agents/internal/config"
 	"doc-agents/internal/embeddings"
+	"doc-agents/internal/events"
 	"doc-agents/internal/llm"
+	"doc-agents/internal/queue"
 	"doc-agents/internal/store"
+	"doc-agents/internal/webhook"
 )
 
 func newTestDeps(st store.Store, l llm.Client, e embeddings.Embedder) app.AnalysisDeps {
@@ -26,8 +30,11 @@ func newTestDeps(st store.Store, l llm.Client, e embeddings.Embedder) app.Analys
 			},
 			Log: slog.New(slog.NewTextHandler(io.Discard, nil)),
 		},
+		Events:   events.NewHub(),
+		Webhooks: webhook.NoOpNotifier{},
 		LLM:      l,
 		Embedder: e,
+		Cache:    cache.NewNoOpCache(),
 	}
 }
 
@@ -54,7 +61,7 @@ func TestHandleAnalyze(t *testing.T) {
 					Return(store.Document{ID: validDocID, Filename: "test.pdf"}, nil).Once()
 
 				// Expect ListChunks to be called
-				s.On("ListChunks", mock.Anything, validDocID).
+				s.On("ListChunks", mock.Anything, validDocID, 0).
 					Return([]store.Chunk{
 						{ID: chunk1ID, Index: 0, Text: "Test chunk", TokenCount: 2},
 					}, nil).Once()
@@ -69,7 +76,7 @@ func TestHandleAnalyze(t *testing.T) {
 				})).Return(nil).Once()
 
 				// Expect batch embedder to be called with enriched chunk texts
-				e.On("EmbedBatch", []string{"Document: test.pdf\n\nTest chunk"}).
+				e.On("EmbedBatch", mock.Anything, []string{"Document: test.pdf\n\nTest chunk"}).
 					Return([]embeddings.Vector{{0.1, 0.2, 0.3}}, nil).Once()
 
 				// Expect SaveEmbeddings (batch) to be called with 1 embedding
@@ -93,7 +100,7 @@ func TestHandleAnalyze(t *testing.T) {
 				s.On("GetDocument", mock.Anything, validDocID).
 					Return(store.Document{ID: validDocID, Filename: "test.pdf"}, nil).Once()
 
-				s.On("ListChunks", mock.Anything, validDocID).
+				s.On("ListChunks", mock.Anything, validDocID, 0).
 					Return([]store.Chunk{
 						{ID: chunk1ID, Index: 0, Text: "First chunk", TokenCount: 2},
 						{ID: chunk2ID, Index: 1, Text: "Second chunk", TokenCount: 2},
@@ -106,7 +113,7 @@ func TestHandleAnalyze(t *testing.T) {
 				s.On("SaveSummary", mock.Anything, validDocID, mock.Anything).Return(nil).Once()
 
 				// Expect batch embedder called with enriched chunk texts
-				e.On("EmbedBatch", []string{"Document: test.pdf\n\nFirst chunk", "Document: test.pdf\n\nSecond chunk"}).
+				e.On("EmbedBatch", mock.Anything, []string{"Document: test.pdf\n\nFirst chunk", "Document: test.pdf\n\nSecond chunk"}).
 					Return([]embeddings.Vector{{0.1}, {0.2}}, nil).Once()
 
 				// Expect SaveEmbeddings (batch) called with 2 embeddings
@@ -119,6 +126,113 @@ func TestHandleAnalyze(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "staged chunks are embedded and promoted",
+			payload: analyzeTaskPayload{
+				DocumentID: validDocID.String(),
+				Staged:     true,
+			},
+			setup: func(s *store.MockStore, l *llm.MockClient, e *embeddings.MockEmbedder) {
+				s.On("GetDocument", mock.Anything, validDocID).
+					Return(store.Document{ID: validDocID, Filename: "test.pdf"}, nil).Once()
+				s.On("ListStagedChunks", mock.Anything, validDocID).
+					Return([]store.Chunk{{ID: chunk2ID, Text: "New", TokenCount: 1}}, nil).Once()
+				l.On("Summarize", mock.Anything, "New\n").Return("Summary", []string{"Point"}, nil).Once()
+				s.On("SaveSummary", mock.Anything, validDocID, mock.Anything).Return(nil).Once()
+				e.On("EmbedBatch", mock.Anything, []string{"Document: test.pdf\n\nNew"}).Return([]embeddings.Vector{{0.1}}, nil).Once()
+				s.On("SaveEmbeddings", mock.Anything, mock.MatchedBy(func(embs []store.Embedding) bool {
+					return len(embs) == 1 && embs[0].ChunkID == chunk2ID
+				})).Return(nil).Once()
+				s.On("PromoteStagedChunks", mock.Anything, validDocID).Return(nil).Once()
+				s.On("UpdateDocumentStatus", mock.Anything, validDocID, store.StatusReady).Return(nil).Once()
+			},
+			wantErr: false,
+		},
+		{
+			name: "summarize stage only skips embeddings",
+			payload: analyzeTaskPayload{
+				DocumentID: validDocID.String(),
+				Stages:     []string{"summarize"},
+			},
+			setup: func(s *store.MockStore, l *llm.MockClient, e *embeddings.MockEmbedder) {
+				s.On("GetDocument", mock.Anything, validDocID).
+					Return(store.Document{ID: validDocID, Filename: "test.pdf"}, nil).Once()
+				s.On("ListChunks", mock.Anything, validDocID, 0).
+					Return([]store.Chunk{{ID: chunk1ID, Text: "Test", TokenCount: 1}}, nil).Once()
+				l.On("Summarize", mock.Anything, "Test\n").Return("Summary", []string{"Point"}, nil).Once()
+				s.On("SaveSummary", mock.Anything, validDocID, mock.Anything).Return(nil).Once()
+				s.On("UpdateDocumentStatus", mock.Anything, validDocID, store.StatusReady).Return(nil).Once()
+			},
+			wantErr: false,
+		},
+		{
+			name: "embed stage only skips summarization",
+			payload: analyzeTaskPayload{
+				DocumentID: validDocID.String(),
+				Stages:     []string{"embed"},
+			},
+			setup: func(s *store.MockStore, l *llm.MockClient, e *embeddings.MockEmbedder) {
+				s.On("GetDocument", mock.Anything, validDocID).
+					Return(store.Document{ID: validDocID, Filename: "test.pdf"}, nil).Once()
+				s.On("ListChunks", mock.Anything, validDocID, 0).
+					Return([]store.Chunk{{ID: chunk1ID, Text: "Test", TokenCount: 1}}, nil).Once()
+				e.On("EmbedBatch", mock.Anything, []string{"Document: test.pdf\n\nTest"}).Return([]embeddings.Vector{{0.1}}, nil).Once()
+				s.On("SaveEmbeddings", mock.Anything, mock.Anything).Return(nil).Once()
+				s.On("UpdateDocumentStatus", mock.Anything, validDocID, store.StatusReady).Return(nil).Once()
+			},
+			wantErr: false,
+		},
+		{
+			name: "stage progress is recorded",
+			payload: analyzeTaskPayload{
+				DocumentID: validDocID.String(),
+				Stages:     []string{"summarize"},
+			},
+			setup: func(s *store.MockStore, l *llm.MockClient, e *embeddings.MockEmbedder) {
+				s.On("GetDocument", mock.Anything, validDocID).
+					Return(store.Document{ID: validDocID, Filename: "test.pdf"}, nil).Once()
+				s.On("ListChunks", mock.Anything, validDocID, 0).
+					Return([]store.Chunk{{ID: chunk1ID, Text: "Test", TokenCount: 1}}, nil).Once()
+				l.On("Summarize", mock.Anything, mock.Anything).Return("", []string{}, errors.New("rate limited")).Once()
+				s.On("RecordStage", mock.Anything, validDocID, store.StageSummarize, store.StageRunning, 1, "").Return(nil).Once()
+				s.On("RecordStage", mock.Anything, validDocID, store.StageSummarize, store.StageFailed, 1, "rate limited").Return(nil).Once()
+			},
+			wantErr: true,
+		},
+		{
+			name: "deleted document is skipped",
+			payload: analyzeTaskPayload{
+				DocumentID: validDocID.String(),
+				ChunkIDs:   []uuid.UUID{chunk1ID},
+			},
+			setup: func(s *store.MockStore, l *llm.MockClient, e *embeddings.MockEmbedder) {
+				s.On("GetDocument", mock.Anything, validDocID).
+					Return(store.Document{}, store.ErrDocumentNotFound).Once()
+				// No LLM or embedding calls for a deleted document
+			},
+			wantErr: false,
+		},
+		{
+			name: "document deleted during analysis is not resurrected",
+			payload: analyzeTaskPayload{
+				DocumentID: validDocID.String(),
+				ChunkIDs:   []uuid.UUID{chunk1ID},
+			},
+			setup: func(s *store.MockStore, l *llm.MockClient, e *embeddings.MockEmbedder) {
+				s.On("GetDocument", mock.Anything, validDocID).
+					Return(store.Document{ID: validDocID, Filename: "test.pdf"}, nil).Once()
+				s.On("ListChunks", mock.Anything, validDocID, 0).
+					Return([]store.Chunk{{ID: chunk1ID, Text: "Test", TokenCount: 1}}, nil).Once()
+				l.On("Summarize", mock.Anything, mock.Anything).
+					Return("Summary", []string{"Point"}, nil).Once()
+				s.On("SaveSummary", mock.Anything, validDocID, mock.Anything).Return(nil).Once()
+				e.On("EmbedBatch", mock.Anything, mock.Anything).Return([]embeddings.Vector{{0.1}}, nil).Once()
+				s.On("SaveEmbeddings", mock.Anything, mock.Anything).Return(nil).Once()
+				s.On("UpdateDocumentStatus", mock.Anything, validDocID, store.StatusReady).
+					Return(store.ErrDocumentNotFound).Once()
+			},
+			wantErr: false,
+		},
 		{
 			name: "invalid document ID returns error",
 			payload: analyzeTaskPayload{
@@ -135,7 +249,10 @@ func TestHandleAnalyze(t *testing.T) {
 				ChunkIDs:   []uuid.UUID{chunk1ID},
 			},
 			setup: func(s *store.MockStore, l *llm.MockClient, e *embeddings.MockEmbedder) {
-				s.On("ListChunks", mock.Anything, validDocID).
+				s.On("GetDocument", mock.Anything, validDocID).
+					Return(store.Document{ID: validDocID, Filename: "test.pdf"}, nil).Once()
+
+				s.On("ListChunks", mock.Anything, validDocID, 0).
 					Return(nil, errors.New("database error")).Once()
 			},
 			wantErr: true,
@@ -147,7 +264,10 @@ func TestHandleAnalyze(t *testing.T) {
 				ChunkIDs:   []uuid.UUID{chunk1ID},
 			},
 			setup: func(s *store.MockStore, l *llm.MockClient, e *embeddings.MockEmbedder) {
-				s.On("ListChunks", mock.Anything, validDocID).
+				s.On("GetDocument", mock.Anything, validDocID).
+					Return(store.Document{ID: validDocID, Filename: "test.pdf"}, nil).Once()
+
+				s.On("ListChunks", mock.Anything, validDocID, 0).
 					Return([]store.Chunk{{ID: chunk1ID, Text: "Test", TokenCount: 1}}, nil).Once()
 
 				l.On("Summarize", mock.Anything, mock.Anything).
@@ -165,7 +285,7 @@ func TestHandleAnalyze(t *testing.T) {
 				s.On("GetDocument", mock.Anything, validDocID).
 					Return(store.Document{ID: validDocID, Filename: "test.pdf"}, nil).Once()
 
-				s.On("ListChunks", mock.Anything, validDocID).
+				s.On("ListChunks", mock.Anything, validDocID, 0).
 					Return([]store.Chunk{{ID: chunk1ID, Text: "Test", TokenCount: 1}}, nil).Once()
 
 				l.On("Summarize", mock.Anything, mock.Anything).
@@ -174,7 +294,7 @@ func TestHandleAnalyze(t *testing.T) {
 				s.On("SaveSummary", mock.Anything, validDocID, mock.Anything).Return(nil).Once()
 
 				// EmbedBatch fails
-				e.On("EmbedBatch", []string{"Document: test.pdf\n\nTest"}).
+				e.On("EmbedBatch", mock.Anything, []string{"Document: test.pdf\n\nTest"}).
 					Return(nil, errors.New("embedding API error")).Once()
 			},
 			wantErr: true,
@@ -189,7 +309,7 @@ func TestHandleAnalyze(t *testing.T) {
 				s.On("GetDocument", mock.Anything, validDocID).
 					Return(store.Document{ID: validDocID, Filename: "test.pdf"}, nil).Once()
 
-				s.On("ListChunks", mock.Anything, validDocID).
+				s.On("ListChunks", mock.Anything, validDocID, 0).
 					Return([]store.Chunk{{ID: chunk1ID, Text: "Test", TokenCount: 1}}, nil).Once()
 
 				l.On("Summarize", mock.Anything, mock.Anything).
@@ -197,7 +317,7 @@ func TestHandleAnalyze(t *testing.T) {
 
 				s.On("SaveSummary", mock.Anything, validDocID, mock.Anything).Return(nil).Once()
 
-				e.On("EmbedBatch", []string{"Document: test.pdf\n\nTest"}).
+				e.On("EmbedBatch", mock.Anything, []string{"Document: test.pdf\n\nTest"}).
 					Return([]embeddings.Vector{{0.1}}, nil).Once()
 
 				// SaveEmbeddings fails
@@ -217,7 +337,7 @@ func TestHandleAnalyze(t *testing.T) {
 					Return(store.Document{ID: validDocID, Filename: "test.pdf"}, nil).Once()
 
 				// Return empty chunks
-				s.On("ListChunks", mock.Anything, validDocID).Return([]store.Chunk{}, nil).Once()
+				s.On("ListChunks", mock.Anything, validDocID, 0).Return([]store.Chunk{}, nil).Once()
 
 				// LLM should still be called with empty text
 				l.On("Summarize", mock.Anything, "").Return("No content", []string{}, nil).Once()
@@ -225,7 +345,7 @@ func TestHandleAnalyze(t *testing.T) {
 				s.On("SaveSummary", mock.Anything, validDocID, mock.Anything).Return(nil).Once()
 
 				// EmbedBatch called with empty texts array
-				e.On("EmbedBatch", []string{}).Return([]embeddings.Vector{}, nil).Once()
+				e.On("EmbedBatch", mock.Anything, []string{}).Return([]embeddings.Vector{}, nil).Once()
 
 				// SaveEmbeddings called with empty slice
 				s.On("SaveEmbeddings", mock.Anything, []store.Embedding{}).Return(nil).Once()
@@ -248,12 +368,14 @@ func TestHandleAnalyze(t *testing.T) {
 			if tt.setup != nil {
 				tt.setup(mockStore, mockLLM, mockEmbedder)
 			}
+			mockStore.On("RecordStage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+				Return(nil).Maybe()
 
 			// Create test dependencies
 			deps := newTestDeps(mockStore, mockLLM, mockEmbedder)
 
 			// Execute
-			err := handleAnalyze(context.Background(), deps, tt.payload)
+			err := handleAnalyze(context.Background(), deps, queue.Task{}, tt.payload)
 
 			// Check error expectation
 			if (err != nil) != tt.wantErr {
@@ -267,3 +389,88 @@ func TestHandleAnalyze(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleAnalyzeNotifiesReady(t *testing.T) {
+	docID := uuid.New()
+	doc := store.Document{ID: docID, Filename: "test.pdf", Status: store.StatusProcessing}
+	mockStore := new(store.MockStore)
+	mockLLM := new(llm.MockClient)
+	mockEmbedder := new(embeddings.MockEmbedder)
+	hooks := new(webhook.MockNotifier)
+
+	mockStore.On("GetDocument", mock.Anything, docID).Return(doc, nil).Once()
+	mockStore.On("ListChunks", mock.Anything, docID, 0).Return([]store.Chunk{{ID: uuid.New(), Text: "Test"}}, nil).Once()
+	mockStore.On("RecordStage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockStore.On("SaveSummary", mock.Anything, docID, mock.Anything).Return(nil).Once()
+	mockStore.On("SaveEmbeddings", mock.Anything, mock.Anything).Return(nil).Once()
+	mockStore.On("UpdateDocumentStatus", mock.Anything, docID, store.StatusReady).Return(nil).Once()
+	mockLLM.On("Summarize", mock.Anything, mock.Anything).Return("Summary", []string{"point"}, nil).Once()
+	mockEmbedder.On("EmbedBatch", mock.Anything, mock.Anything).Return([]embeddings.Vector{{0.1}}, nil).Once()
+	hooks.On("Notify", mock.Anything, webhook.EventDocumentReady, mock.MatchedBy(func(d store.Document) bool {
+		return d.ID == docID && d.Filename == "test.pdf" && d.Status == store.StatusReady
+	}), "").Once()
+
+	deps := newTestDeps(mockStore, mockLLM, mockEmbedder)
+	deps.Webhooks = hooks
+	if err := handleAnalyze(context.Background(), deps, queue.Task{}, analyzeTaskPayload{DocumentID: docID.String()}); err != nil {
+		t.Fatalf("handleAnalyze() error = %v", err)
+	}
+	hooks.AssertExpectations(t)
+	mockStore.AssertExpectations(t)
+}
+
+func TestHandleAnalyzeInvalidatesCacheOnPromote(t *testing.T) {
+	docID := uuid.New()
+	mockStore := new(store.MockStore)
+	mockLLM := new(llm.MockClient)
+	mockEmbedder := new(embeddings.MockEmbedder)
+	mockCache := new(cache.MockCache)
+
+	mockStore.On("GetDocument", mock.Anything, docID).Return(store.Document{ID: docID}, nil).Once()
+	mockStore.On("ListStagedChunks", mock.Anything, docID).Return([]store.Chunk{{ID: uuid.New(), Text: "New"}}, nil).Once()
+	mockStore.On("RecordStage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockStore.On("SaveEmbeddings", mock.Anything, mock.Anything).Return(nil).Once()
+	mockStore.On("PromoteStagedChunks", mock.Anything, docID).Return(nil).Once()
+	mockStore.On("UpdateDocumentStatus", mock.Anything, docID, store.StatusReady).Return(nil).Once()
+	mockEmbedder.On("EmbedBatch", mock.Anything, mock.Anything).Return([]embeddings.Vector{{0.1}}, nil).Once()
+	mockCache.On("InvalidateDocument", mock.Anything, docID.String()).Return(nil).Once()
+
+	deps := newTestDeps(mockStore, mockLLM, mockEmbedder)
+	deps.Cache = mockCache
+	payload := analyzeTaskPayload{DocumentID: docID.String(), Stages: []string{stageEmbed}, Staged: true}
+	if err := handleAnalyze(context.Background(), deps, queue.Task{}, payload); err != nil {
+		t.Fatalf("handleAnalyze() error = %v", err)
+	}
+	mockStore.AssertExpectations(t)
+	mockCache.AssertExpectations(t)
+}
+
+func TestHandleAnalyzePublishesNewVersion(t *testing.T) {
+	docID := uuid.New()
+	mockStore := new(store.MockStore)
+	mockLLM := new(llm.MockClient)
+	mockEmbedder := new(embeddings.MockEmbedder)
+	mockCache := new(cache.MockCache)
+
+	mockStore.On("GetDocument", mock.Anything, docID).Return(store.Document{ID: docID, Filename: "policy.txt", Version: 1}, nil).Once()
+	mockStore.On("ListChunks", mock.Anything, docID, 2).Return([]store.Chunk{{ID: uuid.New(), Version: 2, Text: "Revised"}}, nil).Once()
+	mockStore.On("RecordStage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockStore.On("SaveSummary", mock.Anything, docID, mock.MatchedBy(func(sum store.Summary) bool {
+		return sum.Version == 2 && sum.Summary == "Revised summary"
+	})).Return(nil).Once()
+	mockStore.On("SaveEmbeddings", mock.Anything, mock.Anything).Return(nil).Once()
+	mockStore.On("PublishVersion", mock.Anything, docID, 2).Return(nil).Once()
+	mockStore.On("UpdateDocumentStatus", mock.Anything, docID, store.StatusReady).Return(nil).Once()
+	mockLLM.On("Summarize", mock.Anything, mock.Anything).Return("Revised summary", []string{"point"}, nil).Once()
+	mockEmbedder.On("EmbedBatch", mock.Anything, []string{"Document: policy-v2.txt\n\nRevised"}).Return([]embeddings.Vector{{0.1}}, nil).Once()
+	mockCache.On("InvalidateDocument", mock.Anything, docID.String()).Return(nil).Once()
+
+	deps := newTestDeps(mockStore, mockLLM, mockEmbedder)
+	deps.Cache = mockCache
+	payload := analyzeTaskPayload{DocumentID: docID.String(), Version: 2, Filename: "policy-v2.txt"}
+	if err := handleAnalyze(context.Background(), deps, queue.Task{}, payload); err != nil {
+		t.Fatalf("handleAnalyze() error = %v", err)
+	}
+	mockStore.AssertExpectations(t)
+	mockCache.AssertExpectations(t)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"time"
+
+	"doc-agents/internal/auth"
+	"doc-agents/internal/config"
+	"doc-agents/internal/oidc"
+)
+
+// newTokenVerifier builds the OIDC verifier and the scopes granted per group
+// from cfg.
+func newTokenVerifier(cfg config.Config) (*oidc.Verifier, map[string][]auth.Scope, error) {
+	groupScopes, err := auth.ParseGroupScopes(cfg.OIDCGroupScopes)
+	if err != nil {
+		return nil, nil, err
+	}
+	verifier, err := oidc.NewVerifier(oidc.Options{
+		Issuer:      cfg.OIDCIssuer,
+		Audience:    cfg.OIDCAudience,
+		JWKSURL:     cfg.OIDCJWKSURL,
+		GroupsClaim: cfg.OIDCGroupsClaim,
+		TenantClaim: cfg.OIDCTenantClaim,
+		CacheTTL:    time.Duration(cfg.OIDCJWKSCacheTTL) * time.Second,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return verifier, groupScopes, nil
+}
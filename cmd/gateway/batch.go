@@ -0,0 +1,374 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+
+	"doc-agents/internal/app"
+	"doc-agents/internal/httputil"
+	"doc-agents/internal/openapi"
+	"doc-agents/internal/store"
+)
+
+// maxZipCompressionRatio rejects ZIP entries that inflate suspiciously well,
+// a cheap signal for zip bombs before any bytes are decompressed.
+const maxZipCompressionRatio = 100
+
+// batchEntry is one file taken from a batch request, either a multipart
+// part or a member of an uploaded ZIP archive.
+type batchEntry struct {
+	filename    string
+	contentType string
+	size        int64
+	// open returns the entry's content and its actual size.
+	open func() (io.ReadCloser, int64, error)
+}
+
+type batchDocument struct {
+	DocumentID  string               `json:"document_id"`
+	Filename    string               `json:"filename"`
+	Status      store.DocumentStatus `json:"status"`
+	DuplicateOf string               `json:"duplicate_of,omitempty"`
+}
+
+type rejectedEntry struct {
+	Filename string `json:"filename"`
+	Error    string `json:"error"`
+}
+
+// batchForm is the multipart body of POST /api/documents/batch.
+type batchForm struct {
+	Files    []openapi.File `json:"files" doc:"Documents or ZIP archives of documents; \"file\" is accepted too."`
+	Metadata string         `json:"metadata,omitempty" doc:"JSON object of string values applied to every document."`
+}
+
+type batchResponse struct {
+	BatchID   string          `json:"batch_id"`
+	Documents []batchDocument `json:"documents"`
+	Rejected  []rejectedEntry `json:"rejected"`
+}
+
+// batchRejectedResponse is returned with 400 when no file in a batch could
+// be ingested.
+type batchRejectedResponse struct {
+	httputil.ErrorResponse
+	Rejected []rejectedEntry `json:"rejected"`
+}
+
+type batchStatusResponse struct {
+	BatchID    string          `json:"batch_id"`
+	CreatedAt  string          `json:"created_at"`
+	Status     string          `json:"status" doc:"processing, completed or completed_with_errors"`
+	Total      int             `json:"total"`
+	Processing int             `json:"processing"`
+	Ready      int             `json:"ready"`
+	Failed     int             `json:"failed"`
+	Progress   float64         `json:"progress" doc:"Share of documents that are ready or failed, from 0 to 1."`
+	Documents  []batchDocument `json:"documents"`
+}
+
+// batchUploadHandler accepts several files (multipart field "files" or "file"),
+// expanding any ZIP archives, and ingests each entry as its own document. An
+// optional "metadata" field is applied to every document in the batch.
+func batchUploadHandler(deps app.GatewayDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		cfg := deps.Config
+
+		if r.ContentLength > cfg.MaxBatchSize {
+			httputil.Fail(deps.Log, w, r, fmt.Sprintf("batch too large (max %d bytes)", cfg.MaxBatchSize), nil, http.StatusBadRequest)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, cfg.MaxBatchSize)
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			httputil.Fail(deps.Log, w, r, "invalid multipart batch", err, http.StatusBadRequest)
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+
+		headers := append(r.MultipartForm.File["files"], r.MultipartForm.File["file"]...)
+		if len(headers) == 0 {
+			httputil.Fail(deps.Log, w, r, "at least one file is required", nil, http.StatusBadRequest)
+			return
+		}
+
+		entries, rejected, closeArchives, err := collectBatchEntries(headers, cfg.MaxUploadSize, cfg.MaxBatchSize)
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, err.Error(), err, http.StatusBadRequest)
+			return
+		}
+		defer closeArchives()
+		if len(entries) > cfg.MaxBatchEntries {
+			httputil.Fail(deps.Log, w, r, fmt.Sprintf("too many files in batch (max %d)", cfg.MaxBatchEntries), nil, http.StatusBadRequest)
+			return
+		}
+
+		metadata, err := parseMetadataField(r.FormValue("metadata"))
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, err.Error(), err, http.StatusBadRequest)
+			return
+		}
+
+		force := forceRequested(r)
+		var docs []batchDocument
+		var docIDs []uuid.UUID
+		seen := map[uuid.UUID]bool{}
+		for _, entry := range entries {
+			doc, duplicate, err := ingestBatchEntry(ctx, deps, entry, metadata, force)
+			if doc.ID != uuid.Nil {
+				// The same content may appear twice in one batch; track it once.
+				if !seen[doc.ID] {
+					seen[doc.ID] = true
+					docIDs = append(docIDs, doc.ID)
+				}
+				bd := batchDocument{DocumentID: doc.ID.String(), Filename: entry.filename, Status: doc.Status}
+				if duplicate {
+					bd.DuplicateOf = doc.ID.String()
+				}
+				docs = append(docs, bd)
+			}
+			if err != nil {
+				deps.Log.Warn("batch entry rejected", "filename", entry.filename, "err", err)
+				rejected = append(rejected, rejectedEntry{Filename: entry.filename, Error: batchEntryMessage(err)})
+			}
+		}
+
+		if len(docIDs) == 0 {
+			httputil.WriteJSON(w, http.StatusBadRequest, batchRejectedResponse{
+				ErrorResponse: httputil.ErrorResponse{
+					Code:      "no_valid_documents",
+					Message:   "no valid documents in batch",
+					RequestID: middleware.GetReqID(ctx),
+				},
+				Rejected: rejected,
+			})
+			return
+		}
+
+		batch, err := deps.Store.CreateBatch(ctx, docIDs)
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "failed to persist batch", err, http.StatusInternalServerError)
+			return
+		}
+
+		if rejected == nil {
+			rejected = []rejectedEntry{}
+		}
+		httputil.WriteJSON(w, http.StatusAccepted, batchResponse{
+			BatchID:   batch.ID.String(),
+			Documents: docs,
+			Rejected:  rejected,
+		})
+	}
+}
+
+// collectBatchEntries flattens the uploaded parts into entries, expanding ZIP
+// archives. Entries that are unsafe to read are reported as rejected; an error
+// is returned only when the batch as a whole must be refused. The returned
+// func closes the archives and must be called once the entries are ingested.
+func collectBatchEntries(headers []*multipart.FileHeader, maxEntrySize, maxTotalSize int64) ([]batchEntry, []rejectedEntry, func(), error) {
+	var entries []batchEntry
+	var rejected []rejectedEntry
+	var archives []io.Closer
+	var total int64
+
+	closeArchives := func() {
+		for _, a := range archives {
+			a.Close()
+		}
+	}
+
+	for _, header := range headers {
+		if !isZip(header) {
+			entries = append(entries, batchEntry{
+				filename:    header.Filename,
+				contentType: header.Header.Get("Content-Type"),
+				size:        header.Size,
+				open: func() (io.ReadCloser, int64, error) {
+					f, err := header.Open()
+					return f, header.Size, err
+				},
+			})
+			continue
+		}
+
+		f, err := header.Open()
+		if err != nil {
+			closeArchives()
+			return nil, nil, nil, fmt.Errorf("failed to open archive %s", header.Filename)
+		}
+		archives = append(archives, f)
+		zr, err := zip.NewReader(f, header.Size)
+		if err != nil {
+			closeArchives()
+			return nil, nil, nil, fmt.Errorf("invalid zip archive %s", header.Filename)
+		}
+
+		for _, zf := range zr.File {
+			if zf.FileInfo().IsDir() || isIgnoredZipEntry(zf.Name) {
+				continue
+			}
+			name, ok := safeZipEntryName(zf.Name)
+			if !ok {
+				rejected = append(rejected, rejectedEntry{Filename: zf.Name, Error: "invalid path in archive"})
+				continue
+			}
+			if zf.CompressedSize64 > 0 && zf.UncompressedSize64/zf.CompressedSize64 > maxZipCompressionRatio {
+				rejected = append(rejected, rejectedEntry{Filename: name, Error: "suspicious compression ratio"})
+				continue
+			}
+
+			total += int64(zf.UncompressedSize64)
+			if total > maxTotalSize {
+				closeArchives()
+				return nil, nil, nil, fmt.Errorf("archive contents too large (max %d bytes uncompressed)", maxTotalSize)
+			}
+
+			entries = append(entries, batchEntry{
+				filename: name,
+				size:     int64(zf.UncompressedSize64),
+				open: func() (io.ReadCloser, int64, error) {
+					// Declared sizes can lie; never decompress past the per-file limit.
+					rc, err := zf.Open()
+					if err != nil {
+						return nil, 0, err
+					}
+					defer rc.Close()
+					content, err := io.ReadAll(io.LimitReader(rc, maxEntrySize+1))
+					if err != nil {
+						return nil, 0, err
+					}
+					if int64(len(content)) > maxEntrySize {
+						return nil, 0, fmt.Errorf("file too large (max %d bytes)", maxEntrySize)
+					}
+					return io.NopCloser(bytes.NewReader(content)), int64(len(content)), nil
+				},
+			})
+		}
+	}
+
+	return entries, rejected, closeArchives, nil
+}
+
+// ingestBatchEntry validates one entry with the regular upload rules and ingests it.
+func ingestBatchEntry(ctx context.Context, deps app.GatewayDeps, entry batchEntry, metadata map[string]string, force bool) (store.Document, bool, error) {
+	contentType, _, err := validateFile(entry.filename, entry.contentType, entry.size, deps.Config.MaxUploadSize, deps.Extractors)
+	if err != nil {
+		return store.Document{}, false, err
+	}
+
+	rc, size, err := entry.open()
+	if err != nil {
+		return store.Document{}, false, err
+	}
+	defer rc.Close()
+
+	return ingestDocument(ctx, deps, store.Document{Filename: entry.filename, Metadata: metadata}, contentType, rc, size, force)
+}
+
+// batchEntryMessage returns the client-facing reason an entry was rejected.
+func batchEntryMessage(err error) string {
+	var ie *ingestError
+	if errors.As(err, &ie) {
+		return ie.message
+	}
+	return err.Error()
+}
+
+func isZip(header *multipart.FileHeader) bool {
+	switch header.Header.Get("Content-Type") {
+	case "application/zip", "application/x-zip-compressed":
+		return true
+	}
+	return strings.EqualFold(path.Ext(header.Filename), ".zip")
+}
+
+// isIgnoredZipEntry skips archive metadata that is never a real document.
+func isIgnoredZipEntry(name string) bool {
+	base := path.Base(name)
+	return strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(base, ".")
+}
+
+// safeZipEntryName rejects absolute paths and ".." segments and returns the
+// entry's base name, which is what the document is stored under.
+func safeZipEntryName(name string) (string, bool) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	if name == "" || strings.HasPrefix(name, "/") || (len(name) > 1 && name[1] == ':') {
+		return "", false
+	}
+	for _, segment := range strings.Split(name, "/") {
+		if segment == ".." {
+			return "", false
+		}
+	}
+	return path.Base(name), true
+}
+
+// batchStatusHandler reports aggregate progress for a batch.
+func batchStatusHandler(deps app.GatewayDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		batchID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "invalid batch id", err, http.StatusBadRequest)
+			return
+		}
+
+		batch, err := deps.Store.GetBatch(r.Context(), batchID)
+		if errors.Is(err, store.ErrBatchNotFound) {
+			httputil.Fail(deps.Log, w, r, "batch not found", err, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "failed to load batch", err, http.StatusInternalServerError)
+			return
+		}
+
+		counts := map[store.DocumentStatus]int{}
+		docs := make([]batchDocument, 0, len(batch.Documents))
+		for _, doc := range batch.Documents {
+			counts[doc.Status]++
+			docs = append(docs, batchDocument{DocumentID: doc.ID.String(), Filename: doc.Filename, Status: doc.Status})
+		}
+
+		total := len(batch.Documents)
+		done := counts[store.StatusReady] + counts[store.StatusFailed]
+		status := "processing"
+		switch {
+		case done < total:
+		case counts[store.StatusFailed] > 0:
+			status = "completed_with_errors"
+		default:
+			status = "completed"
+		}
+
+		var progress float64
+		if total > 0 {
+			progress = float64(done) / float64(total)
+		}
+
+		httputil.WriteJSON(w, http.StatusOK, batchStatusResponse{
+			BatchID:    batch.ID.String(),
+			CreatedAt:  batch.CreatedAt.Format(time.RFC3339),
+			Status:     status,
+			Total:      total,
+			Processing: counts[store.StatusProcessing],
+			Ready:      counts[store.StatusReady],
+			Failed:     counts[store.StatusFailed],
+			Progress:   progress,
+			Documents:  docs,
+		})
+	}
+}
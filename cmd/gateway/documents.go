@@ -0,0 +1,557 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"doc-agents/internal/app"
+	"doc-agents/internal/blobstore"
+	"doc-agents/internal/httputil"
+	"doc-agents/internal/store"
+	"doc-agents/internal/webhook"
+)
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+type documentView struct {
+	DocumentID string               `json:"document_id"`
+	Filename   string               `json:"filename"`
+	Status     store.DocumentStatus `json:"status"`
+	Version    int                  `json:"version" doc:"Live version, which summaries and queries default to."`
+	CreatedAt  string               `json:"created_at"`
+	SourceURL  string               `json:"source_url,omitempty"`
+	Metadata   map[string]string    `json:"metadata"`
+}
+
+func newDocumentView(doc store.Document) documentView {
+	metadata := doc.Metadata
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	return documentView{
+		DocumentID: doc.ID.String(),
+		Filename:   doc.Filename,
+		Status:     doc.Status,
+		Version:    doc.Version,
+		CreatedAt:  doc.CreatedAt.Format(time.RFC3339Nano),
+		SourceURL:  doc.SourceURL,
+		Metadata:   metadata,
+	}
+}
+
+// listDocumentsQuery holds the query parameters of the document listing;
+// metadata.<key> parameters, which have free-form names, come on top. The
+// limit bounds match defaultListLimit and maxListLimit.
+type listDocumentsQuery struct {
+	Status        string    `json:"status" validate:"omitempty,oneof=processing ready failed"`
+	Filename      string    `json:"filename" doc:"Substring of the filename."`
+	CreatedAfter  time.Time `json:"created_after"`
+	CreatedBefore time.Time `json:"created_before"`
+	Sort          string    `json:"sort" validate:"omitempty,oneof=created_at -created_at" doc:"Newest first (-created_at) unless set to created_at."`
+	Limit         int       `json:"limit" validate:"omitempty,min=1,max=100" doc:"Defaults to 20."`
+	Cursor        string    `json:"cursor" doc:"next_cursor of the previous page."`
+}
+
+type documentListResponse struct {
+	Documents []documentView `json:"documents"`
+	// NextCursor is null on the last page.
+	NextCursor *string `json:"next_cursor"`
+}
+
+// listDocumentsHandler serves GET /api/documents. Query parameters:
+// status, filename (substring), created_after, created_before (RFC 3339),
+// metadata.<key> (exact value), sort (created_at or -created_at), limit and
+// cursor. The response carries next_cursor while more documents remain.
+func listDocumentsHandler(deps app.GatewayDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseDocumentFilter(r)
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, err.Error(), err, http.StatusBadRequest)
+			return
+		}
+
+		docs, cursor, err := listDocuments(r.Context(), deps, filter)
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "failed to list documents", err, http.StatusInternalServerError)
+			return
+		}
+		var nextCursor *string
+		if cursor != "" {
+			nextCursor = &cursor
+		}
+
+		views := make([]documentView, 0, len(docs))
+		for _, doc := range docs {
+			views = append(views, newDocumentView(doc))
+		}
+		httputil.WriteJSON(w, http.StatusOK, documentListResponse{
+			Documents:  views,
+			NextCursor: nextCursor,
+		})
+	}
+}
+
+// listDocuments returns a page of documents matching filter, and the cursor
+// of the next page, empty on the last one.
+func listDocuments(ctx context.Context, deps app.GatewayDeps, filter store.DocumentFilter) ([]store.Document, string, error) {
+	// Fetch one extra row to learn whether another page exists.
+	limit := filter.Limit
+	filter.Limit++
+	docs, err := deps.Store.ListDocuments(ctx, filter)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(docs) <= limit {
+		return docs, "", nil
+	}
+	docs = docs[:limit]
+	last := docs[len(docs)-1]
+	return docs, encodeCursor(store.DocumentCursor{CreatedAt: last.CreatedAt, ID: last.ID}), nil
+}
+
+// listOptions are the listing parameters shared by the REST and gRPC APIs.
+type listOptions struct {
+	Status        string
+	Filename      string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Metadata      map[string]string // Exact values
+	Ascending     bool
+	Limit         int // defaultListLimit when 0
+	Cursor        string
+}
+
+// filter validates o and turns it into a store filter.
+func (o listOptions) filter() (store.DocumentFilter, error) {
+	filter := store.DocumentFilter{
+		Status:        store.DocumentStatus(o.Status),
+		Filename:      o.Filename,
+		CreatedAfter:  o.CreatedAfter,
+		CreatedBefore: o.CreatedBefore,
+		Ascending:     o.Ascending,
+		Limit:         defaultListLimit,
+	}
+
+	switch filter.Status {
+	case "", store.StatusProcessing, store.StatusReady, store.StatusFailed:
+	default:
+		return filter, fmt.Errorf("invalid status %q (allowed: processing, ready, failed)", filter.Status)
+	}
+
+	if o.Limit != 0 {
+		if o.Limit < 1 || o.Limit > maxListLimit {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxListLimit)
+		}
+		filter.Limit = o.Limit
+	}
+
+	if o.Cursor != "" {
+		cursor, err := decodeCursor(o.Cursor)
+		if err != nil {
+			return filter, errors.New("invalid cursor")
+		}
+		filter.After = &cursor
+	}
+
+	for key, value := range o.Metadata {
+		filter.Metadata = append(filter.Metadata, store.MetadataCondition{
+			Key: key, Op: store.MetadataEq, Values: []string{value},
+		})
+	}
+	// Map iteration order is random; keep the generated query stable
+	slices.SortFunc(filter.Metadata, func(a, b store.MetadataCondition) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	if err := filter.Metadata.Validate(); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+// parseDocumentFilter reads and validates the listing query parameters.
+func parseDocumentFilter(r *http.Request) (store.DocumentFilter, error) {
+	q := r.URL.Query()
+	opts := listOptions{
+		Status:   q.Get("status"),
+		Filename: q.Get("filename"),
+		Cursor:   q.Get("cursor"),
+	}
+
+	var err error
+	if opts.CreatedAfter, err = parseTimeParam(q.Get("created_after"), "created_after"); err != nil {
+		return store.DocumentFilter{}, err
+	}
+	if opts.CreatedBefore, err = parseTimeParam(q.Get("created_before"), "created_before"); err != nil {
+		return store.DocumentFilter{}, err
+	}
+
+	switch q.Get("sort") {
+	case "", "-created_at":
+	case "created_at":
+		opts.Ascending = true
+	default:
+		return store.DocumentFilter{}, errors.New("invalid sort (allowed: created_at, -created_at)")
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			return store.DocumentFilter{}, fmt.Errorf("limit must be between 1 and %d", maxListLimit)
+		}
+		opts.Limit = limit
+	}
+
+	for param := range q {
+		if key, ok := strings.CutPrefix(param, metadataParamPrefix); ok {
+			if opts.Metadata == nil {
+				opts.Metadata = map[string]string{}
+			}
+			opts.Metadata[key] = q.Get(param)
+		}
+	}
+
+	return opts.filter()
+}
+
+// metadataParamPrefix marks listing query parameters that filter on metadata.
+const metadataParamPrefix = "metadata."
+
+// parseMetadataField decodes the JSON object of string values sent alongside
+// an upload. An empty field means no metadata.
+func parseMetadataField(raw string) (map[string]string, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var metadata map[string]string
+	if err := json.Unmarshal([]byte(raw), &metadata); err != nil {
+		return nil, errors.New("metadata must be a JSON object of string values")
+	}
+	if err := store.ValidateMetadata(metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// updateDocumentRequest is a JSON merge patch of a document's metadata: a
+// string sets the key and null removes it. Keys not mentioned are kept.
+type updateDocumentRequest struct {
+	Metadata map[string]*string `json:"metadata" validate:"required"`
+}
+
+// updateDocumentHandler serves PATCH /api/documents/{id}.
+func updateDocumentHandler(deps app.GatewayDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		docID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "invalid document id", err, http.StatusBadRequest)
+			return
+		}
+
+		var req updateDocumentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			httputil.Fail(deps.Log, w, r, "invalid payload", err, http.StatusBadRequest)
+			return
+		}
+		if err := httputil.Validator.Struct(&req); err != nil {
+			httputil.ValidationError(deps.Log, w, r, err)
+			return
+		}
+		set := map[string]string{}
+		var remove []string
+		for key, value := range req.Metadata {
+			if value == nil {
+				remove = append(remove, key)
+				continue
+			}
+			set[key] = *value
+		}
+		if err := store.ValidateMetadata(set); err != nil {
+			httputil.Fail(deps.Log, w, r, err.Error(), err, http.StatusBadRequest)
+			return
+		}
+
+		doc, err := deps.Store.GetDocument(ctx, docID)
+		if errors.Is(err, store.ErrDocumentNotFound) {
+			httputil.Fail(deps.Log, w, r, "document not found", err, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "failed to load document", err, http.StatusInternalServerError)
+			return
+		}
+		if n := mergedMetadataSize(doc.Metadata, set, remove); n > store.MaxMetadataKeys {
+			err := fmt.Errorf("too many metadata keys (max %d)", store.MaxMetadataKeys)
+			httputil.Fail(deps.Log, w, r, err.Error(), err, http.StatusBadRequest)
+			return
+		}
+
+		doc, err = deps.Store.UpdateDocumentMetadata(ctx, docID, set, remove)
+		if errors.Is(err, store.ErrDocumentNotFound) {
+			httputil.Fail(deps.Log, w, r, "document not found", err, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "failed to update document", err, http.StatusInternalServerError)
+			return
+		}
+		httputil.WriteJSON(w, http.StatusOK, newDocumentView(doc))
+	}
+}
+
+// mergedMetadataSize counts the keys left after applying set and remove to current.
+func mergedMetadataSize(current, set map[string]string, remove []string) int {
+	keys := maps.Clone(current)
+	if keys == nil {
+		keys = map[string]string{}
+	}
+	maps.Copy(keys, set)
+	for _, key := range remove {
+		delete(keys, key)
+	}
+	return len(keys)
+}
+
+func parseTimeParam(value, name string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+	}
+	return t, nil
+}
+
+// cursorToken is the JSON form of an opaque pagination cursor.
+type cursorToken struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+}
+
+func encodeCursor(c store.DocumentCursor) string {
+	raw, _ := json.Marshal(cursorToken{CreatedAt: c.CreatedAt, ID: c.ID})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(s string) (store.DocumentCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return store.DocumentCursor{}, err
+	}
+	var tok cursorToken
+	if err := json.Unmarshal(raw, &tok); err != nil {
+		return store.DocumentCursor{}, err
+	}
+	if tok.ID == uuid.Nil || tok.CreatedAt.IsZero() {
+		return store.DocumentCursor{}, errors.New("incomplete cursor")
+	}
+	return store.DocumentCursor{CreatedAt: tok.CreatedAt, ID: tok.ID}, nil
+}
+
+// deleteDocumentHandler purges a document: its rows (chunks, summary and
+// embeddings cascade), the original file and any cached answers. Parse and
+// analyze tasks still in flight find the document gone and drop themselves.
+func deleteDocumentHandler(deps app.GatewayDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		docID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "invalid document id", err, http.StatusBadRequest)
+			return
+		}
+		log := deps.Log.With("document_id", docID)
+
+		err = deleteDocument(ctx, deps, docID)
+		switch {
+		case errors.Is(err, store.ErrDocumentNotFound):
+			httputil.Fail(log, w, r, "document not found", err, http.StatusNotFound)
+		case errors.Is(err, errOriginalNotRemoved):
+			httputil.Fail(log, w, r, "original file could not be removed; the document was kept, retry the delete", err, http.StatusInternalServerError)
+		case err != nil:
+			httputil.Fail(log, w, r, "failed to delete document", err, http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+// errOriginalNotRemoved means an original file of the document could not be
+// removed. The document is kept so that deleting again finishes the purge.
+var errOriginalNotRemoved = errors.New("original file not removed")
+
+// deleteDocument purges the document with docID: its rows, the original file
+// of every version and any cached answers citing it.
+func deleteDocument(ctx context.Context, deps app.GatewayDeps, docID uuid.UUID) error {
+	log := deps.Log.With("document_id", docID)
+
+	// Listing the versions also checks the document belongs to the tenant,
+	// since blob keys are not tenant-scoped
+	versions, err := deps.Store.ListVersions(ctx, docID)
+	if err != nil {
+		return err
+	}
+	// The files go before the rows: while the rows remain, a failed purge
+	// can be retried, whereas a file without its rows could never be found again
+	for _, v := range versions {
+		if err := deps.Blobs.Delete(ctx, blobstore.VersionKey(docID, v.Number)); err != nil {
+			return fmt.Errorf("%w: version %d: %v", errOriginalNotRemoved, v.Number, err)
+		}
+	}
+	if err := deps.Store.DeleteDocument(ctx, docID); err != nil {
+		return err
+	}
+
+	if err := deps.Cache.InvalidateDocument(ctx, docID.String()); err != nil {
+		// Cached answers expire with CACHE_TTL; the document itself is gone.
+		log.Error("failed to invalidate cached queries", "err", err)
+	}
+	deps.Webhooks.Notify(ctx, webhook.EventDocumentDeleted, store.Document{ID: docID}, "")
+
+	log.Info("document deleted")
+	return nil
+}
+
+type stageView struct {
+	Stage       store.Stage      `json:"stage"`
+	State       store.StageState `json:"state"`
+	Attempts    int              `json:"attempts"`
+	LastError   string           `json:"last_error,omitempty"`
+	StartedAt   string           `json:"started_at,omitempty"`
+	CompletedAt string           `json:"completed_at,omitempty"`
+	UpdatedAt   string           `json:"updated_at,omitempty"`
+}
+
+type documentStatusResponse struct {
+	DocumentID string               `json:"document_id"`
+	Filename   string               `json:"filename"`
+	Status     store.DocumentStatus `json:"status"`
+	CreatedAt  string               `json:"created_at"`
+	// CurrentStage is the first unfinished stage while the document is not ready.
+	CurrentStage store.Stage `json:"current_stage,omitempty"`
+	// Error is only present when the document failed.
+	Error  *string     `json:"error,omitempty"`
+	Stages []stageView `json:"stages"`
+}
+
+// documentStatusHandler reports a document's overall status plus the state of
+// each pipeline stage, so a stuck or failed document shows where and why.
+func documentStatusHandler(deps app.GatewayDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		docID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "invalid document id", err, http.StatusBadRequest)
+			return
+		}
+
+		progress, err := loadDocumentProgress(ctx, deps, docID)
+		if errors.Is(err, store.ErrDocumentNotFound) {
+			httputil.Fail(deps.Log, w, r, "document not found", err, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "failed to load document status", err, http.StatusInternalServerError)
+			return
+		}
+
+		doc := progress.Document
+		body := documentStatusResponse{
+			DocumentID:   doc.ID.String(),
+			Filename:     doc.Filename,
+			Status:       doc.Status,
+			CreatedAt:    doc.CreatedAt.Format(time.RFC3339Nano),
+			CurrentStage: progress.CurrentStage,
+			Stages:       make([]stageView, 0, len(progress.Stages)),
+		}
+		if progress.Failed {
+			body.Error = &progress.Error
+		}
+		for _, st := range progress.Stages {
+			body.Stages = append(body.Stages, newStageView(st))
+		}
+
+		httputil.WriteJSON(w, http.StatusOK, body)
+	}
+}
+
+// documentProgress is a document with the state of every pipeline stage.
+type documentProgress struct {
+	Document store.Document
+	// Stages has one entry per store.Stages; stages not started yet are pending
+	Stages []store.StageStatus
+	// CurrentStage is the first unfinished stage while the document is not ready
+	CurrentStage store.Stage
+	// Failed is set with the Error of the failed stage when the document failed
+	Failed bool
+	Error  string
+}
+
+// loadDocumentProgress reports where the document with docID is in the pipeline.
+func loadDocumentProgress(ctx context.Context, deps app.GatewayDeps, docID uuid.UUID) (documentProgress, error) {
+	doc, err := deps.Store.GetDocument(ctx, docID)
+	if err != nil {
+		return documentProgress{}, err
+	}
+	recorded, err := deps.Store.ListStages(ctx, docID)
+	if err != nil {
+		return documentProgress{}, fmt.Errorf("failed to load document stages: %w", err)
+	}
+
+	byStage := make(map[store.Stage]store.StageStatus, len(recorded))
+	for _, st := range recorded {
+		byStage[st.Stage] = st
+	}
+
+	progress := documentProgress{Document: doc, Stages: make([]store.StageStatus, 0, len(store.Stages))}
+	for _, stage := range store.Stages {
+		st, ok := byStage[stage]
+		if !ok {
+			st = store.StageStatus{Stage: stage, State: store.StagePending}
+		}
+		progress.Stages = append(progress.Stages, st)
+		if progress.CurrentStage == "" && st.State != store.StageDone && doc.Status != store.StatusReady {
+			progress.CurrentStage = stage
+		}
+		if st.State == store.StageFailed && doc.Status == store.StatusFailed {
+			progress.Failed = true
+			progress.Error = st.LastError
+		}
+	}
+	return progress, nil
+}
+
+func newStageView(st store.StageStatus) stageView {
+	return stageView{
+		Stage:       st.Stage,
+		State:       st.State,
+		Attempts:    st.Attempts,
+		LastError:   st.LastError,
+		StartedAt:   formatOptionalTime(st.StartedAt),
+		CompletedAt: formatOptionalTime(st.CompletedAt),
+		UpdatedAt:   formatOptionalTime(st.UpdatedAt),
+	}
+}
+
+func formatOptionalTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
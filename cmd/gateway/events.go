@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"doc-agents/internal/app"
+	"doc-agents/internal/events"
+	"doc-agents/internal/httputil"
+	"doc-agents/internal/progress"
+	"doc-agents/internal/store"
+)
+
+// sseHeartbeat keeps idle streams alive through proxies and load balancers.
+const sseHeartbeat = 15 * time.Second
+
+// documentEventsHandler streams a document's processing events as
+// Server-Sent Events. The stream opens with the document's current state, so
+// a client that connects late or reconnects knows where things stand, and it
+// ends after the ready or failed event.
+func documentEventsHandler(deps app.GatewayDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		docID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "invalid document id", err, http.StatusBadRequest)
+			return
+		}
+		log := deps.Log.With("document_id", docID)
+
+		// Subscribe before reading the current state so no transition falls in between
+		sub, cancel := deps.Events.Subscribe(docID)
+		defer cancel()
+
+		doc, err := deps.Store.GetDocument(ctx, docID)
+		if errors.Is(err, store.ErrDocumentNotFound) {
+			httputil.Fail(log, w, r, "document not found", err, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			httputil.Fail(log, w, r, "failed to load document", err, http.StatusInternalServerError)
+			return
+		}
+		current, err := currentEvent(ctx, deps, doc)
+		if err != nil {
+			httputil.Fail(log, w, r, "failed to load document stages", err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		rc := http.NewResponseController(w)
+		send := func(ev events.Event) bool {
+			if err := writeEvent(w, ev); err != nil {
+				return false
+			}
+			if err := rc.Flush(); err != nil {
+				log.Warn("event stream flush failed", "err", err)
+				return false
+			}
+			return !ev.Type.Terminal()
+		}
+
+		if !send(current) {
+			return
+		}
+		heartbeat := time.NewTicker(sseHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-httputil.Draining(ctx):
+				// The gateway is shutting down; EventSource clients reconnect
+				// to another replica and start again from the current state
+				return
+			case <-heartbeat.C:
+				if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil {
+					return
+				}
+				if err := rc.Flush(); err != nil {
+					return
+				}
+			case ev, ok := <-sub:
+				if !ok || !send(ev) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// currentEvent describes where doc is now: ready, failed with the failing
+// stage's error, the stage currently running, or queued between stages.
+func currentEvent(ctx context.Context, deps app.GatewayDeps, doc store.Document) (events.Event, error) {
+	ev := events.Event{DocumentID: doc.ID, Type: events.TypeQueued, Time: time.Now().UTC()}
+	if doc.Status == store.StatusReady {
+		ev.Type = events.TypeReady
+		return ev, nil
+	}
+
+	stages, err := deps.Store.ListStages(ctx, doc.ID)
+	if err != nil {
+		return ev, err
+	}
+	if doc.Status == store.StatusFailed {
+		ev.Type = events.TypeFailed
+		for _, st := range stages {
+			if st.State == store.StageFailed {
+				ev.Error = st.LastError
+			}
+		}
+		return ev, nil
+	}
+	for _, st := range stages {
+		if st.State == store.StageRunning {
+			ev.Type = progress.StageEvent(st.Stage)
+			ev.Attempt = st.Attempts
+		}
+	}
+	return ev, nil
+}
+
+// writeEvent writes ev in SSE framing, named by its type.
+func writeEvent(w io.Writer, ev events.Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data)
+	return err
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"doc-agents/internal/app"
+	"doc-agents/internal/extractor"
+	"doc-agents/internal/httputil"
+	"doc-agents/internal/store"
+	"doc-agents/internal/urlfetch"
+)
+
+type fromURLRequest struct {
+	URL      string            `json:"url" validate:"required,url,max=2048"`
+	Filename string            `json:"filename" validate:"omitempty,max=255"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+type fromURLResponse struct {
+	ingestedResponse
+	Filename string `json:"filename"`
+	// SourceURL is the URL the document was downloaded from, after redirects.
+	SourceURL string `json:"source_url"`
+}
+
+// fromURLHandler downloads a document from a remote URL and ingests it like
+// a regular upload. The fetch itself is guarded against SSRF by urlfetch.
+func fromURLHandler(deps app.GatewayDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		var req fromURLRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			httputil.Fail(deps.Log, w, r, "invalid payload", err, http.StatusBadRequest)
+			return
+		}
+		if err := httputil.Validator.Struct(&req); err != nil {
+			httputil.ValidationError(deps.Log, w, r, err)
+			return
+		}
+		if err := store.ValidateMetadata(req.Metadata); err != nil {
+			httputil.Fail(deps.Log, w, r, err.Error(), err, http.StatusBadRequest)
+			return
+		}
+
+		res, err := deps.Fetcher.Fetch(ctx, req.URL)
+		if err != nil {
+			message, status := fetchErrorResponse(err, deps.Config.MaxUploadSize)
+			httputil.Fail(deps.Log.With("url", req.URL), w, r, message, err, status)
+			return
+		}
+
+		filename := req.Filename
+		if filename == "" {
+			filename = res.Filename
+		}
+		contentType, statusCode, err := validateFile(filename, detectFetchedType(res, filename, deps.Extractors), int64(len(res.Body)), deps.Config.MaxUploadSize, deps.Extractors)
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, err.Error(), err, statusCode)
+			return
+		}
+
+		newDoc := store.Document{Filename: filename, SourceURL: res.FinalURL, Metadata: req.Metadata}
+		doc, duplicate, err := ingestDocument(ctx, deps, newDoc, contentType, bytes.NewReader(res.Body), int64(len(res.Body)), forceRequested(r))
+		if err != nil {
+			failIngest(deps, w, r, err, doc.ID)
+			return
+		}
+
+		status, body := ingestResponse(doc, duplicate)
+		httputil.WriteJSON(w, status, fromURLResponse{
+			ingestedResponse: body,
+			Filename:         doc.Filename,
+			SourceURL:        doc.SourceURL,
+		})
+	}
+}
+
+// detectFetchedType picks the content type for a downloaded body. Servers
+// often label everything text/plain or application/octet-stream, so a
+// specific server type wins, then the filename extension, then magic bytes.
+func detectFetchedType(res *urlfetch.Result, filename string, extractors *extractor.Registry) string {
+	if resolved, ok := extractors.Resolve(res.ContentType); ok && resolved != extractor.TypePlainText {
+		return resolved
+	}
+	if detected, ok := extractors.DetectType(filename); ok {
+		return detected
+	}
+	if sniffed, ok := extractors.Sniff(res.Body); ok {
+		return sniffed
+	}
+	return res.ContentType
+}
+
+// fetchErrorResponse maps a fetch failure to a client-facing message and status.
+func fetchErrorResponse(err error, maxSize int64) (string, int) {
+	switch {
+	case errors.Is(err, urlfetch.ErrBlockedDestination):
+		return "url destination is not allowed", http.StatusBadRequest
+	case errors.Is(err, urlfetch.ErrTooLarge):
+		return fmt.Sprintf("file too large (max %d bytes)", maxSize), http.StatusBadRequest
+	case errors.Is(err, urlfetch.ErrTooManyRedirects):
+		return "too many redirects", http.StatusBadRequest
+	default:
+		return "failed to fetch url", http.StatusBadGateway
+	}
+}
@@ -0,0 +1,528 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"doc-agents/internal/app"
+	"doc-agents/internal/auth"
+	"doc-agents/internal/httputil"
+	"doc-agents/internal/oidc"
+	"doc-agents/internal/queryapi"
+	"doc-agents/internal/store"
+	"doc-agents/internal/tenant"
+	"doc-agents/internal/tracing"
+	docagentsv1 "doc-agents/proto/docagents/v1"
+)
+
+var grpcTracer = otel.Tracer("doc-agents/cmd/gateway")
+
+// grpcScopes is the scope each gRPC method requires, matching its REST route.
+var grpcScopes = map[string]auth.Scope{
+	docagentsv1.DocumentService_Upload_FullMethodName:     auth.ScopeUpload,
+	docagentsv1.DocumentService_GetStatus_FullMethodName:  auth.ScopeRead,
+	docagentsv1.DocumentService_GetSummary_FullMethodName: auth.ScopeRead,
+	docagentsv1.DocumentService_List_FullMethodName:       auth.ScopeRead,
+	docagentsv1.DocumentService_Delete_FullMethodName:     auth.ScopeUpload,
+	docagentsv1.QueryService_Query_FullMethodName:         auth.ScopeQuery,
+}
+
+// newGRPCServer serves the gRPC API. Calls are authenticated, rate limited,
+// scoped to a tenant and bounded by RequestTimeout like their REST routes,
+// and share the REST handlers' logic.
+func newGRPCServer(deps app.GatewayDeps, authn *auth.Authenticator) *grpc.Server {
+	g := &grpcCalls{deps: deps, authn: authn}
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(g.unary),
+		grpc.ChainStreamInterceptor(g.stream),
+	)
+	docagentsv1.RegisterDocumentServiceServer(srv, &documentServer{deps: deps})
+	docagentsv1.RegisterQueryServiceServer(srv, &queryServer{deps: deps})
+	return srv
+}
+
+// serveGRPC serves srv on lis until ctx is done, then lets the calls in
+// progress finish for up to timeout before closing their connections.
+func serveGRPC(ctx context.Context, deps app.GatewayDeps, srv *grpc.Server, lis net.Listener, timeout time.Duration) error {
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(lis) }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	deps.Log.Info("gRPC server shutting down", "timeout", timeout)
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(timeout):
+		deps.Log.Warn("gRPC calls still running after shutdown timeout; closing their connections")
+		srv.Stop()
+	}
+	return <-errc
+}
+
+// grpcCalls prepares the context of every gRPC call.
+type grpcCalls struct {
+	deps  app.GatewayDeps
+	authn *auth.Authenticator
+}
+
+func (g *grpcCalls) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	ctx, span := traceCall(ctx, info.FullMethod)
+	ctx, cancel, err := g.begin(ctx, info.FullMethod)
+	if err == nil {
+		defer cancel()
+		var resp any
+		resp, err = handler(ctx, req)
+		g.log(ctx, info.FullMethod, start, err)
+		tracing.End(span, err)
+		return resp, err
+	}
+	g.log(ctx, info.FullMethod, start, err)
+	tracing.End(span, err)
+	return nil, err
+}
+
+func (g *grpcCalls) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	ctx, span := traceCall(ss.Context(), info.FullMethod)
+	ctx, cancel, err := g.begin(ctx, info.FullMethod)
+	if err == nil {
+		defer cancel()
+		err = handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+	g.log(ctx, info.FullMethod, start, err)
+	tracing.End(span, err)
+	return err
+}
+
+// traceCall starts the server span of a gRPC call, continuing the caller's
+// trace when the call's metadata carries one.
+func traceCall(ctx context.Context, method string) (context.Context, trace.Span) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	return grpcTracer.Start(ctx, strings.TrimPrefix(method, "/"),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.RPCSystemGRPC, attribute.String("rpc.method", method)))
+}
+
+// metadataCarrier reads trace context from gRPC metadata.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if v := metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// begin assigns the call a request ID, authenticates it, resolves its tenant
+// and checks the method's scope.
+func (g *grpcCalls) begin(ctx context.Context, method string) (context.Context, context.CancelFunc, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	first := func(key string) string {
+		if v := md.Get(key); len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+
+	reqID := first(strings.ToLower(middleware.RequestIDHeader))
+	if reqID == "" {
+		reqID = uuid.NewString()
+	}
+	ctx = context.WithValue(ctx, middleware.RequestIDKey, reqID)
+
+	requested := first(strings.ToLower(tenant.Header))
+	authed, err := g.authn.Authenticate(ctx, auth.Key(first("authorization"), first(strings.ToLower(auth.KeyHeader))), requested)
+	if err != nil {
+		return ctx, nil, authStatus(err)
+	}
+	ctx, err = tenant.Resolve(authed, requested, g.deps.Config.DefaultTenant)
+	if errors.Is(err, tenant.ErrMissing) {
+		return authed, nil, status.Error(codes.Unauthenticated, "tenant required")
+	}
+	if err != nil {
+		return authed, nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var scopeErr *auth.ScopeError
+	switch err := g.authn.Authorize(ctx, grpcScopes[method]); {
+	case errors.As(err, &scopeErr):
+		return ctx, nil, status.Error(codes.PermissionDenied, err.Error())
+	case err != nil:
+		return ctx, nil, status.Error(codes.Unauthenticated, "authentication required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, httputil.RequestTimeout)
+	return ctx, cancel, nil
+}
+
+// authStatus maps an auth.Authenticate error to the gRPC status a REST
+// client would get as an HTTP status.
+func authStatus(err error) error {
+	var limited *auth.RateLimitError
+	switch {
+	case errors.Is(err, auth.ErrNoCredentials):
+		return status.Error(codes.Unauthenticated, "authentication required")
+	case errors.Is(err, store.ErrAPIKeyNotFound):
+		return status.Error(codes.Unauthenticated, "invalid api key")
+	case errors.Is(err, oidc.ErrInvalidToken):
+		return status.Error(codes.Unauthenticated, "invalid token")
+	case errors.Is(err, auth.ErrNoTenant), errors.Is(err, auth.ErrTenantMismatch):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.As(err, &limited):
+		return status.Errorf(codes.ResourceExhausted, "rate limit exceeded; retry after %s", limited.Wait.Round(time.Second))
+	default:
+		return status.Error(codes.Unavailable, "failed to authenticate")
+	}
+}
+
+func (g *grpcCalls) log(ctx context.Context, method string, start time.Time, err error) {
+	g.deps.Log.Info("grpc call",
+		"method", method,
+		"code", status.Code(err).String(),
+		"duration_ms", time.Since(start).Milliseconds(),
+		"request_id", middleware.GetReqID(ctx),
+	)
+}
+
+// contextStream is a server stream whose handler sees ctx.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// grpcFail logs err and returns it as a status with code and message.
+func grpcFail(deps app.GatewayDeps, code codes.Code, message string, err error) error {
+	deps.Log.Error(message, "err", err)
+	return status.Error(code, message)
+}
+
+// parseDocumentID parses a document ID from a request message.
+func parseDocumentID(raw string) (uuid.UUID, error) {
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return uuid.Nil, status.Error(codes.InvalidArgument, "invalid document id")
+	}
+	return id, nil
+}
+
+// timestamp converts t, leaving unset times unset.
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// documentServer implements DocumentService.
+type documentServer struct {
+	docagentsv1.UnimplementedDocumentServiceServer
+	deps app.GatewayDeps
+}
+
+// Upload spools the streamed file to disk, subject to MAX_UPLOAD_SIZE, and
+// ingests it like a multipart upload.
+func (s *documentServer) Upload(stream docagentsv1.DocumentService_UploadServer) error {
+	ctx := stream.Context()
+	first, err := stream.Recv()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	info := first.GetInfo()
+	if info == nil || info.GetFilename() == "" {
+		return status.Error(codes.InvalidArgument, "the first message must carry the upload info with a filename")
+	}
+	if err := store.ValidateMetadata(info.GetMetadata()); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	file, err := os.CreateTemp("", "grpc-upload-*")
+	if err != nil {
+		return grpcFail(s.deps, codes.Internal, "failed to receive file", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	maxSize := s.deps.Config.MaxUploadSize
+	var size int64
+	for {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		chunk := msg.GetChunk()
+		if chunk == nil {
+			return status.Error(codes.InvalidArgument, "only the first message may carry upload info")
+		}
+		if size += int64(len(chunk)); size > maxSize {
+			return status.Errorf(codes.InvalidArgument, "file too large (max %d bytes)", maxSize)
+		}
+		if _, err := file.Write(chunk); err != nil {
+			return grpcFail(s.deps, codes.Internal, "failed to receive file", err)
+		}
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return grpcFail(s.deps, codes.Internal, "failed to receive file", err)
+	}
+
+	contentType, _, err := validateFile(info.GetFilename(), info.GetContentType(), size, maxSize, s.deps.Extractors)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var metadata map[string]string
+	if len(info.GetMetadata()) > 0 {
+		metadata = info.GetMetadata()
+	}
+	newDoc := store.Document{Filename: info.GetFilename(), Metadata: metadata}
+	doc, duplicate, err := ingestDocument(ctx, s.deps, newDoc, contentType, file, size, info.GetForce())
+	if err != nil {
+		message := "failed to ingest document"
+		var ie *ingestError
+		if errors.As(err, &ie) {
+			message = ie.message
+		}
+		return grpcFail(s.deps, codes.Internal, message, err)
+	}
+
+	resp := &docagentsv1.UploadResponse{DocumentId: doc.ID.String(), Status: string(doc.Status)}
+	if duplicate {
+		resp.DuplicateOf = doc.ID.String()
+	}
+	return stream.SendAndClose(resp)
+}
+
+func (s *documentServer) GetStatus(ctx context.Context, req *docagentsv1.GetStatusRequest) (*docagentsv1.DocumentStatus, error) {
+	docID, err := parseDocumentID(req.GetDocumentId())
+	if err != nil {
+		return nil, err
+	}
+	progress, err := loadDocumentProgress(ctx, s.deps, docID)
+	if errors.Is(err, store.ErrDocumentNotFound) {
+		return nil, status.Error(codes.NotFound, "document not found")
+	}
+	if err != nil {
+		return nil, grpcFail(s.deps, codes.Internal, "failed to load document status", err)
+	}
+
+	doc := progress.Document
+	resp := &docagentsv1.DocumentStatus{
+		DocumentId:   doc.ID.String(),
+		Filename:     doc.Filename,
+		Status:       string(doc.Status),
+		CreatedAt:    timestamp(doc.CreatedAt),
+		CurrentStage: string(progress.CurrentStage),
+		Error:        progress.Error,
+	}
+	for _, st := range progress.Stages {
+		resp.Stages = append(resp.Stages, &docagentsv1.Stage{
+			Stage:       string(st.Stage),
+			State:       string(st.State),
+			Attempts:    int32(st.Attempts),
+			LastError:   st.LastError,
+			StartedAt:   timestamp(st.StartedAt),
+			CompletedAt: timestamp(st.CompletedAt),
+			UpdatedAt:   timestamp(st.UpdatedAt),
+		})
+	}
+	return resp, nil
+}
+
+func (s *documentServer) GetSummary(ctx context.Context, req *docagentsv1.GetSummaryRequest) (*docagentsv1.Summary, error) {
+	docID, err := parseDocumentID(req.GetDocumentId())
+	if err != nil {
+		return nil, err
+	}
+	sum, err := s.deps.Store.GetSummary(ctx, docID, 0)
+	if errors.Is(err, store.ErrDocumentNotFound) {
+		return nil, status.Error(codes.NotFound, "document not found")
+	}
+	if errors.Is(err, store.ErrSummaryNotFound) {
+		return nil, status.Error(codes.NotFound, "summary not ready")
+	}
+	if err != nil {
+		return nil, grpcFail(s.deps, codes.Internal, "failed to load summary", err)
+	}
+	return &docagentsv1.Summary{Summary: sum.Summary, KeyPoints: sum.KeyPoints}, nil
+}
+
+func (s *documentServer) List(ctx context.Context, req *docagentsv1.ListRequest) (*docagentsv1.ListResponse, error) {
+	opts := listOptions{
+		Status:    req.GetStatus(),
+		Filename:  req.GetFilename(),
+		Metadata:  req.GetMetadata(),
+		Ascending: req.GetAscending(),
+		Limit:     int(req.GetLimit()),
+		Cursor:    req.GetCursor(),
+	}
+	if req.GetCreatedAfter() != nil {
+		opts.CreatedAfter = req.GetCreatedAfter().AsTime()
+	}
+	if req.GetCreatedBefore() != nil {
+		opts.CreatedBefore = req.GetCreatedBefore().AsTime()
+	}
+	filter, err := opts.filter()
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	docs, cursor, err := listDocuments(ctx, s.deps, filter)
+	if err != nil {
+		return nil, grpcFail(s.deps, codes.Internal, "failed to list documents", err)
+	}
+	resp := &docagentsv1.ListResponse{NextCursor: cursor}
+	for _, doc := range docs {
+		resp.Documents = append(resp.Documents, &docagentsv1.Document{
+			DocumentId: doc.ID.String(),
+			Filename:   doc.Filename,
+			Status:     string(doc.Status),
+			CreatedAt:  timestamp(doc.CreatedAt),
+			SourceUrl:  doc.SourceURL,
+			Metadata:   doc.Metadata,
+		})
+	}
+	return resp, nil
+}
+
+func (s *documentServer) Delete(ctx context.Context, req *docagentsv1.DeleteRequest) (*docagentsv1.DeleteResponse, error) {
+	docID, err := parseDocumentID(req.GetDocumentId())
+	if err != nil {
+		return nil, err
+	}
+	err = deleteDocument(ctx, s.deps, docID)
+	switch {
+	case errors.Is(err, store.ErrDocumentNotFound):
+		return nil, status.Error(codes.NotFound, "document not found")
+	case errors.Is(err, errOriginalNotRemoved):
+		return nil, grpcFail(s.deps, codes.Internal, "original file could not be removed; the document was kept, retry the delete", err)
+	case err != nil:
+		return nil, grpcFail(s.deps, codes.Internal, "failed to delete document", err)
+	}
+	return &docagentsv1.DeleteResponse{}, nil
+}
+
+// queryServer implements QueryService.
+type queryServer struct {
+	docagentsv1.UnimplementedQueryServiceServer
+	deps app.GatewayDeps
+}
+
+// Query forwards the question to a query service replica, like POST
+// /api/query, and streams the answer back. The query service answers in one
+// piece, so the answer currently arrives as a single delta.
+func (s *queryServer) Query(req *docagentsv1.QueryRequest, stream docagentsv1.QueryService_QueryServer) error {
+	ctx := stream.Context()
+	query := queryapi.Request{
+		Question:    req.GetQuestion(),
+		DocumentIDs: req.GetDocumentIds(),
+		TopK:        int(req.GetTopK()),
+	}
+	for key, value := range req.GetMetadata() {
+		if query.Metadata == nil {
+			query.Metadata = map[string]json.RawMessage{}
+		}
+		query.Metadata[key], _ = json.Marshal(value)
+	}
+	body, err := json.Marshal(query)
+	if err != nil {
+		return grpcFail(s.deps, codes.Internal, "failed to encode query", err)
+	}
+
+	resp, err := forwardQuery(ctx, s.deps, body)
+	if err != nil {
+		return grpcFail(s.deps, codes.Unavailable, "query service unavailable", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return status.Error(httpStatusCode(resp.StatusCode), string(bytes.TrimSpace(message)))
+	}
+	var answer queryapi.Response
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		return grpcFail(s.deps, codes.Internal, "invalid answer from query service", err)
+	}
+
+	for _, src := range answer.Sources {
+		if err := stream.Send(&docagentsv1.QueryResponse{Event: &docagentsv1.QueryResponse_Source{Source: &docagentsv1.Source{
+			ChunkId: src.ChunkID,
+			Score:   src.Score,
+			Preview: src.Preview,
+		}}}); err != nil {
+			return err
+		}
+	}
+	if err := stream.Send(&docagentsv1.QueryResponse{Event: &docagentsv1.QueryResponse_AnswerDelta{AnswerDelta: answer.Answer}}); err != nil {
+		return err
+	}
+	// Widen the float32 confidence through its shortest decimal form, so 0.8
+	// stays 0.8 rather than becoming 0.800000011920929.
+	confidence, _ := strconv.ParseFloat(strconv.FormatFloat(float64(answer.Confidence), 'g', -1, 32), 64)
+	return stream.Send(&docagentsv1.QueryResponse{Event: &docagentsv1.QueryResponse_Done{Done: &docagentsv1.QueryDone{
+		Confidence: confidence,
+		Cached:     answer.Cached,
+	}}})
+}
+
+// httpStatusCode maps an error status of the query service to a gRPC code.
+func httpStatusCode(statusCode int) codes.Code {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
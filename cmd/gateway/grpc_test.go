@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"doc-agents/internal/app"
+	"doc-agents/internal/auth"
+	"doc-agents/internal/blobstore"
+	"doc-agents/internal/queue"
+	"doc-agents/internal/store"
+	"doc-agents/internal/upstream"
+	docagentsv1 "doc-agents/proto/docagents/v1"
+)
+
+const testOperatorKey = "operator-key-for-grpc-tests-0123456789"
+
+// dialGRPC serves the gRPC API for deps over an in-memory listener.
+func dialGRPC(t *testing.T, deps app.GatewayDeps) *grpc.ClientConn {
+	t.Helper()
+	authn := auth.New(auth.Options{Keys: deps.Store, OperatorKey: testOperatorKey, Log: deps.Log})
+	lis := bufconn.Listen(1 << 20)
+	srv := newGRPCServer(deps, authn)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// operatorContext authenticates calls with the operator key for tenant acme.
+func operatorContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+testOperatorKey, "x-tenant-id", "acme")
+}
+
+func TestGRPCUpload(t *testing.T) {
+	docID := uuid.New()
+	mockStore := new(store.MockStore)
+	mockQueue := new(queue.MockQueue)
+	mockBlobs := new(blobstore.MockStore)
+	withoutDuplicates(mockStore)
+	mockStore.On("CreateDocument", mock.Anything, mock.MatchedBy(func(doc store.Document) bool {
+		return doc.Filename == "notes.md" && doc.ContentType == "text/markdown" && doc.Metadata["team"] == "search"
+	})).Return(store.Document{ID: docID, Status: store.StatusProcessing}, nil).Once()
+	mockBlobs.On("Put", mock.Anything, blobstore.DocumentKey(docID), mock.Anything, int64(11), "text/markdown").Return(nil).Once()
+	mockQueue.On("Enqueue", mock.Anything, mock.Anything).Return(nil).Once()
+
+	deps := newTestDeps(mockStore, mockQueue)
+	deps.Blobs = mockBlobs
+	client := docagentsv1.NewDocumentServiceClient(dialGRPC(t, deps))
+
+	stream, err := client.Upload(operatorContext(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := []*docagentsv1.UploadRequest{
+		{Data: &docagentsv1.UploadRequest_Info{Info: &docagentsv1.UploadInfo{Filename: "notes.md", Metadata: map[string]string{"team": "search"}}}},
+		{Data: &docagentsv1.UploadRequest_Chunk{Chunk: []byte("# Hello")}},
+		{Data: &docagentsv1.UploadRequest_Chunk{Chunk: []byte(" Go!")}},
+	}
+	for _, msg := range msgs {
+		if err := stream.Send(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetDocumentId() != docID.String() || resp.GetStatus() != string(store.StatusProcessing) || resp.GetDuplicateOf() != "" {
+		t.Errorf("unexpected response %v", resp)
+	}
+	mockStore.AssertExpectations(t)
+	mockBlobs.AssertExpectations(t)
+	mockQueue.AssertExpectations(t)
+}
+
+func TestGRPCUploadRejectsOversizedFile(t *testing.T) {
+	deps := newTestDeps(new(store.MockStore), new(queue.MockQueue))
+	deps.Config.MaxUploadSize = 4
+	client := docagentsv1.NewDocumentServiceClient(dialGRPC(t, deps))
+
+	stream, err := client.Upload(operatorContext(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = stream.Send(&docagentsv1.UploadRequest{Data: &docagentsv1.UploadRequest_Info{Info: &docagentsv1.UploadInfo{Filename: "a.txt"}}})
+	_ = stream.Send(&docagentsv1.UploadRequest{Data: &docagentsv1.UploadRequest_Chunk{Chunk: []byte("too long")}})
+	if _, err := stream.CloseAndRecv(); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestGRPCDocumentCalls(t *testing.T) {
+	docID := uuid.New()
+	created := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	mockStore := new(store.MockStore)
+	mockBlobs := new(blobstore.MockStore)
+	mockStore.On("GetDocument", mock.Anything, docID).
+		Return(store.Document{ID: docID, Filename: "a.txt", Status: store.StatusFailed, CreatedAt: created}, nil)
+	mockStore.On("ListStages", mock.Anything, docID).Return([]store.StageStatus{
+		{Stage: store.StageParse, State: store.StageDone, Attempts: 1},
+		{Stage: store.StageSummarize, State: store.StageFailed, Attempts: 3, LastError: "llm down"},
+	}, nil)
+	mockStore.On("GetSummary", mock.Anything, docID, 0).Return(store.Summary{}, store.ErrSummaryNotFound)
+	mockStore.On("ListDocuments", mock.Anything, mock.MatchedBy(func(f store.DocumentFilter) bool {
+		return f.Status == store.StatusFailed && f.Limit == 2 && len(f.Metadata) == 1
+	})).Return([]store.Document{{ID: docID, Filename: "a.txt", Status: store.StatusFailed, CreatedAt: created}}, nil)
+	mockStore.On("ListVersions", mock.Anything, docID).Return([]store.Version{{Number: 1}}, nil).Once()
+	mockStore.On("DeleteDocument", mock.Anything, docID).Return(nil).Once()
+	mockBlobs.On("Delete", mock.Anything, blobstore.DocumentKey(docID)).Return(nil).Once()
+
+	deps := newTestDeps(mockStore, new(queue.MockQueue))
+	deps.Blobs = mockBlobs
+	client := docagentsv1.NewDocumentServiceClient(dialGRPC(t, deps))
+	ctx := operatorContext(t)
+
+	st, err := client.GetStatus(ctx, &docagentsv1.GetStatusRequest{DocumentId: docID.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.GetStatus() != string(store.StatusFailed) || st.GetCurrentStage() != string(store.StageSummarize) || st.GetError() != "llm down" || len(st.GetStages()) != len(store.Stages) {
+		t.Errorf("unexpected status %v", st)
+	}
+	if !st.GetCreatedAt().AsTime().Equal(created) {
+		t.Errorf("created_at = %v, want %v", st.GetCreatedAt().AsTime(), created)
+	}
+
+	if _, err := client.GetSummary(ctx, &docagentsv1.GetSummaryRequest{DocumentId: docID.String()}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetSummary() = %v, want NotFound", err)
+	}
+	if _, err := client.GetSummary(ctx, &docagentsv1.GetSummaryRequest{DocumentId: "nope"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("GetSummary(invalid id) = %v, want InvalidArgument", err)
+	}
+
+	list, err := client.List(ctx, &docagentsv1.ListRequest{Status: "failed", Limit: 1, Metadata: map[string]string{"team": "search"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.GetDocuments()) != 1 || list.GetDocuments()[0].GetDocumentId() != docID.String() || list.GetNextCursor() != "" {
+		t.Errorf("unexpected list %v", list)
+	}
+	if _, err := client.List(ctx, &docagentsv1.ListRequest{Status: "archived"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("List(invalid status) = %v, want InvalidArgument", err)
+	}
+
+	if _, err := client.Delete(ctx, &docagentsv1.DeleteRequest{DocumentId: docID.String()}); err != nil {
+		t.Fatal(err)
+	}
+	mockStore.AssertExpectations(t)
+	mockBlobs.AssertExpectations(t)
+}
+
+func TestGRPCAuthentication(t *testing.T) {
+	mockStore := new(store.MockStore)
+	mockStore.On("UseAPIKey", mock.Anything, auth.HashKey("dak_reader")).
+		Return(store.APIKey{ID: uuid.New(), Tenant: "acme", Scopes: []string{string(auth.ScopeRead)}}, nil)
+	mockStore.On("UseAPIKey", mock.Anything, mock.Anything).Return(store.APIKey{}, store.ErrAPIKeyNotFound)
+	client := docagentsv1.NewDocumentServiceClient(dialGRPC(t, newTestDeps(mockStore, new(queue.MockQueue))))
+
+	tests := []struct {
+		name string
+		md   []string
+		want codes.Code
+	}{
+		{name: "no credentials", want: codes.Unauthenticated},
+		{name: "unknown key", md: []string{"authorization", "Bearer dak_unknown"}, want: codes.Unauthenticated},
+		{name: "missing scope", md: []string{"x-api-key", "dak_reader"}, want: codes.PermissionDenied},
+		{name: "other tenant", md: []string{"x-api-key", "dak_reader", "x-tenant-id", "globex"}, want: codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.AppendToOutgoingContext(context.Background(), tt.md...)
+			_, err := client.Delete(ctx, &docagentsv1.DeleteRequest{DocumentId: uuid.NewString()})
+			if status.Code(err) != tt.want {
+				t.Errorf("Delete() = %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGRPCQuery(t *testing.T) {
+	var gotTenant string
+	upstreamSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTenant = r.Header.Get("X-Tenant-ID")
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"question":"What is Go?","document_ids":["d1"],"top_k":3}` {
+			http.Error(w, "unexpected body "+string(body), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"answer": "A language.", "sources": [{"chunk_id": "c1", "score": 0.9, "preview": "Go is"}], "confidence": 0.8, "cached": true}`)
+	}))
+	defer upstreamSrv.Close()
+
+	deps := newTestDeps(new(store.MockStore), new(queue.MockQueue))
+	pool, err := upstream.New([]string{upstreamSrv.URL}, upstream.Options{Log: deps.Log})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deps.Query = pool
+	client := docagentsv1.NewQueryServiceClient(dialGRPC(t, deps))
+
+	stream, err := client.Query(operatorContext(t), &docagentsv1.QueryRequest{Question: "What is Go?", DocumentIds: []string{"d1"}, TopK: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []*docagentsv1.QueryResponse
+	for {
+		ev, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, ev)
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want source, answer and done: %v", len(events), events)
+	}
+	if events[0].GetSource().GetChunkId() != "c1" || events[1].GetAnswerDelta() != "A language." || events[2].GetDone().GetConfidence() != 0.8 || !events[2].GetDone().GetCached() {
+		t.Errorf("unexpected events %v", events)
+	}
+	if gotTenant != "acme" {
+		t.Errorf("query service got tenant %q, want acme", gotTenant)
+	}
+
+	// Query service rejections keep their meaning
+	stream, err = client.Query(operatorContext(t), &docagentsv1.QueryRequest{Question: "?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument, got %v", err)
+	}
+}
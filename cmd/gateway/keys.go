@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"doc-agents/internal/app"
+	"doc-agents/internal/auth"
+	"doc-agents/internal/httputil"
+	"doc-agents/internal/store"
+)
+
+type createAPIKeyRequest struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Scopes []string `json:"scopes" validate:"required,min=1" doc:"Any of upload, read, query and admin."`
+	// RateLimit is in requests per minute; zero uses RATE_LIMIT.
+	RateLimit int `json:"rate_limit" validate:"min=0"`
+}
+
+type apiKeyListResponse struct {
+	Keys []apiKeyView `json:"keys"`
+}
+
+type apiKeyView struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	RateLimit  int      `json:"rate_limit,omitempty"`
+	Requests   int64    `json:"requests"`
+	CreatedAt  string   `json:"created_at"`
+	LastUsedAt string   `json:"last_used_at,omitempty"`
+	RevokedAt  string   `json:"revoked_at,omitempty"`
+	// Key is only returned when the key is created.
+	Key string `json:"key,omitempty"`
+}
+
+func newAPIKeyView(key store.APIKey) apiKeyView {
+	view := apiKeyView{
+		ID:        key.ID.String(),
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    key.Scopes,
+		RateLimit: key.RateLimit,
+		Requests:  key.Requests,
+		CreatedAt: key.CreatedAt.Format(time.RFC3339Nano),
+	}
+	if !key.LastUsedAt.IsZero() {
+		view.LastUsedAt = key.LastUsedAt.Format(time.RFC3339Nano)
+	}
+	if !key.RevokedAt.IsZero() {
+		view.RevokedAt = key.RevokedAt.Format(time.RFC3339Nano)
+	}
+	return view
+}
+
+// createAPIKeyHandler issues an API key for the request's tenant. The
+// response carries the key, which is never shown again.
+func createAPIKeyHandler(deps app.GatewayDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req createAPIKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			httputil.Fail(deps.Log, w, r, "invalid payload", err, http.StatusBadRequest)
+			return
+		}
+		if err := httputil.Validator.Struct(&req); err != nil {
+			httputil.ValidationError(deps.Log, w, r, err)
+			return
+		}
+		if err := auth.ValidateScopes(req.Scopes); err != nil {
+			httputil.Fail(deps.Log, w, r, err.Error(), err, http.StatusBadRequest)
+			return
+		}
+
+		plaintext, prefix, err := auth.NewKey()
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "failed to generate api key", err, http.StatusInternalServerError)
+			return
+		}
+		key, err := deps.Store.CreateAPIKey(r.Context(), store.APIKey{
+			Name:      req.Name,
+			Prefix:    prefix,
+			Hash:      auth.HashKey(plaintext),
+			Scopes:    slices.Compact(slices.Sorted(slices.Values(req.Scopes))),
+			RateLimit: req.RateLimit,
+		})
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "failed to create api key", err, http.StatusInternalServerError)
+			return
+		}
+
+		deps.Log.Info("api key created", "key_id", key.ID, "scopes", key.Scopes)
+		view := newAPIKeyView(key)
+		view.Key = plaintext
+		httputil.WriteJSON(w, http.StatusCreated, view)
+	}
+}
+
+func listAPIKeysHandler(deps app.GatewayDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keys, err := deps.Store.ListAPIKeys(r.Context())
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "failed to list api keys", err, http.StatusInternalServerError)
+			return
+		}
+		views := make([]apiKeyView, 0, len(keys))
+		for _, key := range keys {
+			views = append(views, newAPIKeyView(key))
+		}
+		httputil.WriteJSON(w, http.StatusOK, apiKeyListResponse{Keys: views})
+	}
+}
+
+// revokeAPIKeyHandler disables a key immediately. Revoked keys stay listed
+// with their usage.
+func revokeAPIKeyHandler(deps app.GatewayDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keyID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "invalid api key id", err, http.StatusBadRequest)
+			return
+		}
+		err = deps.Store.RevokeAPIKey(r.Context(), keyID)
+		if errors.Is(err, store.ErrAPIKeyNotFound) {
+			httputil.Fail(deps.Log, w, r, "api key not found", err, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "failed to revoke api key", err, http.StatusInternalServerError)
+			return
+		}
+		deps.Log.Info("api key revoked", "key_id", keyID)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
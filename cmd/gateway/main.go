@@ -3,31 +3,46 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/google/uuid"
-	"github.com/ledongthuc/pdf"
+	"golang.org/x/sync/errgroup"
 
 	"doc-agents/internal/app"
+	"doc-agents/internal/auth"
+	"doc-agents/internal/blobstore"
+	"doc-agents/internal/events"
+	"doc-agents/internal/extractor"
 	"doc-agents/internal/httputil"
+	"doc-agents/internal/openapi"
 	"doc-agents/internal/queue"
 	"doc-agents/internal/store"
+	"doc-agents/internal/tenant"
+	"doc-agents/internal/webhook"
 )
 
 type parseTaskPayload struct {
-	DocumentID uuid.UUID `json:"document_id"`
-	Filename   string    `json:"filename"`
-	Content    string    `json:"content"`
+	DocumentID  uuid.UUID `json:"document_id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	BlobKey     string    `json:"blob_key"`
+	Reprocess   bool      `json:"reprocess,omitempty"`
+	Version     int       `json:"version,omitempty"`
 }
 
 func main() {
@@ -38,14 +53,76 @@ func main() {
 	}
 	r := httputil.NewRouter(deps.Log)
 
-	r.Post("/api/documents/upload", uploadHandler(deps))
-	r.Get("/api/documents/{id}/summary", summaryHandler(deps))
-	r.Post("/api/query", queryHandler(deps))
-	r.Get("/healthz", httputil.HealthHandler(deps))
+	uploads, err := newResumableUploadHandler(deps)
+	if err != nil {
+		deps.Log.Error("failed to initialize resumable uploads", "err", err)
+		os.Exit(1)
+	}
+
+	authOpts := auth.Options{
+		Keys:        deps.Store,
+		OperatorKey: deps.Config.AdminAPIKey,
+		TokenTenant: deps.Config.DefaultTenant,
+		RateLimit:   deps.Config.RateLimit,
+		Limiter:     auth.NewLimiter(deps.Config.RateLimitBurst),
+		Disabled:    !deps.Config.AuthEnabled,
+		Log:         deps.Log,
+	}
+	if deps.Config.OIDCIssuer != "" {
+		authOpts.Tokens, authOpts.GroupScopes, err = newTokenVerifier(deps.Config)
+		if err != nil {
+			deps.Log.Error("failed to initialize OIDC", "err", err)
+			os.Exit(1)
+		}
+	}
+	authn := auth.New(authOpts)
+	if !deps.Config.AuthEnabled {
+		deps.Log.Warn("authentication disabled; the API is open to anyone who can reach it")
+	}
+
+	mountRoutes(r, deps, authn, uploads)
+
+	var grpcListener net.Listener
+	if deps.Config.GRPCPort != 0 {
+		grpcListener, err = net.Listen("tcp", fmt.Sprintf(":%d", deps.Config.GRPCPort))
+		if err != nil {
+			deps.Log.Error("failed to listen for gRPC", "err", err)
+			os.Exit(1)
+		}
+	}
+	shutdownTimeout := time.Duration(deps.Config.ShutdownTimeout) * time.Second
+
+	err = app.Run(deps.BaseDeps, func(ctx context.Context, g *errgroup.Group) {
+		// Deliver the webhooks that every service enqueues
+		g.Go(func() error {
+			return deps.Queue.Worker(ctx, queue.TaskTypeWebhook, deps.WebhookDeliverer.Handle)
+		})
+
+		// Route queries only to query replicas that pass their health checks
+		g.Go(func() error {
+			return deps.Query.Run(ctx, time.Duration(deps.Config.QueryHealthInterval)*time.Second)
+		})
+
+		// Abandoned resumable uploads would otherwise fill TUS_DIR
+		g.Go(func() error {
+			return uploads.ExpireUploads(ctx, time.Hour)
+		})
+
+		if grpcListener != nil {
+			grpcServer := newGRPCServer(deps, authn)
+			g.Go(func() error {
+				deps.Log.Info("gateway gRPC listening", "addr", grpcListener.Addr().String())
+				return serveGRPC(ctx, deps, grpcServer, grpcListener, shutdownTimeout)
+			})
+		}
 
-	addr := fmt.Sprintf(":%d", deps.Config.Port)
-	deps.Log.Info("gateway listening", "addr", addr)
-	if err := http.ListenAndServe(addr, r); err != nil {
+		g.Go(func() error {
+			addr := fmt.Sprintf(":%d", deps.Config.Port)
+			deps.Log.Info("gateway listening", "addr", addr)
+			return httputil.ListenAndServe(ctx, deps.Log, addr, r, shutdownTimeout)
+		})
+	})
+	if err != nil {
 		deps.Log.Error("server failed", "err", err)
 	}
 }
@@ -57,104 +134,243 @@ func uploadHandler(deps app.GatewayDeps) http.HandlerFunc {
 		// Receive and validate file
 		file, header, err := r.FormFile("file")
 		if err != nil {
-			httputil.Fail(deps.Log, w, "file is required", err, http.StatusBadRequest)
+			httputil.Fail(deps.Log, w, r, "file is required", err, http.StatusBadRequest)
 			return
 		}
 		defer file.Close()
 
-		_, statusCode, err := validateUploadedFile(r, header, deps.Config.MaxUploadSize)
+		contentType, statusCode, err := validateUploadedFile(r, header, deps.Config.MaxUploadSize, deps.Extractors)
 		if err != nil {
-			httputil.Fail(deps.Log, w, err.Error(), nil, statusCode)
+			httputil.Fail(deps.Log, w, r, err.Error(), err, statusCode)
 			return
 		}
-
-		// Process file and create document
-		content, err := io.ReadAll(file)
+		metadata, err := parseMetadataField(r.FormValue("metadata"))
 		if err != nil {
-			httputil.Fail(deps.Log, w, "failed to read file", err, http.StatusInternalServerError)
+			httputil.Fail(deps.Log, w, r, err.Error(), err, http.StatusBadRequest)
 			return
 		}
-		text := extractText(header.Filename, content, deps)
 
-		doc, err := deps.Store.CreateDocument(ctx, header.Filename)
+		newDoc := store.Document{Filename: header.Filename, Metadata: metadata}
+		doc, duplicate, err := ingestDocument(ctx, deps, newDoc, contentType, file, header.Size, forceRequested(r))
 		if err != nil {
-			httputil.Fail(deps.Log, w, "failed to persist document", err, http.StatusInternalServerError)
+			failIngest(deps, w, r, err, doc.ID)
 			return
 		}
 
-		// Enqueue parse task for background processing
-		payload := parseTaskPayload{
-			DocumentID: doc.ID,
-			Filename:   header.Filename,
-			Content:    text,
-		}
-		body, err := json.Marshal(payload)
-		if err != nil {
-			fail(deps, ctx, w, "marshal payload failed", err, doc.ID, http.StatusInternalServerError, true)
-			return
-		}
-		task := queue.Task{Type: queue.TaskTypeParse, Payload: body}
-		if err := queue.EnqueueWithRetry(ctx, deps.Queue, task, 3, 200*time.Millisecond); err != nil {
-			fail(deps, ctx, w, "failed to enqueue document; please retry", err, doc.ID, http.StatusInternalServerError, true)
-			return
-		}
+		status, body := ingestResponse(doc, duplicate)
+		httputil.WriteJSON(w, status, body)
+	}
+}
 
-		httputil.WriteJSON(w, http.StatusAccepted, map[string]any{
-			"document_id": doc.ID.String(),
-			"status":      doc.Status,
-		})
+// uploadForm is the multipart body of POST /api/documents/upload.
+type uploadForm struct {
+	File     openapi.File `json:"file" validate:"required"`
+	Metadata string       `json:"metadata,omitempty" doc:"JSON object of string values."`
+}
+
+// forceQuery holds the ?force parameter of the ingestion endpoints.
+type forceQuery struct {
+	Force bool `json:"force" doc:"Ingest a fresh copy even if identical content was uploaded before."`
+}
+
+// ingestedResponse is the answer to an upload, whichever path it took.
+type ingestedResponse struct {
+	DocumentID string               `json:"document_id"`
+	Status     store.DocumentStatus `json:"status"`
+	// DuplicateOf is set when identical content was already ingested;
+	// document_id is then the existing document.
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+}
+
+// forceRequested reports whether the client asked to ingest a fresh copy
+// even if identical content was uploaded before (?force=true).
+func forceRequested(r *http.Request) bool {
+	force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+	return force
+}
+
+// ingestResponse builds the status and body for an ingested document. A
+// duplicate points at the existing document with 200, since nothing new was
+// queued; a fresh document is 202 Accepted.
+func ingestResponse(doc store.Document, duplicate bool) (int, ingestedResponse) {
+	body := ingestedResponse{DocumentID: doc.ID.String(), Status: doc.Status}
+	if duplicate {
+		body.DuplicateOf = doc.ID.String()
+		return http.StatusOK, body
 	}
+	return http.StatusAccepted, body
 }
 
 // validateUploadedFile validates file size and content type.
 // Returns the validated content type, HTTP status code, and error.
-func validateUploadedFile(r *http.Request, header *multipart.FileHeader, maxSize int64) (contentType string, statusCode int, err error) {
+func validateUploadedFile(r *http.Request, header *multipart.FileHeader, maxSize int64, extractors *extractor.Registry) (contentType string, statusCode int, err error) {
 	// Check ContentLength header
 	if r.ContentLength > maxSize {
 		return "", http.StatusBadRequest, fmt.Errorf("file too large (max %d bytes)", maxSize)
 	}
 
+	return validateFile(header.Filename, header.Header.Get("Content-Type"), header.Size, maxSize, extractors)
+}
+
+// validateFile applies the upload rules shared by every ingestion path:
+// size limit and a content type some registered extractor understands.
+// Returns the canonical content type, HTTP status code, and error.
+func validateFile(filename, contentType string, size, maxSize int64, extractors *extractor.Registry) (string, int, error) {
 	// Check actual file size
-	if header.Size > maxSize {
+	if size > maxSize {
 		return "", http.StatusBadRequest, fmt.Errorf("file too large (max %d bytes)", maxSize)
 	}
 
-	// Get or detect Content-Type
-	contentType = header.Header.Get("Content-Type")
-	if contentType == "" {
-		ext := strings.ToLower(filepath.Ext(header.Filename))
-		switch ext {
-		case ".txt":
-			contentType = "text/plain"
-		case ".pdf":
-			contentType = "application/pdf"
-		default:
-			return "", http.StatusBadRequest, fmt.Errorf("unsupported file type (only PDF and TXT allowed)")
+	unsupported := fmt.Errorf("%w (allowed: %s)", extractor.ErrUnsupportedType, strings.Join(extractors.Extensions(), ", "))
+
+	// Get or detect Content-Type; generic binary types are treated as missing
+	if contentType == "" || strings.HasPrefix(contentType, "application/octet-stream") {
+		detected, ok := extractors.DetectType(filename)
+		if !ok {
+			return "", http.StatusBadRequest, unsupported
 		}
+		contentType = detected
 	}
 
-	// Validate against allowlist
-	allowedTypes := map[string]bool{
-		"text/plain":      true,
-		"application/pdf": true,
-	}
-	if !allowedTypes[contentType] {
-		return "", http.StatusBadRequest, fmt.Errorf("unsupported file type (only PDF and TXT allowed)")
+	// Validate against registered extractors
+	contentType, ok := extractors.Resolve(contentType)
+	if !ok {
+		return "", http.StatusBadRequest, unsupported
 	}
 
 	return contentType, 0, nil
 }
 
-// fail is gateway-specific error handler that can mark documents as failed
-func fail(deps app.GatewayDeps, ctx context.Context, w http.ResponseWriter, message string, err error, docID uuid.UUID, status int, markFailed bool) {
-	log := deps.Log.With("document_id", docID)
-	if markFailed && docID != uuid.Nil {
-		if upErr := deps.Store.UpdateDocumentStatus(ctx, docID, store.StatusFailed); upErr != nil {
-			log.Error("failed to mark document failed", "err", upErr)
+// ingestError records which ingestion step failed so handlers can report it.
+type ingestError struct {
+	message string
+	err     error
+}
+
+func (e *ingestError) Error() string {
+	return e.message + ": " + e.err.Error()
+}
+
+func (e *ingestError) Unwrap() error {
+	return e.err
+}
+
+// ingestDocument creates the document, streams the original file into blob
+// storage and enqueues its parse task. Every upload path funnels through here.
+// Unless force is set, content that was already ingested is not processed
+// again: the existing document is returned and duplicate is true.
+// If a step fails after the document was created, the document is marked
+// failed and returned alongside the error.
+func ingestDocument(ctx context.Context, deps app.GatewayDeps, newDoc store.Document, contentType string, content io.Reader, size int64, force bool) (doc store.Document, duplicate bool, err error) {
+	hash, content, err := hashContent(content)
+	if err != nil {
+		return store.Document{}, false, &ingestError{"failed to read file", err}
+	}
+	if !force {
+		existing, err := deps.Store.FindDocumentByHash(ctx, hash)
+		if err == nil {
+			deps.Log.Info("duplicate upload", "document_id", existing.ID, "filename", newDoc.Filename)
+			return existing, true, nil
+		}
+		if !errors.Is(err, store.ErrDocumentNotFound) {
+			return store.Document{}, false, &ingestError{"failed to check for duplicates", err}
+		}
+	}
+
+	newDoc.ContentHash = hash
+	newDoc.ContentType = contentType
+	doc, err = deps.Store.CreateDocument(ctx, newDoc)
+	if err != nil {
+		return store.Document{}, false, &ingestError{"failed to persist document", err}
+	}
+
+	markFailed := func(message string, err error) (store.Document, bool, error) {
+		if upErr := deps.Store.UpdateDocumentStatus(ctx, doc.ID, store.StatusFailed); upErr != nil {
+			deps.Log.Error("failed to mark document failed", "document_id", doc.ID, "err", upErr)
+		}
+		doc.Status = store.StatusFailed
+		return doc, false, &ingestError{message, err}
+	}
+
+	// Stream the original file into blob storage; the parser extracts text from it
+	blobKey := blobstore.DocumentKey(doc.ID)
+	if err := deps.Blobs.Put(ctx, blobKey, content, size, contentType); err != nil {
+		return markFailed("failed to store file", err)
+	}
+
+	// Enqueue parse task for background processing
+	payload := parseTaskPayload{
+		DocumentID:  doc.ID,
+		Filename:    doc.Filename,
+		ContentType: contentType,
+		BlobKey:     blobKey,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return markFailed("marshal payload failed", err)
+	}
+	task := queue.Task{Type: queue.TaskTypeParse, Payload: body}
+	if err := queue.EnqueueWithRetry(ctx, deps.Queue, task, 3, 200*time.Millisecond); err != nil {
+		return markFailed("failed to enqueue document; please retry", err)
+	}
+	publishEvent(ctx, deps, events.Event{DocumentID: doc.ID, Type: events.TypeQueued})
+	deps.Webhooks.Notify(ctx, webhook.EventDocumentCreated, doc, "")
+
+	return doc, false, nil
+}
+
+// publishEvent announces a document event. Clients that miss it catch up
+// from the snapshot sent when they (re)connect to the event stream.
+func publishEvent(ctx context.Context, deps app.GatewayDeps, ev events.Event) {
+	if err := deps.Events.Publish(ctx, ev); err != nil {
+		deps.Log.Warn("failed to publish document event", "document_id", ev.DocumentID, "type", ev.Type, "err", err)
+	}
+}
+
+// hashContent returns the hex SHA-256 of content and a reader that yields the
+// same bytes from the start. Seekable inputs (multipart and tus files) are
+// rewound; anything else is buffered, which is bounded by the upload limits.
+func hashContent(content io.Reader) (string, io.Reader, error) {
+	h := sha256.New()
+	if rs, ok := content.(io.ReadSeeker); ok {
+		if _, err := io.Copy(h, rs); err != nil {
+			return "", nil, err
 		}
+		if _, err := rs.Seek(0, io.SeekStart); err != nil {
+			return "", nil, err
+		}
+		return hex.EncodeToString(h.Sum(nil)), rs, nil
+	}
+	buf, err := io.ReadAll(io.TeeReader(content, h))
+	if err != nil {
+		return "", nil, err
 	}
+	return hex.EncodeToString(h.Sum(nil)), bytes.NewReader(buf), nil
+}
 
-	httputil.Fail(log, w, message, err, status)
+// failIngest writes the error response for a failed ingestDocument call.
+func failIngest(deps app.GatewayDeps, w http.ResponseWriter, r *http.Request, err error, docID uuid.UUID) {
+	log := deps.Log
+	if docID != uuid.Nil {
+		log = log.With("document_id", docID)
+	}
+	message := "failed to ingest document"
+	var ie *ingestError
+	if errors.As(err, &ie) {
+		message = ie.message
+	}
+	httputil.Fail(log, w, r, message, err, http.StatusInternalServerError)
+}
+
+type summaryResponse struct {
+	Version   int      `json:"version"`
+	Summary   string   `json:"summary"`
+	KeyPoints []string `json:"key_points"`
+}
+
+// summaryQuery holds the query parameters of the summary endpoint.
+type summaryQuery struct {
+	Version int `json:"version" validate:"omitempty,min=1" doc:"Version to summarize; defaults to the live one."`
 }
 
 func summaryHandler(deps app.GatewayDeps) http.HandlerFunc {
@@ -162,88 +378,97 @@ func summaryHandler(deps app.GatewayDeps) http.HandlerFunc {
 		idStr := chi.URLParam(r, "id")
 		docID, err := uuid.Parse(idStr)
 		if err != nil {
-			httputil.Fail(deps.Log, w, "invalid document id", err, http.StatusBadRequest)
+			httputil.Fail(deps.Log, w, r, "invalid document id", err, http.StatusBadRequest)
 			return
 		}
-		sum, err := deps.Store.GetSummary(r.Context(), docID)
+		version, err := versionParam(r, "version")
 		if err != nil {
-			fail(deps, r.Context(), w, "summary not ready", err, docID, http.StatusNotFound, false)
+			httputil.Fail(deps.Log, w, r, err.Error(), err, http.StatusBadRequest)
 			return
 		}
-		httputil.WriteJSON(w, http.StatusOK, map[string]any{
-			"summary":    sum.Summary,
-			"key_points": sum.KeyPoints,
+		sum, err := deps.Store.GetSummary(r.Context(), docID, version)
+		switch {
+		case errors.Is(err, store.ErrDocumentNotFound):
+			httputil.Fail(deps.Log.With("document_id", docID), w, r, "document not found", err, http.StatusNotFound)
+			return
+		case errors.Is(err, store.ErrVersionNotFound):
+			httputil.Fail(deps.Log.With("document_id", docID), w, r, fmt.Sprintf("document has no version %d", version), err, http.StatusNotFound)
+			return
+		case errors.Is(err, store.ErrSummaryNotFound):
+			httputil.Fail(deps.Log.With("document_id", docID), w, r, "summary not ready", err, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			httputil.Fail(deps.Log.With("document_id", docID), w, r, "failed to load summary", err, http.StatusInternalServerError)
+			return
+		}
+		httputil.WriteJSON(w, http.StatusOK, summaryResponse{
+			Version:   sum.Version,
+			Summary:   sum.Summary,
+			KeyPoints: sum.KeyPoints,
 		})
 	}
 }
 
-func queryHandler(deps app.GatewayDeps) http.HandlerFunc {
-	queryURL := "http://query:8081/api/query"
-	client := &http.Client{Timeout: 60 * time.Second}
+// forwardQuery sends the JSON query body to a query service replica on
+// behalf of the tenant and request in ctx.
+func forwardQuery(ctx context.Context, deps app.GatewayDeps, body []byte) (*http.Response, error) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set(httputil.InternalTokenHeader, deps.Config.InternalToken)
+	header.Set(middleware.RequestIDHeader, middleware.GetReqID(ctx))
+	if id, ok := tenant.FromContext(ctx); ok {
+		header.Set(tenant.Header, id)
+	}
+	return deps.Query.Do(ctx, http.MethodPost, "/api/query", header, body)
+}
+
+// maxQueryBody bounds the query payload the gateway buffers to resend on retries.
+const maxQueryBody = 1 << 20
 
+// queryUnavailableResponse tells the client no query replica could answer.
+type queryUnavailableResponse struct {
+	httputil.ErrorResponse
+	HealthyReplicas int `json:"healthy_replicas"`
+	Replicas        int `json:"replicas"`
+}
+
+// queryHandler forwards queries to a healthy query service replica. Queries
+// only read, so a query that could not be delivered is retried on the next
+// replica.
+func queryHandler(deps app.GatewayDeps) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Forward request to query agent service
-		req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, queryURL, r.Body)
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxQueryBody))
 		if err != nil {
-			httputil.Fail(deps.Log, w, "failed to create request", err, http.StatusInternalServerError)
+			httputil.Fail(deps.Log, w, r, "invalid payload", err, http.StatusBadRequest)
 			return
 		}
-		req.Header.Set("Content-Type", "application/json")
 
-		resp, err := client.Do(req)
+		resp, err := forwardQuery(r.Context(), deps, body)
 		if err != nil {
-			httputil.Fail(deps.Log, w, "query service unavailable", err, http.StatusServiceUnavailable)
+			deps.Log.Error("query service unavailable", "err", err)
+			healthy, total := deps.Query.Healthy()
+			w.Header().Set("Retry-After", strconv.Itoa(max(deps.Config.QueryHealthInterval, 1)))
+			httputil.WriteJSON(w, http.StatusServiceUnavailable, queryUnavailableResponse{
+				ErrorResponse: httputil.ErrorResponse{
+					Code:      "query_unavailable",
+					Message:   "query service unavailable",
+					RequestID: middleware.GetReqID(r.Context()),
+				},
+				HealthyReplicas: healthy,
+				Replicas:        total,
+			})
 			return
 		}
 		defer resp.Body.Close()
 
 		// Copy response status, headers, and body
-		w.Header().Set("Content-Type", "application/json")
+		if ct := resp.Header.Get("Content-Type"); ct != "" {
+			w.Header().Set("Content-Type", ct)
+		}
 		w.WriteHeader(resp.StatusCode)
 		if _, err := io.Copy(w, resp.Body); err != nil {
 			deps.Log.Error("failed to copy response", "err", err)
 		}
 	}
 }
-
-// extractText extracts text from uploaded files, with PDF support.
-func extractText(filename string, content []byte, deps app.GatewayDeps) string {
-	if strings.HasSuffix(strings.ToLower(filename), ".pdf") {
-		text, err := extractPDF(content)
-		if err != nil {
-			deps.Log.Warn("pdf extraction failed, using raw bytes", "err", err, "filename", filename)
-			return string(content)
-		}
-		return text
-	}
-	// Treat other files as plain text
-	return string(content)
-}
-
-func extractPDF(content []byte) (string, error) {
-	reader := bytes.NewReader(content)
-	pdfReader, err := pdf.NewReader(reader, int64(len(content)))
-	if err != nil {
-		return "", err
-	}
-
-	var textBuilder strings.Builder
-	numPages := pdfReader.NumPage()
-
-	for pageNum := 1; pageNum <= numPages; pageNum++ {
-		page := pdfReader.Page(pageNum)
-		if page.V.IsNull() || page.V.Key("Contents").Kind() == pdf.Null {
-			continue
-		}
-
-		text, err := page.GetPlainText(nil)
-		if err != nil {
-			// Skip pages that fail to extract
-			continue
-		}
-		textBuilder.WriteString(text)
-		textBuilder.WriteString("\n")
-	}
-
-	return textBuilder.String(), nil
-}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"archive/zip"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -8,19 +9,35 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
 
 	"doc-agents/internal/app"
+	"doc-agents/internal/auth"
+	"doc-agents/internal/blobstore"
+	"doc-agents/internal/cache"
 	"doc-agents/internal/config"
+	"doc-agents/internal/events"
+	"doc-agents/internal/extractor"
+	"doc-agents/internal/httputil"
 	"doc-agents/internal/queue"
 	"doc-agents/internal/store"
+	"doc-agents/internal/tenant"
+	"doc-agents/internal/textdiff"
+	"doc-agents/internal/upstream"
+	"doc-agents/internal/urlfetch"
+	"doc-agents/internal/webhook"
 )
 
 func newTestDeps(st store.Store, q queue.Queue) app.GatewayDeps {
@@ -28,11 +45,17 @@ func newTestDeps(st store.Store, q queue.Queue) app.GatewayDeps {
 		BaseDeps: app.BaseDeps{
 			Store: st,
 			Config: config.Config{
-				MaxUploadSize: 1024 * 1024, // 1MB for tests
+				MaxUploadSize:   1024 * 1024, // 1MB for tests
+				MaxBatchSize:    4 * 1024 * 1024,
+				MaxBatchEntries: 10,
 			},
 			Log: slog.New(slog.NewTextHandler(io.Discard, nil)),
 		},
-		Queue: q,
+		Queue:      q,
+		Events:     events.NewHub(),
+		Webhooks:   webhook.NoOpNotifier{},
+		Extractors: extractor.NewDefaultRegistry(),
+		Cache:      cache.NewNoOpCache(),
 	}
 }
 
@@ -44,7 +67,9 @@ func TestUploadHandler(t *testing.T) {
 		filename      string
 		contentType   string
 		content       []byte
-		setup         func(*store.MockStore, *queue.MockQueue)
+		metadata      string
+		force         bool
+		setup         func(*store.MockStore, *queue.MockQueue, *blobstore.MockStore)
 		wantStatus    int
 		checkResponse func(*testing.T, *http.Response)
 	}{
@@ -53,9 +78,10 @@ func TestUploadHandler(t *testing.T) {
 			filename:    "test.txt",
 			contentType: "text/plain",
 			content:     []byte("Hello"),
-			setup: func(s *store.MockStore, q *queue.MockQueue) {
-				s.On("CreateDocument", mock.Anything, "test.txt").
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
+				s.On("CreateDocument", mock.Anything, docNamed("test.txt")).
 					Return(store.Document{ID: validDocID, Status: store.StatusProcessing}, nil).Once()
+				b.On("Put", mock.Anything, blobstore.DocumentKey(validDocID), mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
 				q.On("Enqueue", mock.Anything, mock.Anything).Return(nil).Once()
 			},
 			wantStatus: http.StatusAccepted,
@@ -72,6 +98,97 @@ func TestUploadHandler(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:        "stores content hash",
+			filename:    "test.txt",
+			contentType: "text/plain",
+			content:     []byte("Hello"),
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
+				s.On("CreateDocument", mock.Anything, mock.MatchedBy(func(doc store.Document) bool {
+					return doc.ContentHash == "185f8db32271fe25f561a6fc938b2e264306ec304eda518007d1764826381969"
+				})).Return(store.Document{ID: validDocID, Status: store.StatusProcessing}, nil).Once()
+				b.On("Put", mock.Anything, blobstore.DocumentKey(validDocID), mock.Anything, int64(5), "text/plain").Return(nil).Once()
+				q.On("Enqueue", mock.Anything, mock.Anything).Return(nil).Once()
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:        "duplicate returns existing document",
+			filename:    "copy.txt",
+			contentType: "text/plain",
+			content:     []byte("Hello"),
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
+				s.On("FindDocumentByHash", mock.Anything, "185f8db32271fe25f561a6fc938b2e264306ec304eda518007d1764826381969").
+					Return(store.Document{ID: validDocID, Filename: "test.txt", Status: store.StatusReady}, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			checkResponse: func(t *testing.T, resp *http.Response) {
+				var result map[string]any
+				if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if result["document_id"] != validDocID.String() || result["duplicate_of"] != validDocID.String() {
+					t.Errorf("Expected existing document %s, got %v", validDocID, result)
+				}
+				if result["status"] != string(store.StatusReady) {
+					t.Errorf("Expected status %s, got %v", store.StatusReady, result["status"])
+				}
+			},
+		},
+		{
+			name:        "force bypasses deduplication",
+			filename:    "copy.txt",
+			contentType: "text/plain",
+			content:     []byte("Hello"),
+			force:       true,
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
+				s.On("CreateDocument", mock.Anything, docNamed("copy.txt")).
+					Return(store.Document{ID: validDocID, Status: store.StatusProcessing}, nil).Once()
+				b.On("Put", mock.Anything, blobstore.DocumentKey(validDocID), mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+				q.On("Enqueue", mock.Anything, mock.Anything).Return(nil).Once()
+			},
+			wantStatus: http.StatusAccepted,
+			checkResponse: func(t *testing.T, resp *http.Response) {
+				var result map[string]any
+				if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if _, ok := result["duplicate_of"]; ok {
+					t.Errorf("Expected no duplicate_of with force, got %v", result)
+				}
+			},
+		},
+		{
+			name:        "stores metadata",
+			filename:    "test.txt",
+			contentType: "text/plain",
+			content:     []byte("Hello"),
+			metadata:    `{"project":"apollo","team":"search"}`,
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
+				s.On("CreateDocument", mock.Anything, mock.MatchedBy(func(doc store.Document) bool {
+					return doc.Metadata["project"] == "apollo" && doc.Metadata["team"] == "search" && len(doc.Metadata) == 2
+				})).Return(store.Document{ID: validDocID, Status: store.StatusProcessing}, nil).Once()
+				b.On("Put", mock.Anything, blobstore.DocumentKey(validDocID), mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+				q.On("Enqueue", mock.Anything, mock.Anything).Return(nil).Once()
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:        "metadata must be an object of strings",
+			filename:    "test.txt",
+			contentType: "text/plain",
+			content:     []byte("Hello"),
+			metadata:    `{"version":2}`,
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "invalid metadata key",
+			filename:    "test.txt",
+			contentType: "text/plain",
+			content:     []byte("Hello"),
+			metadata:    `{"has space":"x"}`,
+			wantStatus:  http.StatusBadRequest,
+		},
 		{
 			name:        "file too large",
 			filename:    "large.txt",
@@ -84,46 +201,96 @@ func TestUploadHandler(t *testing.T) {
 			filename:    "test.txt",
 			contentType: "", // Empty, should detect from .txt
 			content:     []byte("content"),
-			setup: func(s *store.MockStore, q *queue.MockQueue) {
-				s.On("CreateDocument", mock.Anything, "test.txt").
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
+				s.On("CreateDocument", mock.Anything, docNamed("test.txt")).
 					Return(store.Document{ID: validDocID, Status: store.StatusProcessing}, nil).Once()
+				b.On("Put", mock.Anything, blobstore.DocumentKey(validDocID), mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
 				q.On("Enqueue", mock.Anything, mock.Anything).Return(nil).Once()
 			},
 			wantStatus: http.StatusAccepted,
 		},
 		{
-			name:        "unsupported extension",
-			filename:    "test.docx",
+			name:        "markdown detected from extension",
+			filename:    "notes.md",
 			contentType: "",
-			content:     []byte("content"),
-			wantStatus:  http.StatusBadRequest,
+			content:     []byte("# Title\n\nSome **bold** text"),
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
+				s.On("CreateDocument", mock.Anything, docNamed("notes.md")).
+					Return(store.Document{ID: validDocID, Status: store.StatusProcessing}, nil).Once()
+				b.On("Put", mock.Anything, blobstore.DocumentKey(validDocID), mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+				q.On("Enqueue", mock.Anything, mock.MatchedBy(func(task queue.Task) bool {
+					var payload parseTaskPayload
+					if err := json.Unmarshal(task.Payload, &payload); err != nil {
+						return false
+					}
+					return payload.ContentType == extractor.TypeMarkdown && payload.BlobKey == blobstore.DocumentKey(validDocID)
+				})).Return(nil).Once()
+			},
+			wantStatus: http.StatusAccepted,
 		},
 		{
-			name:        "unsupported Content-Type",
-			filename:    "test.doc",
-			contentType: "application/msword",
-			content:     []byte("content"),
-			wantStatus:  http.StatusBadRequest,
+			name:        "HTML with charset parameter",
+			filename:    "page.html",
+			contentType: "text/html; charset=utf-8",
+			content:     []byte("<html><body><p>Hello</p><script>x()</script></body></html>"),
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
+				s.On("CreateDocument", mock.Anything, docNamed("page.html")).
+					Return(store.Document{ID: validDocID, Status: store.StatusProcessing}, nil).Once()
+				b.On("Put", mock.Anything, blobstore.DocumentKey(validDocID), mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+				q.On("Enqueue", mock.Anything, mock.Anything).Return(nil).Once()
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:          "unsupported extension",
+			filename:      "test.xlsx",
+			contentType:   "",
+			content:       []byte("content"),
+			wantStatus:    http.StatusBadRequest,
+			checkResponse: wantErrorCode("unsupported_file_type"),
+		},
+		{
+			name:          "unsupported Content-Type",
+			filename:      "test.doc",
+			contentType:   "application/msword",
+			content:       []byte("content"),
+			wantStatus:    http.StatusBadRequest,
+			checkResponse: wantErrorCode("unsupported_file_type"),
 		},
 		{
 			name:        "CreateDocument failure",
 			filename:    "test.txt",
 			contentType: "text/plain",
 			content:     []byte("content"),
-			setup: func(s *store.MockStore, q *queue.MockQueue) {
-				s.On("CreateDocument", mock.Anything, "test.txt").
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
+				s.On("CreateDocument", mock.Anything, docNamed("test.txt")).
 					Return(store.Document{}, errors.New("db error")).Once()
 			},
 			wantStatus: http.StatusInternalServerError,
 		},
+		{
+			name:        "blob store failure marks doc failed",
+			filename:    "test.txt",
+			contentType: "text/plain",
+			content:     []byte("content"),
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
+				s.On("CreateDocument", mock.Anything, docNamed("test.txt")).
+					Return(store.Document{ID: validDocID, Status: store.StatusProcessing}, nil).Once()
+				b.On("Put", mock.Anything, blobstore.DocumentKey(validDocID), mock.Anything, int64(7), "text/plain").
+					Return(errors.New("disk full")).Once()
+				s.On("UpdateDocumentStatus", mock.Anything, validDocID, store.StatusFailed).Return(nil).Once()
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
 		{
 			name:        "Enqueue failure marks doc failed",
 			filename:    "test.txt",
 			contentType: "text/plain",
 			content:     []byte("content"),
-			setup: func(s *store.MockStore, q *queue.MockQueue) {
-				s.On("CreateDocument", mock.Anything, "test.txt").
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
+				s.On("CreateDocument", mock.Anything, docNamed("test.txt")).
 					Return(store.Document{ID: validDocID, Status: store.StatusProcessing}, nil).Once()
+				b.On("Put", mock.Anything, blobstore.DocumentKey(validDocID), mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
 				q.On("Enqueue", mock.Anything, mock.Anything).Return(errors.New("queue error")).Times(3)
 				s.On("UpdateDocumentStatus", mock.Anything, validDocID, store.StatusFailed).Return(nil).Once()
 			},
@@ -135,18 +302,26 @@ func TestUploadHandler(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			mockStore := new(store.MockStore)
 			mockQueue := new(queue.MockQueue)
+			mockBlobs := new(blobstore.MockStore)
 
 			if tt.setup != nil {
-				tt.setup(mockStore, mockQueue)
+				tt.setup(mockStore, mockQueue, mockBlobs)
+			}
+			if !tt.force {
+				withoutDuplicates(mockStore)
 			}
 
 			deps := newTestDeps(mockStore, mockQueue)
+			deps.Blobs = mockBlobs
 			handler := uploadHandler(deps)
 
-			req, err := createMultipartRequest(tt.filename, tt.contentType, tt.content)
+			req, err := createMultipartRequest(tt.filename, tt.contentType, tt.content, tt.metadata)
 			if err != nil {
 				t.Fatalf("Failed to create request: %v", err)
 			}
+			if tt.force {
+				req.URL.RawQuery = "force=true"
+			}
 
 			w := httptest.NewRecorder()
 			handler(w, req)
@@ -164,6 +339,7 @@ func TestUploadHandler(t *testing.T) {
 
 			mockStore.AssertExpectations(t)
 			mockQueue.AssertExpectations(t)
+			mockBlobs.AssertExpectations(t)
 		})
 	}
 
@@ -186,12 +362,27 @@ func TestUploadHandler(t *testing.T) {
 	})
 }
 
+// wantErrorCode checks that a response is an error with code.
+func wantErrorCode(code string) func(*testing.T, *http.Response) {
+	return func(t *testing.T, resp *http.Response) {
+		t.Helper()
+		var body httputil.ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode error response: %v", err)
+		}
+		if body.Code != code {
+			t.Errorf("Expected error code %q, got %q (%s)", code, body.Code, body.Message)
+		}
+	}
+}
+
 func TestSummaryHandler(t *testing.T) {
 	validDocID := uuid.New()
 
 	tests := []struct {
 		name          string
 		docID         string
+		query         string
 		setup         func(*store.MockStore)
 		wantStatus    int
 		checkResponse func(*testing.T, *http.Response)
@@ -200,7 +391,7 @@ func TestSummaryHandler(t *testing.T) {
 			name:  "successful retrieval",
 			docID: validDocID.String(),
 			setup: func(s *store.MockStore) {
-				s.On("GetSummary", mock.Anything, validDocID).
+				s.On("GetSummary", mock.Anything, validDocID, 0).
 					Return(store.Summary{
 						DocumentID: validDocID,
 						Summary:    "Test summary",
@@ -222,6 +413,31 @@ func TestSummaryHandler(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:  "pinned version",
+			docID: validDocID.String(),
+			query: "?version=1",
+			setup: func(s *store.MockStore) {
+				s.On("GetSummary", mock.Anything, validDocID, 1).
+					Return(store.Summary{DocumentID: validDocID, Version: 1, Summary: "Old summary"}, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			checkResponse: func(t *testing.T, resp *http.Response) {
+				var result summaryResponse
+				if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if result.Version != 1 || result.Summary != "Old summary" {
+					t.Errorf("Expected the summary of version 1, got %+v", result)
+				}
+			},
+		},
+		{
+			name:       "invalid version",
+			docID:      validDocID.String(),
+			query:      "?version=0",
+			wantStatus: http.StatusBadRequest,
+		},
 		{
 			name:       "invalid UUID",
 			docID:      "not-a-uuid",
@@ -231,19 +447,42 @@ func TestSummaryHandler(t *testing.T) {
 			name:  "summary not found",
 			docID: validDocID.String(),
 			setup: func(s *store.MockStore) {
-				s.On("GetSummary", mock.Anything, validDocID).
+				s.On("GetSummary", mock.Anything, validDocID, 0).
 					Return(store.Summary{}, store.ErrSummaryNotFound).Once()
 			},
-			wantStatus: http.StatusNotFound,
+			wantStatus:    http.StatusNotFound,
+			checkResponse: wantErrorCode("summary_not_ready"),
+		},
+		{
+			name:  "document not found",
+			docID: validDocID.String(),
+			setup: func(s *store.MockStore) {
+				s.On("GetSummary", mock.Anything, validDocID, 0).
+					Return(store.Summary{}, store.ErrDocumentNotFound).Once()
+			},
+			wantStatus:    http.StatusNotFound,
+			checkResponse: wantErrorCode("document_not_found"),
+		},
+		{
+			name:  "version not found",
+			docID: validDocID.String(),
+			query: "?version=7",
+			setup: func(s *store.MockStore) {
+				s.On("GetSummary", mock.Anything, validDocID, 7).
+					Return(store.Summary{}, store.ErrVersionNotFound).Once()
+			},
+			wantStatus:    http.StatusNotFound,
+			checkResponse: wantErrorCode("version_not_found"),
 		},
 		{
 			name:  "store error",
 			docID: validDocID.String(),
 			setup: func(s *store.MockStore) {
-				s.On("GetSummary", mock.Anything, validDocID).
+				s.On("GetSummary", mock.Anything, validDocID, 0).
 					Return(store.Summary{}, errors.New("db error")).Once()
 			},
-			wantStatus: http.StatusNotFound,
+			wantStatus:    http.StatusInternalServerError,
+			checkResponse: wantErrorCode(httputil.CodeInternal),
 		},
 	}
 
@@ -259,7 +498,7 @@ func TestSummaryHandler(t *testing.T) {
 			deps := newTestDeps(mockStore, mockQueue)
 			handler := summaryHandler(deps)
 
-			req := httptest.NewRequest(http.MethodGet, "/api/documents/"+tt.docID+"/summary", nil)
+			req := httptest.NewRequest(http.MethodGet, "/api/documents/"+tt.docID+"/summary"+tt.query, nil)
 			rctx := chi.NewRouteContext()
 			rctx.URLParams.Add("id", tt.docID)
 			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
@@ -284,31 +523,1785 @@ func TestSummaryHandler(t *testing.T) {
 	}
 }
 
-func createMultipartRequest(filename, contentType string, content []byte) (*http.Request, error) {
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
+func TestUploadVersionHandler(t *testing.T) {
+	docID := uuid.New()
+	liveDoc := store.Document{ID: docID, Filename: "policy.txt", Status: store.StatusReady, Version: 1, ContentHash: hashOf("Old policy")}
 
-	h := make(map[string][]string)
-	h["Content-Disposition"] = []string{fmt.Sprintf(`form-data; name="file"; filename="%s"`, filename)}
-	if contentType != "" {
-		h["Content-Type"] = []string{contentType}
+	tests := []struct {
+		name          string
+		content       string
+		force         bool
+		setup         func(*store.MockStore, *queue.MockQueue, *blobstore.MockStore)
+		wantStatus    int
+		checkResponse func(*testing.T, *http.Response)
+	}{
+		{
+			name:    "queues the new version",
+			content: "Revised policy",
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
+				s.On("GetDocument", mock.Anything, docID).Return(liveDoc, nil).Once()
+				s.On("CreateVersion", mock.Anything, docID, mock.MatchedBy(func(v store.Version) bool {
+					return v.Filename == "policy-v2.txt" && v.ContentHash == hashOf("Revised policy") && v.ContentType == extractor.TypePlainText
+				})).Return(store.Version{DocumentID: docID, Number: 2, Filename: "policy-v2.txt", ContentType: extractor.TypePlainText}, nil).Once()
+				b.On("Put", mock.Anything, blobstore.VersionKey(docID, 2), mock.Anything, int64(len("Revised policy")), extractor.TypePlainText).Return(nil).Once()
+				q.On("Enqueue", mock.Anything, mock.MatchedBy(func(task queue.Task) bool {
+					var p parseTaskPayload
+					return json.Unmarshal(task.Payload, &p) == nil && p.Version == 2 && p.BlobKey == blobstore.VersionKey(docID, 2) && !p.Reprocess
+				})).Return(nil).Once()
+			},
+			wantStatus: http.StatusAccepted,
+			checkResponse: func(t *testing.T, resp *http.Response) {
+				var result versionCreatedResponse
+				if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if result.Version != 2 || result.Status != store.StatusProcessing || result.Unchanged {
+					t.Errorf("Expected version 2 processing, got %+v", result)
+				}
+			},
+		},
+		{
+			name:    "identical file leaves the live version",
+			content: "Old policy",
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
+				s.On("GetDocument", mock.Anything, docID).Return(liveDoc, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			checkResponse: func(t *testing.T, resp *http.Response) {
+				var result versionCreatedResponse
+				if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if result.Version != 1 || !result.Unchanged {
+					t.Errorf("Expected the unchanged live version, got %+v", result)
+				}
+			},
+		},
+		{
+			name:    "document still processing",
+			content: "Revised policy",
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
+				s.On("GetDocument", mock.Anything, docID).Return(liveDoc, nil).Once()
+				s.On("CreateVersion", mock.Anything, docID, mock.Anything).Return(store.Version{}, store.ErrDocumentProcessing).Once()
+			},
+			wantStatus:    http.StatusConflict,
+			checkResponse: wantErrorCode("document_processing"),
+		},
+		{
+			name:    "enqueue failure fails the version",
+			content: "Revised policy",
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
+				s.On("GetDocument", mock.Anything, docID).Return(liveDoc, nil).Once()
+				s.On("CreateVersion", mock.Anything, docID, mock.Anything).Return(store.Version{DocumentID: docID, Number: 2}, nil).Once()
+				b.On("Put", mock.Anything, blobstore.VersionKey(docID, 2), mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+				q.On("Enqueue", mock.Anything, mock.Anything).Return(errors.New("queue error")).Times(3)
+				s.On("FailVersion", mock.Anything, docID, 2, "failed to enqueue version; please retry").Return(nil).Once()
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:    "missing document",
+			content: "Revised policy",
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
+				s.On("GetDocument", mock.Anything, docID).Return(store.Document{}, store.ErrDocumentNotFound).Once()
+			},
+			wantStatus:    http.StatusNotFound,
+			checkResponse: wantErrorCode("document_not_found"),
+		},
 	}
 
-	part, err := writer.CreatePart(h)
-	if err != nil {
-		return nil, err
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(store.MockStore)
+			mockQueue := new(queue.MockQueue)
+			mockBlobs := new(blobstore.MockStore)
+			if tt.setup != nil {
+				tt.setup(mockStore, mockQueue, mockBlobs)
+			}
+			mockStore.On("RecordStage", mock.Anything, docID, mock.Anything, store.StagePending, 0, "").Return(nil).Maybe()
+			deps := newTestDeps(mockStore, mockQueue)
+			deps.Blobs = mockBlobs
+
+			req, err := createMultipartRequest("policy-v2.txt", "text/plain", []byte(tt.content), "")
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", docID.String())
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			w := httptest.NewRecorder()
+			uploadVersionHandler(deps)(w, req)
+
+			resp := w.Result()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d. Body: %s", tt.wantStatus, resp.StatusCode, w.Body.String())
+			}
+			if tt.checkResponse != nil {
+				tt.checkResponse(t, resp)
+			}
+			mockStore.AssertExpectations(t)
+			mockQueue.AssertExpectations(t)
+			mockBlobs.AssertExpectations(t)
+		})
 	}
+}
 
-	if _, err := part.Write(content); err != nil {
-		return nil, err
+// hashOf returns the content hash the gateway records for content.
+func hashOf(content string) string {
+	hash, _, _ := hashContent(strings.NewReader(content))
+	return hash
+}
+
+func TestListVersionsHandler(t *testing.T) {
+	docID := uuid.New()
+	created := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	versions := []store.Version{
+		{DocumentID: docID, Number: 1, Filename: "policy.txt", CreatedAt: created},
+		{DocumentID: docID, Number: 2, Filename: "policy-v2.txt", CreatedAt: created.Add(time.Hour)},
+		{DocumentID: docID, Number: 3, Filename: "policy-v3.txt", CreatedAt: created.Add(2 * time.Hour)},
 	}
 
-	if err := writer.Close(); err != nil {
-		return nil, err
+	failed := slices.Clone(versions)
+	failed[2].Error = "corrupt pdf"
+
+	tests := []struct {
+		name     string
+		doc      store.Document
+		versions []store.Version
+		want     []string
+	}{
+		{
+			name: "latest version processing",
+			doc:  store.Document{ID: docID, Status: store.StatusProcessing, Version: 2},
+			want: []string{"superseded", "live", "processing"},
+		},
+		{
+			name: "latest version failed",
+			doc:  store.Document{ID: docID, Status: store.StatusFailed, Version: 2},
+			want: []string{"superseded", "live", "failed"},
+		},
+		{
+			name:     "failed version stays failed while the live one reprocesses",
+			doc:      store.Document{ID: docID, Status: store.StatusProcessing, Version: 2},
+			versions: failed,
+			want:     []string{"superseded", "live", "failed"},
+		},
+		{
+			name: "latest version live",
+			doc:  store.Document{ID: docID, Status: store.StatusReady, Version: 3},
+			want: []string{"superseded", "superseded", "live"},
+		},
 	}
 
-	req := httptest.NewRequest(http.MethodPost, "/api/documents/upload", body)
-	req.Header.Set("Content-Type", writer.FormDataContentType())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(store.MockStore)
+			mockStore.On("GetDocument", mock.Anything, docID).Return(tt.doc, nil).Once()
+			list := versions
+			if tt.versions != nil {
+				list = tt.versions
+			}
+			mockStore.On("ListVersions", mock.Anything, docID).Return(list, nil).Once()
+			deps := newTestDeps(mockStore, new(queue.MockQueue))
 
-	return req, nil
+			req := httptest.NewRequest(http.MethodGet, "/api/documents/"+docID.String()+"/versions", nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", docID.String())
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			w := httptest.NewRecorder()
+			listVersionsHandler(deps)(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+			}
+			var result versionListResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			var got []string
+			for _, v := range result.Versions {
+				got = append(got, v.Status)
+			}
+			if !slices.Equal(got, tt.want) || result.Live != tt.doc.Version {
+				t.Errorf("Expected live %d and statuses %v, got %d and %v", tt.doc.Version, tt.want, result.Live, got)
+			}
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestVersionDiffHandler(t *testing.T) {
+	docID := uuid.New()
+	v1 := store.Summary{DocumentID: docID, Version: 1, Summary: "Staff get 20 days of leave. Requests go to HR.", KeyPoints: []string{"20 days of leave", "HR approves"}}
+	v2 := store.Summary{DocumentID: docID, Version: 2, Summary: "Staff get 25 days of leave. Requests go to HR.", KeyPoints: []string{"25 days of leave", "HR approves"}}
+
+	tests := []struct {
+		name       string
+		query      string
+		setup      func(*store.MockStore)
+		wantStatus int
+		check      func(*testing.T, versionDiffResponse)
+	}{
+		{
+			name: "defaults to the live version and the one before",
+			setup: func(s *store.MockStore) {
+				s.On("GetDocument", mock.Anything, docID).Return(store.Document{ID: docID, Version: 2}, nil).Once()
+				s.On("GetSummary", mock.Anything, docID, 1).Return(v1, nil).Once()
+				s.On("GetSummary", mock.Anything, docID, 2).Return(v2, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, diff versionDiffResponse) {
+				if diff.From != 1 || diff.To != 2 {
+					t.Errorf("Expected versions 1 to 2, got %d to %d", diff.From, diff.To)
+				}
+				if !slices.Equal(diff.KeyPointsAdded, []string{"25 days of leave"}) || !slices.Equal(diff.KeyPointsRemoved, []string{"20 days of leave"}) {
+					t.Errorf("Expected the leave key point to change, got +%v -%v", diff.KeyPointsAdded, diff.KeyPointsRemoved)
+				}
+				want := []diffOpView{
+					{Op: textdiff.Removed, Text: "Staff get 20 days of leave."},
+					{Op: textdiff.Added, Text: "Staff get 25 days of leave."},
+					{Op: textdiff.Equal, Text: "Requests go to HR."},
+				}
+				if !slices.Equal(diff.Summary, want) {
+					t.Errorf("Expected summary diff %v, got %v", want, diff.Summary)
+				}
+			},
+		},
+		{
+			name:  "explicit versions",
+			query: "?from=2&to=1",
+			setup: func(s *store.MockStore) {
+				s.On("GetDocument", mock.Anything, docID).Return(store.Document{ID: docID, Version: 2}, nil).Once()
+				s.On("GetSummary", mock.Anything, docID, 2).Return(v2, nil).Once()
+				s.On("GetSummary", mock.Anything, docID, 1).Return(v1, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, diff versionDiffResponse) {
+				if diff.From != 2 || diff.To != 1 || !slices.Equal(diff.KeyPointsAdded, []string{"20 days of leave"}) {
+					t.Errorf("Expected the diff from 2 to 1, got %+v", diff)
+				}
+			},
+		},
+		{
+			name: "single version",
+			setup: func(s *store.MockStore) {
+				s.On("GetDocument", mock.Anything, docID).Return(store.Document{ID: docID, Version: 1}, nil).Once()
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "summary of a version not ready",
+			query: "?from=1&to=3",
+			setup: func(s *store.MockStore) {
+				s.On("GetDocument", mock.Anything, docID).Return(store.Document{ID: docID, Version: 2}, nil).Once()
+				s.On("GetSummary", mock.Anything, docID, 1).Return(v1, nil).Once()
+				s.On("GetSummary", mock.Anything, docID, 3).Return(store.Summary{}, store.ErrSummaryNotFound).Once()
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "invalid version",
+			query:      "?from=first",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(store.MockStore)
+			if tt.setup != nil {
+				tt.setup(mockStore)
+			}
+			deps := newTestDeps(mockStore, new(queue.MockQueue))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/documents/"+docID.String()+"/versions/diff"+tt.query, nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", docID.String())
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			w := httptest.NewRecorder()
+			versionDiffHandler(deps)(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d. Body: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.check != nil {
+				var diff versionDiffResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &diff); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				tt.check(t, diff)
+			}
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestBatchUploadHandler(t *testing.T) {
+	zipContent := createZip(t, map[string]string{
+		"docs/spec.md":         "# Spec",
+		"docs/notes.txt":       "notes",
+		"../../etc/passwd.txt": "root",
+		"__MACOSX/._spec.md":   "junk",
+		"image.png":            "png",
+	})
+
+	mockStore := new(store.MockStore)
+	mockQueue := new(queue.MockQueue)
+	mockBlobs := new(blobstore.MockStore)
+
+	var created []uuid.UUID
+	for _, name := range []string{"readme.txt", "spec.md", "notes.txt"} {
+		id := uuid.New()
+		created = append(created, id)
+		mockStore.On("CreateDocument", mock.Anything, docNamed(name)).
+			Return(store.Document{ID: id, Filename: name, Status: store.StatusProcessing}, nil).Once()
+		mockBlobs.On("Put", mock.Anything, blobstore.DocumentKey(id), mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+	}
+	mockQueue.On("Enqueue", mock.Anything, mock.Anything).Return(nil).Times(3)
+	batchID := uuid.New()
+	mockStore.On("CreateBatch", mock.Anything, mock.MatchedBy(func(ids []uuid.UUID) bool {
+		return len(ids) == 3
+	})).Return(store.Batch{ID: batchID}, nil).Once()
+	withoutDuplicates(mockStore)
+
+	deps := newTestDeps(mockStore, mockQueue)
+	deps.Blobs = mockBlobs
+
+	req, err := createBatchRequest(map[string][]byte{
+		"readme.txt": []byte("hello"),
+		"bundle.zip": zipContent,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	w := httptest.NewRecorder()
+	batchUploadHandler(deps)(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var result struct {
+		BatchID   string          `json:"batch_id"`
+		Documents []batchDocument `json:"documents"`
+		Rejected  []rejectedEntry `json:"rejected"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if result.BatchID != batchID.String() {
+		t.Errorf("Expected batch_id %s, got %s", batchID, result.BatchID)
+	}
+	if len(result.Documents) != 3 {
+		t.Errorf("Expected 3 documents, got %d", len(result.Documents))
+	}
+	rejected := map[string]string{}
+	for _, r := range result.Rejected {
+		rejected[r.Filename] = r.Error
+	}
+	if rejected["../../etc/passwd.txt"] != "invalid path in archive" {
+		t.Errorf("Expected path traversal entry to be rejected, got %v", result.Rejected)
+	}
+	if _, ok := rejected["image.png"]; !ok {
+		t.Errorf("Expected unsupported entry to be rejected, got %v", result.Rejected)
+	}
+	if len(result.Rejected) != 2 {
+		t.Errorf("Expected 2 rejected entries, got %v", result.Rejected)
+	}
+
+	mockStore.AssertExpectations(t)
+	mockQueue.AssertExpectations(t)
+	mockBlobs.AssertExpectations(t)
+}
+
+func TestBatchUploadRejectsZipBomb(t *testing.T) {
+	bomb := createZip(t, map[string]string{
+		"bomb.txt": strings.Repeat("0", 512*1024),
+	})
+
+	mockStore := new(store.MockStore)
+	mockQueue := new(queue.MockQueue)
+	deps := newTestDeps(mockStore, mockQueue)
+
+	req, err := createBatchRequest(map[string][]byte{"bomb.zip": bomb})
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	w := httptest.NewRecorder()
+	batchUploadHandler(deps)(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "suspicious compression ratio") {
+		t.Errorf("Expected compression ratio rejection, got %s", w.Body.String())
+	}
+	mockStore.AssertExpectations(t)
+}
+
+func TestFromURLHandler(t *testing.T) {
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Servers frequently mislabel documents; the extension should win.
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprint(w, "# Remote notes")
+	}))
+	defer remote.Close()
+
+	docID := uuid.New()
+	tests := []struct {
+		name       string
+		body       string
+		allowlist  []string
+		setup      func(*store.MockStore, *queue.MockQueue, *blobstore.MockStore)
+		wantStatus int
+	}{
+		{
+			name:      "successful ingest",
+			body:      fmt.Sprintf(`{"url": %q}`, remote.URL+"/files/notes.md"),
+			allowlist: []string{"127.0.0.0/8"},
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
+				s.On("CreateDocument", mock.Anything, mock.MatchedBy(func(doc store.Document) bool {
+					return doc.Filename == "notes.md" && doc.SourceURL == remote.URL+"/files/notes.md"
+				})).Return(store.Document{ID: docID, Filename: "notes.md", SourceURL: remote.URL + "/files/notes.md", Status: store.StatusProcessing}, nil)
+				b.On("Put", mock.Anything, blobstore.DocumentKey(docID), mock.Anything, int64(len("# Remote notes")), extractor.TypeMarkdown).Return(nil)
+				q.On("Enqueue", mock.Anything, mock.Anything).Return(nil)
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:       "loopback blocked by default",
+			body:       fmt.Sprintf(`{"url": %q}`, remote.URL+"/files/notes.md"),
+			setup:      func(*store.MockStore, *queue.MockQueue, *blobstore.MockStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid url",
+			body:       `{"url": "not a url"}`,
+			setup:      func(*store.MockStore, *queue.MockQueue, *blobstore.MockStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(store.MockStore)
+			mockQueue := new(queue.MockQueue)
+			mockBlobs := new(blobstore.MockStore)
+			tt.setup(mockStore, mockQueue, mockBlobs)
+			withoutDuplicates(mockStore)
+
+			deps := newTestDeps(mockStore, mockQueue)
+			deps.Blobs = mockBlobs
+			fetcher, err := urlfetch.New(urlfetch.Options{Allowlist: tt.allowlist, MaxRedirects: 3, MaxSize: deps.Config.MaxUploadSize})
+			if err != nil {
+				t.Fatalf("Failed to create fetcher: %v", err)
+			}
+			deps.Fetcher = fetcher
+
+			req := httptest.NewRequest(http.MethodPost, "/api/documents/from-url", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			fromURLHandler(deps)(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d. Body: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			mockStore.AssertExpectations(t)
+			mockQueue.AssertExpectations(t)
+			mockBlobs.AssertExpectations(t)
+		})
+	}
+}
+
+func TestListDocumentsHandler(t *testing.T) {
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	docs := make([]store.Document, 3)
+	for i := range docs {
+		docs[i] = store.Document{ID: uuid.New(), Filename: fmt.Sprintf("doc%d.pdf", i), Status: store.StatusReady, CreatedAt: base.Add(-time.Duration(i) * time.Minute)}
+	}
+	cursor := encodeCursor(store.DocumentCursor{CreatedAt: docs[1].CreatedAt, ID: docs[1].ID})
+
+	tests := []struct {
+		name       string
+		query      string
+		setup      func(*store.MockStore)
+		wantStatus int
+		wantCount  int
+		wantNext   bool
+	}{
+		{
+			name:  "first page with more remaining",
+			query: "?limit=2&status=ready&filename=doc&created_after=2025-01-01T00:00:00Z",
+			setup: func(s *store.MockStore) {
+				s.On("ListDocuments", mock.Anything, store.DocumentFilter{
+					Status:       store.StatusReady,
+					Filename:     "doc",
+					CreatedAfter: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+					Limit:        3,
+				}).Return(docs, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantCount:  2,
+			wantNext:   true,
+		},
+		{
+			name:  "last page resumes from cursor",
+			query: "?limit=2&cursor=" + cursor,
+			setup: func(s *store.MockStore) {
+				s.On("ListDocuments", mock.Anything, mock.MatchedBy(func(f store.DocumentFilter) bool {
+					return f.After != nil && f.After.ID == docs[1].ID && f.After.CreatedAt.Equal(docs[1].CreatedAt) && f.Limit == 3
+				})).Return(docs[2:], nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantCount:  1,
+		},
+		{
+			name:  "ascending sort",
+			query: "?sort=created_at",
+			setup: func(s *store.MockStore) {
+				s.On("ListDocuments", mock.Anything, store.DocumentFilter{Ascending: true, Limit: defaultListLimit + 1}).
+					Return([]store.Document{}, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:  "metadata filter",
+			query: "?metadata.team=search&metadata.project=apollo",
+			setup: func(s *store.MockStore) {
+				s.On("ListDocuments", mock.Anything, store.DocumentFilter{
+					Metadata: store.MetadataFilter{
+						{Key: "project", Op: store.MetadataEq, Values: []string{"apollo"}},
+						{Key: "team", Op: store.MetadataEq, Values: []string{"search"}},
+					},
+					Limit: defaultListLimit + 1,
+				}).Return([]store.Document{}, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "invalid metadata key",
+			query:      "?metadata.bad%20key=x",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid status",
+			query:      "?status=done",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid cursor",
+			query:      "?cursor=not-a-cursor",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid date",
+			query:      "?created_before=yesterday",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "limit out of range",
+			query:      "?limit=1000",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "store error",
+			query: "",
+			setup: func(s *store.MockStore) {
+				s.On("ListDocuments", mock.Anything, mock.Anything).Return([]store.Document(nil), errors.New("db error")).Once()
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(store.MockStore)
+			if tt.setup != nil {
+				tt.setup(mockStore)
+			}
+			deps := newTestDeps(mockStore, new(queue.MockQueue))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/documents"+tt.query, nil)
+			w := httptest.NewRecorder()
+			listDocumentsHandler(deps)(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d. Body: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK {
+				var result struct {
+					Documents  []documentView `json:"documents"`
+					NextCursor *string        `json:"next_cursor"`
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if len(result.Documents) != tt.wantCount {
+					t.Errorf("Expected %d documents, got %d", tt.wantCount, len(result.Documents))
+				}
+				if (result.NextCursor != nil) != tt.wantNext {
+					t.Errorf("Expected next_cursor present=%v, got %v", tt.wantNext, result.NextCursor)
+				}
+				if tt.wantNext && *result.NextCursor != cursor {
+					t.Errorf("Expected next_cursor to point at the last returned document")
+				}
+			}
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDeleteDocumentHandler(t *testing.T) {
+	docID := uuid.New()
+
+	tests := []struct {
+		name       string
+		docID      string
+		setup      func(*store.MockStore, *blobstore.MockStore, *cache.MockCache)
+		wantStatus int
+	}{
+		{
+			name:  "deletes rows, the file of every version and cached answers",
+			docID: docID.String(),
+			setup: func(s *store.MockStore, b *blobstore.MockStore, c *cache.MockCache) {
+				s.On("ListVersions", mock.Anything, docID).Return([]store.Version{{Number: 1}, {Number: 2}}, nil).Once()
+				s.On("DeleteDocument", mock.Anything, docID).Return(nil).Once()
+				b.On("Delete", mock.Anything, blobstore.VersionKey(docID, 2)).Return(nil).Once()
+				b.On("Delete", mock.Anything, blobstore.DocumentKey(docID)).Return(nil).Once()
+				c.On("InvalidateDocument", mock.Anything, docID.String()).Return(nil).Once()
+			},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:  "cache failure does not fail the delete",
+			docID: docID.String(),
+			setup: func(s *store.MockStore, b *blobstore.MockStore, c *cache.MockCache) {
+				s.On("ListVersions", mock.Anything, docID).Return([]store.Version{{Number: 1}}, nil).Once()
+				s.On("DeleteDocument", mock.Anything, docID).Return(nil).Once()
+				b.On("Delete", mock.Anything, blobstore.DocumentKey(docID)).Return(nil).Once()
+				c.On("InvalidateDocument", mock.Anything, docID.String()).Return(errors.New("redis down")).Once()
+			},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:  "blob failure keeps the document for a retry",
+			docID: docID.String(),
+			setup: func(s *store.MockStore, b *blobstore.MockStore, c *cache.MockCache) {
+				s.On("ListVersions", mock.Anything, docID).Return([]store.Version{{Number: 1}}, nil).Once()
+				b.On("Delete", mock.Anything, blobstore.DocumentKey(docID)).Return(errors.New("s3 error")).Once()
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:  "later version's blob failure keeps the document for a retry",
+			docID: docID.String(),
+			setup: func(s *store.MockStore, b *blobstore.MockStore, c *cache.MockCache) {
+				s.On("ListVersions", mock.Anything, docID).Return([]store.Version{{Number: 1}, {Number: 2}}, nil).Once()
+				b.On("Delete", mock.Anything, blobstore.DocumentKey(docID)).Return(nil).Once()
+				b.On("Delete", mock.Anything, blobstore.VersionKey(docID, 2)).Return(errors.New("s3 error")).Once()
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			// Blob keys are not tenant-scoped: another tenant's document looks
+			// missing and its file must be left alone
+			name:  "missing document touches no file",
+			docID: docID.String(),
+			setup: func(s *store.MockStore, b *blobstore.MockStore, c *cache.MockCache) {
+				s.On("ListVersions", mock.Anything, docID).Return(nil, store.ErrDocumentNotFound).Once()
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:  "store error",
+			docID: docID.String(),
+			setup: func(s *store.MockStore, b *blobstore.MockStore, c *cache.MockCache) {
+				s.On("ListVersions", mock.Anything, docID).Return([]store.Version{{Number: 1}}, nil).Once()
+				b.On("Delete", mock.Anything, blobstore.DocumentKey(docID)).Return(nil).Once()
+				s.On("DeleteDocument", mock.Anything, docID).Return(errors.New("db error")).Once()
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "invalid UUID",
+			docID:      "not-a-uuid",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(store.MockStore)
+			mockBlobs := new(blobstore.MockStore)
+			mockCache := new(cache.MockCache)
+			if tt.setup != nil {
+				tt.setup(mockStore, mockBlobs, mockCache)
+			}
+
+			hooks := new(webhook.MockNotifier)
+			if tt.wantStatus == http.StatusNoContent {
+				hooks.On("Notify", mock.Anything, webhook.EventDocumentDeleted, store.Document{ID: docID}, "").Once()
+			}
+
+			deps := newTestDeps(mockStore, new(queue.MockQueue))
+			deps.Blobs = mockBlobs
+			deps.Cache = mockCache
+			deps.Webhooks = hooks
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/documents/"+tt.docID, nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.docID)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			w := httptest.NewRecorder()
+			deleteDocumentHandler(deps)(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d. Body: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			mockStore.AssertExpectations(t)
+			mockBlobs.AssertExpectations(t)
+			mockCache.AssertExpectations(t)
+			hooks.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUpdateDocumentHandler(t *testing.T) {
+	docID := uuid.New()
+	existing := store.Document{ID: docID, Filename: "a.txt", Status: store.StatusReady, Metadata: map[string]string{"project": "apollo", "draft": "yes"}}
+	full := map[string]string{}
+	for i := range store.MaxMetadataKeys {
+		full[fmt.Sprintf("k%d", i)] = "v"
+	}
+
+	tests := []struct {
+		name       string
+		docID      string
+		body       string
+		setup      func(*store.MockStore)
+		wantStatus int
+		wantMeta   map[string]string
+	}{
+		{
+			name:  "sets and removes keys",
+			docID: docID.String(),
+			body:  `{"metadata":{"team":"search","draft":null}}`,
+			setup: func(s *store.MockStore) {
+				s.On("GetDocument", mock.Anything, docID).Return(existing, nil).Once()
+				s.On("UpdateDocumentMetadata", mock.Anything, docID, map[string]string{"team": "search"}, []string{"draft"}).
+					Return(store.Document{ID: docID, Filename: "a.txt", Status: store.StatusReady, Metadata: map[string]string{"project": "apollo", "team": "search"}}, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantMeta:   map[string]string{"project": "apollo", "team": "search"},
+		},
+		{
+			name:  "too many keys after merge",
+			docID: docID.String(),
+			body:  `{"metadata":{"extra":"x"}}`,
+			setup: func(s *store.MockStore) {
+				s.On("GetDocument", mock.Anything, docID).Return(store.Document{ID: docID, Metadata: full}, nil).Once()
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "value too long",
+			docID:      docID.String(),
+			body:       `{"metadata":{"note":"` + strings.Repeat("x", store.MaxMetadataValueLength+1) + `"}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "metadata is required",
+			docID:      docID.String(),
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "missing document",
+			docID: docID.String(),
+			body:  `{"metadata":{"team":"search"}}`,
+			setup: func(s *store.MockStore) {
+				s.On("GetDocument", mock.Anything, docID).Return(store.Document{}, store.ErrDocumentNotFound).Once()
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:  "store error",
+			docID: docID.String(),
+			body:  `{"metadata":{"team":"search"}}`,
+			setup: func(s *store.MockStore) {
+				s.On("GetDocument", mock.Anything, docID).Return(existing, nil).Once()
+				s.On("UpdateDocumentMetadata", mock.Anything, docID, mock.Anything, mock.Anything).
+					Return(store.Document{}, errors.New("db error")).Once()
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "invalid UUID",
+			docID:      "not-a-uuid",
+			body:       `{"metadata":{"team":"search"}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(store.MockStore)
+			if tt.setup != nil {
+				tt.setup(mockStore)
+			}
+			deps := newTestDeps(mockStore, new(queue.MockQueue))
+
+			req := httptest.NewRequest(http.MethodPatch, "/api/documents/"+tt.docID, strings.NewReader(tt.body))
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.docID)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			w := httptest.NewRecorder()
+			updateDocumentHandler(deps)(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d. Body: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantMeta != nil {
+				var view documentView
+				if err := json.Unmarshal(w.Body.Bytes(), &view); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if !maps.Equal(view.Metadata, tt.wantMeta) {
+					t.Errorf("Expected metadata %v, got %v", tt.wantMeta, view.Metadata)
+				}
+			}
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDocumentStatusHandler(t *testing.T) {
+	docID := uuid.New()
+	started := time.Date(2025, 1, 2, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		docID       string
+		setup       func(*store.MockStore)
+		wantStatus  int
+		wantCurrent string
+		wantError   string
+		wantStates  []store.StageState
+	}{
+		{
+			name:  "stuck in summarize",
+			docID: docID.String(),
+			setup: func(s *store.MockStore) {
+				s.On("GetDocument", mock.Anything, docID).Return(store.Document{ID: docID, Status: store.StatusProcessing}, nil).Once()
+				s.On("ListStages", mock.Anything, docID).Return([]store.StageStatus{
+					{Stage: store.StageParse, State: store.StageDone, Attempts: 1, StartedAt: started, CompletedAt: started},
+					{Stage: store.StageSummarize, State: store.StageRunning, Attempts: 2, LastError: "llm timeout", StartedAt: started},
+				}, nil).Once()
+			},
+			wantStatus:  http.StatusOK,
+			wantCurrent: "summarize",
+			wantStates:  []store.StageState{store.StageDone, store.StageRunning, store.StagePending},
+		},
+		{
+			name:  "failed stage error is surfaced",
+			docID: docID.String(),
+			setup: func(s *store.MockStore) {
+				s.On("GetDocument", mock.Anything, docID).Return(store.Document{ID: docID, Status: store.StatusFailed}, nil).Once()
+				s.On("ListStages", mock.Anything, docID).Return([]store.StageStatus{
+					{Stage: store.StageParse, State: store.StageFailed, Attempts: 5, LastError: "corrupt pdf"},
+				}, nil).Once()
+			},
+			wantStatus:  http.StatusOK,
+			wantCurrent: "parse",
+			wantError:   "corrupt pdf",
+			wantStates:  []store.StageState{store.StageFailed, store.StagePending, store.StagePending},
+		},
+		{
+			name:  "document not found",
+			docID: docID.String(),
+			setup: func(s *store.MockStore) {
+				s.On("GetDocument", mock.Anything, docID).Return(store.Document{}, store.ErrDocumentNotFound).Once()
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:  "stage lookup failure",
+			docID: docID.String(),
+			setup: func(s *store.MockStore) {
+				s.On("GetDocument", mock.Anything, docID).Return(store.Document{ID: docID, Status: store.StatusReady}, nil).Once()
+				s.On("ListStages", mock.Anything, docID).Return(nil, errors.New("db error")).Once()
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "invalid UUID",
+			docID:      "not-a-uuid",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(store.MockStore)
+			if tt.setup != nil {
+				tt.setup(mockStore)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/documents/"+tt.docID+"/status", nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.docID)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			w := httptest.NewRecorder()
+			documentStatusHandler(newTestDeps(mockStore, new(queue.MockQueue)))(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d. Body: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			mockStore.AssertExpectations(t)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				CurrentStage string      `json:"current_stage"`
+				Error        string      `json:"error"`
+				Stages       []stageView `json:"stages"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.CurrentStage != tt.wantCurrent {
+				t.Errorf("current_stage = %q, want %q", resp.CurrentStage, tt.wantCurrent)
+			}
+			if resp.Error != tt.wantError {
+				t.Errorf("error = %q, want %q", resp.Error, tt.wantError)
+			}
+			if len(resp.Stages) != len(tt.wantStates) {
+				t.Fatalf("got %d stages, want %d", len(resp.Stages), len(tt.wantStates))
+			}
+			for i, want := range tt.wantStates {
+				if resp.Stages[i].Stage != store.Stages[i] || resp.Stages[i].State != want {
+					t.Errorf("stage %d = %s/%s, want %s/%s", i, resp.Stages[i].Stage, resp.Stages[i].State, store.Stages[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestDocumentEventsHandler(t *testing.T) {
+	docID := uuid.New()
+
+	tests := []struct {
+		name       string
+		docID      string
+		setup      func(*store.MockStore, *events.Hub)
+		wantStatus int
+		wantEvents []string
+		wantBody   string
+	}{
+		{
+			name:  "streams transitions until ready",
+			docID: docID.String(),
+			setup: func(s *store.MockStore, hub *events.Hub) {
+				s.On("GetDocument", mock.Anything, docID).Return(store.Document{ID: docID, Status: store.StatusProcessing}, nil).Once().
+					Run(func(mock.Arguments) {
+						// The handler has subscribed by now; these arrive after the snapshot
+						for _, ev := range []events.Event{
+							{DocumentID: docID, Type: events.TypeChunked, Chunks: 12},
+							{DocumentID: docID, Type: events.TypeSummarizing},
+							{DocumentID: docID, Type: events.TypeEmbedding},
+							{DocumentID: docID, Type: events.TypeReady},
+						} {
+							hub.Dispatch(ev)
+						}
+					})
+				s.On("ListStages", mock.Anything, docID).Return([]store.StageStatus{
+					{Stage: store.StageParse, State: store.StageRunning, Attempts: 1},
+				}, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantEvents: []string{"parsing", "chunked", "summarizing", "embedding", "ready"},
+			wantBody:   `"chunks":12`,
+		},
+		{
+			name:  "ready document closes after snapshot",
+			docID: docID.String(),
+			setup: func(s *store.MockStore, hub *events.Hub) {
+				s.On("GetDocument", mock.Anything, docID).Return(store.Document{ID: docID, Status: store.StatusReady}, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantEvents: []string{"ready"},
+		},
+		{
+			name:  "failed document reports reason",
+			docID: docID.String(),
+			setup: func(s *store.MockStore, hub *events.Hub) {
+				s.On("GetDocument", mock.Anything, docID).Return(store.Document{ID: docID, Status: store.StatusFailed}, nil).Once()
+				s.On("ListStages", mock.Anything, docID).Return([]store.StageStatus{
+					{Stage: store.StageParse, State: store.StageFailed, LastError: "corrupt pdf"},
+				}, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantEvents: []string{"failed"},
+			wantBody:   `"error":"corrupt pdf"`,
+		},
+		{
+			name:  "document not found",
+			docID: docID.String(),
+			setup: func(s *store.MockStore, hub *events.Hub) {
+				s.On("GetDocument", mock.Anything, docID).Return(store.Document{}, store.ErrDocumentNotFound).Once()
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "invalid UUID",
+			docID:      "not-a-uuid",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(store.MockStore)
+			hub := events.NewHub()
+			if tt.setup != nil {
+				tt.setup(mockStore, hub)
+			}
+			deps := newTestDeps(mockStore, new(queue.MockQueue))
+			deps.Events = hub
+
+			req := httptest.NewRequest(http.MethodGet, "/api/documents/"+tt.docID+"/events", nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.docID)
+			ctx, cancel := context.WithTimeout(context.WithValue(req.Context(), chi.RouteCtxKey, rctx), 5*time.Second)
+			defer cancel()
+			req = req.WithContext(ctx)
+
+			w := httptest.NewRecorder()
+			documentEventsHandler(deps)(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d. Body: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			mockStore.AssertExpectations(t)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+				t.Errorf("Content-Type = %q, want text/event-stream", ct)
+			}
+			var got []string
+			for _, line := range strings.Split(w.Body.String(), "\n") {
+				if name, ok := strings.CutPrefix(line, "event: "); ok {
+					got = append(got, name)
+				}
+			}
+			if !slices.Equal(got, tt.wantEvents) {
+				t.Errorf("events = %v, want %v", got, tt.wantEvents)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body missing %s:\n%s", tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestReprocessHandler(t *testing.T) {
+	docID := uuid.New()
+	readyDoc := store.Document{ID: docID, Filename: "report.pdf", Status: store.StatusReady, ContentType: extractor.TypePDF}
+
+	taskMatching := func(taskType queue.TaskType, check func([]byte) bool) any {
+		return mock.MatchedBy(func(task queue.Task) bool {
+			return task.Type == taskType && check(task.Payload)
+		})
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		setup      func(*store.MockStore, *queue.MockQueue)
+		wantStatus int
+	}{
+		{
+			name: "default reruns the whole pipeline from parse",
+			body: "",
+			setup: func(s *store.MockStore, q *queue.MockQueue) {
+				s.On("GetDocument", mock.Anything, docID).Return(readyDoc, nil).Once()
+				s.On("MarkProcessing", mock.Anything, docID).Return(nil).Once()
+				q.On("Enqueue", mock.Anything, taskMatching(queue.TaskTypeParse, func(b []byte) bool {
+					var p parseTaskPayload
+					return json.Unmarshal(b, &p) == nil && p.Reprocess && p.ContentType == extractor.TypePDF && p.BlobKey == blobstore.DocumentKey(docID)
+				})).Return(nil).Once()
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name: "embed only goes straight to analysis",
+			body: `{"stages": ["embed"]}`,
+			setup: func(s *store.MockStore, q *queue.MockQueue) {
+				s.On("GetDocument", mock.Anything, docID).Return(readyDoc, nil).Once()
+				s.On("MarkProcessing", mock.Anything, docID).Return(nil).Once()
+				q.On("Enqueue", mock.Anything, taskMatching(queue.TaskTypeAnalyze, func(b []byte) bool {
+					var p analyzeTaskPayload
+					return json.Unmarshal(b, &p) == nil && len(p.Stages) == 1 && p.Stages[0] == stageEmbed
+				})).Return(nil).Once()
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name: "enqueue failure restores status",
+			body: `{"stages": ["summarize"]}`,
+			setup: func(s *store.MockStore, q *queue.MockQueue) {
+				s.On("GetDocument", mock.Anything, docID).Return(readyDoc, nil).Once()
+				s.On("MarkProcessing", mock.Anything, docID).Return(nil).Once()
+				q.On("Enqueue", mock.Anything, mock.Anything).Return(errors.New("queue error")).Times(3)
+				s.On("UpdateDocumentStatus", mock.Anything, docID, store.StatusReady).Return(nil).Once()
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "document still processing",
+			body: "",
+			setup: func(s *store.MockStore, q *queue.MockQueue) {
+				s.On("GetDocument", mock.Anything, docID).
+					Return(store.Document{ID: docID, Status: store.StatusProcessing}, nil).Once()
+				s.On("MarkProcessing", mock.Anything, docID).Return(store.ErrDocumentProcessing).Once()
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "concurrent reprocess loses the claim",
+			body: "",
+			setup: func(s *store.MockStore, q *queue.MockQueue) {
+				// Both requests saw the document ready; the store lets only one through
+				s.On("GetDocument", mock.Anything, docID).Return(readyDoc, nil).Once()
+				s.On("MarkProcessing", mock.Anything, docID).Return(store.ErrDocumentProcessing).Once()
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "unknown document",
+			body: "",
+			setup: func(s *store.MockStore, q *queue.MockQueue) {
+				s.On("GetDocument", mock.Anything, docID).Return(store.Document{}, store.ErrDocumentNotFound).Once()
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "unknown stage",
+			body:       `{"stages": ["translate"]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(store.MockStore)
+			mockQueue := new(queue.MockQueue)
+			if tt.setup != nil {
+				tt.setup(mockStore, mockQueue)
+			}
+			mockStore.On("RecordStage", mock.Anything, docID, mock.Anything, store.StagePending, 0, "").Return(nil).Maybe()
+			mockCache := new(cache.MockCache)
+			if tt.wantStatus == http.StatusAccepted {
+				mockCache.On("InvalidateDocument", mock.Anything, docID.String()).Return(nil).Once()
+			}
+			deps := newTestDeps(mockStore, mockQueue)
+			deps.Cache = mockCache
+
+			req := httptest.NewRequest(http.MethodPost, "/api/documents/"+docID.String()+"/reprocess", strings.NewReader(tt.body))
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", docID.String())
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			w := httptest.NewRecorder()
+			reprocessHandler(deps)(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d. Body: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			mockStore.AssertExpectations(t)
+			mockQueue.AssertExpectations(t)
+			mockCache.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAdminReprocessHandler(t *testing.T) {
+	page := make([]store.Document, maxListLimit)
+	for i := range page {
+		page[i] = store.Document{ID: uuid.New(), Status: store.StatusReady, CreatedAt: time.Now().Add(-time.Duration(i) * time.Second)}
+	}
+	page[0].Status = store.StatusProcessing
+	last := store.Document{ID: uuid.New(), Status: store.StatusFailed, CreatedAt: time.Now().Add(-time.Hour)}
+
+	mockStore := new(store.MockStore)
+	mockQueue := new(queue.MockQueue)
+	mockStore.On("ListDocuments", mock.Anything, mock.MatchedBy(func(f store.DocumentFilter) bool {
+		return f.After == nil && f.Filename == "report"
+	})).Return(page, nil).Once()
+	mockStore.On("ListDocuments", mock.Anything, mock.MatchedBy(func(f store.DocumentFilter) bool {
+		return f.After != nil && f.After.ID == page[len(page)-1].ID
+	})).Return([]store.Document{last}, nil).Once()
+	mockStore.On("MarkProcessing", mock.Anything, page[0].ID).Return(store.ErrDocumentProcessing).Once()
+	mockStore.On("MarkProcessing", mock.Anything, mock.Anything).Return(nil).Times(maxListLimit)
+	mockQueue.On("Enqueue", mock.Anything, mock.MatchedBy(func(task queue.Task) bool {
+		return task.Type == queue.TaskTypeAnalyze
+	})).Return(nil).Times(maxListLimit)
+	mockStore.On("RecordStage", mock.Anything, mock.Anything, store.StageEmbed, store.StagePending, 0, "").Return(nil).Times(maxListLimit)
+
+	deps := newTestDeps(mockStore, mockQueue)
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/documents/reprocess?filename=report", strings.NewReader(`{"stages": ["embed"]}`))
+	w := httptest.NewRecorder()
+	adminReprocessHandler(deps)(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var result struct {
+		Queued  int `json:"queued"`
+		Skipped int `json:"skipped"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if result.Queued != maxListLimit || result.Skipped != 1 {
+		t.Errorf("Expected %d queued and 1 skipped, got %+v", maxListLimit, result)
+	}
+	mockStore.AssertExpectations(t)
+	mockQueue.AssertExpectations(t)
+}
+
+func TestCreateWebhookHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		setup      func(*store.MockStore)
+		wantStatus int
+	}{
+		{
+			name: "creates webhook and returns secret once",
+			body: `{"url":"https://hooks.example.com/doc","events":["document.ready","document.failed","document.ready"]}`,
+			setup: func(s *store.MockStore) {
+				s.On("CreateWebhook", mock.Anything, mock.MatchedBy(func(h store.Webhook) bool {
+					return h.URL == "https://hooks.example.com/doc" &&
+						slices.Equal(h.Events, []string{"document.failed", "document.ready"}) &&
+						strings.HasPrefix(h.Secret, "whsec_")
+				})).Return(store.Webhook{
+					ID:        uuid.New(),
+					URL:       "https://hooks.example.com/doc",
+					Secret:    "whsec_stored",
+					Events:    []string{"document.failed", "document.ready"},
+					CreatedAt: time.Now(),
+				}, nil).Once()
+			},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "unknown event",
+			body:       `{"url":"https://hooks.example.com/doc","events":["document.updated"]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "no events",
+			body:       `{"url":"https://hooks.example.com/doc","events":[]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "non-http url",
+			body:       `{"url":"ftp://hooks.example.com/doc","events":["document.ready"]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "store error",
+			body: `{"url":"https://hooks.example.com/doc","events":["document.ready"]}`,
+			setup: func(s *store.MockStore) {
+				s.On("CreateWebhook", mock.Anything, mock.Anything).Return(store.Webhook{}, errors.New("db error")).Once()
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(store.MockStore)
+			if tt.setup != nil {
+				tt.setup(mockStore)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/api/webhooks", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			createWebhookHandler(newTestDeps(mockStore, new(queue.MockQueue)))(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d. Body: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantStatus == http.StatusCreated {
+				var resp webhookView
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("decode response: %v", err)
+				}
+				if resp.ID == "" || !strings.HasPrefix(resp.Secret, "whsec_") {
+					t.Errorf("response missing id or secret: %s", w.Body.String())
+				}
+			}
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestWebhookDeliveriesHandler(t *testing.T) {
+	hookID := uuid.New()
+
+	tests := []struct {
+		name       string
+		query      string
+		setup      func(*store.MockStore)
+		wantStatus int
+		wantCount  int
+	}{
+		{
+			name: "lists delivery log",
+			setup: func(s *store.MockStore) {
+				s.On("GetWebhook", mock.Anything, hookID).Return(store.Webhook{ID: hookID}, nil).Once()
+				s.On("ListWebhookDeliveries", mock.Anything, hookID, defaultDeliveryLimit).Return([]store.WebhookDelivery{
+					{ID: uuid.New(), WebhookID: hookID, Event: "document.ready", Attempt: 2, StatusCode: 200},
+					{ID: uuid.New(), WebhookID: hookID, Event: "document.ready", Attempt: 1, StatusCode: 503, Error: "receiver returned 503"},
+				}, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantCount:  2,
+		},
+		{
+			name: "unknown webhook",
+			setup: func(s *store.MockStore) {
+				s.On("GetWebhook", mock.Anything, hookID).Return(store.Webhook{}, store.ErrWebhookNotFound).Once()
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "invalid limit",
+			query:      "?limit=0",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(store.MockStore)
+			if tt.setup != nil {
+				tt.setup(mockStore)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/webhooks/"+hookID.String()+"/deliveries"+tt.query, nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", hookID.String())
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			w := httptest.NewRecorder()
+			webhookDeliveriesHandler(newTestDeps(mockStore, new(queue.MockQueue)))(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d. Body: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK {
+				var resp struct {
+					Deliveries []deliveryView `json:"deliveries"`
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("decode response: %v", err)
+				}
+				if len(resp.Deliveries) != tt.wantCount {
+					t.Fatalf("got %d deliveries, want %d", len(resp.Deliveries), tt.wantCount)
+				}
+				if !resp.Deliveries[0].Success || resp.Deliveries[1].Success {
+					t.Errorf("success flags wrong: %+v", resp.Deliveries)
+				}
+			}
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCreateAPIKeyHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		setup      func(*store.MockStore)
+		wantStatus int
+	}{
+		{
+			name: "creates key and returns it once",
+			body: `{"name":"ci","scopes":["upload","read","upload"],"rate_limit":120}`,
+			setup: func(s *store.MockStore) {
+				s.On("CreateAPIKey", mock.Anything, mock.MatchedBy(func(k store.APIKey) bool {
+					return k.Name == "ci" && k.RateLimit == 120 &&
+						slices.Equal(k.Scopes, []string{"read", "upload"}) &&
+						strings.HasPrefix(k.Prefix, auth.KeyPrefix) && len(k.Hash) == 64
+				})).Return(store.APIKey{
+					ID:        uuid.New(),
+					Name:      "ci",
+					Prefix:    "dak_0123abcd",
+					Scopes:    []string{"read", "upload"},
+					RateLimit: 120,
+					CreatedAt: time.Now(),
+				}, nil).Once()
+			},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "unknown scope",
+			body:       `{"name":"ci","scopes":["delete"]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "no scopes",
+			body:       `{"name":"ci","scopes":[]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing name",
+			body:       `{"scopes":["read"]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "negative rate limit",
+			body:       `{"name":"ci","scopes":["read"],"rate_limit":-1}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "store error",
+			body: `{"name":"ci","scopes":["read"]}`,
+			setup: func(s *store.MockStore) {
+				s.On("CreateAPIKey", mock.Anything, mock.Anything).Return(store.APIKey{}, errors.New("db error")).Once()
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(store.MockStore)
+			if tt.setup != nil {
+				tt.setup(mockStore)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/api/keys", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			createAPIKeyHandler(newTestDeps(mockStore, new(queue.MockQueue)))(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d. Body: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantStatus == http.StatusCreated {
+				var resp apiKeyView
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("decode response: %v", err)
+				}
+				if resp.ID == "" || !strings.HasPrefix(resp.Key, auth.KeyPrefix) {
+					t.Errorf("response missing id or key: %s", w.Body.String())
+				}
+			}
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRevokeAPIKeyHandler(t *testing.T) {
+	keyID := uuid.New()
+
+	tests := []struct {
+		name       string
+		id         string
+		setup      func(*store.MockStore)
+		wantStatus int
+	}{
+		{
+			name: "revokes key",
+			id:   keyID.String(),
+			setup: func(s *store.MockStore) {
+				s.On("RevokeAPIKey", mock.Anything, keyID).Return(nil).Once()
+			},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name: "unknown key",
+			id:   keyID.String(),
+			setup: func(s *store.MockStore) {
+				s.On("RevokeAPIKey", mock.Anything, keyID).Return(store.ErrAPIKeyNotFound).Once()
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "invalid id",
+			id:         "not-a-uuid",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(store.MockStore)
+			if tt.setup != nil {
+				tt.setup(mockStore)
+			}
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/keys/"+tt.id, nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.id)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+			revokeAPIKeyHandler(newTestDeps(mockStore, new(queue.MockQueue)))(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d. Body: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestBatchStatusHandler(t *testing.T) {
+	batchID := uuid.New()
+
+	tests := []struct {
+		name       string
+		batchID    string
+		setup      func(*store.MockStore)
+		wantStatus int
+		wantBody   map[string]any
+	}{
+		{
+			name:    "aggregates document statuses",
+			batchID: batchID.String(),
+			setup: func(s *store.MockStore) {
+				s.On("GetBatch", mock.Anything, batchID).Return(store.Batch{
+					ID: batchID,
+					Documents: []store.Document{
+						{ID: uuid.New(), Filename: "a.txt", Status: store.StatusReady},
+						{ID: uuid.New(), Filename: "b.txt", Status: store.StatusFailed},
+						{ID: uuid.New(), Filename: "c.txt", Status: store.StatusProcessing},
+						{ID: uuid.New(), Filename: "d.txt", Status: store.StatusReady},
+					},
+				}, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantBody: map[string]any{
+				"status": "processing", "total": float64(4), "ready": float64(2),
+				"failed": float64(1), "processing": float64(1), "progress": 0.75,
+			},
+		},
+		{
+			name:    "completed with errors",
+			batchID: batchID.String(),
+			setup: func(s *store.MockStore) {
+				s.On("GetBatch", mock.Anything, batchID).Return(store.Batch{
+					ID: batchID,
+					Documents: []store.Document{
+						{ID: uuid.New(), Filename: "a.txt", Status: store.StatusReady},
+						{ID: uuid.New(), Filename: "b.txt", Status: store.StatusFailed},
+					},
+				}, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   map[string]any{"status": "completed_with_errors", "progress": float64(1)},
+		},
+		{
+			name:    "unknown batch",
+			batchID: batchID.String(),
+			setup: func(s *store.MockStore) {
+				s.On("GetBatch", mock.Anything, batchID).Return(store.Batch{}, store.ErrBatchNotFound).Once()
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "invalid id",
+			batchID:    "nope",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(store.MockStore)
+			if tt.setup != nil {
+				tt.setup(mockStore)
+			}
+			deps := newTestDeps(mockStore, new(queue.MockQueue))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/batches/"+tt.batchID, nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.batchID)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			w := httptest.NewRecorder()
+			batchStatusHandler(deps)(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d. Body: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantBody != nil {
+				var result map[string]any
+				if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				for k, want := range tt.wantBody {
+					if result[k] != want {
+						t.Errorf("Expected %s=%v, got %v", k, want, result[k])
+					}
+				}
+			}
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
+
+func createZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, content := range files {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func createBatchRequest(files map[string][]byte) (*http.Request, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for name, content := range files {
+		part, err := writer.CreateFormFile("files", name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write(content); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/documents/batch", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req, nil
+}
+
+// withoutDuplicates makes every content-hash lookup miss unless the test
+// registered a more specific expectation first.
+func withoutDuplicates(s *store.MockStore) {
+	s.On("FindDocumentByHash", mock.Anything, mock.Anything).Return(store.Document{}, store.ErrDocumentNotFound).Maybe()
+}
+
+// docNamed matches the document passed to Store.CreateDocument by filename.
+func docNamed(filename string) any {
+	return mock.MatchedBy(func(doc store.Document) bool {
+		return doc.Filename == filename
+	})
+}
+
+func createMultipartRequest(filename, contentType string, content []byte, metadata string) (*http.Request, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	if metadata != "" {
+		if err := writer.WriteField("metadata", metadata); err != nil {
+			return nil, err
+		}
+	}
+
+	h := make(map[string][]string)
+	h["Content-Disposition"] = []string{fmt.Sprintf(`form-data; name="file"; filename="%s"`, filename)}
+	if contentType != "" {
+		h["Content-Type"] = []string{contentType}
+	}
+
+	part, err := writer.CreatePart(h)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := part.Write(content); err != nil {
+		return nil, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/documents/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	return req, nil
+}
+
+func TestQueryHandler(t *testing.T) {
+	var got http.Header
+	upstreamSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"answer": "42"}`)
+	}))
+	defer upstreamSrv.Close()
+
+	deps := newTestDeps(new(store.MockStore), new(queue.MockQueue))
+	deps.Config.InternalToken = "internal-secret"
+	pool, err := upstream.New([]string{upstreamSrv.URL}, upstream.Options{Log: deps.Log})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deps.Query = pool
+
+	handler := middleware.RequestID(queryHandler(deps))
+	req := httptest.NewRequest(http.MethodPost, "/api/query", strings.NewReader(`{"question": "why?"}`))
+	req.Header.Set(middleware.RequestIDHeader, "req-123")
+	req = req.WithContext(tenant.WithID(req.Context(), "acme"))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Body.String() != `{"answer": "42"}` {
+		t.Fatalf("got %d %q", w.Code, w.Body.String())
+	}
+	for header, want := range map[string]string{
+		middleware.RequestIDHeader:   "req-123",
+		tenant.Header:                "acme",
+		httputil.InternalTokenHeader: "internal-secret",
+	} {
+		if got.Get(header) != want {
+			t.Errorf("upstream got %s %q, want %q", header, got.Get(header), want)
+		}
+	}
+
+	// With every replica down the caller gets a structured 503
+	upstreamSrv.Close()
+	pool.Check(context.Background())
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/query", strings.NewReader(`{}`)))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", w.Code)
+	}
+	var resp map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp["code"] != "query_unavailable" || resp["replicas"] != float64(1) || resp["healthy_replicas"] != float64(0) {
+		t.Errorf("unexpected 503 body %v", resp)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("503 without Retry-After")
+	}
 }
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"doc-agents/internal/app"
+	"doc-agents/internal/blobstore"
+	"doc-agents/internal/events"
+	"doc-agents/internal/httputil"
+	"doc-agents/internal/queue"
+	"doc-agents/internal/store"
+)
+
+// Pipeline stages that can be rerun for an existing document.
+const (
+	stageParse     = string(store.StageParse)
+	stageSummarize = string(store.StageSummarize)
+	stageEmbed     = string(store.StageEmbed)
+)
+
+type analyzeTaskPayload struct {
+	DocumentID string   `json:"document_id"`
+	Stages     []string `json:"stages,omitempty"`
+}
+
+type reprocessRequest struct {
+	Stages []string `json:"stages" validate:"omitempty,dive,oneof=parse summarize embed" doc:"Stages to rerun; empty reruns all of them, and parse implies the rest."`
+}
+
+type reprocessResponse struct {
+	DocumentID string               `json:"document_id"`
+	Status     store.DocumentStatus `json:"status"`
+	Stages     []string             `json:"stages"`
+}
+
+type bulkReprocessResponse struct {
+	Stages      []string `json:"stages"`
+	Queued      int      `json:"queued"`
+	Skipped     int      `json:"skipped" doc:"Documents still processing or deleted meanwhile."`
+	Failed      int      `json:"failed"`
+	DocumentIDs []string `json:"document_ids" doc:"Documents queued for reprocessing."`
+}
+
+// normalizedStages expands the requested stages: nothing means everything,
+// and re-parsing produces new chunks that must be summarized and embedded.
+func (r reprocessRequest) normalizedStages() []string {
+	if len(r.Stages) == 0 || slices.Contains(r.Stages, stageParse) {
+		return []string{stageParse, stageSummarize, stageEmbed}
+	}
+	var stages []string
+	for _, stage := range []string{stageSummarize, stageEmbed} {
+		if slices.Contains(r.Stages, stage) {
+			stages = append(stages, stage)
+		}
+	}
+	return stages
+}
+
+// decodeReprocessRequest reads an optional JSON body; an empty body reruns every stage.
+func decodeReprocessRequest(r *http.Request) (reprocessRequest, error) {
+	var req reprocessRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		return req, err
+	}
+	return req, nil
+}
+
+// reprocessHandler reruns pipeline stages for one document, e.g. after
+// changing chunking parameters or EMBEDDING_MODEL.
+func reprocessHandler(deps app.GatewayDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		docID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "invalid document id", err, http.StatusBadRequest)
+			return
+		}
+
+		req, err := decodeReprocessRequest(r)
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "invalid payload", err, http.StatusBadRequest)
+			return
+		}
+		if err := httputil.Validator.Struct(&req); err != nil {
+			httputil.ValidationError(deps.Log, w, r, err)
+			return
+		}
+
+		doc, err := deps.Store.GetDocument(ctx, docID)
+		if errors.Is(err, store.ErrDocumentNotFound) {
+			httputil.Fail(deps.Log, w, r, "document not found", err, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "failed to load document", err, http.StatusInternalServerError)
+			return
+		}
+
+		stages := req.normalizedStages()
+		if err := reprocessDocument(ctx, deps, doc, stages); err != nil {
+			if errors.Is(err, store.ErrDocumentProcessing) {
+				httputil.Fail(deps.Log, w, r, err.Error(), err, http.StatusConflict)
+				return
+			}
+			if errors.Is(err, store.ErrDocumentNotFound) {
+				httputil.Fail(deps.Log, w, r, "document not found", err, http.StatusNotFound)
+				return
+			}
+			httputil.Fail(deps.Log.With("document_id", docID), w, r, "failed to enqueue reprocessing; please retry", err, http.StatusInternalServerError)
+			return
+		}
+
+		httputil.WriteJSON(w, http.StatusAccepted, reprocessResponse{
+			DocumentID: doc.ID.String(),
+			Status:     store.StatusProcessing,
+			Stages:     stages,
+		})
+	}
+}
+
+// adminReprocessHandler reruns stages for every document matching the
+// listing filters (status, filename, created_after, created_before query
+// parameters). Documents still processing, or deleted meanwhile, are skipped.
+func adminReprocessHandler(deps app.GatewayDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		filter, err := parseDocumentFilter(r)
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, err.Error(), err, http.StatusBadRequest)
+			return
+		}
+		req, err := decodeReprocessRequest(r)
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "invalid payload", err, http.StatusBadRequest)
+			return
+		}
+		if err := httputil.Validator.Struct(&req); err != nil {
+			httputil.ValidationError(deps.Log, w, r, err)
+			return
+		}
+		stages := req.normalizedStages()
+
+		queued := []string{}
+		var skipped, failed int
+		filter.Limit = maxListLimit
+		for {
+			docs, err := deps.Store.ListDocuments(ctx, filter)
+			if err != nil {
+				httputil.Fail(deps.Log, w, r, "failed to list documents", err, http.StatusInternalServerError)
+				return
+			}
+			for _, doc := range docs {
+				err := reprocessDocument(ctx, deps, doc, stages)
+				switch {
+				case errors.Is(err, store.ErrDocumentProcessing), errors.Is(err, store.ErrDocumentNotFound):
+					skipped++
+				case err != nil:
+					deps.Log.Error("failed to enqueue reprocessing", "document_id", doc.ID, "err", err)
+					failed++
+				default:
+					queued = append(queued, doc.ID.String())
+				}
+			}
+			if len(docs) < filter.Limit {
+				break
+			}
+			last := docs[len(docs)-1]
+			filter.After = &store.DocumentCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+		}
+
+		deps.Log.Info("bulk reprocess enqueued", "queued", len(queued), "skipped", skipped, "failed", failed, "stages", stages)
+		httputil.WriteJSON(w, http.StatusAccepted, bulkReprocessResponse{
+			Stages:      stages,
+			Queued:      len(queued),
+			Skipped:     skipped,
+			Failed:      failed,
+			DocumentIDs: queued,
+		})
+	}
+}
+
+// reprocessDocument moves doc back to processing and enqueues the first
+// stage to rerun. Re-parsing stages new chunks that analysis swaps in
+// atomically; summarize/embed alone reuse the live chunks. If the task
+// cannot be enqueued the previous status is restored. Fails with
+// store.ErrDocumentProcessing if a run is already in progress.
+func reprocessDocument(ctx context.Context, deps app.GatewayDeps, doc store.Document, stages []string) error {
+	var task queue.Task
+	if slices.Contains(stages, stageParse) {
+		body, err := json.Marshal(parseTaskPayload{
+			DocumentID:  doc.ID,
+			Filename:    doc.Filename,
+			ContentType: doc.ContentType,
+			BlobKey:     blobstore.VersionKey(doc.ID, doc.Version),
+			Reprocess:   true,
+		})
+		if err != nil {
+			return err
+		}
+		task = queue.Task{Type: queue.TaskTypeParse, Payload: body}
+	} else {
+		body, err := json.Marshal(analyzeTaskPayload{DocumentID: doc.ID.String(), Stages: stages})
+		if err != nil {
+			return err
+		}
+		task = queue.Task{Type: queue.TaskTypeAnalyze, Payload: body}
+	}
+
+	// Claimed atomically: a concurrent run would replace this run's staged chunks
+	if err := deps.Store.MarkProcessing(ctx, doc.ID); err != nil {
+		return err
+	}
+	// Reset the rerun stages so the status endpoint reflects the new run
+	for _, stage := range stages {
+		if err := deps.Store.RecordStage(ctx, doc.ID, store.Stage(stage), store.StagePending, 0, ""); err != nil {
+			deps.Log.Warn("failed to reset stage progress", "document_id", doc.ID, "stage", stage, "err", err)
+		}
+	}
+	if err := queue.EnqueueWithRetry(ctx, deps.Queue, task, 3, 200*time.Millisecond); err != nil {
+		if upErr := deps.Store.UpdateDocumentStatus(ctx, doc.ID, doc.Status); upErr != nil {
+			deps.Log.Error("failed to restore document status", "document_id", doc.ID, "err", upErr)
+		}
+		return err
+	}
+	// Cached answers may cite chunks the new run replaces
+	if err := deps.Cache.InvalidateDocument(ctx, doc.ID.String()); err != nil {
+		deps.Log.Error("failed to invalidate cached queries", "document_id", doc.ID, "err", err)
+	}
+	publishEvent(ctx, deps, events.Event{DocumentID: doc.ID, Type: events.TypeQueued})
+	return nil
+}
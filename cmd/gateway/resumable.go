@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"doc-agents/internal/app"
+	"doc-agents/internal/store"
+	"doc-agents/internal/tenant"
+	"doc-agents/internal/tus"
+)
+
+// newResumableUploadHandler serves tus uploads at /api/uploads. Clients send the
+// original filename and MIME type as "filename" and "filetype" metadata (and
+// optionally "force" to skip deduplication and "metadata" holding the document
+// metadata as a JSON object); once the final byte arrives the file goes
+// through the same ingestion path as a regular multipart upload.
+func newResumableUploadHandler(deps app.GatewayDeps) (*tus.Handler, error) {
+	return tus.NewHandler(tus.Options{
+		BasePath: "/api/uploads",
+		Dir:      deps.Config.TusDir,
+		MaxSize:  maxResumableUploadSize(deps),
+		Expiry:   time.Duration(deps.Config.TusUploadExpiry) * time.Second,
+		Validate: func(u tus.Upload) (int, error) {
+			_, status, err := validateResumableUpload(deps, u)
+			return status, err
+		},
+		OnComplete: func(ctx context.Context, u tus.Upload, content io.Reader) (string, error) {
+			contentType, _, err := validateResumableUpload(deps, u)
+			if err != nil {
+				return "", err
+			}
+			metadata, err := parseMetadataField(u.Metadata["metadata"])
+			if err != nil {
+				return "", err
+			}
+			force, _ := strconv.ParseBool(u.Metadata["force"])
+			newDoc := store.Document{Filename: u.Metadata["filename"], Metadata: metadata}
+			doc, duplicate, err := ingestDocument(ctx, deps, newDoc, contentType, content, u.Size, force)
+			if err != nil {
+				return "", err
+			}
+			deps.Log.Info("resumable upload completed", "upload_id", u.ID, "document_id", doc.ID, "duplicate", duplicate)
+			return doc.ID.String(), nil
+		},
+		// Uploads belong to the tenant that created them
+		Owner: func(r *http.Request) string {
+			id, _ := tenant.FromContext(r.Context())
+			return id
+		},
+		Log: deps.Log,
+	})
+}
+
+// validateResumableUpload applies the regular upload rules to a tus upload.
+func validateResumableUpload(deps app.GatewayDeps, u tus.Upload) (string, int, error) {
+	filename := u.Metadata["filename"]
+	if filename == "" {
+		return "", http.StatusBadRequest, errors.New("filename metadata is required")
+	}
+	// Reject bad document metadata at creation rather than after the upload
+	if _, err := parseMetadataField(u.Metadata["metadata"]); err != nil {
+		return "", http.StatusBadRequest, err
+	}
+	return validateFile(filename, u.Metadata["filetype"], u.Size, maxResumableUploadSize(deps), deps.Extractors)
+}
+
+// maxResumableUploadSize is the largest tus upload accepted: files the
+// parser would refuse to load are rejected before they are uploaded.
+func maxResumableUploadSize(deps app.GatewayDeps) int64 {
+	return min(deps.Config.MaxResumableUploadSize, deps.Config.MaxParseSize)
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"doc-agents/internal/app"
+	"doc-agents/internal/auth"
+	"doc-agents/internal/httputil"
+	"doc-agents/internal/openapi"
+	"doc-agents/internal/queryapi"
+	"doc-agents/internal/tenant"
+	"doc-agents/internal/tus"
+)
+
+// apiDescription is the introduction of the OpenAPI document.
+const apiDescription = `Upload documents, follow their processing and ask questions about them.
+
+Resumable uploads under /api/uploads follow the tus 1.0 protocol and are not
+described here; the gRPC API mirrors these endpoints.`
+
+// mountRoutes registers every route of the gateway on r and returns the
+// OpenAPI document describing them. API routes are mounted through the
+// document, which validates their requests before the handlers run.
+func mountRoutes(r chi.Router, deps app.GatewayDeps, authn *auth.Authenticator, uploads *tus.Handler) *openapi.Spec {
+	spec := openapi.New(openapi.Info{
+		Title:       "doc-agents gateway",
+		Version:     "1.0.0",
+		Description: apiDescription,
+	}, map[string]openapi.SecurityScheme{
+		"bearer": {Type: "http", Scheme: "bearer", Description: "API key or OIDC access token."},
+		"apiKey": {Type: "apiKey", In: "header", Name: auth.KeyHeader},
+	})
+	spec.OnInvalid(func(w http.ResponseWriter, r *http.Request, err *openapi.ValidationError) {
+		if err.Status != http.StatusBadRequest {
+			httputil.Fail(deps.Log, w, r, err.Error(), err, err.Status)
+			return
+		}
+		fields := make([]httputil.FieldError, len(err.Fields))
+		for i, f := range err.Fields {
+			fields[i] = httputil.FieldError{Field: f.Field, Message: f.Message}
+		}
+		httputil.InvalidFields(deps.Log, w, r, fields)
+	})
+	spec.ErrorResponse(httputil.ErrorResponse{})
+
+	// Every API route acts for one tenant; the store, cache and queue stay scoped to it
+	scoped := r.With(authn.Middleware, tenant.Middleware(deps.Log, deps.Config.DefaultTenant))
+	api := scoped.With(httputil.Timeout(httputil.RequestTimeout))
+
+	read := api.With(authn.Require(auth.ScopeRead))
+	spec.Route(read, http.MethodGet, "/api/documents", openapi.Op{
+		ID:          "listDocuments",
+		Summary:     "List documents",
+		Description: "Newest first unless sort=created_at. metadata.<key>=<value> parameters match exact metadata values.",
+		Tag:         "documents",
+		Scope:       string(auth.ScopeRead),
+		Query:       listDocumentsQuery{},
+		Responses:   map[int]any{http.StatusOK: documentListResponse{}},
+	}, listDocumentsHandler(deps))
+	spec.Route(read, http.MethodGet, "/api/batches/{id}", openapi.Op{
+		ID:        "getBatch",
+		Summary:   "Report the progress of a batch",
+		Tag:       "documents",
+		Scope:     string(auth.ScopeRead),
+		Responses: map[int]any{http.StatusOK: batchStatusResponse{}},
+	}, batchStatusHandler(deps))
+	spec.Route(read, http.MethodGet, "/api/documents/{id}/status", openapi.Op{
+		ID:        "getDocumentStatus",
+		Summary:   "Report a document's status and pipeline stages",
+		Tag:       "documents",
+		Scope:     string(auth.ScopeRead),
+		Responses: map[int]any{http.StatusOK: documentStatusResponse{}},
+	}, documentStatusHandler(deps))
+	spec.Route(read, http.MethodGet, "/api/documents/{id}/summary", openapi.Op{
+		ID:        "getDocumentSummary",
+		Summary:   "Get the summary of a processed document",
+		Tag:       "documents",
+		Scope:     string(auth.ScopeRead),
+		Query:     summaryQuery{},
+		Responses: map[int]any{http.StatusOK: summaryResponse{}},
+	}, summaryHandler(deps))
+	spec.Route(read, http.MethodGet, "/api/documents/{id}/versions", openapi.Op{
+		ID:        "listDocumentVersions",
+		Summary:   "List the versions of a document",
+		Tag:       "documents",
+		Scope:     string(auth.ScopeRead),
+		Responses: map[int]any{http.StatusOK: versionListResponse{}},
+	}, listVersionsHandler(deps))
+	spec.Route(read, http.MethodGet, "/api/documents/{id}/versions/diff", openapi.Op{
+		ID:          "diffDocumentVersions",
+		Summary:     "Compare the summaries of two versions of a document",
+		Description: "Without parameters, compares the live version with the one before it.",
+		Tag:         "documents",
+		Scope:       string(auth.ScopeRead),
+		Query:       versionDiffQuery{},
+		Responses:   map[int]any{http.StatusOK: versionDiffResponse{}},
+	}, versionDiffHandler(deps))
+
+	upload := api.With(authn.Require(auth.ScopeUpload))
+	spec.Route(upload, http.MethodPost, "/api/documents/upload", openapi.Op{
+		ID:      "uploadDocument",
+		Summary: "Upload a document",
+		Tag:     "documents",
+		Scope:   string(auth.ScopeUpload),
+		Query:   forceQuery{},
+		Form:    uploadForm{},
+		Responses: map[int]any{
+			http.StatusAccepted: ingestedResponse{},
+			http.StatusOK:       ingestedResponse{},
+		},
+	}, uploadHandler(deps))
+	spec.Route(upload, http.MethodPost, "/api/documents/batch", openapi.Op{
+		ID:          "uploadBatch",
+		Summary:     "Upload several documents or ZIP archives",
+		Description: "Each file, and each member of a ZIP archive, becomes its own document.",
+		Tag:         "documents",
+		Scope:       string(auth.ScopeUpload),
+		Query:       forceQuery{},
+		Form:        batchForm{},
+		Responses: map[int]any{
+			http.StatusAccepted:   batchResponse{},
+			http.StatusBadRequest: batchRejectedResponse{},
+		},
+	}, batchUploadHandler(deps))
+	spec.Route(upload, http.MethodPost, "/api/documents/from-url", openapi.Op{
+		ID:      "ingestFromURL",
+		Summary: "Download a document from a URL and ingest it",
+		Tag:     "documents",
+		Scope:   string(auth.ScopeUpload),
+		Query:   forceQuery{},
+		Body:    fromURLRequest{},
+		Responses: map[int]any{
+			http.StatusAccepted: fromURLResponse{},
+			http.StatusOK:       fromURLResponse{},
+		},
+	}, fromURLHandler(deps))
+	spec.Route(upload, http.MethodPost, "/api/documents/{id}/versions", openapi.Op{
+		ID:          "uploadDocumentVersion",
+		Summary:     "Upload a new version of a document",
+		Description: "Earlier versions keep their summaries and chunks. Queries switch to the new version once it is processed.",
+		Tag:         "documents",
+		Scope:       string(auth.ScopeUpload),
+		Query:       forceQuery{},
+		Form:        versionForm{},
+		Responses: map[int]any{
+			http.StatusAccepted: versionCreatedResponse{},
+			http.StatusOK:       versionCreatedResponse{},
+		},
+	}, uploadVersionHandler(deps))
+	spec.Route(upload, http.MethodPatch, "/api/documents/{id}", openapi.Op{
+		ID:          "updateDocument",
+		Summary:     "Change a document's metadata",
+		Description: "A JSON merge patch: a string sets the key, null removes it, and keys not mentioned are kept.",
+		Tag:         "documents",
+		Scope:       string(auth.ScopeUpload),
+		Body:        updateDocumentRequest{},
+		Responses:   map[int]any{http.StatusOK: documentView{}},
+	}, updateDocumentHandler(deps))
+	spec.Route(upload, http.MethodDelete, "/api/documents/{id}", openapi.Op{
+		ID:        "deleteDocument",
+		Summary:   "Delete a document, its original file and cached answers",
+		Tag:       "documents",
+		Scope:     string(auth.ScopeUpload),
+		Responses: map[int]any{http.StatusNoContent: nil},
+	}, deleteDocumentHandler(deps))
+	spec.Route(upload, http.MethodPost, "/api/documents/{id}/reprocess", openapi.Op{
+		ID:           "reprocessDocument",
+		Summary:      "Rerun pipeline stages for a document",
+		Tag:          "documents",
+		Scope:        string(auth.ScopeUpload),
+		Body:         reprocessRequest{},
+		BodyOptional: true,
+		Responses:    map[int]any{http.StatusAccepted: reprocessResponse{}},
+	}, reprocessHandler(deps))
+
+	// tus parts of a large file, and handing the finished file off, can take
+	// longer than RequestTimeout
+	resumable := scoped.With(authn.Require(auth.ScopeUpload))
+	resumable.Handle("/api/uploads", uploads)
+	resumable.Handle("/api/uploads/*", uploads)
+
+	// Event streams stay open until the client leaves
+	spec.Route(scoped.With(authn.Require(auth.ScopeRead)), http.MethodGet, "/api/documents/{id}/events", openapi.Op{
+		ID:          "streamDocumentEvents",
+		Summary:     "Stream a document's processing events",
+		Description: "Server-Sent Events, starting with the document's current state and ending after the ready or failed event.",
+		Tag:         "documents",
+		Scope:       string(auth.ScopeRead),
+		Responses:   map[int]any{http.StatusOK: openapi.EventStream("")},
+	}, documentEventsHandler(deps))
+
+	spec.Route(api.With(authn.Require(auth.ScopeQuery)), http.MethodPost, "/api/query", openapi.Op{
+		ID:          "query",
+		Summary:     "Ask a question about documents",
+		Description: "At least one of document_ids and metadata is required.",
+		Tag:         "query",
+		Scope:       string(auth.ScopeQuery),
+		Body:        queryapi.Request{},
+		Responses: map[int]any{
+			http.StatusOK:                 queryapi.Response{},
+			http.StatusServiceUnavailable: queryUnavailableResponse{},
+		},
+	}, queryHandler(deps))
+
+	admin := api.With(authn.Require(auth.ScopeAdmin))
+	spec.Route(admin, http.MethodPost, "/api/admin/documents/reprocess", openapi.Op{
+		ID:           "reprocessDocuments",
+		Summary:      "Rerun pipeline stages for every matching document",
+		Description:  "Takes the filters of the document listing; documents still processing are skipped.",
+		Tag:          "admin",
+		Scope:        string(auth.ScopeAdmin),
+		Query:        listDocumentsQuery{},
+		Body:         reprocessRequest{},
+		BodyOptional: true,
+		Responses:    map[int]any{http.StatusAccepted: bulkReprocessResponse{}},
+	}, adminReprocessHandler(deps))
+	spec.Route(admin, http.MethodPost, "/api/webhooks", openapi.Op{
+		ID:          "createWebhook",
+		Summary:     "Register a webhook",
+		Description: "The response carries the signing secret, which is never shown again.",
+		Tag:         "admin",
+		Scope:       string(auth.ScopeAdmin),
+		Body:        createWebhookRequest{},
+		Responses:   map[int]any{http.StatusCreated: webhookView{}},
+	}, createWebhookHandler(deps))
+	spec.Route(admin, http.MethodGet, "/api/webhooks", openapi.Op{
+		ID:        "listWebhooks",
+		Summary:   "List webhooks",
+		Tag:       "admin",
+		Scope:     string(auth.ScopeAdmin),
+		Responses: map[int]any{http.StatusOK: webhookListResponse{}},
+	}, listWebhooksHandler(deps))
+	spec.Route(admin, http.MethodDelete, "/api/webhooks/{id}", openapi.Op{
+		ID:        "deleteWebhook",
+		Summary:   "Delete a webhook and its delivery log",
+		Tag:       "admin",
+		Scope:     string(auth.ScopeAdmin),
+		Responses: map[int]any{http.StatusNoContent: nil},
+	}, deleteWebhookHandler(deps))
+	spec.Route(admin, http.MethodGet, "/api/webhooks/{id}/deliveries", openapi.Op{
+		ID:        "listWebhookDeliveries",
+		Summary:   "List a webhook's latest delivery attempts",
+		Tag:       "admin",
+		Scope:     string(auth.ScopeAdmin),
+		Query:     deliveriesQuery{},
+		Responses: map[int]any{http.StatusOK: deliveryListResponse{}},
+	}, webhookDeliveriesHandler(deps))
+	spec.Route(admin, http.MethodPost, "/api/keys", openapi.Op{
+		ID:          "createAPIKey",
+		Summary:     "Issue an API key",
+		Description: "The response carries the key, which is never shown again.",
+		Tag:         "admin",
+		Scope:       string(auth.ScopeAdmin),
+		Body:        createAPIKeyRequest{},
+		Responses:   map[int]any{http.StatusCreated: apiKeyView{}},
+	}, createAPIKeyHandler(deps))
+	spec.Route(admin, http.MethodGet, "/api/keys", openapi.Op{
+		ID:        "listAPIKeys",
+		Summary:   "List API keys",
+		Tag:       "admin",
+		Scope:     string(auth.ScopeAdmin),
+		Responses: map[int]any{http.StatusOK: apiKeyListResponse{}},
+	}, listAPIKeysHandler(deps))
+	spec.Route(admin, http.MethodDelete, "/api/keys/{id}", openapi.Op{
+		ID:        "revokeAPIKey",
+		Summary:   "Revoke an API key",
+		Tag:       "admin",
+		Scope:     string(auth.ScopeAdmin),
+		Responses: map[int]any{http.StatusNoContent: nil},
+	}, revokeAPIKeyHandler(deps))
+
+	spec.Route(r, http.MethodGet, "/healthz", openapi.Op{
+		ID:        "health",
+		Summary:   "Report that the gateway is up",
+		Public:    true,
+		Responses: map[int]any{http.StatusOK: openapi.Text("")},
+	}, httputil.HealthHandler(deps))
+	spec.Route(r, http.MethodGet, "/readyz", openapi.Op{
+		ID:          "ready",
+		Summary:     "Report whether the gateway's dependencies are usable",
+		Description: "Results are reused for a couple of seconds, so frequent probes stay cheap.",
+		Public:      true,
+		Responses: map[int]any{
+			http.StatusOK:                 httputil.ReadyResponse{},
+			http.StatusServiceUnavailable: httputil.ReadyResponse{},
+		},
+	}, httputil.ReadyHandler(deps))
+	spec.Route(r, http.MethodGet, "/metrics", openapi.Op{
+		ID:          "metrics",
+		Summary:     "Prometheus metrics",
+		Description: "Requires METRICS_TOKEN as a bearer token when the gateway sets one.",
+		Public:      true,
+		Responses:   map[int]any{http.StatusOK: openapi.Text("")},
+	}, httputil.MetricsHandler(deps))
+	spec.Route(r, http.MethodGet, "/openapi.json", openapi.Op{
+		ID:        "openapi",
+		Summary:   "This document",
+		Public:    true,
+		Responses: map[int]any{http.StatusOK: json.RawMessage(nil)},
+	}, spec.Handler())
+
+	return spec
+}
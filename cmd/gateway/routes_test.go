@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"doc-agents/internal/auth"
+	"doc-agents/internal/httputil"
+	"doc-agents/internal/openapi"
+	"doc-agents/internal/queue"
+	"doc-agents/internal/store"
+)
+
+// newTestRouter mounts the gateway's routes with authentication disabled.
+// The store and queue mocks have no expectations, so a request that gets
+// past validation to a handler that uses them fails.
+func newTestRouter(t *testing.T) *chi.Mux {
+	t.Helper()
+	deps := newTestDeps(new(store.MockStore), new(queue.MockQueue))
+	deps.Config.DefaultTenant = "default"
+	deps.Config.TusDir = t.TempDir()
+	uploads, err := newResumableUploadHandler(deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httputil.NewRouter(deps.Log)
+	mountRoutes(r, deps, auth.New(auth.Options{Disabled: true, Log: deps.Log}), uploads)
+	return r
+}
+
+func TestOpenAPIDocumentCoversRoutes(t *testing.T) {
+	r := newTestRouter(t)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json = %d", w.Code)
+	}
+	var doc struct {
+		OpenAPI    string                                `json:"openapi"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != openapi.Version {
+		t.Errorf("openapi = %q, want %q", doc.OpenAPI, openapi.Version)
+	}
+
+	// Every route but the tus protocol endpoints is described
+	err := chi.Walk(r, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if strings.HasPrefix(route, "/api/uploads") {
+			return nil
+		}
+		if _, ok := doc.Paths[route][strings.ToLower(method)]; !ok {
+			t.Errorf("%s %s is missing from the OpenAPI document", method, route)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"DocumentListResponse", "QueryRequest", "QueryResponse", "BatchStatusResponse"} {
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("schema %s is missing", name)
+		}
+	}
+}
+
+func TestRoutesValidateRequests(t *testing.T) {
+	r := newTestRouter(t)
+	docID := "6f1c2b7e-3a0d-4c5e-9f8a-1b2c3d4e5f60"
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		body     string
+		wantBody string
+	}{
+		{
+			name:     "document id is not a UUID",
+			method:   http.MethodGet,
+			target:   "/api/documents/not-a-uuid/status",
+			wantBody: "id must be a valid UUID",
+		},
+		{
+			name:     "unknown status filter",
+			method:   http.MethodGet,
+			target:   "/api/documents?status=archived",
+			wantBody: "status must be one of processing, ready, failed",
+		},
+		{
+			name:     "limit is not a number",
+			method:   http.MethodGet,
+			target:   "/api/documents?limit=ten",
+			wantBody: "limit must be an integer",
+		},
+		{
+			name:     "created_after is not a timestamp",
+			method:   http.MethodGet,
+			target:   "/api/documents?created_after=yesterday",
+			wantBody: "created_after must be an RFC 3339 timestamp",
+		},
+		{
+			name:     "question missing and top_k too large",
+			method:   http.MethodPost,
+			target:   "/api/query",
+			body:     `{"document_ids": ["` + docID + `"], "top_k": 50}`,
+			wantBody: "question is required; top_k must be at most 20",
+		},
+		{
+			name:     "document id in body is not a UUID",
+			method:   http.MethodPost,
+			target:   "/api/query",
+			body:     `{"question": "What is Go?", "document_ids": ["nope"]}`,
+			wantBody: "document_ids[0] must be a valid UUID",
+		},
+		{
+			name:     "unknown reprocess stage",
+			method:   http.MethodPost,
+			target:   "/api/documents/" + docID + "/reprocess",
+			body:     `{"stages": ["index"]}`,
+			wantBody: "stages[0] must be one of parse, summarize, embed",
+		},
+		{
+			name:     "metadata patch with a number",
+			method:   http.MethodPatch,
+			target:   "/api/documents/" + docID,
+			body:     `{"metadata": {"year": 2024}}`,
+			wantBody: "metadata.year must be a string",
+		},
+		{
+			name:     "missing body",
+			method:   http.MethodPost,
+			target:   "/api/webhooks",
+			wantBody: "request body is required",
+		},
+		{
+			name:     "malformed JSON",
+			method:   http.MethodPost,
+			target:   "/api/keys",
+			body:     `{"name":`,
+			wantBody: "invalid JSON body",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400 (body %q)", w.Code, w.Body.String())
+			}
+			var resp httputil.ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Code != httputil.CodeValidationFailed || resp.Message != tt.wantBody {
+				t.Errorf("error = %s %q, want %s %q", resp.Code, resp.Message, httputil.CodeValidationFailed, tt.wantBody)
+			}
+			if len(resp.Details) != strings.Count(tt.wantBody, "; ")+1 || resp.RequestID == "" {
+				t.Errorf("want one detail per message and a request id, got %+v", resp)
+			}
+		})
+	}
+}
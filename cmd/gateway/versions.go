@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"doc-agents/internal/app"
+	"doc-agents/internal/blobstore"
+	"doc-agents/internal/events"
+	"doc-agents/internal/httputil"
+	"doc-agents/internal/openapi"
+	"doc-agents/internal/queue"
+	"doc-agents/internal/store"
+	"doc-agents/internal/textdiff"
+)
+
+// versionForm is the multipart body of POST /api/documents/{id}/versions.
+type versionForm struct {
+	File openapi.File `json:"file" validate:"required"`
+}
+
+type versionCreatedResponse struct {
+	DocumentID string               `json:"document_id"`
+	Version    int                  `json:"version"`
+	Status     store.DocumentStatus `json:"status"`
+	// Unchanged is set when the file is identical to the live version;
+	// version is then the live version and nothing was queued.
+	Unchanged bool `json:"unchanged,omitempty"`
+}
+
+type versionView struct {
+	Version     int    `json:"version"`
+	Status      string `json:"status" validate:"oneof=live superseded processing failed" doc:"Only the live version is queried by default; a failed version never became live."`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type,omitempty"`
+	SourceURL   string `json:"source_url,omitempty"`
+	CreatedAt   string `json:"created_at"`
+	Error       string `json:"error,omitempty" doc:"Why a failed version could not be processed."`
+}
+
+type versionListResponse struct {
+	DocumentID string        `json:"document_id"`
+	Live       int           `json:"live"`
+	Versions   []versionView `json:"versions"`
+}
+
+// versionDiffQuery holds the query parameters of the summary diff.
+type versionDiffQuery struct {
+	From int `json:"from" validate:"omitempty,min=1" doc:"Defaults to the version before to."`
+	To   int `json:"to" validate:"omitempty,min=1" doc:"Defaults to the live version."`
+}
+
+type diffOpView struct {
+	Op   textdiff.Kind `json:"op" validate:"oneof=equal added removed"`
+	Text string        `json:"text"`
+}
+
+type versionDiffResponse struct {
+	DocumentID       string       `json:"document_id"`
+	From             int          `json:"from"`
+	To               int          `json:"to"`
+	KeyPointsAdded   []string     `json:"key_points_added"`
+	KeyPointsRemoved []string     `json:"key_points_removed"`
+	Summary          []diffOpView `json:"summary" doc:"The summary of from turned into that of to, sentence by sentence."`
+}
+
+// versionParam reads an optional version number from the query parameter
+// name; zero means it is absent.
+func versionParam(r *http.Request, name string) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s must be a version number", name)
+	}
+	return n, nil
+}
+
+// versionStatus describes version v of doc.
+func versionStatus(doc store.Document, v store.Version, latest bool) string {
+	switch {
+	case v.Number == doc.Version:
+		return "live"
+	case v.Number < doc.Version:
+		return "superseded"
+	case v.Error == "" && latest && doc.Status == store.StatusProcessing:
+		return "processing"
+	default:
+		return "failed"
+	}
+}
+
+// uploadVersionHandler adds a new version of a document from an uploaded
+// file. The document keeps answering from its live version until the new
+// one is processed, and earlier versions keep their summaries and chunks.
+func uploadVersionHandler(deps app.GatewayDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		docID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "invalid document id", err, http.StatusBadRequest)
+			return
+		}
+		log := deps.Log.With("document_id", docID)
+
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			httputil.Fail(log, w, r, "file is required", err, http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		contentType, statusCode, err := validateUploadedFile(r, header, deps.Config.MaxUploadSize, deps.Extractors)
+		if err != nil {
+			httputil.Fail(log, w, r, err.Error(), err, statusCode)
+			return
+		}
+
+		doc, err := deps.Store.GetDocument(ctx, docID)
+		if errors.Is(err, store.ErrDocumentNotFound) {
+			httputil.Fail(log, w, r, "document not found", err, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			httputil.Fail(log, w, r, "failed to load document", err, http.StatusInternalServerError)
+			return
+		}
+		hash, content, err := hashContent(file)
+		if err != nil {
+			httputil.Fail(log, w, r, "failed to read file", err, http.StatusInternalServerError)
+			return
+		}
+		if hash == doc.ContentHash && !forceRequested(r) {
+			httputil.WriteJSON(w, http.StatusOK, versionCreatedResponse{
+				DocumentID: doc.ID.String(),
+				Version:    doc.Version,
+				Status:     doc.Status,
+				Unchanged:  true,
+			})
+			return
+		}
+
+		v := store.Version{Filename: header.Filename, ContentHash: hash, ContentType: contentType}
+		v, err = createVersion(ctx, deps, doc, v, content, header.Size)
+		switch {
+		case errors.Is(err, store.ErrDocumentProcessing):
+			httputil.Fail(log, w, r, err.Error(), err, http.StatusConflict)
+		case errors.Is(err, store.ErrDocumentNotFound):
+			httputil.Fail(log, w, r, "document not found", err, http.StatusNotFound)
+		case err != nil:
+			failIngest(deps, w, r, err, docID)
+		default:
+			httputil.WriteJSON(w, http.StatusAccepted, versionCreatedResponse{
+				DocumentID: doc.ID.String(),
+				Version:    v.Number,
+				Status:     store.StatusProcessing,
+			})
+		}
+	}
+}
+
+// createVersion records v as the next version of doc, stores its file and
+// enqueues its parse task. Fails with store.ErrDocumentProcessing if a run
+// is already in progress; if a later step fails, the version is failed and
+// the document keeps its live version and status.
+func createVersion(ctx context.Context, deps app.GatewayDeps, doc store.Document, v store.Version, content io.Reader, size int64) (store.Version, error) {
+	v, err := deps.Store.CreateVersion(ctx, doc.ID, v)
+	if err != nil {
+		return store.Version{}, err
+	}
+	restore := func(message string, err error) (store.Version, error) {
+		if upErr := deps.Store.FailVersion(ctx, doc.ID, v.Number, message); upErr != nil {
+			deps.Log.Error("failed to mark version failed", "document_id", doc.ID, "version", v.Number, "err", upErr)
+		}
+		return store.Version{}, &ingestError{message, err}
+	}
+	// Reset every stage so the status endpoint reflects the new run
+	for _, stage := range store.Stages {
+		if err := deps.Store.RecordStage(ctx, doc.ID, stage, store.StagePending, 0, ""); err != nil {
+			deps.Log.Warn("failed to reset stage progress", "document_id", doc.ID, "stage", stage, "err", err)
+		}
+	}
+
+	blobKey := blobstore.VersionKey(doc.ID, v.Number)
+	if err := deps.Blobs.Put(ctx, blobKey, content, size, v.ContentType); err != nil {
+		return restore("failed to store file", err)
+	}
+	body, err := json.Marshal(parseTaskPayload{
+		DocumentID:  doc.ID,
+		Filename:    v.Filename,
+		ContentType: v.ContentType,
+		BlobKey:     blobKey,
+		Version:     v.Number,
+	})
+	if err != nil {
+		return restore("marshal payload failed", err)
+	}
+	task := queue.Task{Type: queue.TaskTypeParse, Payload: body}
+	if err := queue.EnqueueWithRetry(ctx, deps.Queue, task, 3, 200*time.Millisecond); err != nil {
+		return restore("failed to enqueue version; please retry", err)
+	}
+	publishEvent(ctx, deps, events.Event{DocumentID: doc.ID, Type: events.TypeQueued})
+	deps.Log.Info("document version queued", "document_id", doc.ID, "version", v.Number)
+	return v, nil
+}
+
+// listVersionsHandler lists every version of a document, oldest first.
+func listVersionsHandler(deps app.GatewayDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		docID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "invalid document id", err, http.StatusBadRequest)
+			return
+		}
+		log := deps.Log.With("document_id", docID)
+		doc, err := deps.Store.GetDocument(ctx, docID)
+		if errors.Is(err, store.ErrDocumentNotFound) {
+			httputil.Fail(log, w, r, "document not found", err, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			httputil.Fail(log, w, r, "failed to load document", err, http.StatusInternalServerError)
+			return
+		}
+		versions, err := deps.Store.ListVersions(ctx, docID)
+		if errors.Is(err, store.ErrDocumentNotFound) {
+			httputil.Fail(log, w, r, "document not found", err, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			httputil.Fail(log, w, r, "failed to list versions", err, http.StatusInternalServerError)
+			return
+		}
+
+		resp := versionListResponse{DocumentID: doc.ID.String(), Live: doc.Version, Versions: make([]versionView, len(versions))}
+		for i, v := range versions {
+			resp.Versions[i] = versionView{
+				Version:     v.Number,
+				Status:      versionStatus(doc, v, i == len(versions)-1),
+				Filename:    v.Filename,
+				ContentType: v.ContentType,
+				SourceURL:   v.SourceURL,
+				CreatedAt:   v.CreatedAt.Format(time.RFC3339Nano),
+				Error:       v.Error,
+			}
+		}
+		httputil.WriteJSON(w, http.StatusOK, resp)
+	}
+}
+
+// versionDiffHandler compares the summaries of two versions of a document:
+// the key points one has and the other lacks, and the summary text sentence
+// by sentence.
+func versionDiffHandler(deps app.GatewayDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		docID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "invalid document id", err, http.StatusBadRequest)
+			return
+		}
+		log := deps.Log.With("document_id", docID)
+		from, err := versionParam(r, "from")
+		if err != nil {
+			httputil.Fail(log, w, r, err.Error(), err, http.StatusBadRequest)
+			return
+		}
+		to, err := versionParam(r, "to")
+		if err != nil {
+			httputil.Fail(log, w, r, err.Error(), err, http.StatusBadRequest)
+			return
+		}
+
+		doc, err := deps.Store.GetDocument(ctx, docID)
+		if errors.Is(err, store.ErrDocumentNotFound) {
+			httputil.Fail(log, w, r, "document not found", err, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			httputil.Fail(log, w, r, "failed to load document", err, http.StatusInternalServerError)
+			return
+		}
+		if to == 0 {
+			to = doc.Version
+		}
+		if from == 0 {
+			from = to - 1
+		}
+		if from < 1 {
+			err := errors.New("the document has no version before the one compared; pass from")
+			httputil.Fail(log, w, r, err.Error(), err, http.StatusBadRequest)
+			return
+		}
+
+		var sums [2]store.Summary
+		for i, version := range []int{from, to} {
+			sums[i], err = deps.Store.GetSummary(ctx, docID, version)
+			if errors.Is(err, store.ErrDocumentNotFound) || errors.Is(err, store.ErrVersionNotFound) {
+				httputil.Fail(log, w, r, fmt.Sprintf("document has no version %d", version), err, http.StatusNotFound)
+				return
+			}
+			if errors.Is(err, store.ErrSummaryNotFound) {
+				httputil.Fail(log, w, r, fmt.Sprintf("summary of version %d not ready", version), err, http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				httputil.Fail(log, w, r, "failed to load summary", err, http.StatusInternalServerError)
+				return
+			}
+		}
+		httputil.WriteJSON(w, http.StatusOK, diffSummaries(docID, sums[0], sums[1]))
+	}
+}
+
+// diffSummaries compares the summaries of two versions of a document.
+func diffSummaries(docID uuid.UUID, from, to store.Summary) versionDiffResponse {
+	resp := versionDiffResponse{
+		DocumentID:       docID.String(),
+		From:             from.Version,
+		To:               to.Version,
+		KeyPointsAdded:   []string{},
+		KeyPointsRemoved: []string{},
+		Summary:          []diffOpView{},
+	}
+	for _, p := range to.KeyPoints {
+		if !slices.Contains(from.KeyPoints, p) {
+			resp.KeyPointsAdded = append(resp.KeyPointsAdded, p)
+		}
+	}
+	for _, p := range from.KeyPoints {
+		if !slices.Contains(to.KeyPoints, p) {
+			resp.KeyPointsRemoved = append(resp.KeyPointsRemoved, p)
+		}
+	}
+	for _, op := range textdiff.Diff(textdiff.Sentences(from.Summary), textdiff.Sentences(to.Summary)) {
+		resp.Summary = append(resp.Summary, diffOpView{Op: op.Kind, Text: op.Text})
+	}
+	return resp
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"doc-agents/internal/app"
+	"doc-agents/internal/httputil"
+	"doc-agents/internal/store"
+	"doc-agents/internal/webhook"
+)
+
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 200
+)
+
+type createWebhookRequest struct {
+	URL    string   `json:"url" validate:"required,http_url,max=2048"`
+	Events []string `json:"events" validate:"required,min=1,dive,oneof=document.created document.ready document.failed document.deleted"`
+}
+
+type webhookListResponse struct {
+	Webhooks []webhookView `json:"webhooks"`
+}
+
+// deliveriesQuery holds the query parameters of the deliveries listing; the
+// bounds match defaultDeliveryLimit and maxDeliveryLimit.
+type deliveriesQuery struct {
+	Limit int `json:"limit" validate:"omitempty,min=1,max=200" doc:"Defaults to 50."`
+}
+
+type deliveryListResponse struct {
+	Deliveries []deliveryView `json:"deliveries"`
+}
+
+type webhookView struct {
+	ID        string   `json:"id"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	CreatedAt string   `json:"created_at"`
+	// Secret is only returned when the webhook is created.
+	Secret string `json:"secret,omitempty"`
+}
+
+func newWebhookView(hook store.Webhook) webhookView {
+	return webhookView{
+		ID:        hook.ID.String(),
+		URL:       hook.URL,
+		Events:    hook.Events,
+		CreatedAt: hook.CreatedAt.Format(time.RFC3339Nano),
+	}
+}
+
+type deliveryView struct {
+	ID         string `json:"id"`
+	EventID    string `json:"event_id"`
+	Event      string `json:"event"`
+	DocumentID string `json:"document_id"`
+	Attempt    int    `json:"attempt"`
+	Success    bool   `json:"success"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// createWebhookHandler registers a webhook. The response carries the signing
+// secret, which is never shown again.
+func createWebhookHandler(deps app.GatewayDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req createWebhookRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			httputil.Fail(deps.Log, w, r, "invalid payload", err, http.StatusBadRequest)
+			return
+		}
+		if err := httputil.Validator.Struct(&req); err != nil {
+			httputil.ValidationError(deps.Log, w, r, err)
+			return
+		}
+
+		secret, err := webhook.NewSecret()
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "failed to generate webhook secret", err, http.StatusInternalServerError)
+			return
+		}
+		events := slices.Compact(slices.Sorted(slices.Values(req.Events)))
+		hook, err := deps.Store.CreateWebhook(r.Context(), store.Webhook{URL: req.URL, Secret: secret, Events: events})
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "failed to create webhook", err, http.StatusInternalServerError)
+			return
+		}
+
+		deps.Log.Info("webhook created", "webhook_id", hook.ID, "events", hook.Events)
+		view := newWebhookView(hook)
+		view.Secret = hook.Secret
+		httputil.WriteJSON(w, http.StatusCreated, view)
+	}
+}
+
+func listWebhooksHandler(deps app.GatewayDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hooks, err := deps.Store.ListWebhooks(r.Context(), "")
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "failed to list webhooks", err, http.StatusInternalServerError)
+			return
+		}
+		views := make([]webhookView, 0, len(hooks))
+		for _, hook := range hooks {
+			views = append(views, newWebhookView(hook))
+		}
+		httputil.WriteJSON(w, http.StatusOK, webhookListResponse{Webhooks: views})
+	}
+}
+
+// deleteWebhookHandler removes a webhook and its delivery log. Deliveries
+// still queued for it are dropped.
+func deleteWebhookHandler(deps app.GatewayDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hookID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "invalid webhook id", err, http.StatusBadRequest)
+			return
+		}
+		err = deps.Store.DeleteWebhook(r.Context(), hookID)
+		if errors.Is(err, store.ErrWebhookNotFound) {
+			httputil.Fail(deps.Log, w, r, "webhook not found", err, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "failed to delete webhook", err, http.StatusInternalServerError)
+			return
+		}
+		deps.Log.Info("webhook deleted", "webhook_id", hookID)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// webhookDeliveriesHandler returns a webhook's latest delivery attempts,
+// newest first (?limit=, default 50).
+func webhookDeliveriesHandler(deps app.GatewayDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		hookID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "invalid webhook id", err, http.StatusBadRequest)
+			return
+		}
+		limit := defaultDeliveryLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			limit, err = strconv.Atoi(v)
+			if err != nil || limit < 1 || limit > maxDeliveryLimit {
+				err = fmt.Errorf("limit must be between 1 and %d", maxDeliveryLimit)
+				httputil.Fail(deps.Log, w, r, err.Error(), err, http.StatusBadRequest)
+				return
+			}
+		}
+
+		if _, err := deps.Store.GetWebhook(ctx, hookID); errors.Is(err, store.ErrWebhookNotFound) {
+			httputil.Fail(deps.Log, w, r, "webhook not found", err, http.StatusNotFound)
+			return
+		} else if err != nil {
+			httputil.Fail(deps.Log, w, r, "failed to load webhook", err, http.StatusInternalServerError)
+			return
+		}
+		deliveries, err := deps.Store.ListWebhookDeliveries(ctx, hookID, limit)
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, "failed to list deliveries", err, http.StatusInternalServerError)
+			return
+		}
+
+		views := make([]deliveryView, 0, len(deliveries))
+		for _, d := range deliveries {
+			views = append(views, deliveryView{
+				ID:         d.ID.String(),
+				EventID:    d.EventID.String(),
+				Event:      d.Event,
+				DocumentID: d.DocumentID.String(),
+				Attempt:    d.Attempt,
+				Success:    d.Error == "",
+				StatusCode: d.StatusCode,
+				Error:      d.Error,
+				DurationMS: d.Duration.Milliseconds(),
+				CreatedAt:  d.CreatedAt.Format(time.RFC3339Nano),
+			})
+		}
+		httputil.WriteJSON(w, http.StatusOK, deliveryListResponse{Deliveries: views})
+	}
+}
@@ -3,6 +3,9 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"time"
@@ -12,15 +15,42 @@ import (
 
 	"doc-agents/internal/app"
 	"doc-agents/internal/chunker"
+	"doc-agents/internal/events"
+	"doc-agents/internal/extractor"
 	"doc-agents/internal/httputil"
+	"doc-agents/internal/progress"
 	"doc-agents/internal/queue"
 	"doc-agents/internal/store"
 )
 
+// analyzeTaskPayload is the task the analysis service takes over with.
+type analyzeTaskPayload struct {
+	DocumentID string      `json:"document_id"`
+	ChunkIDs   []uuid.UUID `json:"chunk_ids"`
+	// Staged marks chunks stored for reprocessing rather than live ones.
+	Staged bool `json:"staged,omitempty"`
+	// Version is the new version the chunks belong to; zero means the live one.
+	Version int `json:"version,omitempty"`
+	// Filename is that of the parsed file.
+	Filename string `json:"filename,omitempty"`
+}
+
 type parseTaskPayload struct {
-	DocumentID string `json:"document_id"`
-	Filename   string `json:"filename"`
-	Content    string `json:"content"`
+	DocumentID  string `json:"document_id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	BlobKey     string `json:"blob_key"`
+
+	// Content carries pre-extracted text from gateways that predate blob storage.
+	Content string `json:"content,omitempty"`
+
+	// Reprocess stages the new chunks instead of adding them next to the live
+	// ones; analysis swaps them in once they are embedded.
+	Reprocess bool `json:"reprocess,omitempty"`
+
+	// Version is a newly uploaded version of the document, parsed next to the
+	// live one; zero means the live version itself.
+	Version int `json:"version,omitempty"`
 }
 
 func main() {
@@ -31,57 +61,87 @@ func main() {
 	}
 	deps.Log.Info("parser worker starting")
 
-	g, ctx := errgroup.WithContext(context.Background())
-
-	// Run queue worker
-	g.Go(func() error {
-		return deps.Queue.Worker(ctx, queue.TaskTypeParse, func(ctx context.Context, task queue.Task) error {
-			var payload parseTaskPayload
-			if err := json.Unmarshal(task.Payload, &payload); err != nil {
-				return err
-			}
-			return handleParse(ctx, deps, payload)
+	err = app.Run(deps.BaseDeps, func(ctx context.Context, g *errgroup.Group) {
+		// Run queue worker
+		g.Go(func() error {
+			return deps.Queue.Worker(ctx, queue.TaskTypeParse, func(ctx context.Context, task queue.Task) error {
+				var payload parseTaskPayload
+				if err := json.Unmarshal(task.Payload, &payload); err != nil {
+					return err
+				}
+				return handleParse(ctx, deps, task, payload)
+			})
 		})
-	})
 
-	// Run health check server
-	g.Go(func() error {
-		return httputil.ServeHealth(deps, "parser")
+		// Run health check server
+		g.Go(func() error {
+			return httputil.ServeHealth(ctx, deps, "parser")
+		})
 	})
-
-	// Wait for either to fail
-	if err := g.Wait(); err != nil {
+	if err != nil {
 		deps.Log.Error("parser service stopped", "err", err)
 	}
 }
 
-func handleParse(ctx context.Context, deps app.ParserDeps, payload parseTaskPayload) error {
+func handleParse(ctx context.Context, deps app.ParserDeps, task queue.Task, payload parseTaskPayload) error {
 	docID, err := uuid.Parse(payload.DocumentID)
 	if err != nil {
 		return err
 	}
-	text := payload.Content
+	// The document may have been deleted while the task was queued; chunks
+	// must not be written for it (the FK would reject them anyway).
+	doc, err := deps.Store.GetDocument(ctx, docID)
+	if errors.Is(err, store.ErrDocumentNotFound) {
+		deps.Log.Info("document deleted, skipping parse", "document_id", docID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	tracker := progress.New(deps.Store, deps.Events, deps.Webhooks, deps.Log, doc, task).ForVersion(payload.Version)
+	return tracker.Run(ctx, store.StageParse, func() error {
+		return parse(ctx, deps, tracker, docID, payload)
+	})
+}
+
+// parse extracts and chunks the document, then hands it to analysis.
+func parse(ctx context.Context, deps app.ParserDeps, tracker *progress.Tracker, docID uuid.UUID, payload parseTaskPayload) error {
+	text, err := loadText(ctx, deps, payload)
+	if err != nil {
+		return err
+	}
 	chunks := chunker.ChunkText(text, chunker.Options{MaxTokens: 400, Overlap: 80})
 	var storeChunks []store.Chunk
 	for _, c := range chunks {
 		storeChunks = append(storeChunks, store.Chunk{
+			Version:    payload.Version,
 			Index:      c.Index,
 			Text:       c.Text,
 			TokenCount: c.TokenCount,
 		})
 	}
-	chunksWithIDs, err := deps.Store.SaveChunks(ctx, docID, storeChunks)
+	saveChunks := deps.Store.SaveChunks
+	if payload.Reprocess {
+		saveChunks = deps.Store.StageChunks
+	}
+	chunksWithIDs, err := saveChunks(ctx, docID, storeChunks)
 	if err != nil {
 		return err
 	}
+	tracker.Publish(ctx, events.Event{Type: events.TypeChunked, Chunks: len(chunksWithIDs)})
+
 	// Enqueue analysis task with chunk ids.
 	var chunkIDs []uuid.UUID
 	for _, c := range chunksWithIDs {
 		chunkIDs = append(chunkIDs, c.ID)
 	}
-	body, err := json.Marshal(map[string]any{
-		"document_id": docID.String(),
-		"chunk_ids":   chunkIDs,
+	body, err := json.Marshal(analyzeTaskPayload{
+		DocumentID: docID.String(),
+		ChunkIDs:   chunkIDs,
+		Staged:     payload.Reprocess,
+		Version:    payload.Version,
+		Filename:   payload.Filename,
 	})
 	if err != nil {
 		return err
@@ -89,3 +149,52 @@ func handleParse(ctx context.Context, deps app.ParserDeps, payload parseTaskPayl
 	task := queue.Task{Type: queue.TaskTypeAnalyze, Payload: body, NotBefore: time.Now()}
 	return queue.EnqueueWithRetry(ctx, deps.Queue, task, 3, 200*time.Millisecond)
 }
+
+// loadText fetches the original file from blob storage and extracts its text.
+// Unreadable PDFs fall back to the raw bytes, matching the gateway's
+// historical behavior; any other extraction failure fails the parse stage
+// rather than chunking (and paying to summarize and embed) binary content.
+func loadText(ctx context.Context, deps app.ParserDeps, payload parseTaskPayload) (string, error) {
+	if payload.BlobKey == "" {
+		return payload.Content, nil
+	}
+
+	rc, err := deps.Blobs.Get(ctx, payload.BlobKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch blob %s: %w", payload.BlobKey, err)
+	}
+	defer rc.Close()
+
+	// Extraction works on the whole file in memory, so refuse to load more
+	// than MaxParseSize rather than let one huge upload exhaust the worker
+	maxSize := deps.Config.MaxParseSize
+	content, err := io.ReadAll(io.LimitReader(rc, maxSize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read blob %s: %w", payload.BlobKey, err)
+	}
+	if int64(len(content)) > maxSize {
+		return "", fmt.Errorf("%s is too large to parse (max %d bytes)", payload.Filename, maxSize)
+	}
+
+	contentType := payload.ContentType
+	if contentType == "" {
+		// Documents ingested before content types were recorded
+		contentType, _ = deps.Extractors.DetectType(payload.Filename)
+	}
+	text, err := deps.Extractors.Extract(contentType, content)
+	if err != nil {
+		if !rawFallbackAllowed(deps.Extractors, contentType) {
+			return "", fmt.Errorf("failed to extract text from %s: %w", payload.Filename, err)
+		}
+		deps.Log.Warn("text extraction failed, using raw bytes", "err", err, "filename", payload.Filename, "content_type", payload.ContentType)
+		return string(content), nil
+	}
+	return text, nil
+}
+
+// rawFallbackAllowed reports whether the raw bytes of a file whose
+// extraction failed are still worth indexing as text.
+func rawFallbackAllowed(extractors *extractor.Registry, contentType string) bool {
+	mimeType, _ := extractors.Resolve(contentType)
+	return mimeType == extractor.TypePlainText || mimeType == extractor.TypePDF
+}
@@ -6,15 +6,20 @@ import (
 	"errors"
 	"io"
 	"log/slog"
+	"strings"
 	"testing"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
 
 	"doc-agents/internal/app"
+	"doc-agents/internal/blobstore"
 	"doc-agents/internal/config"
+	"doc-agents/internal/events"
+	"doc-agents/internal/extractor"
 	"doc-agents/internal/queue"
 	"doc-agents/internal/store"
+	"doc-agents/internal/webhook"
 )
 
 func newTestDeps(st store.Store, q queue.Queue) app.ParserDeps {
@@ -23,10 +28,14 @@ func newTestDeps(st store.Store, q queue.Queue) app.ParserDeps {
 			Store: st,
 			Config: config.Config{
 				EmbeddingModel: "test-model",
+				MaxParseSize:   1024 * 1024,
 			},
 			Log: slog.New(slog.NewTextHandler(io.Discard, nil)),
 		},
-		Queue: q,
+		Queue:      q,
+		Events:     events.NewHub(),
+		Webhooks:   webhook.NoOpNotifier{},
+		Extractors: extractor.NewDefaultRegistry(),
 	}
 }
 
@@ -36,7 +45,7 @@ func TestHandleParse(t *testing.T) {
 	tests := []struct {
 		name    string
 		payload parseTaskPayload
-		setup   func(*store.MockStore, *queue.MockQueue)
+		setup   func(*store.MockStore, *queue.MockQueue, *blobstore.MockStore)
 		wantErr bool
 	}{
 		{
@@ -46,7 +55,7 @@ func TestHandleParse(t *testing.T) {
 				Filename:   "test.txt",
 				Content:    "This is a short test document.",
 			},
-			setup: func(s *store.MockStore, q *queue.MockQueue) {
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
 				// Expect SaveChunks to be called with any chunks
 				s.On("SaveChunks", mock.Anything, validDocID, mock.MatchedBy(func(chunks []store.Chunk) bool {
 					return len(chunks) > 0
@@ -71,7 +80,7 @@ func TestHandleParse(t *testing.T) {
 				Filename:   "long.txt",
 				Content:    generateLongText(1000),
 			},
-			setup: func(s *store.MockStore, q *queue.MockQueue) {
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
 				// Expect multiple chunks
 				s.On("SaveChunks", mock.Anything, validDocID, mock.MatchedBy(func(chunks []store.Chunk) bool {
 					return len(chunks) > 1 // Verify multiple chunks
@@ -81,6 +90,142 @@ func TestHandleParse(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "fetches original file from blob store and extracts text",
+			payload: parseTaskPayload{
+				DocumentID:  validDocID.String(),
+				Filename:    "page.html",
+				ContentType: extractor.TypeHTML,
+				BlobKey:     "documents/" + validDocID.String() + "/original",
+			},
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
+				b.On("Get", mock.Anything, "documents/"+validDocID.String()+"/original").
+					Return(io.NopCloser(strings.NewReader("<p>Hello blob</p><script>ignored()</script>")), nil).Once()
+
+				s.On("SaveChunks", mock.Anything, validDocID, mock.MatchedBy(func(chunks []store.Chunk) bool {
+					return len(chunks) == 1 && chunks[0].Text == "Hello blob"
+				})).Return([]store.Chunk{{ID: uuid.New(), DocumentID: validDocID}}, nil).Once()
+
+				q.On("Enqueue", mock.Anything, mock.Anything).Return(nil).Once()
+			},
+			wantErr: false,
+		},
+		{
+			name: "missing blob returns error",
+			payload: parseTaskPayload{
+				DocumentID:  validDocID.String(),
+				Filename:    "gone.txt",
+				ContentType: extractor.TypePlainText,
+				BlobKey:     "documents/gone/original",
+			},
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
+				b.On("Get", mock.Anything, "documents/gone/original").
+					Return(nil, blobstore.ErrNotFound).Once()
+			},
+			wantErr: true,
+		},
+		{
+			name: "reprocess stages chunks and flags the analyze task",
+			payload: parseTaskPayload{
+				DocumentID: validDocID.String(),
+				Filename:   "test.txt",
+				Content:    "Fresh content",
+				Reprocess:  true,
+			},
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
+				s.On("StageChunks", mock.Anything, validDocID, mock.Anything).
+					Return([]store.Chunk{{ID: uuid.New()}}, nil).Once()
+				q.On("Enqueue", mock.Anything, mock.MatchedBy(func(task queue.Task) bool {
+					var payload map[string]any
+					return json.Unmarshal(task.Payload, &payload) == nil && payload["staged"] == true
+				})).Return(nil).Once()
+			},
+			wantErr: false,
+		},
+		{
+			name: "new version saves its chunks under it and hands it to analysis",
+			payload: parseTaskPayload{
+				DocumentID:  validDocID.String(),
+				Filename:    "policy-v2.txt",
+				ContentType: extractor.TypePlainText,
+				BlobKey:     blobstore.VersionKey(validDocID, 2),
+				Version:     2,
+			},
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
+				b.On("Get", mock.Anything, blobstore.VersionKey(validDocID, 2)).
+					Return(io.NopCloser(strings.NewReader("Revised policy")), nil).Once()
+				s.On("SaveChunks", mock.Anything, validDocID, mock.MatchedBy(func(chunks []store.Chunk) bool {
+					return len(chunks) == 1 && chunks[0].Version == 2
+				})).Return([]store.Chunk{{ID: uuid.New(), Version: 2}}, nil).Once()
+				q.On("Enqueue", mock.Anything, mock.MatchedBy(func(task queue.Task) bool {
+					var payload analyzeTaskPayload
+					return json.Unmarshal(task.Payload, &payload) == nil && payload.Version == 2 && payload.Filename == "policy-v2.txt" && !payload.Staged
+				})).Return(nil).Once()
+			},
+			wantErr: false,
+		},
+		{
+			name: "corrupt DOCX fails instead of chunking raw bytes",
+			payload: parseTaskPayload{
+				DocumentID:  validDocID.String(),
+				Filename:    "broken.docx",
+				ContentType: extractor.TypeDOCX,
+				BlobKey:     blobstore.DocumentKey(validDocID),
+			},
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
+				b.On("Get", mock.Anything, blobstore.DocumentKey(validDocID)).
+					Return(io.NopCloser(strings.NewReader("PK\x03\x04 not really a zip")), nil).Once()
+				// Neither SaveChunks nor Enqueue should be touched
+			},
+			wantErr: true,
+		},
+		{
+			name: "unreadable PDF falls back to raw bytes",
+			payload: parseTaskPayload{
+				DocumentID:  validDocID.String(),
+				Filename:    "scan.pdf",
+				ContentType: extractor.TypePDF,
+				BlobKey:     blobstore.DocumentKey(validDocID),
+			},
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
+				b.On("Get", mock.Anything, blobstore.DocumentKey(validDocID)).
+					Return(io.NopCloser(strings.NewReader("plain words in a file named pdf")), nil).Once()
+				s.On("SaveChunks", mock.Anything, validDocID, mock.MatchedBy(func(chunks []store.Chunk) bool {
+					return len(chunks) == 1 && strings.Contains(chunks[0].Text, "plain words")
+				})).Return([]store.Chunk{{ID: uuid.New()}}, nil).Once()
+				q.On("Enqueue", mock.Anything, mock.Anything).Return(nil).Once()
+			},
+			wantErr: false,
+		},
+		{
+			name: "file over the parse limit fails without loading it",
+			payload: parseTaskPayload{
+				DocumentID:  validDocID.String(),
+				Filename:    "huge.txt",
+				ContentType: extractor.TypePlainText,
+				BlobKey:     blobstore.DocumentKey(validDocID),
+			},
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
+				b.On("Get", mock.Anything, blobstore.DocumentKey(validDocID)).
+					Return(io.NopCloser(strings.NewReader(strings.Repeat("a", 1024*1024+1))), nil).Once()
+			},
+			wantErr: true,
+		},
+		{
+			name: "deleted document is skipped",
+			payload: parseTaskPayload{
+				DocumentID:  validDocID.String(),
+				Filename:    "deleted.txt",
+				ContentType: extractor.TypePlainText,
+				BlobKey:     blobstore.DocumentKey(validDocID),
+			},
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
+				s.On("GetDocument", mock.Anything, validDocID).
+					Return(store.Document{}, store.ErrDocumentNotFound).Once()
+				// Neither the blob, SaveChunks nor Enqueue should be touched
+			},
+			wantErr: false,
+		},
 		{
 			name: "invalid document ID returns error",
 			payload: parseTaskPayload{
@@ -88,7 +233,7 @@ func TestHandleParse(t *testing.T) {
 				Filename:   "test.txt",
 				Content:    "Test content",
 			},
-			setup:   func(s *store.MockStore, q *queue.MockQueue) {},
+			setup:   func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {},
 			wantErr: true,
 		},
 		{
@@ -98,7 +243,7 @@ func TestHandleParse(t *testing.T) {
 				Filename:   "test.txt",
 				Content:    "Test content",
 			},
-			setup: func(s *store.MockStore, q *queue.MockQueue) {
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
 				s.On("SaveChunks", mock.Anything, validDocID, mock.Anything).
 					Return(nil, errors.New("database error")).Once()
 				// Enqueue should NOT be called
@@ -112,7 +257,7 @@ func TestHandleParse(t *testing.T) {
 				Filename:   "test.txt",
 				Content:    "Test content",
 			},
-			setup: func(s *store.MockStore, q *queue.MockQueue) {
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
 				s.On("SaveChunks", mock.Anything, validDocID, mock.Anything).
 					Return([]store.Chunk{{ID: uuid.New()}}, nil).Once()
 
@@ -129,7 +274,7 @@ func TestHandleParse(t *testing.T) {
 				Filename:   "empty.txt",
 				Content:    "",
 			},
-			setup: func(s *store.MockStore, q *queue.MockQueue) {
+			setup: func(s *store.MockStore, q *queue.MockQueue, b *blobstore.MockStore) {
 				s.On("SaveChunks", mock.Anything, validDocID, mock.Anything).
 					Return([]store.Chunk{}, nil).Once()
 
@@ -144,17 +289,23 @@ func TestHandleParse(t *testing.T) {
 			// Create fresh mocks for each test
 			mockStore := new(store.MockStore)
 			mockQueue := new(queue.MockQueue)
+			mockBlobs := new(blobstore.MockStore)
 
 			// Setup expectations
 			if tt.setup != nil {
-				tt.setup(mockStore, mockQueue)
+				tt.setup(mockStore, mockQueue, mockBlobs)
 			}
+			mockStore.On("GetDocument", mock.Anything, mock.Anything).
+				Return(store.Document{ID: validDocID}, nil).Maybe()
+			mockStore.On("RecordStage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+				Return(nil).Maybe()
 
 			// Create test dependencies
 			deps := newTestDeps(mockStore, mockQueue)
+			deps.Blobs = mockBlobs
 
 			// Execute
-			err := handleParse(context.Background(), deps, tt.payload)
+			err := handleParse(context.Background(), deps, queue.Task{}, tt.payload)
 
 			// Check error expectation
 			if (err != nil) != tt.wantErr {
@@ -164,10 +315,43 @@ func TestHandleParse(t *testing.T) {
 			// Assert all expectations were met
 			mockStore.AssertExpectations(t)
 			mockQueue.AssertExpectations(t)
+			mockBlobs.AssertExpectations(t)
 		})
 	}
 }
 
+func TestHandleParsePublishesProgress(t *testing.T) {
+	docID := uuid.New()
+	mockStore := new(store.MockStore)
+	mockQueue := new(queue.MockQueue)
+	mockStore.On("GetDocument", mock.Anything, docID).Return(store.Document{ID: docID}, nil).Once()
+	mockStore.On("RecordStage", mock.Anything, docID, store.StageParse, mock.Anything, 1, "").Return(nil).Twice()
+	mockStore.On("SaveChunks", mock.Anything, docID, mock.Anything).
+		Return([]store.Chunk{{ID: uuid.New()}, {ID: uuid.New()}}, nil).Once()
+	mockQueue.On("Enqueue", mock.Anything, mock.Anything).Return(nil).Once()
+
+	hub := events.NewHub()
+	sub, cancel := hub.Subscribe(docID)
+	defer cancel()
+	deps := newTestDeps(mockStore, mockQueue)
+	deps.Events = hub
+
+	payload := parseTaskPayload{DocumentID: docID.String(), Filename: "test.txt", Content: "Some text"}
+	if err := handleParse(context.Background(), deps, queue.Task{}, payload); err != nil {
+		t.Fatalf("handleParse() error = %v", err)
+	}
+
+	var got []events.Event
+	for len(sub) > 0 {
+		got = append(got, <-sub)
+	}
+	if len(got) != 2 || got[0].Type != events.TypeParsing || got[1].Type != events.TypeChunked || got[1].Chunks != 2 {
+		t.Errorf("published %+v, want parsing then chunked with 2 chunks", got)
+	}
+	mockStore.AssertExpectations(t)
+	mockQueue.AssertExpectations(t)
+}
+
 // generateLongText creates text of approximately the specified word count.
 func generateLongText(words int) string {
 	text := ""
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"doc-agents/internal/store"
+)
+
+// metadataFilter is the JSON form of a store.MetadataFilter. Each key maps to
+// a condition on that metadata key, and a document must match all of them:
+//
+//	{"project": "apollo"}                   equals
+//	{"team": ["search", "ingest"]}          equals one of
+//	{"confidentiality": {"ne": "secret"}}   absent or different
+//	{"version": {"exists": true}}           present (false: absent)
+type metadataFilter map[string]json.RawMessage
+
+type metadataOperators struct {
+	Ne     *string  `json:"ne"`
+	In     []string `json:"in"`
+	Exists *bool    `json:"exists"`
+}
+
+// parse converts the filter into store conditions, sorted by key so equal
+// filters produce equal queries.
+func (f metadataFilter) parse() (store.MetadataFilter, error) {
+	keys := make([]string, 0, len(f))
+	for key := range f {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var out store.MetadataFilter
+	for _, key := range keys {
+		cond, err := parseMetadataCondition(key, f[key])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, cond)
+	}
+	if err := out.Validate(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func parseMetadataCondition(key string, raw json.RawMessage) (store.MetadataCondition, error) {
+	cond := store.MetadataCondition{Key: key}
+
+	var value string
+	if err := json.Unmarshal(raw, &value); err == nil {
+		cond.Op, cond.Values = store.MetadataEq, []string{value}
+		return cond, nil
+	}
+	var values []string
+	if err := json.Unmarshal(raw, &values); err == nil {
+		cond.Op, cond.Values = store.MetadataIn, values
+		return cond, nil
+	}
+
+	var ops metadataOperators
+	if err := json.Unmarshal(raw, &ops); err != nil {
+		return cond, fmt.Errorf("metadata filter on %q must be a string, an array of strings or an operator object", key)
+	}
+	set := 0
+	if ops.Ne != nil {
+		cond.Op, cond.Values = store.MetadataNe, []string{*ops.Ne}
+		set++
+	}
+	if ops.In != nil {
+		cond.Op, cond.Values = store.MetadataIn, ops.In
+		set++
+	}
+	if ops.Exists != nil {
+		cond.Op = store.MetadataMissing
+		if *ops.Exists {
+			cond.Op = store.MetadataExists
+		}
+		set++
+	}
+	if set != 1 {
+		return cond, fmt.Errorf("metadata filter on %q needs exactly one of ne, in or exists", key)
+	}
+	return cond, nil
+}
@@ -1,57 +1,90 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"golang.org/x/sync/errgroup"
 
 	"doc-agents/internal/app"
 	"doc-agents/internal/cache"
 	"doc-agents/internal/httputil"
+	"doc-agents/internal/queryapi"
 	"doc-agents/internal/store"
+	"doc-agents/internal/tenant"
 )
 
-type queryRequest struct {
-	Question    string   `json:"question" validate:"required,min=3,max=500"`
-	DocumentIDs []string `json:"document_ids" validate:"required,min=1,dive,uuid4"`
-	TopK        int      `json:"top_k" validate:"omitempty,min=1,max=20"`
-}
-
 func main() {
 	deps, err := app.BuildQuery()
 	if err != nil {
 		slog.Default().Error("failed to build dependencies", "err", err)
 		os.Exit(1)
 	}
-	r := httputil.NewRouter(deps.Log)
+	r := newRouter(deps)
 
-	r.Post("/api/query", queryHandler(deps))
-	r.Get("/healthz", httputil.HealthHandler(deps))
-
-	addr := fmt.Sprintf(":%d", deps.Config.Port)
-	deps.Log.Info("query service listening", "addr", addr)
-	if err := http.ListenAndServe(addr, r); err != nil {
+	err = app.Run(deps.BaseDeps, func(ctx context.Context, g *errgroup.Group) {
+		g.Go(func() error {
+			addr := fmt.Sprintf(":%d", deps.Config.Port)
+			deps.Log.Info("query service listening", "addr", addr)
+			return httputil.ListenAndServe(ctx, deps.Log, addr, r, time.Duration(deps.Config.ShutdownTimeout)*time.Second)
+		})
+	})
+	if err != nil {
 		deps.Log.Error("server error", "err", err)
 	}
 }
 
+// newRouter serves queries to the gateway only: calls must carry the shared
+// internal token and the tenant the gateway resolved, in the X-Tenant-ID
+// header. There is no default tenant here; the gateway already applied it.
+func newRouter(deps app.QueryDeps) *chi.Mux {
+	r := httputil.NewRouter(deps.Log)
+	r.With(
+		httputil.Timeout(httputil.RequestTimeout),
+		httputil.RequireInternalToken(deps.Log, deps.Config.InternalToken),
+		tenant.Middleware(deps.Log, ""),
+	).Post("/api/query", queryHandler(deps))
+	r.Get("/healthz", httputil.HealthHandler(deps))
+	r.Get("/readyz", httputil.ReadyHandler(deps))
+	r.Method(http.MethodGet, "/metrics", httputil.MetricsHandler(deps))
+	return r
+}
+
 func queryHandler(deps app.QueryDeps) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var req queryRequest
+		var req queryapi.Request
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			httputil.Fail(deps.Log, w, "invalid payload", err, http.StatusBadRequest)
+			httputil.Fail(deps.Log, w, r, "invalid payload", err, http.StatusBadRequest)
 			return
 		}
 
 		// Validate request
 		if err := httputil.Validator.Struct(&req); err != nil {
-			httputil.ValidationError(deps.Log, w, err)
+			httputil.ValidationError(deps.Log, w, r, err)
+			return
+		}
+
+		metadata, err := metadataFilter(req.Metadata).parse()
+		if err != nil {
+			httputil.Fail(deps.Log, w, r, err.Error(), err, http.StatusBadRequest)
+			return
+		}
+		if len(metadata) == 0 && len(req.DocumentIDs) == 0 {
+			httputil.Fail(deps.Log, w, r, "document_ids or metadata is required", store.ErrEmptySearchScope, http.StatusBadRequest)
+			return
+		}
+		versions, err := parseVersions(req.Versions)
+		if err != nil {
+			httputil.InvalidFields(deps.Log, w, r, []httputil.FieldError{{Field: "versions", Message: err.Error()}})
 			return
 		}
 
@@ -61,21 +94,29 @@ func queryHandler(deps app.QueryDeps) http.HandlerFunc {
 
 		ctx := r.Context()
 
-		// Check cache first
-		cacheKey := cache.GenerateCacheKey(req.Question, req.DocumentIDs, req.TopK)
-		if cached, err := deps.Cache.GetQueryResult(ctx, cacheKey); err == nil && cached != nil {
+		// Answers are cached per document and dropped when one is deleted; the
+		// documents matching a metadata filter change over time, so filtered
+		// queries bypass the result cache.
+		useCache := len(metadata) == 0
+		tenantID, _ := tenant.FromContext(ctx)
+		cacheKey := cache.GenerateCacheKey(tenantID, req.Question, pinnedIDs(req.DocumentIDs, versions), req.TopK)
+		if cached := lookupCachedResult(ctx, deps, useCache, cacheKey); cached != nil {
 			deps.Log.Info("cache hit", "question", req.Question)
-			httputil.WriteJSON(w, http.StatusOK, map[string]any{
-				"answer":     cached.Answer,
-				"sources":    cached.Sources,
-				"confidence": cached.Confidence,
-				"cached":     true,
+			httputil.WriteJSON(w, http.StatusOK, queryapi.Response{
+				Answer:     cached.Answer,
+				Sources:    cached.Sources,
+				Confidence: cached.Confidence,
+				Cached:     true,
 			})
 			return
 		}
 
 		// Cache miss - proceed with normal flow
-		ids := parseDocumentIDs(req.DocumentIDs)
+		scope := store.SearchScope{
+			DocumentIDs: parseDocumentIDs(req.DocumentIDs),
+			Metadata:    metadata,
+			Versions:    versions,
+		}
 
 		// Check embedding cache first
 		vec, err := deps.Cache.GetEmbedding(ctx, req.Question)
@@ -85,9 +126,9 @@ func queryHandler(deps app.QueryDeps) http.HandlerFunc {
 
 		// If not cached, generate embedding
 		if vec == nil {
-			vec, err = deps.Embedder.Embed(req.Question)
+			vec, err = deps.Embedder.Embed(ctx, req.Question)
 			if err != nil {
-				httputil.Fail(deps.Log, w, "failed to embed question", err, http.StatusInternalServerError)
+				httputil.Fail(deps.Log, w, r, "failed to embed question", err, http.StatusInternalServerError)
 				return
 			}
 
@@ -98,9 +139,9 @@ func queryHandler(deps app.QueryDeps) http.HandlerFunc {
 			}
 		}
 
-		results, err := deps.Store.TopK(ctx, ids, vec, req.TopK)
+		results, err := deps.Store.TopK(ctx, scope, vec, req.TopK)
 		if err != nil {
-			httputil.Fail(deps.Log, w, "search failed", err, http.StatusInternalServerError)
+			httputil.Fail(deps.Log, w, r, "search failed", err, http.StatusInternalServerError)
 			return
 		}
 
@@ -109,32 +150,46 @@ func queryHandler(deps app.QueryDeps) http.HandlerFunc {
 		contextQuality := calculateAvgSimilarity(results)
 		answer, confidence, err := deps.LLM.Answer(ctx, req.Question, context, contextQuality)
 		if err != nil {
-			httputil.Fail(deps.Log, w, "llm failed", err, http.StatusInternalServerError)
+			httputil.Fail(deps.Log, w, r, "llm failed", err, http.StatusInternalServerError)
 			return
 		}
 
 		sources := buildSources(results)
 
 		// Store in cache
-		cacheTTL := time.Duration(deps.Config.CacheTTL) * time.Second
-		if err := deps.Cache.SetQueryResult(ctx, cacheKey, &cache.QueryResult{
-			Answer:     answer,
-			Confidence: confidence,
-			Sources:    sources,
-		}, cacheTTL); err != nil {
-			// Log cache write failure but don't fail the request
-			deps.Log.Warn("failed to cache result", "err", err)
+		if useCache {
+			cacheTTL := time.Duration(deps.Config.CacheTTL) * time.Second
+			if err := deps.Cache.SetQueryResult(ctx, cacheKey, &cache.QueryResult{
+				Answer:     answer,
+				Confidence: confidence,
+				Sources:    sources,
+			}, cacheTTL); err != nil {
+				// Log cache write failure but don't fail the request
+				deps.Log.Warn("failed to cache result", "err", err)
+			}
 		}
 
-		httputil.WriteJSON(w, http.StatusOK, map[string]any{
-			"answer":     answer,
-			"sources":    sources,
-			"confidence": confidence,
-			"cached":     false,
+		httputil.WriteJSON(w, http.StatusOK, queryapi.Response{
+			Answer:     answer,
+			Sources:    sources,
+			Confidence: confidence,
 		})
 	}
 }
 
+// lookupCachedResult returns the cached answer for key, or nil on a miss or
+// when the cache is not used for this query.
+func lookupCachedResult(ctx context.Context, deps app.QueryDeps, useCache bool, key string) *cache.QueryResult {
+	if !useCache {
+		return nil
+	}
+	cached, err := deps.Cache.GetQueryResult(ctx, key)
+	if err != nil {
+		return nil
+	}
+	return cached
+}
+
 // parseDocumentIDs converts string UUIDs to uuid.UUID slice, skipping invalid ones.
 func parseDocumentIDs(ids []string) []uuid.UUID {
 	var result []uuid.UUID
@@ -146,6 +201,38 @@ func parseDocumentIDs(ids []string) []uuid.UUID {
 	return result
 }
 
+// parseVersions converts the version pins of a request, keyed by document ID.
+func parseVersions(pins map[string]int) (map[uuid.UUID]int, error) {
+	if len(pins) == 0 {
+		return nil, nil
+	}
+	versions := make(map[uuid.UUID]int, len(pins))
+	for s, v := range pins {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a document id", s)
+		}
+		versions[id] = v
+	}
+	return versions, nil
+}
+
+// pinnedIDs returns ids with the version each is pinned to, if any, as
+// "<id>@<version>", so pinned and live answers are cached apart.
+func pinnedIDs(ids []string, versions map[uuid.UUID]int) []string {
+	if len(versions) == 0 {
+		return ids
+	}
+	out := make([]string, len(ids))
+	for i, s := range ids {
+		out[i] = s
+		if id, err := uuid.Parse(s); err == nil && versions[id] != 0 {
+			out[i] = s + "@" + strconv.Itoa(versions[id])
+		}
+	}
+	return out
+}
+
 // buildContext concatenates chunk texts from search results for LLM context.
 func buildContext(results []store.SearchResult) string {
 	var builder strings.Builder
@@ -177,6 +264,7 @@ func buildSources(results []store.SearchResult) []cache.Source {
 			ChunkID: res.Chunk.ID.String(),
 			Score:   res.Score,
 			Preview: truncate(res.Chunk.Text, 150),
+			Version: res.Chunk.Version,
 		}
 	}
 	return sources
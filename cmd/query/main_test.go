@@ -17,8 +17,11 @@ import (
 	"doc-agents/internal/cache"
 	"doc-agents/internal/config"
 	"doc-agents/internal/embeddings"
+	"doc-agents/internal/httputil"
 	"doc-agents/internal/llm"
+	"doc-agents/internal/queryapi"
 	"doc-agents/internal/store"
+	"doc-agents/internal/tenant"
 )
 
 func newTestDeps(st store.Store, l llm.Client, e embeddings.Embedder, c cache.Cache) app.QueryDeps {
@@ -28,6 +31,7 @@ func newTestDeps(st store.Store, l llm.Client, e embeddings.Embedder, c cache.Ca
 			Config: config.Config{
 				EmbeddingModel: "test-model",
 				CacheTTL:       86400,
+				InternalToken:  "internal-secret",
 			},
 			Log: slog.New(slog.NewTextHandler(io.Discard, nil)),
 		},
@@ -63,14 +67,14 @@ func TestQueryHandler(t *testing.T) {
 				c.On("GetEmbedding", mock.Anything, "What is Go?").Return(nil, nil).Once()
 
 				// Expect Embed to be called for the question
-				e.On("Embed", "What is Go?").Return(embeddings.Vector{0.1, 0.2}, nil).Once()
+				e.On("Embed", mock.Anything, "What is Go?").Return(embeddings.Vector{0.1, 0.2}, nil).Once()
 
 				// Expect embedding to be cached
 				c.On("SetEmbedding", mock.Anything, "What is Go?", mock.Anything, mock.Anything).Return(nil).Once()
 
 				// Expect TopK search
-				s.On("TopK", mock.Anything, mock.MatchedBy(func(ids []uuid.UUID) bool {
-					return len(ids) == 1 && ids[0] == validDocID
+				s.On("TopK", mock.Anything, mock.MatchedBy(func(scope store.SearchScope) bool {
+					return len(scope.DocumentIDs) == 1 && scope.DocumentIDs[0] == validDocID && scope.Metadata == nil
 				}), mock.Anything, 3).Return([]store.SearchResult{
 					{
 						Chunk: store.Chunk{ID: chunk1ID, Text: "Go is a programming language", TokenCount: 5},
@@ -112,7 +116,7 @@ func TestQueryHandler(t *testing.T) {
 			setup: func(s *store.MockStore, l *llm.MockClient, e *embeddings.MockEmbedder, c *cache.MockCache) {
 				c.On("GetQueryResult", mock.Anything, mock.Anything).Return(nil, nil).Once()
 				c.On("GetEmbedding", mock.Anything, "What is Go?").Return(nil, nil).Once()
-				e.On("Embed", "What is Go?").Return(embeddings.Vector{0.1}, nil).Once()
+				e.On("Embed", mock.Anything, "What is Go?").Return(embeddings.Vector{0.1}, nil).Once()
 				c.On("SetEmbedding", mock.Anything, "What is Go?", mock.Anything, mock.Anything).Return(nil).Once()
 
 				// Expect TopK=5 (default)
@@ -128,6 +132,50 @@ func TestQueryHandler(t *testing.T) {
 			wantStatusCode: http.StatusOK,
 			checkResponse:  func(t *testing.T, resp *http.Response) {},
 		},
+		{
+			name: "pinned version is searched and cached apart",
+			requestBody: `{
+				"question": "What is Go?",
+				"document_ids": ["` + validDocID.String() + `"],
+				"versions": {"` + validDocID.String() + `": 2}
+			}`,
+			setup: func(s *store.MockStore, l *llm.MockClient, e *embeddings.MockEmbedder, c *cache.MockCache) {
+				pinnedKey := cache.GenerateCacheKey("", "What is Go?", []string{validDocID.String() + "@2"}, 5)
+				c.On("GetQueryResult", mock.Anything, pinnedKey).Return(nil, nil).Once()
+				c.On("GetEmbedding", mock.Anything, "What is Go?").Return(nil, nil).Once()
+				e.On("Embed", mock.Anything, "What is Go?").Return(embeddings.Vector{0.1}, nil).Once()
+				c.On("SetEmbedding", mock.Anything, "What is Go?", mock.Anything, mock.Anything).Return(nil).Once()
+				s.On("TopK", mock.Anything, mock.MatchedBy(func(scope store.SearchScope) bool {
+					return scope.Versions[validDocID] == 2
+				}), mock.Anything, 5).Return([]store.SearchResult{
+					{Chunk: store.Chunk{ID: chunk1ID, DocumentID: validDocID, Version: 2, Text: "Go 2"}, Score: 0.9},
+				}, nil).Once()
+				l.On("Answer", mock.Anything, "What is Go?", mock.Anything, float32(0.9)).
+					Return("Go 2", float64(0.9), nil).Once()
+				c.On("SetQueryResult", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+			},
+			wantStatusCode: http.StatusOK,
+			checkResponse: func(t *testing.T, resp *http.Response) {
+				var result queryapi.Response
+				if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if len(result.Sources) != 1 || result.Sources[0].Version != 2 {
+					t.Errorf("Expected a source from version 2, got %+v", result.Sources)
+				}
+			},
+		},
+		{
+			name: "version pinned on an invalid document id returns 400",
+			requestBody: `{
+				"question": "What is Go?",
+				"document_ids": ["` + validDocID.String() + `"],
+				"versions": {"latest": 2}
+			}`,
+			setup:          func(s *store.MockStore, l *llm.MockClient, e *embeddings.MockEmbedder, c *cache.MockCache) {},
+			wantStatusCode: http.StatusBadRequest,
+			checkResponse:  func(t *testing.T, resp *http.Response) {},
+		},
 		{
 			name:           "invalid JSON payload returns 400",
 			requestBody:    `{invalid json}`,
@@ -175,6 +223,75 @@ func TestQueryHandler(t *testing.T) {
 			wantStatusCode: http.StatusBadRequest,
 			checkResponse:  func(t *testing.T, resp *http.Response) {},
 		},
+		{
+			name: "metadata filter searches matching documents without the result cache",
+			requestBody: `{
+				"question": "What is Go?",
+				"metadata": {
+					"team": ["search", "ingest"],
+					"project": "apollo",
+					"confidentiality": {"ne": "secret"},
+					"draft": {"exists": false}
+				}
+			}`,
+			setup: func(s *store.MockStore, l *llm.MockClient, e *embeddings.MockEmbedder, c *cache.MockCache) {
+				c.On("GetEmbedding", mock.Anything, "What is Go?").Return(nil, nil).Once()
+				e.On("Embed", mock.Anything, "What is Go?").Return(embeddings.Vector{0.1}, nil).Once()
+				c.On("SetEmbedding", mock.Anything, "What is Go?", mock.Anything, mock.Anything).Return(nil).Once()
+				want := store.MetadataFilter{
+					{Key: "confidentiality", Op: store.MetadataNe, Values: []string{"secret"}},
+					{Key: "draft", Op: store.MetadataMissing},
+					{Key: "project", Op: store.MetadataEq, Values: []string{"apollo"}},
+					{Key: "team", Op: store.MetadataIn, Values: []string{"search", "ingest"}},
+				}
+				s.On("TopK", mock.Anything, store.SearchScope{Metadata: want}, mock.Anything, 5).
+					Return([]store.SearchResult{}, nil).Once()
+				l.On("Answer", mock.Anything, mock.Anything, mock.Anything, float32(0.0)).
+					Return("Answer", float64(0.8), nil).Once()
+				// Neither GetQueryResult nor SetQueryResult may be called
+			},
+			wantStatusCode: http.StatusOK,
+			checkResponse:  func(t *testing.T, resp *http.Response) {},
+		},
+		{
+			name: "missing document_ids and metadata fails validation",
+			requestBody: `{
+				"question": "Valid question"
+			}`,
+			setup:          func(s *store.MockStore, l *llm.MockClient, e *embeddings.MockEmbedder, c *cache.MockCache) {},
+			wantStatusCode: http.StatusBadRequest,
+			checkResponse:  func(t *testing.T, resp *http.Response) {},
+		},
+		{
+			name: "empty metadata filter fails validation",
+			requestBody: `{
+				"question": "Valid question",
+				"metadata": {}
+			}`,
+			setup:          func(s *store.MockStore, l *llm.MockClient, e *embeddings.MockEmbedder, c *cache.MockCache) {},
+			wantStatusCode: http.StatusBadRequest,
+			checkResponse:  func(t *testing.T, resp *http.Response) {},
+		},
+		{
+			name: "metadata filter with two operators fails validation",
+			requestBody: `{
+				"question": "Valid question",
+				"metadata": {"project": {"ne": "x", "exists": true}}
+			}`,
+			setup:          func(s *store.MockStore, l *llm.MockClient, e *embeddings.MockEmbedder, c *cache.MockCache) {},
+			wantStatusCode: http.StatusBadRequest,
+			checkResponse:  func(t *testing.T, resp *http.Response) {},
+		},
+		{
+			name: "invalid metadata key fails validation",
+			requestBody: `{
+				"question": "Valid question",
+				"metadata": {"bad key": "x"}
+			}`,
+			setup:          func(s *store.MockStore, l *llm.MockClient, e *embeddings.MockEmbedder, c *cache.MockCache) {},
+			wantStatusCode: http.StatusBadRequest,
+			checkResponse:  func(t *testing.T, resp *http.Response) {},
+		},
 		{
 			name: "top_k above max fails validation",
 			requestBody: `{
@@ -195,7 +312,7 @@ func TestQueryHandler(t *testing.T) {
 			setup: func(s *store.MockStore, l *llm.MockClient, e *embeddings.MockEmbedder, c *cache.MockCache) {
 				c.On("GetQueryResult", mock.Anything, mock.Anything).Return(nil, nil).Once()
 				c.On("GetEmbedding", mock.Anything, "What is Go?").Return(nil, nil).Once()
-				e.On("Embed", "What is Go?").Return(embeddings.Vector{0.1}, nil).Once()
+				e.On("Embed", mock.Anything, "What is Go?").Return(embeddings.Vector{0.1}, nil).Once()
 				c.On("SetEmbedding", mock.Anything, "What is Go?", mock.Anything, mock.Anything).Return(nil).Once()
 				s.On("TopK", mock.Anything, mock.Anything, mock.Anything, 5).
 					Return(nil, errors.New("database error")).Once()
@@ -212,7 +329,7 @@ func TestQueryHandler(t *testing.T) {
 			setup: func(s *store.MockStore, l *llm.MockClient, e *embeddings.MockEmbedder, c *cache.MockCache) {
 				c.On("GetQueryResult", mock.Anything, mock.Anything).Return(nil, nil).Once()
 				c.On("GetEmbedding", mock.Anything, "What is Go?").Return(nil, nil).Once()
-				e.On("Embed", "What is Go?").Return(embeddings.Vector{0.1}, nil).Once()
+				e.On("Embed", mock.Anything, "What is Go?").Return(embeddings.Vector{0.1}, nil).Once()
 				c.On("SetEmbedding", mock.Anything, "What is Go?", mock.Anything, mock.Anything).Return(nil).Once()
 				s.On("TopK", mock.Anything, mock.Anything, mock.Anything, 5).
 					Return([]store.SearchResult{}, nil).Once()
@@ -231,7 +348,7 @@ func TestQueryHandler(t *testing.T) {
 			setup: func(s *store.MockStore, l *llm.MockClient, e *embeddings.MockEmbedder, c *cache.MockCache) {
 				c.On("GetQueryResult", mock.Anything, mock.Anything).Return(nil, nil).Once()
 				c.On("GetEmbedding", mock.Anything, "What is Go?").Return(nil, nil).Once()
-				e.On("Embed", "What is Go?").Return(embeddings.Vector{0.1}, nil).Once()
+				e.On("Embed", mock.Anything, "What is Go?").Return(embeddings.Vector{0.1}, nil).Once()
 				c.On("SetEmbedding", mock.Anything, "What is Go?", mock.Anything, mock.Anything).Return(nil).Once()
 				s.On("TopK", mock.Anything, mock.Anything, mock.Anything, 5).
 					Return([]store.SearchResult{}, nil).Once()
@@ -305,3 +422,42 @@ func TestQueryHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestRouterRequiresGatewayCall(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		tenant string
+		want   int
+	}{
+		{name: "missing token", tenant: "acme", want: http.StatusUnauthorized},
+		{name: "wrong token", token: "guess", tenant: "acme", want: http.StatusUnauthorized},
+		{name: "missing tenant", token: "internal-secret", want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(store.MockStore)
+			mockLLM := new(llm.MockClient)
+			mockEmbedder := new(embeddings.MockEmbedder)
+			mockCache := new(cache.MockCache)
+			r := newRouter(newTestDeps(mockStore, mockLLM, mockEmbedder, mockCache))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/query", bytes.NewBufferString(`{"question": "What is Go?", "document_ids": ["`+uuid.NewString()+`"]}`))
+			if tt.token != "" {
+				req.Header.Set(httputil.InternalTokenHeader, tt.token)
+			}
+			if tt.tenant != "" {
+				req.Header.Set(tenant.Header, tt.tenant)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("expected %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+			// Rejected calls never reach the search
+			mockEmbedder.AssertNotCalled(t, "Embed", mock.Anything, mock.Anything)
+		})
+	}
+}
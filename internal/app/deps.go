@@ -1,19 +1,30 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"time"
 
 	"github.com/nats-io/nats.go"
 	"github.com/openai/openai-go/v3"
 
+	"doc-agents/internal/blobstore"
 	"doc-agents/internal/cache"
 	"doc-agents/internal/config"
 	"doc-agents/internal/embeddings"
+	"doc-agents/internal/events"
+	"doc-agents/internal/extractor"
+	"doc-agents/internal/httputil"
 	"doc-agents/internal/llm"
 	"doc-agents/internal/logger"
 	"doc-agents/internal/queue"
 	"doc-agents/internal/store"
+	"doc-agents/internal/tracing"
+	"doc-agents/internal/upstream"
+	"doc-agents/internal/urlfetch"
+	"doc-agents/internal/webhook"
 )
 
 // BaseDeps contains dependencies common to all services
@@ -21,6 +32,8 @@ type BaseDeps struct {
 	Config config.Config
 	Log    *slog.Logger
 	Store  store.Store
+
+	conns *connections
 }
 
 // GetConfig implements httputil.Deps
@@ -36,15 +49,24 @@ func (d BaseDeps) GetLog() *slog.Logger {
 // ParserDeps contains dependencies for the parser service
 type ParserDeps struct {
 	BaseDeps
-	Queue queue.Queue
+	Queue      queue.Queue
+	Events     events.Publisher
+	Webhooks   webhook.Notifier
+	Blobs      blobstore.Store
+	Extractors *extractor.Registry
 }
 
 // AnalysisDeps contains dependencies for the analysis service
 type AnalysisDeps struct {
 	BaseDeps
 	Queue    queue.Queue
+	Events   events.Publisher
+	Webhooks webhook.Notifier
 	LLM      llm.Client
 	Embedder embeddings.Embedder
+	// Cache holds query answers that cite a document's chunks; they are
+	// dropped when reprocessing swaps the chunks
+	Cache cache.Cache
 }
 
 // QueryDeps contains dependencies for the query service
@@ -58,40 +80,59 @@ type QueryDeps struct {
 // GatewayDeps contains dependencies for the gateway service
 type GatewayDeps struct {
 	BaseDeps
-	Queue queue.Queue
+	Queue      queue.Queue
+	Events     events.Bus
+	Webhooks   webhook.Notifier
+	Blobs      blobstore.Store
+	Extractors *extractor.Registry
+	Fetcher    *urlfetch.Fetcher
+	Cache      cache.Cache
+	// WebhookDeliverer sends the deliveries that Webhooks enqueues
+	WebhookDeliverer *webhook.Deliverer
+	// Query balances /api/query across the query service replicas
+	Query *upstream.Pool
 }
 
 // BuildParser initializes dependencies for the parser service
 func BuildParser() (ParserDeps, error) {
-	base, err := buildBase()
+	base, err := buildBase("parser")
 	if err != nil {
 		return ParserDeps{}, err
 	}
 
-	q, err := buildQueue(base.Config, base.Log)
+	q, bus, err := buildQueue(base)
 	if err != nil {
 		return ParserDeps{}, fmt.Errorf("failed to initialize queue: %w", err)
 	}
 
+	blobs, err := buildBlobStore(base.Config, base.Log)
+	if err != nil {
+		return ParserDeps{}, fmt.Errorf("failed to initialize blob store: %w", err)
+	}
+
 	return ParserDeps{
-		BaseDeps: base,
-		Queue:    q,
+		BaseDeps:   base,
+		Queue:      q,
+		Events:     bus,
+		Webhooks:   buildWebhooks(base, q),
+		Blobs:      blobs,
+		Extractors: extractor.NewDefaultRegistry(),
 	}, nil
 }
 
 // BuildAnalysis initializes dependencies for the analysis service
 func BuildAnalysis() (AnalysisDeps, error) {
-	base, err := buildBase()
+	base, err := buildBase("analysis")
 	if err != nil {
 		return AnalysisDeps{}, err
 	}
 
-	q, err := buildQueue(base.Config, base.Log)
+	q, bus, err := buildQueue(base)
 	if err != nil {
 		return AnalysisDeps{}, fmt.Errorf("failed to initialize queue: %w", err)
 	}
 
-	llmClient, err := buildLLM(base.Config, base.Log)
+	llmClient, err := buildLLM(base)
 	if err != nil {
 		return AnalysisDeps{}, fmt.Errorf("failed to initialize LLM: %w", err)
 	}
@@ -101,22 +142,35 @@ func BuildAnalysis() (AnalysisDeps, error) {
 		return AnalysisDeps{}, fmt.Errorf("failed to initialize embedder: %w", err)
 	}
 
+	cacheClient, err := buildCache(base)
+	if err != nil {
+		// Stale answers then only expire with CACHE_TTL
+		base.Log.Warn("cache unavailable, using no-op cache", "err", err)
+		cacheClient = cache.NewNoOpCache()
+	}
+
 	return AnalysisDeps{
 		BaseDeps: base,
 		Queue:    q,
+		Events:   bus,
+		Webhooks: buildWebhooks(base, q),
 		LLM:      llmClient,
 		Embedder: embedder,
+		Cache:    cacheClient,
 	}, nil
 }
 
 // BuildQuery initializes dependencies for the query service
 func BuildQuery() (QueryDeps, error) {
-	base, err := buildBase()
+	base, err := buildBase("query")
 	if err != nil {
 		return QueryDeps{}, err
 	}
+	if base.Config.InternalToken == "" {
+		return QueryDeps{}, fmt.Errorf("INTERNAL_TOKEN is required: the query service only answers the gateway")
+	}
 
-	llmClient, err := buildLLM(base.Config, base.Log)
+	llmClient, err := buildLLM(base)
 	if err != nil {
 		return QueryDeps{}, fmt.Errorf("failed to initialize LLM: %w", err)
 	}
@@ -126,7 +180,7 @@ func BuildQuery() (QueryDeps, error) {
 		return QueryDeps{}, fmt.Errorf("failed to initialize embedder: %w", err)
 	}
 
-	cacheClient, err := buildCache(base.Config, base.Log)
+	cacheClient, err := buildCache(base)
 	if err != nil {
 		// Cache is optional - degrade gracefully if unavailable
 		base.Log.Warn("failed to initialize cache, continuing without caching", "err", err)
@@ -143,28 +197,95 @@ func BuildQuery() (QueryDeps, error) {
 
 // BuildGateway initializes dependencies for the gateway service
 func BuildGateway() (GatewayDeps, error) {
-	base, err := buildBase()
+	base, err := buildBase("gateway")
 	if err != nil {
 		return GatewayDeps{}, err
 	}
+	if err := base.Config.ValidateAdminAPIKey(); err != nil {
+		return GatewayDeps{}, err
+	}
 
-	q, err := buildQueue(base.Config, base.Log)
+	q, bus, err := buildQueue(base)
 	if err != nil {
 		return GatewayDeps{}, fmt.Errorf("failed to initialize queue: %w", err)
 	}
+	// The gateway streams every document's events to its SSE clients
+	if err := bus.Listen(); err != nil {
+		return GatewayDeps{}, fmt.Errorf("failed to subscribe to document events: %w", err)
+	}
+
+	blobs, err := buildBlobStore(base.Config, base.Log)
+	if err != nil {
+		return GatewayDeps{}, fmt.Errorf("failed to initialize blob store: %w", err)
+	}
+
+	fetcher, err := urlfetch.New(urlfetch.Options{
+		Allowlist:    base.Config.URLFetchAllowlist,
+		MaxRedirects: base.Config.URLFetchMaxRedirects,
+		MaxSize:      base.Config.MaxUploadSize,
+		Timeout:      time.Duration(base.Config.URLFetchTimeout) * time.Second,
+	})
+	if err != nil {
+		return GatewayDeps{}, fmt.Errorf("failed to initialize URL fetcher: %w", err)
+	}
+
+	webhookFetcher, err := urlfetch.New(urlfetch.Options{Allowlist: base.Config.WebhookAllowlist})
+	if err != nil {
+		return GatewayDeps{}, fmt.Errorf("failed to initialize webhook client: %w", err)
+	}
+	deliverer := webhook.NewDeliverer(base.Store, webhookFetcher.Transport(), time.Duration(base.Config.WebhookTimeout)*time.Second, base.Log)
+
+	cacheClient, err := buildCache(base)
+	if err != nil {
+		// Deletes still succeed without a cache; there is just nothing to invalidate
+		base.Log.Warn("cache unavailable, using no-op cache", "err", err)
+		cacheClient = cache.NewNoOpCache()
+	}
+
+	queryPool, err := upstream.New(base.Config.QueryURLs, upstream.Options{
+		Client:   &http.Client{Timeout: time.Duration(base.Config.QueryTimeout) * time.Second},
+		Attempts: base.Config.QueryAttempts,
+		Log:      base.Log,
+	})
+	if err != nil {
+		return GatewayDeps{}, fmt.Errorf("invalid QUERY_URLS: %w", err)
+	}
 
 	return GatewayDeps{
-		BaseDeps: base,
-		Queue:    q,
+		BaseDeps:         base,
+		Queue:            q,
+		Events:           bus,
+		Webhooks:         buildWebhooks(base, q),
+		Blobs:            blobs,
+		Extractors:       extractor.NewDefaultRegistry(),
+		Fetcher:          fetcher,
+		Cache:            cacheClient,
+		WebhookDeliverer: deliverer,
+		Query:            queryPool,
 	}, nil
 }
 
 // buildBase creates the base dependencies common to all services
-func buildBase() (BaseDeps, error) {
+func buildBase(service string) (BaseDeps, error) {
 	cfg := config.Load()
 	log := logger.New(cfg.LogLevel)
+	conns := &connections{}
+
+	shutdownTracing, err := tracing.Setup(cfg, service)
+	if err != nil {
+		return BaseDeps{}, err
+	}
+	// Closed last, so spans of the shutdown itself are exported too
+	conns.add("tracing", func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return shutdownTracing(ctx)
+	})
+	if cfg.TracingExporter != "none" {
+		log.Info("tracing enabled", "exporter", cfg.TracingExporter, "sample_ratio", cfg.TracingSampleRatio)
+	}
 
-	st, err := buildStore(cfg, log)
+	st, err := buildStore(cfg, log, conns)
 	if err != nil {
 		return BaseDeps{}, fmt.Errorf("failed to initialize store: %w", err)
 	}
@@ -173,10 +294,11 @@ func buildBase() (BaseDeps, error) {
 		Config: cfg,
 		Log:    log,
 		Store:  st,
+		conns:  conns,
 	}, nil
 }
 
-func buildStore(cfg config.Config, log *slog.Logger) (store.Store, error) {
+func buildStore(cfg config.Config, log *slog.Logger, conns *connections) (store.Store, error) {
 	switch cfg.StoreProvider {
 	case "postgres":
 		// Validate required database parameters
@@ -194,31 +316,88 @@ func buildStore(cfg config.Config, log *slog.Logger) (store.Store, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to initialize Postgres: %w", err)
 		}
+		conns.add("Postgres", db.Close)
+		conns.check(httputil.Check{Name: "postgres", Run: db.Ping})
 		log.Info("using Postgres store")
-		return db, nil
+		return store.WithTracing(db), nil
 	default:
 		return nil, fmt.Errorf("invalid STORE_PROVIDER: %s (valid option: postgres)", cfg.StoreProvider)
 	}
 }
 
-func buildQueue(cfg config.Config, log *slog.Logger) (queue.Queue, error) {
+// buildQueue connects to the message broker, which carries both tasks and
+// document processing events.
+func buildQueue(base BaseDeps) (queue.Queue, *events.NATS, error) {
+	cfg, log := base.Config, base.Log
 	switch cfg.QueueProvider {
 	case "nats":
 		if cfg.QueueURL == "" {
-			return nil, fmt.Errorf("QUEUE_URL is required when QUEUE_PROVIDER=nats")
+			return nil, nil, fmt.Errorf("QUEUE_URL is required when QUEUE_PROVIDER=nats")
 		}
 		nc, err := nats.Connect(cfg.QueueURL)
 		if err != nil {
-			return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+			return nil, nil, fmt.Errorf("failed to connect to NATS: %w", err)
 		}
+		// Tasks and events published while shutting down are flushed first
+		base.conns.add("NATS", func() error {
+			defer nc.Close()
+			return nc.FlushTimeout(5 * time.Second)
+		})
+		base.conns.check(httputil.Check{Name: "nats", Run: func(context.Context) error {
+			if status := nc.Status(); status != nats.CONNECTED {
+				return fmt.Errorf("connection is %s", status)
+			}
+			return nil
+		}})
 		log.Info("using NATS queue")
-		return queue.NewNATS(log, nc), nil
+		return queue.NewNATS(log, nc, time.Duration(cfg.ShutdownTimeout)*time.Second), events.NewNATS(log, nc), nil
 	default:
-		return nil, fmt.Errorf("invalid QUEUE_PROVIDER: %s (valid option: nats)", cfg.QueueProvider)
+		return nil, nil, fmt.Errorf("invalid QUEUE_PROVIDER: %s (valid option: nats)", cfg.QueueProvider)
 	}
 }
 
-func buildLLM(cfg config.Config, log *slog.Logger) (llm.Client, error) {
+func buildWebhooks(base BaseDeps, q queue.Queue) webhook.Notifier {
+	return webhook.NewNotifier(base.Store, q, base.Log, base.Config.WebhookMaxAttempts)
+}
+
+func buildBlobStore(cfg config.Config, log *slog.Logger) (blobstore.Store, error) {
+	switch cfg.BlobProvider {
+	case "local":
+		blobs, err := blobstore.NewLocal(cfg.BlobDir)
+		if err != nil {
+			return nil, err
+		}
+		log.Info("using local blob store", "dir", cfg.BlobDir)
+		return blobs, nil
+	case "s3":
+		if cfg.S3Endpoint == "" {
+			return nil, fmt.Errorf("S3_ENDPOINT is required when BLOB_PROVIDER=s3")
+		}
+		if cfg.S3AccessKey == "" || cfg.S3SecretKey == "" {
+			return nil, fmt.Errorf("S3_ACCESS_KEY and S3_SECRET_KEY are required when BLOB_PROVIDER=s3")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		blobs, err := blobstore.NewS3(ctx, blobstore.S3Options{
+			Endpoint:  cfg.S3Endpoint,
+			Bucket:    cfg.S3Bucket,
+			Region:    cfg.S3Region,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			UseSSL:    cfg.S3UseSSL,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to S3: %w", err)
+		}
+		log.Info("using S3 blob store", "endpoint", cfg.S3Endpoint, "bucket", cfg.S3Bucket)
+		return blobs, nil
+	default:
+		return nil, fmt.Errorf("invalid BLOB_PROVIDER: %s (valid options: local, s3)", cfg.BlobProvider)
+	}
+}
+
+func buildLLM(base BaseDeps) (llm.Client, error) {
+	cfg, log := base.Config, base.Log
 	switch cfg.LLMProvider {
 	case "openai":
 		if cfg.OpenAIKey == "" {
@@ -228,8 +407,11 @@ func buildLLM(cfg config.Config, log *slog.Logger) (llm.Client, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to initialize OpenAI client: %w", err)
 		}
+		if cfg.ReadyCheckLLM {
+			base.conns.check(httputil.Check{Name: "openai", Run: client.Ping})
+		}
 		log.Info("using OpenAI LLM client", "model", cfg.LLMModel)
-		return client, nil
+		return llm.WithTracing(llm.WithMetrics(client)), nil
 	default:
 		return nil, fmt.Errorf("invalid LLM_PROVIDER: %s (valid option: openai)", cfg.LLMProvider)
 	}
@@ -246,21 +428,26 @@ func buildEmbedder(cfg config.Config, log *slog.Logger) (embeddings.Embedder, er
 			return nil, fmt.Errorf("failed to initialize OpenAI embedder: %w", err)
 		}
 		log.Info("using OpenAI embedder", "model", cfg.EmbeddingModel)
-		return embedder, nil
+		return embeddings.WithTracing(embeddings.WithMetrics(embedder)), nil
 	default:
 		return nil, fmt.Errorf("invalid LLM_PROVIDER: %s (valid option: openai)", cfg.LLMProvider)
 	}
 }
 
-func buildCache(cfg config.Config, log *slog.Logger) (cache.Cache, error) {
+func buildCache(base BaseDeps) (cache.Cache, error) {
+	cfg, log := base.Config, base.Log
 	switch cfg.CacheProvider {
 	case "redis":
 		cacheClient, err := cache.NewRedisCache(cfg.RedisAddr, cfg.RedisPassword)
 		if err != nil {
 			return nil, fmt.Errorf("failed to initialize Redis cache: %w", err)
 		}
+		base.conns.add("Redis", cacheClient.Close)
+		// Every service carries on without the cache, only slower or with
+		// stale answers
+		base.conns.check(httputil.Check{Name: "redis", Run: cacheClient.Ping, Optional: true})
 		log.Info("using Redis cache", "addr", cfg.RedisAddr, "ttl_seconds", cfg.CacheTTL)
-		return cacheClient, nil
+		return cache.WithMetrics(cacheClient), nil
 	default:
 		return nil, fmt.Errorf("invalid CACHE_PROVIDER: %s (valid option: redis)", cfg.CacheProvider)
 	}
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sync/errgroup"
+
+	"doc-agents/internal/httputil"
+)
+
+// connections are what a service opened while it was built, closed in
+// reverse order once it has shut down.
+// Each connection may come with a readiness check.
+type connections struct {
+	mu      sync.Mutex
+	closers []closer
+	checks  []httputil.Check
+}
+
+type closer struct {
+	name  string
+	close func() error
+}
+
+func (c *connections) add(name string, close func() error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closers = append(c.closers, closer{name: name, close: close})
+}
+
+func (c *connections) check(check httputil.Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, check)
+}
+
+// ReadyChecks implements httputil.Deps
+func (d BaseDeps) ReadyChecks() []httputil.Check {
+	if d.conns == nil {
+		return nil
+	}
+	d.conns.mu.Lock()
+	defer d.conns.mu.Unlock()
+	return slices.Clone(d.conns.checks)
+}
+
+// Close closes the connections the service opened, logging the ones that
+// fail. Deps built by hand, as in tests, have nothing to close.
+func (d BaseDeps) Close() error {
+	if d.conns == nil {
+		return nil
+	}
+	d.conns.mu.Lock()
+	closers := slices.Clone(d.conns.closers)
+	d.conns.closers = nil
+	d.conns.mu.Unlock()
+
+	var errs []error
+	for _, c := range slices.Backward(closers) {
+		if err := c.close(); err != nil {
+			d.Log.Error("failed to close "+c.name, "err", err)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run runs a service until it receives SIGINT or SIGTERM, or one of its
+// components fails. start launches the components on g; they must return
+// once ctx is done, after finishing the requests and tasks in progress. Run
+// then closes the service's connections and returns the first component
+// error.
+func Run(deps BaseDeps, start func(ctx context.Context, g *errgroup.Group)) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	g, ctx := errgroup.WithContext(ctx)
+	// A second signal during the shutdown kills the process as usual
+	stopLogging := context.AfterFunc(ctx, func() {
+		stop()
+		deps.Log.Info("shutting down", "timeout_seconds", deps.Config.ShutdownTimeout)
+	})
+	defer stopLogging()
+
+	start(ctx, g)
+	err := g.Wait()
+	// Close logs its own failures
+	_ = deps.Close()
+	return err
+}
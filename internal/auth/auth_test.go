@@ -0,0 +1,307 @@
+package auth
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+
+	"doc-agents/internal/oidc"
+	"doc-agents/internal/store"
+	"doc-agents/internal/tenant"
+)
+
+func TestValidateScopes(t *testing.T) {
+	if err := ValidateScopes([]string{"upload", "read", "query", "admin"}); err != nil {
+		t.Errorf("ValidateScopes() = %v, want nil", err)
+	}
+	if err := ValidateScopes([]string{"read", "write"}); err == nil {
+		t.Error("ValidateScopes() accepted an unknown scope")
+	}
+}
+
+func TestNewKey(t *testing.T) {
+	key, prefix, err := NewKey()
+	if err != nil {
+		t.Fatalf("NewKey() error = %v", err)
+	}
+	if !strings.HasPrefix(key, KeyPrefix) || !strings.HasPrefix(key, prefix) || len(prefix) >= len(key) {
+		t.Errorf("NewKey() = %q, %q", key, prefix)
+	}
+	other, _, _ := NewKey()
+	if other == key {
+		t.Error("NewKey() returned the same key twice")
+	}
+	if HashKey(key) != HashKey(key) || HashKey(key) == HashKey(other) {
+		t.Error("HashKey() is not a deterministic per-key hash")
+	}
+}
+
+func TestPrincipalHas(t *testing.T) {
+	reader := Principal{Scopes: []Scope{ScopeRead}}
+	if !reader.Has(ScopeRead) || reader.Has(ScopeUpload) {
+		t.Errorf("read-only principal: Has(read) = %v, Has(upload) = %v", reader.Has(ScopeRead), reader.Has(ScopeUpload))
+	}
+	admin := Principal{Scopes: []Scope{ScopeAdmin}}
+	for _, s := range Scopes {
+		if !admin.Has(s) {
+			t.Errorf("admin principal lacks %s", s)
+		}
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter(2)
+	l.now = func() time.Time { return now }
+	key := "key:1"
+
+	for i := range 2 {
+		if ok, _ := l.Allow(key, 60); !ok {
+			t.Fatalf("request %d within burst was limited", i+1)
+		}
+	}
+	ok, wait := l.Allow(key, 60)
+	if ok || wait != time.Second {
+		t.Fatalf("Allow() = %v, %v, want false, 1s", ok, wait)
+	}
+	if ok, _ := l.Allow("key:2", 60); !ok {
+		t.Error("another key shares the exhausted bucket")
+	}
+
+	now = now.Add(time.Second)
+	if ok, _ := l.Allow(key, 60); !ok {
+		t.Error("bucket did not refill")
+	}
+	if ok, _ := l.Allow(key, 0); !ok {
+		t.Error("zero rate limit throttled")
+	}
+}
+
+// fakeVerifier accepts the tokens it maps to identities.
+type fakeVerifier map[string]oidc.Identity
+
+func (f fakeVerifier) Verify(ctx context.Context, token string) (oidc.Identity, error) {
+	id, ok := f[token]
+	if !ok {
+		return oidc.Identity{}, oidc.ErrInvalidToken
+	}
+	return id, nil
+}
+
+func TestParseGroupScopes(t *testing.T) {
+	got, err := ParseGroupScopes([]string{"eng:read", "eng:query", " admins:admin"})
+	if err != nil {
+		t.Fatalf("ParseGroupScopes() error = %v", err)
+	}
+	if !slices.Equal(got["eng"], []Scope{ScopeRead, ScopeQuery}) || !slices.Equal(got["admins"], []Scope{ScopeAdmin}) {
+		t.Errorf("ParseGroupScopes() = %v", got)
+	}
+	for _, bad := range []string{"eng", ":read", "eng:write"} {
+		if _, err := ParseGroupScopes([]string{bad}); err == nil {
+			t.Errorf("ParseGroupScopes(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	const operatorKey = "operator-secret"
+	keyID := uuid.New()
+	stored := store.APIKey{ID: keyID, Tenant: "acme", Scopes: []string{"read"}}
+	tokens := fakeVerifier{
+		"h.scoped.s":     {Subject: "alice", Tenant: "acme", Scopes: []string{"openid", "read"}},
+		"h.grouped.s":    {Subject: "bob", Tenant: "acme", Groups: []string{"writers"}},
+		"h.tenantless.s": {Subject: "carol", Scopes: []string{"read"}},
+	}
+
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		scope      Scope
+		setup      func(*store.MockStore)
+		limiter    func() *Limiter
+		disabled   bool
+		wantStatus int
+		wantTenant string
+		wantSub    string
+	}{
+		{
+			name:    "valid key",
+			headers: map[string]string{"Authorization": "Bearer dak_valid"},
+			scope:   ScopeRead,
+			setup: func(s *store.MockStore) {
+				s.On("UseAPIKey", mock.Anything, HashKey("dak_valid")).Return(stored, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantTenant: "acme",
+		},
+		{
+			name:    "X-API-Key header",
+			headers: map[string]string{KeyHeader: "dak_valid"},
+			scope:   ScopeRead,
+			setup: func(s *store.MockStore) {
+				s.On("UseAPIKey", mock.Anything, HashKey("dak_valid")).Return(stored, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantTenant: "acme",
+		},
+		{
+			name:       "missing key",
+			scope:      ScopeRead,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:    "unknown or revoked key",
+			headers: map[string]string{"Authorization": "Bearer dak_revoked"},
+			scope:   ScopeRead,
+			setup: func(s *store.MockStore) {
+				s.On("UseAPIKey", mock.Anything, mock.Anything).Return(store.APIKey{}, store.ErrAPIKeyNotFound).Once()
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:    "missing scope",
+			headers: map[string]string{"Authorization": "Bearer dak_valid"},
+			scope:   ScopeUpload,
+			setup: func(s *store.MockStore) {
+				s.On("UseAPIKey", mock.Anything, mock.Anything).Return(stored, nil).Once()
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:    "other tenant",
+			headers: map[string]string{"Authorization": "Bearer dak_valid", tenant.Header: "globex"},
+			scope:   ScopeRead,
+			setup: func(s *store.MockStore) {
+				s.On("UseAPIKey", mock.Anything, mock.Anything).Return(stored, nil).Once()
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:    "rate limited",
+			headers: map[string]string{"Authorization": "Bearer dak_valid"},
+			scope:   ScopeRead,
+			setup: func(s *store.MockStore) {
+				s.On("UseAPIKey", mock.Anything, mock.Anything).Return(stored, nil).Once()
+			},
+			limiter: func() *Limiter {
+				l := NewLimiter(1)
+				l.Allow("key:"+keyID.String(), 60)
+				return l
+			},
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:       "operator key acts for the named tenant",
+			headers:    map[string]string{"Authorization": "Bearer " + operatorKey, tenant.Header: "globex"},
+			scope:      ScopeAdmin,
+			wantStatus: http.StatusOK,
+			wantTenant: "globex",
+		},
+		{
+			name:       "token scope claim",
+			headers:    map[string]string{"Authorization": "Bearer h.scoped.s"},
+			scope:      ScopeRead,
+			wantStatus: http.StatusOK,
+			wantTenant: "acme",
+			wantSub:    "alice",
+		},
+		{
+			name:       "token without the scope",
+			headers:    map[string]string{"Authorization": "Bearer h.scoped.s"},
+			scope:      ScopeUpload,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "token group grants scope",
+			headers:    map[string]string{"Authorization": "Bearer h.grouped.s"},
+			scope:      ScopeUpload,
+			wantStatus: http.StatusOK,
+			wantTenant: "acme",
+			wantSub:    "bob",
+		},
+		{
+			name:       "invalid token",
+			headers:    map[string]string{"Authorization": "Bearer h.forged.s"},
+			scope:      ScopeRead,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "token without tenant",
+			headers:    map[string]string{"Authorization": "Bearer h.tenantless.s"},
+			scope:      ScopeRead,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "disabled",
+			scope:      ScopeAdmin,
+			disabled:   true,
+			wantStatus: http.StatusOK,
+			wantTenant: tenant.Default,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(store.MockStore)
+			if tt.setup != nil {
+				tt.setup(mockStore)
+			}
+			log := slog.New(slog.NewTextHandler(io.Discard, nil))
+			opts := Options{
+				Keys:        mockStore,
+				OperatorKey: operatorKey,
+				Tokens:      tokens,
+				GroupScopes: map[string][]Scope{"writers": {ScopeUpload}},
+				RateLimit:   60,
+				Disabled:    tt.disabled,
+				Log:         log,
+			}
+			if tt.limiter != nil {
+				opts.Limiter = tt.limiter()
+			}
+			a := New(opts)
+
+			var gotTenant, gotSub string
+			h := a.Middleware(tenant.Middleware(log, tenant.Default)(a.Require(tt.scope)(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					gotTenant, _ = tenant.FromContext(r.Context())
+					p, _ := FromContext(r.Context())
+					gotSub = p.Subject
+				}))))
+			req := httptest.NewRequest(http.MethodGet, "/api/documents", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d. Body: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if gotTenant != tt.wantTenant {
+				t.Errorf("tenant = %q, want %q", gotTenant, tt.wantTenant)
+			}
+			if gotSub != tt.wantSub {
+				t.Errorf("subject = %q, want %q", gotSub, tt.wantSub)
+			}
+			if tt.wantStatus == http.StatusTooManyRequests {
+				if w.Header().Get("Retry-After") != "1" {
+					t.Errorf("Retry-After = %q, want 1", w.Header().Get("Retry-After"))
+				}
+				if !strings.Contains(w.Body.String(), `"code": "rate_limited"`) {
+					t.Errorf("body = %s, want code rate_limited", w.Body.String())
+				}
+			}
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"doc-agents/internal/errcode"
+	"doc-agents/internal/httputil"
+	"doc-agents/internal/oidc"
+	"doc-agents/internal/store"
+	"doc-agents/internal/tenant"
+)
+
+// KeyHeader is an alternative to "Authorization: Bearer <key>".
+const KeyHeader = "X-API-Key"
+
+// KeyStore looks up API keys; store.Store implements it.
+type KeyStore interface {
+	UseAPIKey(ctx context.Context, hash string) (store.APIKey, error)
+}
+
+// TokenVerifier verifies OIDC bearer tokens; *oidc.Verifier implements it.
+type TokenVerifier interface {
+	Verify(ctx context.Context, token string) (oidc.Identity, error)
+}
+
+// ErrNoTenant means a verified token names no tenant and there is no default.
+var ErrNoTenant = errors.New("token carries no tenant")
+
+// Options configures an Authenticator.
+type Options struct {
+	Keys KeyStore
+	// OperatorKey, when set, is accepted with the admin scope for any tenant,
+	// named by the X-Tenant-ID header. It bootstraps the first API keys.
+	OperatorKey string
+	// Tokens, when set, accepts OIDC bearer tokens alongside API keys.
+	Tokens TokenVerifier
+	// GroupScopes grants token holders scopes by group, on top of the known
+	// scopes in the token's own scope claim.
+	GroupScopes map[string][]Scope
+	// TokenTenant is the tenant of tokens without a tenant claim; when empty
+	// such tokens are rejected.
+	TokenTenant string
+	// RateLimit is the requests per minute of callers without their own limit.
+	RateLimit int
+	Limiter   *Limiter
+	// Disabled lets every request through unauthenticated.
+	Disabled bool
+	Log      *slog.Logger
+}
+
+// Authenticator resolves the API key or token of each request and checks its
+// scopes.
+type Authenticator struct {
+	opts Options
+}
+
+// New returns an Authenticator for opts.
+func New(opts Options) *Authenticator {
+	if opts.Limiter == nil {
+		opts.Limiter = NewLimiter(1)
+	}
+	return &Authenticator{opts: opts}
+}
+
+// ErrNoCredentials means the request carried no API key or token.
+var ErrNoCredentials = errors.New("authentication required")
+
+// ErrTenantMismatch means the request named a tenant other than the
+// caller's own.
+var ErrTenantMismatch = errcode.New("tenant_mismatch", "credentials belong to another tenant")
+
+// RateLimitError means the caller is over its rate limit and may retry
+// after Wait.
+type RateLimitError struct {
+	Wait time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return "rate limit exceeded"
+}
+
+// Code implements the errcode convention.
+func (e *RateLimitError) Code() string {
+	return "rate_limited"
+}
+
+// Authenticate resolves key, an API key or bearer token, rate limits the
+// caller and returns ctx bound to the caller and its tenant. requestedTenant
+// is the tenant the request names, if any; naming another tenant than the
+// caller's is ErrTenantMismatch. Both the HTTP middleware and the gRPC
+// interceptors go through here.
+func (a *Authenticator) Authenticate(ctx context.Context, key, requestedTenant string) (context.Context, error) {
+	if a.opts.Disabled {
+		return ctx, nil
+	}
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+	p, err := a.principal(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if ok, wait := a.opts.Limiter.Allow(p.rateKey(), p.RateLimit); !ok {
+		return nil, &RateLimitError{Wait: wait}
+	}
+	if p.Tenant != "" {
+		if requestedTenant != "" && requestedTenant != p.Tenant {
+			return nil, fmt.Errorf("%w: %s", ErrTenantMismatch, requestedTenant)
+		}
+		ctx = tenant.WithID(ctx, p.Tenant)
+	}
+	return WithPrincipal(ctx, p), nil
+}
+
+// Authorize checks that the caller bound to ctx by Authenticate has scope.
+// It returns ErrNoCredentials without a caller and a *ScopeError when the
+// caller lacks the scope.
+func (a *Authenticator) Authorize(ctx context.Context, scope Scope) error {
+	if a.opts.Disabled {
+		return nil
+	}
+	p, ok := FromContext(ctx)
+	if !ok {
+		return ErrNoCredentials
+	}
+	if !p.Has(scope) {
+		return &ScopeError{Scope: scope}
+	}
+	return nil
+}
+
+// ScopeError means the caller lacks Scope.
+type ScopeError struct {
+	Scope Scope
+}
+
+func (e *ScopeError) Error() string {
+	return fmt.Sprintf("credentials lack the %s scope", e.Scope)
+}
+
+// Code implements the errcode convention.
+func (e *ScopeError) Code() string {
+	return "insufficient_scope"
+}
+
+// Middleware authenticates the request's API key or bearer token, rate
+// limits it and binds the request to the caller's tenant. A request naming
+// another tenant in X-Tenant-ID is rejected rather than silently rescoped.
+// The caller is available to handlers through FromContext.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, err := a.Authenticate(r.Context(), requestKey(r), r.Header.Get(tenant.Header))
+		var limited *RateLimitError
+		switch {
+		case err == nil:
+			next.ServeHTTP(w, r.WithContext(ctx))
+		case errors.Is(err, ErrNoCredentials):
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			httputil.Fail(a.opts.Log, w, r, "authentication required", nil, http.StatusUnauthorized)
+		case errors.Is(err, store.ErrAPIKeyNotFound):
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			// Not the key's absence from the store: to the caller it is unusable
+			httputil.Fail(a.opts.Log, w, r, "invalid api key", errcode.Wrap("invalid_api_key", err), http.StatusUnauthorized)
+		case errors.Is(err, oidc.ErrInvalidToken):
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			httputil.Fail(a.opts.Log, w, r, "invalid token", err, http.StatusUnauthorized)
+		case errors.Is(err, ErrNoTenant):
+			httputil.Fail(a.opts.Log, w, r, err.Error(), err, http.StatusForbidden)
+		case errors.As(err, &limited):
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limited.Wait.Seconds()))))
+			httputil.Fail(a.opts.Log, w, r, "rate limit exceeded", err, http.StatusTooManyRequests)
+		case errors.Is(err, ErrTenantMismatch):
+			httputil.Fail(a.opts.Log, w, r, "credentials do not belong to tenant "+r.Header.Get(tenant.Header), err, http.StatusForbidden)
+		default:
+			httputil.Fail(a.opts.Log, w, r, "failed to authenticate", err, http.StatusServiceUnavailable)
+		}
+	})
+}
+
+// Require rejects requests whose principal lacks scope with 403.
+func (a *Authenticator) Require(scope Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var scopeErr *ScopeError
+			switch err := a.Authorize(r.Context(), scope); {
+			case err == nil:
+				next.ServeHTTP(w, r)
+			case errors.As(err, &scopeErr):
+				httputil.Fail(a.opts.Log, w, r, err.Error(), err, http.StatusForbidden)
+			default:
+				httputil.Fail(a.opts.Log, w, r, "authentication required", nil, http.StatusUnauthorized)
+			}
+		})
+	}
+}
+
+// principal resolves key, which is the operator key, an OIDC token or an API
+// key. API keys have their use recorded.
+func (a *Authenticator) principal(ctx context.Context, key string) (Principal, error) {
+	if a.opts.OperatorKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(a.opts.OperatorKey)) == 1 {
+		return Principal{Scopes: []Scope{ScopeAdmin}}, nil
+	}
+	if a.opts.Tokens != nil && oidc.LooksLikeJWT(key) {
+		return a.tokenPrincipal(ctx, key)
+	}
+	stored, err := a.opts.Keys.UseAPIKey(ctx, HashKey(key))
+	if err != nil {
+		return Principal{}, err
+	}
+	p := Principal{KeyID: stored.ID, Tenant: stored.Tenant, RateLimit: stored.RateLimit}
+	if p.RateLimit == 0 {
+		p.RateLimit = a.opts.RateLimit
+	}
+	for _, s := range stored.Scopes {
+		p.Scopes = append(p.Scopes, Scope(s))
+	}
+	return p, nil
+}
+
+// tokenPrincipal verifies an OIDC token. Its scopes are the known ones in
+// the token's scope claim plus those granted to its groups.
+func (a *Authenticator) tokenPrincipal(ctx context.Context, token string) (Principal, error) {
+	id, err := a.opts.Tokens.Verify(ctx, token)
+	if err != nil {
+		return Principal{}, err
+	}
+	p := Principal{Subject: id.Subject, Groups: id.Groups, Tenant: id.Tenant, RateLimit: a.opts.RateLimit}
+	if p.Tenant == "" {
+		p.Tenant = a.opts.TokenTenant
+	}
+	if p.Tenant == "" {
+		return Principal{}, ErrNoTenant
+	}
+	if err := tenant.Validate(p.Tenant); err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", oidc.ErrInvalidToken, err)
+	}
+	for _, s := range id.Scopes {
+		if slices.Contains(Scopes, Scope(s)) {
+			p.Scopes = append(p.Scopes, Scope(s))
+		}
+	}
+	for _, group := range id.Groups {
+		p.Scopes = append(p.Scopes, a.opts.GroupScopes[group]...)
+	}
+	return p, nil
+}
+
+// requestKey returns the key from the Authorization or X-API-Key header.
+func requestKey(r *http.Request) string {
+	return Key(r.Header.Get("Authorization"), r.Header.Get(KeyHeader))
+}
+
+// Key returns the key from an "Authorization: Bearer <key>" value, or else
+// from the X-API-Key value.
+func Key(authorization, apiKey string) string {
+	if token, ok := strings.CutPrefix(authorization, "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return apiKey
+}
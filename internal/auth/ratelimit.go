@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter keeps a token bucket per caller. Buckets live in memory, so each
+// gateway replica enforces the limit on the requests it serves.
+type Limiter struct {
+	burst int
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a Limiter whose buckets hold up to burst requests.
+func NewLimiter(burst int) *Limiter {
+	return &Limiter{burst: max(burst, 1), now: time.Now, buckets: make(map[string]*bucket)}
+}
+
+// Allow takes a token from key's bucket, which refills at perMinute tokens a
+// minute. When the bucket is empty it returns false and how long until the
+// next token. A non-positive perMinute never limits.
+func (l *Limiter) Allow(key string, perMinute int) (bool, time.Duration) {
+	if perMinute <= 0 {
+		return true, 0
+	}
+	rate := float64(perMinute) / 60 // Tokens per second
+	capacity := float64(l.burst)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := (1 - b.tokens) / rate
+	return false, time.Duration(wait * float64(time.Second))
+}
@@ -0,0 +1,41 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// ErrNotFound is returned when no object exists for a key.
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps original uploaded files so they can be extracted by workers
+// and reprocessed later.
+type Store interface {
+	// Put streams r into the object at key. size may be -1 when unknown.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+
+	// Get opens the object at key. Callers must close the returned reader.
+	// Returns ErrNotFound if the object does not exist.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// Delete removes the object at key. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// DocumentKey returns the key under which a document's original file is stored.
+func DocumentKey(docID uuid.UUID) string {
+	return "documents/" + docID.String() + "/original"
+}
+
+// VersionKey returns the key under which the original file of a version of
+// a document is stored. The first version is the document's original file.
+func VersionKey(docID uuid.UUID, version int) string {
+	if version <= 1 {
+		return DocumentKey(docID)
+	}
+	return "documents/" + docID.String() + "/v" + strconv.Itoa(version) + "/original"
+}
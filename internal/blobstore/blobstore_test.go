@@ -0,0 +1,109 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// exerciseStore runs the behaviour every backend must share.
+func exerciseStore(t *testing.T, s Store) {
+	t.Helper()
+	ctx := context.Background()
+	key := DocumentKey(uuid.New())
+
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound before Put, got %v", err)
+	}
+
+	content := "original file bytes"
+	if err := s.Put(ctx, key, strings.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	rc, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	got, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if string(got) != content {
+		t.Errorf("expected %q, got %q", content, got)
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after Delete, got %v", err)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Errorf("expected deleting a missing blob to succeed, got %v", err)
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	s, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal failed: %v", err)
+	}
+	exerciseStore(t, s)
+}
+
+func TestLocalStoreRejectsTraversal(t *testing.T) {
+	s, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal failed: %v", err)
+	}
+	for _, key := range []string{"../escape", "/abs/path", "a/../../b", ""} {
+		if err := s.Put(context.Background(), key, strings.NewReader("x"), 1, ""); err == nil {
+			t.Errorf("expected error for key %q", key)
+		}
+	}
+}
+
+// TestS3Store runs against a real S3-compatible server, e.g.
+//
+//	docker run -p 9000:9000 minio/minio server /data
+//	BLOBSTORE_TEST_S3_ENDPOINT=localhost:9000 go test ./internal/blobstore
+func TestS3Store(t *testing.T) {
+	endpoint := os.Getenv("BLOBSTORE_TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("BLOBSTORE_TEST_S3_ENDPOINT not set")
+	}
+	s, err := NewS3(context.Background(), S3Options{
+		Endpoint:  endpoint,
+		Bucket:    "doc-agents-test",
+		AccessKey: envOr("BLOBSTORE_TEST_S3_ACCESS_KEY", "minioadmin"),
+		SecretKey: envOr("BLOBSTORE_TEST_S3_SECRET_KEY", "minioadmin"),
+	})
+	if err != nil {
+		t.Fatalf("NewS3 failed: %v", err)
+	}
+	exerciseStore(t, s)
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func TestVersionKey(t *testing.T) {
+	id := uuid.New()
+	if got := VersionKey(id, 1); got != DocumentKey(id) {
+		t.Errorf("VersionKey(1) = %q, want the original file %q", got, DocumentKey(id))
+	}
+	if v2, v3 := VersionKey(id, 2), VersionKey(id, 3); v2 == DocumentKey(id) || v2 == v3 {
+		t.Errorf("later versions share a key: %q, %q", v2, v3)
+	}
+}
@@ -0,0 +1,31 @@
+package blobstore
+
+import (
+	"context"
+	"io"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockStore is a mock implementation of Store using testify/mock.
+type MockStore struct {
+	mock.Mock
+}
+
+func (m *MockStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	args := m.Called(ctx, key, r, size, contentType)
+	return args.Error(0)
+}
+
+func (m *MockStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	args := m.Called(ctx, key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
+
+func (m *MockStore) Delete(ctx context.Context, key string) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
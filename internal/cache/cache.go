@@ -19,13 +19,14 @@ type Cache interface {
 	SetQueryResult(ctx context.Context, key string, result *QueryResult, ttl time.Duration) error
 
 	// GetEmbedding retrieves a cached embedding vector for the given text
-	// Returns nil if not found
+	// Returns nil if not found. Embeddings are cached per tenant in ctx
 	GetEmbedding(ctx context.Context, text string) ([]float32, error)
 
 	// SetEmbedding stores an embedding vector for the given text with TTL
 	SetEmbedding(ctx context.Context, text string, vector []float32, ttl time.Duration) error
 
-	// InvalidateDocument removes all cached queries for a document
+	// InvalidateDocument removes all cached queries for a document of the
+	// tenant in ctx
 	InvalidateDocument(ctx context.Context, docID string) error
 
 	// Close closes the cache connection
@@ -43,12 +44,15 @@ type QueryResult struct {
 type Source struct {
 	ChunkID string  `json:"chunk_id"`
 	Score   float32 `json:"score"`
-	Preview string  `json:"preview"` // Truncated text preview
+	Preview string  `json:"preview"`           // Truncated text preview
+	Version int     `json:"version,omitempty"` // Version of the document the chunk belongs to
 }
 
 // GenerateCacheKey creates a deterministic cache key from query parameters.
 // The key is implementation-agnostic and can be used with any cache backend.
-func GenerateCacheKey(question string, docIDs []string, topK int) string {
+// It starts with "<tenantID>:" so tenants never share entries and one
+// tenant's entries can be dropped without touching the others.
+func GenerateCacheKey(tenantID, question string, docIDs []string, topK int) string {
 	// Sort docIDs to ensure consistent ordering
 	sortedIDs := make([]string, len(docIDs))
 	copy(sortedIDs, docIDs)
@@ -63,7 +67,7 @@ func GenerateCacheKey(question string, docIDs []string, topK int) string {
 
 	data := fmt.Sprintf("q:%s|docs:%s|k:%d", question, strings.Join(sortedIDs, ","), topK)
 	hash := sha256.Sum256([]byte(data))
-	return hex.EncodeToString(hash[:])
+	return tenantID + ":" + hex.EncodeToString(hash[:])
 }
 
 // GenerateEmbeddingKey creates a deterministic cache key for embedding text.
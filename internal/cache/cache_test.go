@@ -0,0 +1,19 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateCacheKey(t *testing.T) {
+	key := GenerateCacheKey("acme", "What is Go?", []string{"b", "a"}, 5)
+	if !strings.HasPrefix(key, "acme:") {
+		t.Errorf("key %q does not start with the tenant", key)
+	}
+	if got := GenerateCacheKey("acme", "What is Go?", []string{"a", "b"}, 5); got != key {
+		t.Errorf("document order changed the key: %q != %q", got, key)
+	}
+	if other := GenerateCacheKey("globex", "What is Go?", []string{"a", "b"}, 5); other == key {
+		t.Errorf("two tenants share the key %q", key)
+	}
+}
@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var lookups = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "docagents",
+	Name:      "cache_lookups_total",
+	Help:      "Cache lookups by kind (query, embedding) and result (hit, miss, error).",
+}, []string{"kind", "result"})
+
+// WithMetrics counts the hits, misses and errors of c's lookups.
+func WithMetrics(c Cache) Cache {
+	return &instrumentedCache{Cache: c}
+}
+
+type instrumentedCache struct {
+	Cache
+}
+
+func (c *instrumentedCache) GetQueryResult(ctx context.Context, key string) (*QueryResult, error) {
+	result, err := c.Cache.GetQueryResult(ctx, key)
+	countLookup("query", result != nil, err)
+	return result, err
+}
+
+func (c *instrumentedCache) GetEmbedding(ctx context.Context, text string) ([]float32, error) {
+	vector, err := c.Cache.GetEmbedding(ctx, text)
+	countLookup("embedding", vector != nil, err)
+	return vector, err
+}
+
+func countLookup(kind string, found bool, err error) {
+	result := "miss"
+	switch {
+	case err != nil:
+		result = "error"
+	case found:
+		result = "hit"
+	}
+	lookups.WithLabelValues(kind, result).Inc()
+}
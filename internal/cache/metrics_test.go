@@ -0,0 +1,37 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestWithMetricsCountsLookups(t *testing.T) {
+	ctx := context.Background()
+	m := new(MockCache)
+	m.On("GetQueryResult", ctx, "cached").Return(&QueryResult{Answer: "42"}, nil)
+	m.On("GetQueryResult", ctx, "absent").Return(nil, nil)
+	m.On("GetEmbedding", ctx, "question").Return(nil, errors.New("connection refused"))
+	c := WithMetrics(m)
+
+	count := func(kind, result string) float64 {
+		return testutil.ToFloat64(lookups.WithLabelValues(kind, result))
+	}
+	hits, misses, errs := count("query", "hit"), count("query", "miss"), count("embedding", "error")
+
+	_, _ = c.GetQueryResult(ctx, "cached")
+	_, _ = c.GetQueryResult(ctx, "absent")
+	_, _ = c.GetEmbedding(ctx, "question")
+
+	if got := count("query", "hit") - hits; got != 1 {
+		t.Errorf("query hits = %v, want 1", got)
+	}
+	if got := count("query", "miss") - misses; got != 1 {
+		t.Errorf("query misses = %v, want 1", got)
+	}
+	if got := count("embedding", "error") - errs; got != 1 {
+		t.Errorf("embedding errors = %v, want 1", got)
+	}
+}
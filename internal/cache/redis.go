@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/redis/go-redis/v9"
+
+	"doc-agents/internal/tenant"
 )
 
 const (
@@ -41,6 +43,11 @@ func NewRedisCache(addr, password string) (*RedisCache, error) {
 	}, nil
 }
 
+// Ping checks that Redis answers
+func (c *RedisCache) Ping(ctx context.Context) error {
+	return c.client.Ping(ctx).Err()
+}
+
 // GetQueryResult retrieves a cached query result by key
 func (c *RedisCache) GetQueryResult(ctx context.Context, key string) (*QueryResult, error) {
 	data, err := c.client.Get(ctx, cacheKeyPrefix+key).Bytes()
@@ -75,8 +82,7 @@ func (c *RedisCache) SetQueryResult(ctx context.Context, key string, result *Que
 
 // GetEmbedding retrieves a cached embedding vector for the given text
 func (c *RedisCache) GetEmbedding(ctx context.Context, text string) ([]float32, error) {
-	key := GenerateEmbeddingKey(text)
-	data, err := c.client.Get(ctx, embeddingKeyPrefix+key).Bytes()
+	data, err := c.client.Get(ctx, embeddingKey(ctx, text)).Bytes()
 	if err == redis.Nil {
 		return nil, nil // Cache miss
 	}
@@ -93,13 +99,12 @@ func (c *RedisCache) GetEmbedding(ctx context.Context, text string) ([]float32,
 
 // SetEmbedding stores an embedding vector for the given text with TTL
 func (c *RedisCache) SetEmbedding(ctx context.Context, text string, vector []float32, ttl time.Duration) error {
-	key := GenerateEmbeddingKey(text)
 	data, err := json.Marshal(vector)
 	if err != nil {
 		return err
 	}
 
-	if err := c.client.Set(ctx, embeddingKeyPrefix+key, data, ttl).Err(); err != nil {
+	if err := c.client.Set(ctx, embeddingKey(ctx, text), data, ttl).Err(); err != nil {
 		return err
 	}
 
@@ -108,10 +113,17 @@ func (c *RedisCache) SetEmbedding(ctx context.Context, text string, vector []flo
 
 // InvalidateDocument removes all cached queries for a document
 func (c *RedisCache) InvalidateDocument(ctx context.Context, docID string) error {
+	// Query keys start with the tenant (see GenerateCacheKey), so only the
+	// document's tenant loses its cached answers
+	pattern := cacheKeyPrefix + "*"
+	if id, ok := tenant.FromContext(ctx); ok {
+		pattern = cacheKeyPrefix + id + ":*"
+	}
+
 	// Use SCAN to find all keys containing this docID
 	// This is a simple implementation - for production you might want to maintain
 	// a separate index of document->query mappings
-	iter := c.client.Scan(ctx, 0, cacheKeyPrefix+"*", 0).Iterator()
+	iter := c.client.Scan(ctx, 0, pattern, 0).Iterator()
 
 	pipe := c.client.Pipeline()
 	count := 0
@@ -137,6 +149,13 @@ func (c *RedisCache) InvalidateDocument(ctx context.Context, docID string) error
 	return nil
 }
 
+// embeddingKey returns the Redis key of text's embedding, scoped to the
+// tenant in ctx so that a cache hit reveals nothing about other tenants.
+func embeddingKey(ctx context.Context, text string) string {
+	id, _ := tenant.FromContext(ctx)
+	return embeddingKeyPrefix + id + ":" + GenerateEmbeddingKey(text)
+}
+
 // Close closes the cache connection
 func (c *RedisCache) Close() error {
 	return c.client.Close()
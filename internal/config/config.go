@@ -11,10 +11,76 @@ import (
 type Config struct {
 	// Server
 	Port     int    `env:"PORT" envDefault:"8080"`
+	GRPCPort int    `env:"GRPC_PORT" envDefault:"9090"` // Gateway gRPC API; 0 disables it
 	LogLevel string `env:"LOG_LEVEL" envDefault:"info"`
 
+	// Shutdown: on SIGTERM services stop taking requests and tasks, then wait
+	// this many seconds for in-flight ones before cutting them off
+	ShutdownTimeout int `env:"SHUTDOWN_TIMEOUT" envDefault:"25"`
+
+	// Readiness: /readyz checks the database, NATS and Redis; this adds a call
+	// to the LLM provider, so an OpenAI outage takes the LLM services out of
+	// rotation
+	ReadyCheckLLM bool `env:"READY_CHECK_LLM" envDefault:"false"`
+
+	// Metrics: every service serves Prometheus metrics on /metrics; when set,
+	// scrapes must send this token as a bearer token
+	MetricsToken string `env:"METRICS_TOKEN"`
+
+	// Tracing: spans follow a document across services in the trace of the
+	// request that uploaded it
+	TracingExporter     string  `env:"TRACING_EXPORTER" envDefault:"none"`  // none, otlp or stdout
+	TracingOTLPEndpoint string  `env:"TRACING_OTLP_ENDPOINT"`               // e.g. "http://otel-collector:4318"; defaults to OTEL_EXPORTER_OTLP_ENDPOINT
+	TracingSampleRatio  float64 `env:"TRACING_SAMPLE_RATIO" envDefault:"1"` // Share of new traces recorded; traces started upstream follow the caller
+
+	// Tenancy: requests not bound to a tenant by their API key act for the one
+	// in the X-Tenant-ID header, or this one when it is absent; leave empty to
+	// reject requests without a tenant
+	DefaultTenant string `env:"DEFAULT_TENANT" envDefault:"default"`
+
+	// Internal services: the gateway sends this token with every call to the
+	// query service, which rejects calls without it
+	InternalToken string `env:"INTERNAL_TOKEN"`
+
+	// Query service replicas the gateway balances /api/query across
+	QueryURLs           []string `env:"QUERY_URLS" envSeparator:"," envDefault:"http://query:8081"` // Base URLs, e.g. "http://query-1:8081,http://query-2:8081"
+	QueryTimeout        int      `env:"QUERY_TIMEOUT" envDefault:"60"`                              // Seconds per attempt
+	QueryAttempts       int      `env:"QUERY_ATTEMPTS" envDefault:"3"`                              // Replicas a query is tried on before giving up
+	QueryHealthInterval int      `env:"QUERY_HEALTH_INTERVAL" envDefault:"5"`                       // Seconds between /readyz checks of each replica
+
+	// Gateway authentication: API keys bind requests to their tenant
+	AuthEnabled    bool   `env:"AUTH_ENABLED" envDefault:"true"`   // Disable only for local development
+	AdminAPIKey    string `env:"ADMIN_API_KEY"`                    // Operator key with the admin scope for any tenant; creates the first keys
+	RateLimit      int    `env:"RATE_LIMIT" envDefault:"600"`      // Requests per minute per key, unless the key sets its own
+	RateLimitBurst int    `env:"RATE_LIMIT_BURST" envDefault:"60"` // Requests a key may make at once before being throttled
+
+	// OIDC: bearer tokens from this issuer are accepted alongside API keys
+	OIDCIssuer       string   `env:"OIDC_ISSUER"`                           // Enables OIDC; must match the tokens' iss claim
+	OIDCAudience     string   `env:"OIDC_AUDIENCE"`                         // Required with OIDC_ISSUER
+	OIDCJWKSURL      string   `env:"OIDC_JWKS_URL"`                         // Defaults to the jwks_uri of the issuer's discovery document
+	OIDCJWKSCacheTTL int      `env:"OIDC_JWKS_CACHE_TTL" envDefault:"3600"` // Seconds fetched keys are trusted; unknown key IDs refresh early
+	OIDCGroupsClaim  string   `env:"OIDC_GROUPS_CLAIM" envDefault:"groups"`
+	OIDCTenantClaim  string   `env:"OIDC_TENANT_CLAIM" envDefault:"tenant"` // Tokens without it act for DEFAULT_TENANT
+	OIDCGroupScopes  []string `env:"OIDC_GROUP_SCOPES" envSeparator:","`    // group:scope pairs, e.g. "doc-admins:admin,analysts:query"
+
 	// Upload limits
-	MaxUploadSize int64 `env:"MAX_UPLOAD_SIZE" envDefault:"10485760"` // 10MB in bytes
+	MaxUploadSize          int64  `env:"MAX_UPLOAD_SIZE" envDefault:"10485760"`            // 10MB in bytes
+	MaxResumableUploadSize int64  `env:"MAX_RESUMABLE_UPLOAD_SIZE" envDefault:"268435456"` // 256MB in bytes (tus uploads); capped by MaxParseSize
+	TusDir                 string `env:"TUS_DIR" envDefault:"./data/uploads"`              // Where partial tus uploads are assembled
+	TusUploadExpiry        int    `env:"TUS_UPLOAD_EXPIRY" envDefault:"86400"`             // Seconds a tus upload is kept after creation; 0 keeps them forever
+	MaxBatchSize           int64  `env:"MAX_BATCH_SIZE" envDefault:"104857600"`            // 100MB: request body and total uncompressed ZIP contents
+	MaxBatchEntries        int    `env:"MAX_BATCH_ENTRIES" envDefault:"100"`               // Documents per batch upload
+	MaxParseSize           int64  `env:"MAX_PARSE_SIZE" envDefault:"268435456"`            // 256MB: largest original the parser loads into memory for extraction
+
+	// URL ingestion (POST /api/documents/from-url); fetched bodies share MaxUploadSize
+	URLFetchAllowlist    []string `env:"URL_FETCH_ALLOWLIST" envSeparator:","`   // Hostnames or CIDRs allowed despite resolving to private ranges
+	URLFetchTimeout      int      `env:"URL_FETCH_TIMEOUT" envDefault:"30"`      // Seconds for the whole fetch, including redirects
+	URLFetchMaxRedirects int      `env:"URL_FETCH_MAX_REDIRECTS" envDefault:"5"` // Redirects followed before giving up
+
+	// Webhooks; receivers are subject to the same destination checks as URL ingestion
+	WebhookAllowlist   []string `env:"WEBHOOK_ALLOWLIST" envSeparator:","`  // Hostnames or CIDRs allowed despite resolving to private ranges
+	WebhookTimeout     int      `env:"WEBHOOK_TIMEOUT" envDefault:"10"`     // Seconds per delivery attempt
+	WebhookMaxAttempts int      `env:"WEBHOOK_MAX_ATTEMPTS" envDefault:"8"` // Delivery attempts per event before giving up
 
 	// Store
 	StoreProvider string `env:"STORE_PROVIDER" envDefault:"postgres"` // "postgres" (production database)
@@ -34,6 +100,16 @@ type Config struct {
 	LLMModel       string `env:"LLM_MODEL" envDefault:"gpt-4o-mini"`
 	EmbeddingModel string `env:"EMBEDDING_MODEL" envDefault:"text-embedding-3-large"`
 
+	// Blob storage for original uploaded files
+	BlobProvider string `env:"BLOB_PROVIDER" envDefault:"local"`   // "local" (shared directory) or "s3" (S3-compatible, e.g. MinIO)
+	BlobDir      string `env:"BLOB_DIR" envDefault:"./data/blobs"` // Root directory when BLOB_PROVIDER=local
+	S3Endpoint   string `env:"S3_ENDPOINT"`                        // host:port of the S3-compatible endpoint
+	S3Bucket     string `env:"S3_BUCKET" envDefault:"doc-agents"`  // Created on startup if missing
+	S3Region     string `env:"S3_REGION"`                          // Optional for MinIO
+	S3AccessKey  string `env:"S3_ACCESS_KEY"`                      // Required when BLOB_PROVIDER=s3
+	S3SecretKey  string `env:"S3_SECRET_KEY"`                      // Required when BLOB_PROVIDER=s3
+	S3UseSSL     bool   `env:"S3_USE_SSL" envDefault:"true"`       // Disable for local MinIO over plain HTTP
+
 	// Cache
 	CacheProvider string `env:"CACHE_PROVIDER" envDefault:"redis"` // "redis" (production cache)
 	RedisAddr     string `env:"REDIS_ADDR" envDefault:"localhost:6379"`
@@ -50,6 +126,27 @@ func Load() Config {
 	return cfg
 }
 
+// MinAdminAPIKeyLength is the shortest ADMIN_API_KEY the gateway accepts.
+const MinAdminAPIKeyLength = 32
+
+// placeholderAdminAPIKey is the value env.example used to ship with.
+const placeholderAdminAPIKey = "change-me-admin-api-key"
+
+// ValidateAdminAPIKey rejects an operator key that is guessable: the old
+// env.example placeholder or anything shorter than MinAdminAPIKeyLength.
+// An empty key is valid and disables the operator key.
+func (c Config) ValidateAdminAPIKey() error {
+	switch {
+	case c.AdminAPIKey == "":
+		return nil
+	case c.AdminAPIKey == placeholderAdminAPIKey:
+		return fmt.Errorf("ADMIN_API_KEY is the example placeholder; generate one with `openssl rand -hex 32`")
+	case len(c.AdminAPIKey) < MinAdminAPIKeyLength:
+		return fmt.Errorf("ADMIN_API_KEY must be at least %d characters; generate one with `openssl rand -hex 32`", MinAdminAPIKeyLength)
+	}
+	return nil
+}
+
 // DatabaseURL constructs a PostgreSQL connection URL from individual components.
 // SSL mode is hard-coded to "disable" for development/demo environments.
 // For production, modify this to use "require" or configure via connection pooler.
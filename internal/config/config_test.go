@@ -32,7 +32,21 @@ func TestLoadDefaults(t *testing.T) {
 		expected interface{}
 	}{
 		{"Port", cfg.Port, 8080},
+		{"GRPCPort", cfg.GRPCPort, 9090},
 		{"LogLevel", cfg.LogLevel, "info"},
+		{"DefaultTenant", cfg.DefaultTenant, "default"},
+		{"AuthEnabled", cfg.AuthEnabled, true},
+		{"QueryTimeout", cfg.QueryTimeout, 60},
+		{"QueryAttempts", cfg.QueryAttempts, 3},
+		{"QueryHealthInterval", cfg.QueryHealthInterval, 5},
+		{"RateLimit", cfg.RateLimit, 600},
+		{"RateLimitBurst", cfg.RateLimitBurst, 60},
+		{"OIDCJWKSCacheTTL", cfg.OIDCJWKSCacheTTL, 3600},
+		{"OIDCGroupsClaim", cfg.OIDCGroupsClaim, "groups"},
+		{"OIDCTenantClaim", cfg.OIDCTenantClaim, "tenant"},
+		{"MaxResumableUploadSize", cfg.MaxResumableUploadSize, int64(256 << 20)},
+		{"MaxParseSize", cfg.MaxParseSize, int64(256 << 20)},
+		{"TusUploadExpiry", cfg.TusUploadExpiry, 86400},
 		{"LLMProvider", cfg.LLMProvider, "openai"},
 		{"StoreProvider", cfg.StoreProvider, "postgres"},
 		{"QueueProvider", cfg.QueueProvider, "nats"},
@@ -88,3 +102,21 @@ func TestLoadProviderOverrides(t *testing.T) {
 		t.Errorf("expected LLM provider 'stub', got %s", cfg.LLMProvider)
 	}
 }
+
+func TestValidateAdminAPIKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		wantErr bool
+	}{
+		{"", false},
+		{"change-me-admin-api-key", true},
+		{"short-but-not-placeholder", true},
+		{"3f6c1e0d9b8a7f6e5d4c3b2a19081726", false},
+	}
+	for _, tt := range tests {
+		err := Config{AdminAPIKey: tt.key}.ValidateAdminAPIKey()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateAdminAPIKey(%q) = %v, wantErr %v", tt.key, err, tt.wantErr)
+		}
+	}
+}
@@ -1,10 +1,24 @@
 package embeddings
 
+import "context"
+
 // Vector is a simple float32 slice wrapper.
 type Vector []float32
 
 // Embedder defines the embedding interface.
 type Embedder interface {
-	Embed(text string) (Vector, error)
-	EmbedBatch(texts []string) ([]Vector, error)
+	Embed(ctx context.Context, text string) (Vector, error)
+	EmbedBatch(ctx context.Context, texts []string) ([]Vector, error)
+}
+
+// Usage is the tokens one call consumed.
+type Usage struct {
+	Model  string
+	Tokens int64
+}
+
+// UsageReporter is implemented by embedders that report the tokens their
+// calls consume. OnUsage must be called before the embedder is used.
+type UsageReporter interface {
+	OnUsage(fn func(Usage))
 }
@@ -0,0 +1,69 @@
+package embeddings
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	callDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "docagents",
+		Name:      "embedding_request_duration_seconds",
+		Help:      "Time taken by embedding calls, by operation and outcome.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2, 4, 8, 15, 30},
+	}, []string{"operation", "outcome"})
+
+	tokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "docagents",
+		Name:      "embedding_tokens_total",
+		Help:      "Tokens consumed by embedding calls, by model.",
+	}, []string{"model"})
+
+	texts = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "docagents",
+		Name:      "embedding_texts_total",
+		Help:      "Texts sent for embedding.",
+	})
+)
+
+// WithMetrics records the latency and outcome of e's calls, and the tokens
+// they consume when e is a UsageReporter.
+func WithMetrics(e Embedder) Embedder {
+	if r, ok := e.(UsageReporter); ok {
+		r.OnUsage(func(u Usage) {
+			tokens.WithLabelValues(u.Model).Add(float64(u.Tokens))
+		})
+	}
+	return &instrumentedEmbedder{next: e}
+}
+
+type instrumentedEmbedder struct {
+	next Embedder
+}
+
+func (e *instrumentedEmbedder) Embed(ctx context.Context, text string) (Vector, error) {
+	start := time.Now()
+	vec, err := e.next.Embed(ctx, text)
+	observeCall("embed", start, err)
+	texts.Inc()
+	return vec, err
+}
+
+func (e *instrumentedEmbedder) EmbedBatch(ctx context.Context, batch []string) ([]Vector, error) {
+	start := time.Now()
+	vecs, err := e.next.EmbedBatch(ctx, batch)
+	observeCall("embed_batch", start, err)
+	texts.Add(float64(len(batch)))
+	return vecs, err
+}
+
+func observeCall(operation string, start time.Time, err error) {
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	callDuration.WithLabelValues(operation, outcome).Observe(time.Since(start).Seconds())
+}
@@ -14,8 +14,9 @@ import (
 
 // OpenAIEmbedder calls OpenAI's embeddings API.
 type OpenAIEmbedder struct {
-	model  openai.EmbeddingModel
-	client *openai.Client
+	model   openai.EmbeddingModel
+	client  *openai.Client
+	onUsage func(Usage)
 }
 
 const defaultEmbeddingTimeout = 30 * time.Second
@@ -35,7 +36,18 @@ func NewOpenAIEmbedder(apiKey string, model openai.EmbeddingModel) (*OpenAIEmbed
 	}, nil
 }
 
-func (e *OpenAIEmbedder) Embed(text string) (Vector, error) {
+// OnUsage implements UsageReporter.
+func (e *OpenAIEmbedder) OnUsage(fn func(Usage)) {
+	e.onUsage = fn
+}
+
+func (e *OpenAIEmbedder) reportUsage(resp *openai.CreateEmbeddingResponse) {
+	if e.onUsage != nil {
+		e.onUsage(Usage{Model: resp.Model, Tokens: resp.Usage.PromptTokens})
+	}
+}
+
+func (e *OpenAIEmbedder) Embed(ctx context.Context, text string) (Vector, error) {
 	if e == nil || e.client == nil {
 		return nil, fmt.Errorf("embedder not initialized")
 	}
@@ -46,7 +58,7 @@ func (e *OpenAIEmbedder) Embed(text string) (Vector, error) {
 		return nil, fmt.Errorf("text is empty after preprocessing")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), defaultEmbeddingTimeout)
+	ctx, cancel := context.WithTimeout(ctx, defaultEmbeddingTimeout)
 	defer cancel()
 
 	resp, err := e.client.Embeddings.New(ctx, openai.EmbeddingNewParams{
@@ -58,6 +70,7 @@ func (e *OpenAIEmbedder) Embed(text string) (Vector, error) {
 	if err != nil {
 		return nil, fmt.Errorf("openai embedding failed: %w", err)
 	}
+	e.reportUsage(resp)
 	if len(resp.Data) == 0 {
 		return nil, fmt.Errorf("no embedding data returned")
 	}
@@ -73,7 +86,7 @@ func (e *OpenAIEmbedder) Embed(text string) (Vector, error) {
 }
 
 // EmbedBatch generates embeddings for multiple texts in a single API call.
-func (e *OpenAIEmbedder) EmbedBatch(texts []string) ([]Vector, error) {
+func (e *OpenAIEmbedder) EmbedBatch(ctx context.Context, texts []string) ([]Vector, error) {
 	if e == nil || e.client == nil {
 		return nil, fmt.Errorf("embedder not initialized")
 	}
@@ -94,7 +107,7 @@ func (e *OpenAIEmbedder) EmbedBatch(texts []string) ([]Vector, error) {
 		return []Vector{}, nil
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), defaultEmbeddingTimeout)
+	ctx, cancel := context.WithTimeout(ctx, defaultEmbeddingTimeout)
 	defer cancel()
 
 	resp, err := e.client.Embeddings.New(ctx, openai.EmbeddingNewParams{
@@ -106,6 +119,7 @@ func (e *OpenAIEmbedder) EmbedBatch(texts []string) ([]Vector, error) {
 	if err != nil {
 		return nil, fmt.Errorf("openai batch embedding failed: %w", err)
 	}
+	e.reportUsage(resp)
 	if len(resp.Data) != len(processedTexts) {
 		return nil, fmt.Errorf("expected %d embeddings, got %d", len(processedTexts), len(resp.Data))
 	}
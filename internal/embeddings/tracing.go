@@ -0,0 +1,38 @@
+package embeddings
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"doc-agents/internal/tracing"
+)
+
+var tracer = otel.Tracer("doc-agents/internal/embeddings")
+
+// WithTracing wraps every call to e in a client span.
+func WithTracing(e Embedder) Embedder {
+	return &tracedEmbedder{next: e}
+}
+
+type tracedEmbedder struct {
+	next Embedder
+}
+
+func (e *tracedEmbedder) Embed(ctx context.Context, text string) (Vector, error) {
+	ctx, span := tracer.Start(ctx, "embeddings.Embed", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.Int("embeddings.texts", 1)))
+	vec, err := e.next.Embed(ctx, text)
+	tracing.End(span, err)
+	return vec, err
+}
+
+func (e *tracedEmbedder) EmbedBatch(ctx context.Context, texts []string) ([]Vector, error) {
+	ctx, span := tracer.Start(ctx, "embeddings.EmbedBatch", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.Int("embeddings.texts", len(texts))))
+	vecs, err := e.next.EmbedBatch(ctx, texts)
+	tracing.End(span, err)
+	return vecs, err
+}
@@ -0,0 +1,52 @@
+// Package errcode gives domain errors the stable codes API error responses
+// carry, so clients branch on a code rather than on the message.
+package errcode
+
+import "errors"
+
+// Error is a sentinel error with a code.
+type Error struct {
+	code    string
+	message string
+}
+
+// New returns an error with message and code; compare it with errors.Is like
+// any other sentinel error.
+func New(code, message string) *Error {
+	return &Error{code: code, message: message}
+}
+
+func (e *Error) Error() string {
+	return e.message
+}
+
+// Code returns the error's code.
+func (e *Error) Code() string {
+	return e.code
+}
+
+// Wrap returns err with code, for when what err means to the caller differs
+// from the code err carries.
+func Wrap(code string, err error) error {
+	return &wrapped{code: code, err: err}
+}
+
+type wrapped struct {
+	code string
+	err  error
+}
+
+func (e *wrapped) Error() string { return e.err.Error() }
+func (e *wrapped) Unwrap() error { return e.err }
+func (e *wrapped) Code() string  { return e.code }
+
+// Of returns the code of the first error in err's chain that has one, or ""
+// when none does. Error types outside this package take part by having a
+// Code() string method.
+func Of(err error) string {
+	var coded interface{ Code() string }
+	if errors.As(err, &coded) {
+		return coded.Code()
+	}
+	return ""
+}
@@ -0,0 +1,60 @@
+// Package events carries document processing progress from the workers to
+// the gateway, which streams it to clients as Server-Sent Events.
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Type names a processing transition of a document.
+type Type string
+
+const (
+	TypeQueued      Type = "queued"
+	TypeParsing     Type = "parsing"
+	TypeChunked     Type = "chunked"
+	TypeSummarizing Type = "summarizing"
+	TypeEmbedding   Type = "embedding"
+	TypeReady       Type = "ready"
+	TypeFailed      Type = "failed"
+	// TypeVersionFailed ends a new version's run that failed; the document
+	// keeps its live version and status.
+	TypeVersionFailed Type = "version_failed"
+)
+
+// Terminal reports whether no further events follow for the document.
+func (t Type) Terminal() bool {
+	return t == TypeReady || t == TypeFailed || t == TypeVersionFailed
+}
+
+// Event is one processing transition of a document.
+type Event struct {
+	DocumentID uuid.UUID `json:"document_id"`
+	Type       Type      `json:"type"`
+	// Chunks is set on chunked events.
+	Chunks int `json:"chunks,omitempty"`
+	// Attempt is the delivery number of the task that reached this stage.
+	Attempt int `json:"attempt,omitempty"`
+	// Version is set on version_failed events.
+	Version int `json:"version,omitempty"`
+	// Error explains a failed event.
+	Error string    `json:"error,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+// Publisher announces processing events. Publishing is best effort: events
+// are not persisted, and clients recover state from the status endpoint.
+type Publisher interface {
+	Publish(ctx context.Context, ev Event) error
+}
+
+// Bus publishes events and lets callers follow a single document.
+type Bus interface {
+	Publisher
+	// Subscribe returns a channel of events for docID and a function that
+	// ends the subscription and closes the channel.
+	Subscribe(docID uuid.UUID) (<-chan Event, func())
+}
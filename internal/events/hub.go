@@ -0,0 +1,73 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind before
+// further events for it are dropped.
+const subscriberBuffer = 32
+
+// Hub fans events out to the subscribers of each document within one
+// process. Used directly it is an in-memory Bus; NATS feeds it events
+// published by other services.
+type Hub struct {
+	mu   sync.Mutex
+	subs map[uuid.UUID]map[chan Event]struct{}
+}
+
+// NewHub creates an empty Hub.
+func NewHub() *Hub {
+	return &Hub{subs: make(map[uuid.UUID]map[chan Event]struct{})}
+}
+
+// Publish delivers ev to the local subscribers of its document.
+func (h *Hub) Publish(_ context.Context, ev Event) error {
+	h.Dispatch(ev)
+	return nil
+}
+
+// Dispatch delivers ev to the subscribers of its document. A subscriber that
+// is not keeping up misses the event rather than stalling everyone else.
+func (h *Hub) Dispatch(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs[ev.DocumentID] {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// Subscribe implements Bus.
+func (h *Hub) Subscribe(docID uuid.UUID) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	h.mu.Lock()
+	if h.subs[docID] == nil {
+		h.subs[docID] = make(map[chan Event]struct{})
+	}
+	h.subs[docID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subs[docID], ch)
+			if len(h.subs[docID]) == 0 {
+				delete(h.subs, docID)
+			}
+			h.mu.Unlock()
+			close(ch)
+		})
+	}
+	return ch, cancel
+}
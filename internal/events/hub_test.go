@@ -0,0 +1,69 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestHubDeliversToDocumentSubscribers(t *testing.T) {
+	hub := NewHub()
+	docA, docB := uuid.New(), uuid.New()
+
+	subA, cancelA := hub.Subscribe(docA)
+	defer cancelA()
+	subB, cancelB := hub.Subscribe(docB)
+	defer cancelB()
+
+	if err := hub.Publish(context.Background(), Event{DocumentID: docA, Type: TypeChunked, Chunks: 3}); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	select {
+	case ev := <-subA:
+		if ev.Type != TypeChunked || ev.Chunks != 3 {
+			t.Errorf("got %+v, want chunked with 3 chunks", ev)
+		}
+		if ev.Time.IsZero() {
+			t.Error("event time was not set")
+		}
+	default:
+		t.Fatal("subscriber of docA received nothing")
+	}
+
+	select {
+	case ev := <-subB:
+		t.Errorf("subscriber of docB received %+v", ev)
+	default:
+	}
+}
+
+func TestHubDropsEventsForSlowSubscribers(t *testing.T) {
+	hub := NewHub()
+	docID := uuid.New()
+	sub, cancel := hub.Subscribe(docID)
+	defer cancel()
+
+	for i := 0; i < subscriberBuffer+10; i++ {
+		hub.Dispatch(Event{DocumentID: docID, Type: TypeParsing})
+	}
+	if len(sub) != subscriberBuffer {
+		t.Errorf("buffered %d events, want %d", len(sub), subscriberBuffer)
+	}
+}
+
+func TestHubCancelClosesChannel(t *testing.T) {
+	hub := NewHub()
+	docID := uuid.New()
+	sub, cancel := hub.Subscribe(docID)
+
+	cancel()
+	cancel() // safe to call twice
+
+	if _, ok := <-sub; ok {
+		t.Error("channel still open after cancel")
+	}
+	// Publishing after the last subscriber left must not panic.
+	hub.Dispatch(Event{DocumentID: docID, Type: TypeReady})
+}
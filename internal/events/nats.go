@@ -0,0 +1,59 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+)
+
+// subjectPrefix is followed by the document ID, so consumers can listen to
+// one document or, with a wildcard, to all of them.
+const subjectPrefix = "events.documents."
+
+// NATS publishes events on NATS subjects and, once Listen is called, fans
+// events from every service out to local subscribers.
+type NATS struct {
+	log *slog.Logger
+	nc  *nats.Conn
+	hub *Hub
+}
+
+// NewNATS creates a NATS-backed Bus.
+func NewNATS(log *slog.Logger, nc *nats.Conn) *NATS {
+	return &NATS{log: log, nc: nc, hub: NewHub()}
+}
+
+// Publish implements Publisher.
+func (b *NATS) Publish(_ context.Context, ev Event) error {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	return b.nc.Publish(subjectPrefix+ev.DocumentID.String(), body)
+}
+
+// Listen subscribes to the events of all documents. Only services that serve
+// subscribers (the gateway) need to call it.
+func (b *NATS) Listen() error {
+	_, err := b.nc.Subscribe(subjectPrefix+"*", func(msg *nats.Msg) {
+		var ev Event
+		if err := json.Unmarshal(msg.Data, &ev); err != nil {
+			b.log.Warn("failed to decode document event", "subject", msg.Subject, "err", err)
+			return
+		}
+		b.hub.Dispatch(ev)
+	})
+	return err
+}
+
+// Subscribe implements Bus. Events arrive only after Listen has been called.
+func (b *NATS) Subscribe(docID uuid.UUID) (<-chan Event, func()) {
+	return b.hub.Subscribe(docID)
+}
@@ -0,0 +1,101 @@
+package extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// wordprocessingNS is the OOXML namespace of the elements inside word/document.xml.
+const wordprocessingNS = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+
+// maxDOCXBodySize caps how much decompressed XML is read from a DOCX,
+// protecting the extractor from zip bombs.
+const maxDOCXBodySize = 64 << 20 // 64MB
+
+// extractDOCX reads the main document part of an OOXML (.docx) file and
+// returns its text, one paragraph per line.
+func extractDOCX(content []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return "", err
+	}
+
+	var body *zip.File
+	for _, f := range zr.File {
+		if f.Name == "word/document.xml" {
+			body = f
+			break
+		}
+	}
+	if body == nil {
+		return "", errors.New("docx: word/document.xml not found")
+	}
+
+	rc, err := body.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	dec := xml.NewDecoder(io.LimitReader(rc, maxDOCXBodySize))
+	var textBuilder strings.Builder
+	inText := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space != wordprocessingNS {
+				continue
+			}
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				textBuilder.WriteString("\t")
+			case "br", "cr":
+				textBuilder.WriteString("\n")
+			}
+		case xml.EndElement:
+			if t.Name.Space != wordprocessingNS {
+				continue
+			}
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				textBuilder.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				textBuilder.Write(t)
+			}
+		}
+	}
+
+	return textBuilder.String(), nil
+}
+
+// isDOCX reports whether content is a ZIP archive containing word/document.xml.
+func isDOCX(content []byte) bool {
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return false
+	}
+	for _, f := range zr.File {
+		if f.Name == "word/document.xml" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package extractor
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"doc-agents/internal/errcode"
+)
+
+// MIME types handled by the built-in extractors.
+const (
+	TypePlainText = "text/plain"
+	TypePDF       = "application/pdf"
+	TypeDOCX      = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	TypeHTML      = "text/html"
+	TypeMarkdown  = "text/markdown"
+)
+
+// ErrUnsupportedType is returned when no extractor is registered for a MIME type.
+var ErrUnsupportedType = errcode.New("unsupported_file_type", "unsupported file type")
+
+// Extractor turns the raw bytes of an uploaded file into plain text.
+type Extractor interface {
+	Extract(content []byte) (string, error)
+}
+
+// Func adapts an ordinary function to the Extractor interface.
+type Func func(content []byte) (string, error)
+
+// Extract calls f(content).
+func (f Func) Extract(content []byte) (string, error) {
+	return f(content)
+}
+
+// Registry maps MIME types (and file extensions) to extractors.
+// It is safe for concurrent use.
+type Registry struct {
+	mu         sync.RWMutex
+	extractors map[string]Extractor
+	aliases    map[string]string
+	extensions map[string]string
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		extractors: make(map[string]Extractor),
+		aliases:    make(map[string]string),
+		extensions: make(map[string]string),
+	}
+}
+
+// NewDefaultRegistry creates a registry with the built-in extractors for
+// plain text, PDF, DOCX, HTML and Markdown.
+func NewDefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.Register(TypePlainText, Func(extractPlainText), ".txt")
+	r.Register(TypePDF, Func(extractPDF), ".pdf")
+	r.Register(TypeDOCX, Func(extractDOCX), ".docx")
+	r.Register(TypeHTML, Func(extractHTML), ".html", ".htm")
+	r.Register(TypeMarkdown, Func(extractMarkdown), ".md", ".markdown")
+	r.Alias("text/x-markdown", TypeMarkdown)
+	r.Alias("application/xhtml+xml", TypeHTML)
+	return r
+}
+
+// Register adds (or replaces) the extractor for mimeType. Any extensions
+// given (e.g. ".rtf") are used to detect the type when a client does not
+// send a Content-Type.
+func (r *Registry) Register(mimeType string, e Extractor, extensions ...string) {
+	mimeType = normalizeType(mimeType)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.extractors[mimeType] = e
+	for _, ext := range extensions {
+		r.extensions[strings.ToLower(ext)] = mimeType
+	}
+}
+
+// Alias makes alias resolve to the extractor registered for mimeType.
+// Useful for non-standard types clients send in the wild (e.g. text/x-markdown).
+func (r *Registry) Alias(alias, mimeType string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.aliases[normalizeType(alias)] = normalizeType(mimeType)
+}
+
+// Resolve returns the canonical registered MIME type for contentType,
+// ignoring parameters such as charset. The boolean is false when no
+// extractor handles the type.
+func (r *Registry) Resolve(contentType string) (string, bool) {
+	mimeType := normalizeType(contentType)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if canonical, ok := r.aliases[mimeType]; ok {
+		mimeType = canonical
+	}
+	_, ok := r.extractors[mimeType]
+	return mimeType, ok
+}
+
+// DetectType returns the registered MIME type for filename's extension.
+func (r *Registry) DetectType(filename string) (string, bool) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	mimeType, ok := r.extensions[ext]
+	return mimeType, ok
+}
+
+// Extract runs the extractor registered for contentType over content.
+func (r *Registry) Extract(contentType string, content []byte) (string, error) {
+	mimeType, ok := r.Resolve(contentType)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+	}
+	r.mu.RLock()
+	e := r.extractors[mimeType]
+	r.mu.RUnlock()
+	return e.Extract(content)
+}
+
+// Sniff detects a registered MIME type from the content's magic bytes.
+// ZIP containers are recognized as DOCX when they hold a Word document part.
+func (r *Registry) Sniff(content []byte) (string, bool) {
+	detected := http.DetectContentType(content)
+	if strings.HasPrefix(detected, "application/zip") && isDOCX(content) {
+		detected = TypeDOCX
+	}
+	return r.Resolve(detected)
+}
+
+// Extensions returns the sorted list of file extensions the registry can detect.
+func (r *Registry) Extensions() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	exts := make([]string, 0, len(r.extensions))
+	for ext := range r.extensions {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return exts
+}
+
+// normalizeType lowercases a Content-Type value and strips its parameters.
+func normalizeType(contentType string) string {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mediaType
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+func extractPlainText(content []byte) (string, error) {
+	return string(content), nil
+}
@@ -0,0 +1,144 @@
+package extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRegistryResolve(t *testing.T) {
+	r := NewDefaultRegistry()
+
+	tests := []struct {
+		contentType string
+		want        string
+		wantOK      bool
+	}{
+		{"text/plain", TypePlainText, true},
+		{"text/html; charset=utf-8", TypeHTML, true},
+		{"TEXT/MARKDOWN", TypeMarkdown, true},
+		{"text/x-markdown", TypeMarkdown, true},
+		{TypeDOCX, TypeDOCX, true},
+		{"application/msword", "application/msword", false},
+	}
+	for _, tt := range tests {
+		got, ok := r.Resolve(tt.contentType)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("Resolve(%q) = (%q, %v), want (%q, %v)", tt.contentType, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestRegistryDetectType(t *testing.T) {
+	r := NewDefaultRegistry()
+
+	if got, ok := r.DetectType("Spec.DOCX"); !ok || got != TypeDOCX {
+		t.Errorf("expected DOCX for Spec.DOCX, got %q (ok=%v)", got, ok)
+	}
+	if got, ok := r.DetectType("export.htm"); !ok || got != TypeHTML {
+		t.Errorf("expected HTML for export.htm, got %q (ok=%v)", got, ok)
+	}
+	if _, ok := r.DetectType("sheet.xlsx"); ok {
+		t.Error("expected .xlsx to be unsupported")
+	}
+}
+
+func TestRegistryCustomExtractor(t *testing.T) {
+	r := NewRegistry()
+	r.Register("application/rtf", Func(func(content []byte) (string, error) {
+		return strings.ToUpper(string(content)), nil
+	}), ".rtf")
+
+	mimeType, ok := r.DetectType("notes.rtf")
+	if !ok {
+		t.Fatal("expected .rtf to be detected")
+	}
+	text, err := r.Extract(mimeType, []byte("hello"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text != "HELLO" {
+		t.Errorf("expected HELLO, got %q", text)
+	}
+
+	if _, err := r.Extract("application/pdf", nil); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("expected ErrUnsupportedType, got %v", err)
+	}
+}
+
+func TestExtractHTML(t *testing.T) {
+	input := `<html><head><title>Ignored</title><style>p{color:red}</style></head>
+<body><h1>Heading</h1><p>First <b>para</b>.</p><script>alert("x")</script>
+<noscript>enable js</noscript><ul><li>one</li><li>two</li></ul></body></html>`
+
+	text, err := extractHTML([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "Heading\nFirst para .\none\ntwo"
+	if text != want {
+		t.Errorf("expected %q, got %q", want, text)
+	}
+}
+
+func TestExtractMarkdown(t *testing.T) {
+	input := "# Title\n\n- item with [link](http://x)\n\n```go\nfmt.Println(\"hi\")\n```\n\n---\n\nSome **bold** and *italic* and `code`. ![logo](logo.png)"
+
+	text, err := extractMarkdown([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "Title\n\nitem with link\n\nfmt.Println(\"hi\")\n\n\nSome bold and italic and code. logo"
+	if text != want {
+		t.Errorf("expected %q, got %q", want, text)
+	}
+}
+
+func TestExtractDOCX(t *testing.T) {
+	docXML := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+  <w:body>
+    <w:p><w:r><w:t>Hello</w:t></w:r><w:r><w:tab/><w:t xml:space="preserve">world</w:t></w:r></w:p>
+    <w:p><w:r><w:t>Second paragraph</w:t></w:r></w:p>
+  </w:body>
+</w:document>`
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, err := zw.Create("word/document.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte(docXML)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	text, err := extractDOCX(buf.Bytes())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "Hello\tworld\nSecond paragraph\n"
+	if text != want {
+		t.Errorf("expected %q, got %q", want, text)
+	}
+}
+
+func TestExtractDOCXMissingBody(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if _, err := zw.Create("other.xml"); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := extractDOCX(buf.Bytes()); err == nil {
+		t.Error("expected error for archive without word/document.xml")
+	}
+}
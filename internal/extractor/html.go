@@ -0,0 +1,76 @@
+package extractor
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// skippedHTMLElements never contribute visible text.
+var skippedHTMLElements = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"noscript": true,
+	"template": true,
+	"head":     true,
+	"svg":      true,
+}
+
+// blockHTMLElements start on a new line when rendered.
+var blockHTMLElements = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"section": true, "article": true, "header": true, "footer": true,
+	"blockquote": true, "pre": true, "table": true, "ul": true, "ol": true,
+}
+
+// extractHTML returns the visible text of an HTML document, dropping
+// scripts, styles and markup.
+func extractHTML(content []byte) (string, error) {
+	doc, err := html.Parse(bytes.NewReader(content))
+	if err != nil {
+		return "", err
+	}
+
+	var textBuilder strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			if text := strings.TrimSpace(n.Data); text != "" {
+				textBuilder.WriteString(text)
+				textBuilder.WriteString(" ")
+			}
+			return
+		case html.CommentNode:
+			return
+		case html.ElementNode:
+			if skippedHTMLElements[n.Data] {
+				return
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if n.Type == html.ElementNode && blockHTMLElements[n.Data] {
+			textBuilder.WriteString("\n")
+		}
+	}
+	walk(doc)
+
+	return collapseBlankLines(textBuilder.String()), nil
+}
+
+// collapseBlankLines trims each line and drops empty ones.
+func collapseBlankLines(s string) string {
+	lines := strings.Split(s, "\n")
+	out := lines[:0]
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			out = append(out, line)
+		}
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,52 @@
+package extractor
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	mdFence      = regexp.MustCompile("^\\s*(```|~~~)")
+	mdHeading    = regexp.MustCompile(`^\s{0,3}#{1,6}\s+`)
+	mdBlockquote = regexp.MustCompile(`^\s{0,3}>\s?`)
+	mdListItem   = regexp.MustCompile(`^\s*([-*+]|\d+[.)])\s+`)
+	mdRule       = regexp.MustCompile(`^\s{0,3}([-*_]\s*){3,}$`)
+	mdImage      = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLink       = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	mdInlineCode = regexp.MustCompile("`([^`]+)`")
+	mdStrong     = regexp.MustCompile(`(\*\*|__)(.+?)(\*\*|__)`)
+	mdEmphasis   = regexp.MustCompile(`\*([^*\s][^*]*)\*`)
+)
+
+// extractMarkdown strips Markdown syntax and returns the readable text.
+// Code block contents are kept; fences, heading markers, list bullets,
+// link targets and emphasis markers are removed.
+func extractMarkdown(content []byte) (string, error) {
+	lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+	out := make([]string, 0, len(lines))
+	inCode := false
+	for _, line := range lines {
+		if mdFence.MatchString(line) {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			out = append(out, line)
+			continue
+		}
+		if mdRule.MatchString(line) {
+			continue
+		}
+
+		line = mdHeading.ReplaceAllString(line, "")
+		line = mdBlockquote.ReplaceAllString(line, "")
+		line = mdListItem.ReplaceAllString(line, "")
+		line = mdImage.ReplaceAllString(line, "$1")
+		line = mdLink.ReplaceAllString(line, "$1")
+		line = mdInlineCode.ReplaceAllString(line, "$1")
+		line = mdStrong.ReplaceAllString(line, "$2")
+		line = mdEmphasis.ReplaceAllString(line, "$1")
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n"), nil
+}
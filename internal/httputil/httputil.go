@@ -1,10 +1,14 @@
 package httputil
 
 import (
+	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"reflect"
 	"strings"
 	"time"
 
@@ -13,26 +17,61 @@ import (
 	"github.com/go-playground/validator/v10"
 
 	"doc-agents/internal/config"
+	"doc-agents/internal/errcode"
 )
 
 // Deps is an interface that all service-specific dependencies must implement
 type Deps interface {
 	GetConfig() config.Config
 	GetLog() *slog.Logger
+	// ReadyChecks returns the checks of the dependencies /readyz verifies
+	ReadyChecks() []Check
 }
 
-// NewRouter creates a chi router with standard middleware (RequestID, Recoverer, Logger, Timeout, RealIP).
+// RequestTimeout bounds ordinary API requests.
+const RequestTimeout = 60 * time.Second
+
+// NewRouter creates a chi router with standard middleware (RequestID, RealIP, Trace, Recoverer, Logger).
+// Services add Timeout(RequestTimeout) to their routes; long-lived ones, such
+// as resumable uploads and event streams, are mounted without it.
 func NewRouter(log *slog.Logger) *chi.Mux {
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
-	r.Use(middleware.Timeout(60 * time.Second))
+	r.Use(Trace)
 	r.Use(Recoverer(log))
 	r.Use(RequestLogger(log))
 
 	return r
 }
 
+// Timeout cancels a request's context after d and responds 504 if the
+// handler has not finished. Long-lived routes, such as Server-Sent Events
+// streams, are mounted without it rather than exempted per request.
+func Timeout(d time.Duration) func(next http.Handler) http.Handler {
+	return middleware.Timeout(d)
+}
+
+// InternalTokenHeader carries the shared token the gateway presents to
+// internal services.
+const InternalTokenHeader = "X-Internal-Token"
+
+// RequireInternalToken rejects requests whose X-Internal-Token header does
+// not match token with 401, so internal services only act for callers that
+// hold the shared token.
+func RequireInternalToken(log *slog.Logger, token string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got := r.Header.Get(InternalTokenHeader)
+			if token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				Fail(log, w, r, "invalid internal token", nil, http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // WriteJSON writes a JSON response with proper headers.
 func WriteJSON(w http.ResponseWriter, status int, body any) {
 	w.Header().Set("Content-Type", "application/json")
@@ -42,7 +81,8 @@ func WriteJSON(w http.ResponseWriter, status int, body any) {
 	_ = enc.Encode(body)
 }
 
-// HealthHandler returns a simple health check endpoint.
+// HealthHandler returns a simple liveness endpoint; it does not look at
+// dependencies, which ReadyHandler checks.
 func HealthHandler(deps Deps) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -52,31 +92,36 @@ func HealthHandler(deps Deps) http.HandlerFunc {
 	}
 }
 
-// ServeHealth starts an HTTP server with a health check endpoint.
+// ServeHealth starts an HTTP server with the health, readiness and metrics endpoints, until ctx is done.
 // serviceName is used for logging (e.g., "parser", "analysis").
 // This is a convenience function for services that only need a health endpoint.
-func ServeHealth(deps Deps, serviceName string) error {
+func ServeHealth(ctx context.Context, deps Deps, serviceName string) error {
 	r := NewRouter(deps.GetLog())
 	r.Get("/healthz", HealthHandler(deps))
+	r.Get("/readyz", ReadyHandler(deps))
+	r.Method(http.MethodGet, "/metrics", MetricsHandler(deps))
 
 	addr := fmt.Sprintf(":%d", deps.GetConfig().Port)
 	deps.GetLog().Info(serviceName+" health endpoint listening", "addr", addr)
-	return http.ListenAndServe(addr, r)
+	return ListenAndServe(ctx, deps.GetLog(), addr, r, time.Duration(deps.GetConfig().ShutdownTimeout)*time.Second)
 }
 
-// RequestLogger is a lightweight HTTP logger that uses slog.
+// RequestLogger is a lightweight HTTP logger that uses slog. It also
+// records every request in the HTTP duration histogram served on /metrics.
 func RequestLogger(log *slog.Logger) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
 			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
 			next.ServeHTTP(ww, r)
+			elapsed := time.Since(start)
+			observeRequest(r, ww.Status(), elapsed)
 			log.Info("request",
 				"method", r.Method,
 				"path", r.URL.Path,
 				"status", ww.Status(),
 				"bytes", ww.BytesWritten(),
-				"duration_ms", time.Since(start).Milliseconds(),
+				"duration_ms", elapsed.Milliseconds(),
 				"request_id", middleware.GetReqID(r.Context()),
 			)
 		})
@@ -90,7 +135,7 @@ func Recoverer(log *slog.Logger) func(next http.Handler) http.Handler {
 			defer func() {
 				if rec := recover(); rec != nil {
 					log.Error("panic recovered", "panic", rec, "path", r.URL.Path, "method", r.Method, "request_id", middleware.GetReqID(r.Context()))
-					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					writeError(w, r, http.StatusInternalServerError, ErrorResponse{Code: CodeInternal, Message: http.StatusText(http.StatusInternalServerError)})
 				}
 			}()
 			next.ServeHTTP(w, r)
@@ -98,30 +143,121 @@ func Recoverer(log *slog.Logger) func(next http.Handler) http.Handler {
 	}
 }
 
-// Fail writes an error response with consistent logging.
-func Fail(log *slog.Logger, w http.ResponseWriter, message string, err error, status int) {
+// Error codes of responses whose error carries none of its own; see Fail.
+const (
+	CodeInvalidRequest   = "invalid_request"
+	CodeValidationFailed = "validation_failed"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodeTooLarge         = "payload_too_large"
+	CodeRateLimited      = "rate_limited"
+	CodeInternal         = "internal_error"
+	CodeUnavailable      = "unavailable"
+)
+
+// ErrorResponse is the body of every error response. Code is stable, so
+// clients branch on it rather than on Message; RequestID matches the
+// request's log lines.
+type ErrorResponse struct {
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	RequestID string       `json:"request_id,omitempty"`
+	Details   []FieldError `json:"details,omitempty"`
+}
+
+// FieldError is one invalid field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Fail logs err and writes an error response with message and status. The
+// code is that of the first error in err's chain that has one, such as
+// store.ErrDocumentNotFound, and otherwise follows from status.
+func Fail(log *slog.Logger, w http.ResponseWriter, r *http.Request, message string, err error, status int) {
 	log.Error(message, "err", err)
 	if status == 0 {
 		status = http.StatusInternalServerError
 	}
-	http.Error(w, message, status)
+	code := errcode.Of(err)
+	if code == "" {
+		code = statusCode(status)
+	}
+	writeError(w, r, status, ErrorResponse{Code: code, Message: message})
+}
+
+// InvalidFields answers a request with invalid fields with 400, listing
+// each field in the details.
+func InvalidFields(log *slog.Logger, w http.ResponseWriter, r *http.Request, fields []FieldError) {
+	messages := make([]string, len(fields))
+	for i, f := range fields {
+		messages[i] = f.Message
+	}
+	message := strings.Join(messages, "; ")
+	log.Error(message)
+	writeError(w, r, http.StatusBadRequest, ErrorResponse{Code: CodeValidationFailed, Message: message, Details: fields})
 }
 
-// Validator is a global validator instance for request validation.
-var Validator = validator.New()
+func writeError(w http.ResponseWriter, r *http.Request, status int, resp ErrorResponse) {
+	resp.RequestID = middleware.GetReqID(r.Context())
+	WriteJSON(w, status, resp)
+}
 
-// ValidationError formats validator errors into user-friendly messages.
-func ValidationError(log *slog.Logger, w http.ResponseWriter, err error) {
-	var messages []string
-	if validationErrs, ok := err.(validator.ValidationErrors); ok {
-		for _, fieldErr := range validationErrs {
-			messages = append(messages, formatFieldError(fieldErr))
-		}
-	} else {
-		messages = append(messages, err.Error())
+// statusCode is the code of an error response with status.
+func statusCode(status int) string {
+	switch status {
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return CodeTooLarge
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	}
+	if status >= 500 {
+		return CodeInternal
 	}
+	return CodeInvalidRequest
+}
+
+// Validator is a global validator instance for request validation. Errors
+// name fields by their JSON names, as clients send them.
+var Validator = newValidator()
 
-	Fail(log, w, strings.Join(messages, "; "), err, http.StatusBadRequest)
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			return f.Name
+		}
+		return name
+	})
+	return v
+}
+
+// ValidationError answers a request that failed validation with 400, one
+// detail per invalid field.
+func ValidationError(log *slog.Logger, w http.ResponseWriter, r *http.Request, err error) {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		Fail(log, w, r, err.Error(), err, http.StatusBadRequest)
+		return
+	}
+	fields := make([]FieldError, len(validationErrs))
+	for i, fieldErr := range validationErrs {
+		fields[i] = FieldError{Field: fieldErr.Field(), Message: formatFieldError(fieldErr)}
+	}
+	InvalidFields(log, w, r, fields)
 }
 
 // formatFieldError converts a validator.FieldError to a human-readable message.
@@ -0,0 +1,125 @@
+package httputil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"doc-agents/internal/errcode"
+)
+
+func TestTimeoutIgnoresAcceptHeader(t *testing.T) {
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	h := Timeout(10 * time.Millisecond)(slow)
+
+	// Asking for an event stream must not lift the deadline off an ordinary route
+	req := httptest.NewRequest(http.MethodPost, "/api/query", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected 504, got %d", w.Code)
+	}
+}
+
+func TestRequireInternalToken(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		name       string
+		configured string
+		sent       string
+		want       int
+	}{
+		{name: "matching token", configured: "secret", sent: "secret", want: http.StatusNoContent},
+		{name: "missing token", configured: "secret", want: http.StatusUnauthorized},
+		{name: "wrong token", configured: "secret", sent: "secreT", want: http.StatusUnauthorized},
+		{name: "no token configured", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/query", nil)
+			if tt.sent != "" {
+				req.Header.Set(InternalTokenHeader, tt.sent)
+			}
+			w := httptest.NewRecorder()
+			RequireInternalToken(log, tt.configured)(ok).ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+}
+
+func TestFail(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	notReady := errcode.New("summary_not_ready", "summary not found")
+
+	tests := []struct {
+		name     string
+		err      error
+		status   int
+		wantCode string
+	}{
+		{name: "coded error", err: notReady, status: http.StatusNotFound, wantCode: "summary_not_ready"},
+		{name: "wrapped coded error", err: fmt.Errorf("load: %w", notReady), status: http.StatusNotFound, wantCode: "summary_not_ready"},
+		{name: "code from status", err: errors.New("db down"), status: http.StatusNotFound, wantCode: CodeNotFound},
+		{name: "no status", err: errors.New("db down"), wantCode: CodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp ErrorResponse
+			w := httptest.NewRecorder()
+			h := NewRouter(log)
+			h.Get("/", func(w http.ResponseWriter, r *http.Request) {
+				Fail(log, w, r, "failed", tt.err, tt.status)
+			})
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("body %q: %v", w.Body.String(), err)
+			}
+			if resp.Code != tt.wantCode || resp.Message != "failed" || resp.RequestID == "" {
+				t.Errorf("response = %+v, want code %s with message and request id", resp, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestValidationErrorListsFields(t *testing.T) {
+	var req struct {
+		Question string `json:"question" validate:"required"`
+		TopK     int    `json:"top_k" validate:"max=20"`
+	}
+	req.TopK = 50
+	w := httptest.NewRecorder()
+	ValidationError(slog.New(slog.NewTextHandler(io.Discard, nil)), w, httptest.NewRequest(http.MethodPost, "/", nil), Validator.Struct(&req))
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+	var resp ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	want := []FieldError{
+		{Field: "question", Message: "question is required"},
+		{Field: "top_k", Message: "top_k must be at most 20"},
+	}
+	if resp.Code != CodeValidationFailed || !slices.Equal(resp.Details, want) {
+		t.Errorf("response = %+v, want %s with details %+v", resp, CodeValidationFailed, want)
+	}
+}
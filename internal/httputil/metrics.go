@@ -0,0 +1,52 @@
+package httputil
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "docagents",
+	Name:      "http_request_duration_seconds",
+	Help:      "Time taken to serve HTTP requests, by route pattern and status.",
+	Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+}, []string{"method", "route", "status"})
+
+// observeRequest records a served request under its route pattern, so that
+// requests for different documents share a series. Requests no route
+// matched are counted together.
+func observeRequest(r *http.Request, status int, elapsed time.Duration) {
+	route := "unmatched"
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+		route = rctx.RoutePattern()
+	}
+	if status == 0 {
+		status = http.StatusOK
+	}
+	requestDuration.WithLabelValues(r.Method, route, strconv.Itoa(status)).Observe(elapsed.Seconds())
+}
+
+// MetricsHandler serves the process's Prometheus metrics. When
+// METRICS_TOKEN is set, scrapes without it as their bearer token get 401.
+func MetricsHandler(deps Deps) http.Handler {
+	token := deps.GetConfig().MetricsToken
+	metrics := promhttp.Handler()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" {
+			got, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				Fail(deps.GetLog(), w, r, "invalid metrics token", nil, http.StatusUnauthorized)
+				return
+			}
+		}
+		metrics.ServeHTTP(w, r)
+	})
+}
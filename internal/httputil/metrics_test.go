@@ -0,0 +1,48 @@
+package httputil
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"doc-agents/internal/config"
+)
+
+type testDeps struct {
+	cfg    config.Config
+	checks []Check
+}
+
+func (d testDeps) GetConfig() config.Config { return d.cfg }
+func (d testDeps) GetLog() *slog.Logger     { return slog.New(slog.NewTextHandler(io.Discard, nil)) }
+func (d testDeps) ReadyChecks() []Check     { return d.checks }
+
+func TestMetricsRecordRoutePatterns(t *testing.T) {
+	deps := testDeps{cfg: config.Config{MetricsToken: "scrape-token"}}
+	r := NewRouter(deps.GetLog())
+	r.Get("/api/documents/{id}/status", func(w http.ResponseWriter, r *http.Request) {})
+	r.Method(http.MethodGet, "/metrics", MetricsHandler(deps))
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/documents/6f1c2b7e-3a0d-4c5e-9f8a-1b2c3d4e5f60/status", nil))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("scrape without the token = %d, want 401", w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer scrape-token")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("scrape = %d, want 200", w.Code)
+	}
+	want := `docagents_http_request_duration_seconds_count{method="GET",route="/api/documents/{id}/status",status="200"} 1`
+	if !strings.Contains(w.Body.String(), want) {
+		t.Errorf("metrics do not contain %s", want)
+	}
+}
@@ -0,0 +1,119 @@
+package httputil
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// readyCacheTTL is how long /readyz answers from its last round of
+	// checks, so frequent probes from several sources stay cheap.
+	readyCacheTTL = 2 * time.Second
+	// readyCheckTimeout bounds each dependency check.
+	readyCheckTimeout = 2 * time.Second
+)
+
+// Check is one dependency /readyz verifies; Run returns nil when the
+// dependency is usable. The service keeps working without an optional
+// dependency, so its failure is reported without failing readiness.
+type Check struct {
+	Name     string
+	Run      func(ctx context.Context) error
+	Optional bool
+}
+
+// CheckResult is the outcome of one Check.
+type CheckResult struct {
+	Status    string  `json:"status" validate:"oneof=ok failed"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+	Optional  bool    `json:"optional,omitempty"`
+}
+
+// ReadyResponse is the body of /readyz.
+type ReadyResponse struct {
+	Status    string                 `json:"status" validate:"oneof=ready degraded not_ready"`
+	Checks    map[string]CheckResult `json:"checks"`
+	CheckedAt time.Time              `json:"checked_at"`
+}
+
+// ReadyHandler reports whether every dependency of the service is usable:
+// 200 when the required checks pass, "degraded" when an optional one does
+// not, and 503 otherwise, with the result and latency of each check. Unlike
+// /healthz, a failing /readyz takes the instance out of rotation without
+// restarting it.
+func ReadyHandler(deps Deps) http.HandlerFunc {
+	rd := newReadiness(deps.GetLog(), deps.ReadyChecks(), readyCacheTTL)
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := rd.check()
+		status := http.StatusOK
+		if resp.Status == "not_ready" {
+			status = http.StatusServiceUnavailable
+		}
+		WriteJSON(w, status, resp)
+	}
+}
+
+// readiness runs the checks at most once per ttl; probes arriving while a
+// round runs wait for it and share its result.
+type readiness struct {
+	log    *slog.Logger
+	checks []Check
+	ttl    time.Duration
+
+	mu   sync.Mutex
+	last ReadyResponse
+}
+
+func newReadiness(log *slog.Logger, checks []Check, ttl time.Duration) *readiness {
+	return &readiness{log: log, checks: checks, ttl: ttl}
+}
+
+func (rd *readiness) check() ReadyResponse {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+	if !rd.last.CheckedAt.IsZero() && time.Since(rd.last.CheckedAt) < rd.ttl {
+		return rd.last
+	}
+
+	results := make([]CheckResult, len(rd.checks))
+	var wg sync.WaitGroup
+	for i, c := range rd.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Not the probe's context: a client hanging up must not fail a
+			// result other probes are served from
+			ctx, cancel := context.WithTimeout(context.Background(), readyCheckTimeout)
+			defer cancel()
+			start := time.Now()
+			err := c.Run(ctx)
+			results[i] = CheckResult{Status: "ok", LatencyMS: float64(time.Since(start).Microseconds()) / 1000, Optional: c.Optional}
+			if err != nil {
+				results[i].Status = "failed"
+				results[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	resp := ReadyResponse{Status: "ready", Checks: make(map[string]CheckResult, len(rd.checks)), CheckedAt: time.Now().UTC()}
+	for i, c := range rd.checks {
+		resp.Checks[c.Name] = results[i]
+		if results[i].Status == "ok" {
+			continue
+		}
+		rd.log.Warn("readiness check failed", "check", c.Name, "optional", c.Optional, "err", results[i].Error)
+		switch {
+		case !c.Optional:
+			resp.Status = "not_ready"
+		case resp.Status == "ready":
+			resp.Status = "degraded"
+		}
+	}
+	rd.last = resp
+	return resp
+}
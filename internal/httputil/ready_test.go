@@ -0,0 +1,94 @@
+package httputil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func okCheck(context.Context) error { return nil }
+
+func TestReadyHandler(t *testing.T) {
+	down := func(context.Context) error { return errors.New("connection refused") }
+
+	tests := []struct {
+		name       string
+		checks     []Check
+		wantCode   int
+		wantStatus string
+	}{
+		{
+			name:       "all checks pass",
+			checks:     []Check{{Name: "postgres", Run: okCheck}, {Name: "redis", Run: okCheck, Optional: true}},
+			wantCode:   http.StatusOK,
+			wantStatus: "ready",
+		},
+		{
+			name:       "optional check fails",
+			checks:     []Check{{Name: "postgres", Run: okCheck}, {Name: "redis", Run: down, Optional: true}},
+			wantCode:   http.StatusOK,
+			wantStatus: "degraded",
+		},
+		{
+			name:       "required check fails",
+			checks:     []Check{{Name: "postgres", Run: down}, {Name: "redis", Run: okCheck, Optional: true}},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: "not_ready",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ReadyHandler(testDeps{checks: tt.checks})(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status code = %d, want %d", w.Code, tt.wantCode)
+			}
+			var resp ReadyResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", resp.Status, tt.wantStatus)
+			}
+			if len(resp.Checks) != len(tt.checks) {
+				t.Fatalf("checks = %v, want one per dependency", resp.Checks)
+			}
+			for _, c := range tt.checks {
+				got := resp.Checks[c.Name]
+				wantErr := c.Run(context.Background()) != nil
+				if (got.Status == "failed") != wantErr || (got.Error != "") != wantErr {
+					t.Errorf("check %s = %+v", c.Name, got)
+				}
+			}
+		})
+	}
+}
+
+func TestReadinessReusesRecentResults(t *testing.T) {
+	var runs atomic.Int32
+	checks := []Check{{Name: "postgres", Run: func(context.Context) error {
+		runs.Add(1)
+		return nil
+	}}}
+	rd := newReadiness(slog.New(slog.NewTextHandler(io.Discard, nil)), checks, 50*time.Millisecond)
+
+	first := rd.check()
+	if second := rd.check(); !second.CheckedAt.Equal(first.CheckedAt) || runs.Load() != 1 {
+		t.Fatalf("checks ran %d times within the ttl, want 1", runs.Load())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	rd.check()
+	if runs.Load() != 2 {
+		t.Errorf("checks ran %d times after the ttl, want 2", runs.Load())
+	}
+}
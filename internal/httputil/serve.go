@@ -0,0 +1,63 @@
+package httputil
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+type drainingKey struct{}
+
+// Draining returns a channel that is closed when the server handling the
+// request starts shutting down. Long-lived handlers, such as event streams,
+// return on it so shutdown does not wait for clients that never leave; the
+// channel is nil, and never ready, outside Serve.
+func Draining(ctx context.Context) <-chan struct{} {
+	ch, _ := ctx.Value(drainingKey{}).(chan struct{})
+	return ch
+}
+
+// Serve runs srv on lis until ctx is done, then stops accepting connections
+// and waits up to timeout for in-flight requests before closing the rest.
+// It returns nil after a shutdown that was asked for.
+func Serve(ctx context.Context, log *slog.Logger, srv *http.Server, lis net.Listener, timeout time.Duration) error {
+	draining := make(chan struct{})
+	srv.BaseContext = func(net.Listener) context.Context {
+		return context.WithValue(context.Background(), drainingKey{}, draining)
+	}
+	srv.RegisterOnShutdown(func() { close(draining) })
+
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(lis) }()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Info("http server shutting down", "addr", lis.Addr().String(), "timeout", timeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := srv.Shutdown(shutdownCtx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Warn("requests still running after shutdown timeout; closing their connections")
+		err = srv.Close()
+	}
+	if serveErr := <-errc; !errors.Is(serveErr, http.ErrServerClosed) {
+		return serveErr
+	}
+	return err
+}
+
+// ListenAndServe listens on addr and serves h like Serve.
+func ListenAndServe(ctx context.Context, log *slog.Logger, addr string, h http.Handler, timeout time.Duration) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return Serve(ctx, log, &http.Server{Addr: addr, Handler: h, ReadHeaderTimeout: 10 * time.Second}, lis, timeout)
+}
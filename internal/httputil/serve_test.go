@@ -0,0 +1,88 @@
+package httputil
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServeFinishesRequestsOnShutdown(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	started := make(chan struct{})
+	streaming := make(chan struct{})
+	release := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})
+	mux.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		close(streaming)
+		<-Draining(r.Context())
+	})
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- Serve(ctx, log, &http.Server{Handler: mux}, lis, time.Minute) }()
+
+	base := "http://" + lis.Addr().String()
+	streamed := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(base + "/stream")
+		if err == nil {
+			resp.Body.Close()
+		}
+		streamed <- err
+	}()
+	got := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(base + "/slow")
+		if err != nil {
+			got <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		got <- string(body)
+	}()
+	<-started
+	<-streaming
+	stop()
+
+	// A long-lived handler returns once shutdown starts; a slow one finishes
+	select {
+	case err := <-streamed:
+		if err != nil {
+			t.Fatalf("stream request failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("long-lived request was not told to stop")
+	}
+	select {
+	case <-served:
+		t.Fatal("Serve returned with a request in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if body := <-got; body != "done" {
+		t.Errorf("in-flight request got %q, want done", body)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Serve = %v, want nil after shutdown", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Serve did not return after the last request")
+	}
+}
@@ -0,0 +1,41 @@
+package httputil
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"doc-agents/internal/tracing/tracingtest"
+)
+
+func TestTraceContinuesCallersTrace(t *testing.T) {
+	spans := tracingtest.Record(t)
+	r := NewRouter(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	r.Post("/api/query", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/query", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	got := spans.GetSpans()
+	if len(got) != 1 {
+		t.Fatalf("recorded %d spans, want 1", len(got))
+	}
+	span := got[0]
+	if span.Name != "POST /api/query" {
+		t.Errorf("span name = %q, want POST /api/query", span.Name)
+	}
+	if span.SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace id = %s, want the caller's", span.SpanContext.TraceID())
+	}
+	if span.Parent.SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("parent span = %s, want the caller's", span.Parent.SpanID())
+	}
+	if span.Status.Code.String() != "Error" {
+		t.Errorf("status = %s, want Error for a 503", span.Status.Code)
+	}
+}
@@ -7,3 +7,16 @@ type Client interface {
 	Summarize(ctx context.Context, text string) (string, []string, error)
 	Answer(ctx context.Context, question, context string, contextQuality float32) (string, float32, error)
 }
+
+// Usage is the tokens one call consumed.
+type Usage struct {
+	Model            string
+	PromptTokens     int64
+	CompletionTokens int64
+}
+
+// UsageReporter is implemented by clients that report the tokens their calls
+// consume. OnUsage must be called before the client is used.
+type UsageReporter interface {
+	OnUsage(fn func(Usage))
+}
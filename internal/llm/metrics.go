@@ -0,0 +1,62 @@
+package llm
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	callDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "docagents",
+		Name:      "llm_request_duration_seconds",
+		Help:      "Time taken by LLM calls, by operation and outcome.",
+		Buckets:   []float64{.25, .5, 1, 2, 4, 8, 15, 30, 60},
+	}, []string{"operation", "outcome"})
+
+	tokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "docagents",
+		Name:      "llm_tokens_total",
+		Help:      "Tokens consumed by LLM calls, by model and kind (prompt, completion).",
+	}, []string{"model", "kind"})
+)
+
+// WithMetrics records the latency and outcome of c's calls, and the tokens
+// they consume when c is a UsageReporter.
+func WithMetrics(c Client) Client {
+	if r, ok := c.(UsageReporter); ok {
+		r.OnUsage(func(u Usage) {
+			tokens.WithLabelValues(u.Model, "prompt").Add(float64(u.PromptTokens))
+			tokens.WithLabelValues(u.Model, "completion").Add(float64(u.CompletionTokens))
+		})
+	}
+	return &instrumentedClient{next: c}
+}
+
+type instrumentedClient struct {
+	next Client
+}
+
+func (c *instrumentedClient) Summarize(ctx context.Context, text string) (string, []string, error) {
+	start := time.Now()
+	summary, points, err := c.next.Summarize(ctx, text)
+	observeCall("summarize", start, err)
+	return summary, points, err
+}
+
+func (c *instrumentedClient) Answer(ctx context.Context, question, context string, contextQuality float32) (string, float32, error) {
+	start := time.Now()
+	answer, confidence, err := c.next.Answer(ctx, question, context, contextQuality)
+	observeCall("answer", start, err)
+	return answer, confidence, err
+}
+
+func observeCall(operation string, start time.Time, err error) {
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	callDuration.WithLabelValues(operation, outcome).Observe(time.Since(start).Seconds())
+}
@@ -13,8 +13,9 @@ import (
 
 // OpenAIClient calls the OpenAI Chat Completions API.
 type OpenAIClient struct {
-	model  openai.ChatModel
-	client *openai.Client
+	model   openai.ChatModel
+	client  *openai.Client
+	onUsage func(Usage)
 }
 
 const (
@@ -37,6 +38,23 @@ func NewOpenAIClient(apiKey string, model openai.ChatModel) (*OpenAIClient, erro
 	}, nil
 }
 
+// Ping checks that the API is reachable and the key may use the model.
+func (c *OpenAIClient) Ping(ctx context.Context) error {
+	_, err := c.client.Models.Get(ctx, string(c.model))
+	return err
+}
+
+// OnUsage implements UsageReporter.
+func (c *OpenAIClient) OnUsage(fn func(Usage)) {
+	c.onUsage = fn
+}
+
+func (c *OpenAIClient) reportUsage(resp *openai.ChatCompletion) {
+	if c.onUsage != nil {
+		c.onUsage(Usage{Model: resp.Model, PromptTokens: resp.Usage.PromptTokens, CompletionTokens: resp.Usage.CompletionTokens})
+	}
+}
+
 func (c *OpenAIClient) Summarize(ctx context.Context, text string) (string, []string, error) {
 	if c == nil || c.client == nil {
 		return "", nil, fmt.Errorf("nil openai client")
@@ -55,6 +73,7 @@ func (c *OpenAIClient) Summarize(ctx context.Context, text string) (string, []st
 	if err != nil {
 		return "", nil, err
 	}
+	c.reportUsage(resp)
 	if len(resp.Choices) == 0 || resp.Choices[0].Message.Content == "" {
 		return "", nil, fmt.Errorf("openai: no choices returned")
 	}
@@ -91,6 +110,7 @@ func (c *OpenAIClient) Answer(ctx context.Context, question, contextText string,
 	if err != nil {
 		return "", 0, err
 	}
+	c.reportUsage(resp)
 	if len(resp.Choices) == 0 || resp.Choices[0].Message.Content == "" {
 		return "", 0, fmt.Errorf("openai: no choices returned")
 	}
@@ -0,0 +1,43 @@
+package llm
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"doc-agents/internal/tracing"
+)
+
+var tracer = otel.Tracer("doc-agents/internal/llm")
+
+// WithTracing wraps every call to c in a client span.
+func WithTracing(c Client) Client {
+	return &tracedClient{next: c}
+}
+
+type tracedClient struct {
+	next Client
+}
+
+func (c *tracedClient) Summarize(ctx context.Context, text string) (string, []string, error) {
+	ctx, span := tracer.Start(ctx, "llm.Summarize", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.Int("llm.input_chars", len(text))))
+	summary, points, err := c.next.Summarize(ctx, text)
+	span.SetAttributes(attribute.Int("llm.key_points", len(points)))
+	tracing.End(span, err)
+	return summary, points, err
+}
+
+func (c *tracedClient) Answer(ctx context.Context, question, context string, contextQuality float32) (string, float32, error) {
+	ctx, span := tracer.Start(ctx, "llm.Answer", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.Int("llm.context_chars", len(context)),
+			attribute.Float64("llm.context_quality", float64(contextQuality)),
+		))
+	answer, confidence, err := c.next.Answer(ctx, question, context, contextQuality)
+	span.SetAttributes(attribute.Float64("llm.confidence", float64(confidence)))
+	tracing.End(span, err)
+	return answer, confidence, err
+}
@@ -0,0 +1,210 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minRefreshInterval bounds how often an unknown key ID can trigger a JWKS
+// fetch, so tokens with made-up key IDs cannot hammer the issuer.
+const minRefreshInterval = 30 * time.Second
+
+// maxDocumentSize caps discovery and JWKS responses.
+const maxDocumentSize = 1 << 20
+
+// errUnknownKey means the token names a key the issuer does not publish.
+var errUnknownKey = errors.New("unknown signing key")
+
+// keySet caches the issuer's signing keys by key ID. Keys are refetched when
+// the cache is older than ttl, or early when a token names a key the cache
+// does not have, which is how rotations are picked up.
+type keySet struct {
+	issuer  string
+	url     string // JWKS URL; discovered from the issuer when empty
+	ttl     time.Duration
+	client  *http.Client
+	now     func() time.Time
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time // Last successful fetch
+	tried   time.Time // Last fetch attempt
+	err     error     // Outcome of the last fetch
+}
+
+// get returns the keys that may have signed a token with key ID kid: the
+// matching key, or every key when kid is empty.
+func (ks *keySet) get(ctx context.Context, kid string) ([]crypto.PublicKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	now := ks.now()
+	stale := now.Sub(ks.fetched) >= ks.ttl
+	_, known := ks.keys[kid]
+	if (stale || (kid != "" && !known)) && now.Sub(ks.tried) >= minRefreshInterval {
+		ks.tried = now
+		keys, err := ks.fetch(ctx)
+		ks.err = err
+		// On failure keep serving the cached keys until the issuer recovers
+		if err == nil {
+			ks.keys, ks.fetched = keys, now
+		}
+	}
+	if ks.keys == nil {
+		return nil, ks.err
+	}
+
+	if kid != "" {
+		if key, ok := ks.keys[kid]; ok {
+			return []crypto.PublicKey{key}, nil
+		}
+		return nil, fmt.Errorf("%w %q", errUnknownKey, kid)
+	}
+	out := make([]crypto.PublicKey, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		out = append(out, key)
+	}
+	return out, nil
+}
+
+func (ks *keySet) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	if ks.url == "" {
+		url, err := ks.discover(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ks.url = url
+	}
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := ks.getJSON(ctx, ks.url, &doc); err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			// Skip key types we cannot use rather than rejecting the whole set
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("jwks has no usable signing keys")
+	}
+	return keys, nil
+}
+
+// discover reads the JWKS URL from the issuer's OpenID configuration.
+func (ks *keySet) discover(ctx context.Context) (string, error) {
+	var doc struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	url := strings.TrimSuffix(ks.issuer, "/") + "/.well-known/openid-configuration"
+	if err := ks.getJSON(ctx, url, &doc); err != nil {
+		return "", fmt.Errorf("oidc discovery: %w", err)
+	}
+	if doc.Issuer != ks.issuer {
+		return "", fmt.Errorf("oidc discovery: issuer %q does not match %q", doc.Issuer, ks.issuer)
+	}
+	if doc.JWKSURI == "" {
+		return "", errors.New("oidc discovery: no jwks_uri")
+	}
+	return doc.JWKSURI, nil
+}
+
+func (ks *keySet) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := ks.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxDocumentSize)).Decode(v)
+}
+
+// jwk is a JSON Web Key (RFC 7517); only the RSA and EC public key fields
+// are read.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("rsa exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,266 @@
+// Package oidc verifies OpenID Connect access tokens: JWTs signed by an
+// issuer whose keys are published as a JWKS.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	_ "crypto/sha256" // Registers SHA-256 for crypto.Hash
+	_ "crypto/sha512" // Registers SHA-384 and SHA-512 for crypto.Hash
+)
+
+// ErrInvalidToken wraps every reason a token is rejected.
+var ErrInvalidToken = errors.New("invalid token")
+
+// Options configures a Verifier.
+type Options struct {
+	Issuer   string // Must match the iss claim exactly
+	Audience string // Must be one of the aud claim's values
+	// JWKSURL is where the signing keys are published; when empty it is read
+	// from the issuer's /.well-known/openid-configuration.
+	JWKSURL     string
+	GroupsClaim string        // Claim holding the caller's groups; defaults to "groups"
+	TenantClaim string        // Claim holding the caller's tenant; defaults to "tenant"
+	CacheTTL    time.Duration // How long fetched keys are trusted; defaults to an hour
+	Leeway      time.Duration // Clock skew allowed on exp and nbf; defaults to a minute
+	Client      *http.Client  // Defaults to a client with a 10s timeout
+}
+
+// Identity is what a verified token says about its bearer.
+type Identity struct {
+	Subject string   // sub claim
+	Groups  []string // From GroupsClaim
+	Scopes  []string // From the space-separated scope claim, or scp
+	Tenant  string   // From TenantClaim; empty if absent
+	Expiry  time.Time
+}
+
+// Verifier checks tokens against one issuer.
+type Verifier struct {
+	opts Options
+	keys *keySet
+	now  func() time.Time
+}
+
+// NewVerifier returns a Verifier for opts. Keys are fetched on first use, so
+// the issuer does not need to be reachable at startup.
+func NewVerifier(opts Options) (*Verifier, error) {
+	if opts.Issuer == "" {
+		return nil, errors.New("oidc: issuer is required")
+	}
+	if opts.Audience == "" {
+		return nil, errors.New("oidc: audience is required")
+	}
+	if opts.GroupsClaim == "" {
+		opts.GroupsClaim = "groups"
+	}
+	if opts.TenantClaim == "" {
+		opts.TenantClaim = "tenant"
+	}
+	if opts.CacheTTL <= 0 {
+		opts.CacheTTL = time.Hour
+	}
+	if opts.Leeway <= 0 {
+		opts.Leeway = time.Minute
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	v := &Verifier{opts: opts, now: time.Now}
+	v.keys = &keySet{
+		issuer: opts.Issuer,
+		url:    opts.JWKSURL,
+		ttl:    opts.CacheTTL,
+		client: opts.Client,
+		now:    func() time.Time { return v.now() },
+	}
+	return v, nil
+}
+
+// LooksLikeJWT reports whether token has the three-part shape of a JWT, so
+// callers can tell tokens from other credentials before verifying.
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// Verify checks token's signature, issuer, audience and validity period and
+// returns the identity it carries. Failures wrap ErrInvalidToken, except when
+// the issuer's keys cannot be fetched.
+func (v *Verifier) Verify(ctx context.Context, token string) (Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Identity{}, fmt.Errorf("%w: malformed", ErrInvalidToken)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Identity{}, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	alg, ok := algorithms[header.Alg]
+	if !ok {
+		return Identity{}, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, header.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: signature encoding", ErrInvalidToken)
+	}
+
+	keys, err := v.keys.get(ctx, header.Kid)
+	if errors.Is(err, errUnknownKey) {
+		return Identity{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if err != nil {
+		return Identity{}, err
+	}
+	h := alg.hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	digest := h.Sum(nil)
+	if !verifiesAny(alg, keys, digest, sig) {
+		return Identity{}, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Identity{}, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+	return v.identity(claims)
+}
+
+// identity validates the registered claims and extracts the identity.
+func (v *Verifier) identity(claims map[string]any) (Identity, error) {
+	if iss, _ := claims["iss"].(string); iss != v.opts.Issuer {
+		return Identity{}, fmt.Errorf("%w: issuer %q", ErrInvalidToken, iss)
+	}
+	if !slices.Contains(stringList(claims["aud"]), v.opts.Audience) {
+		return Identity{}, fmt.Errorf("%w: audience", ErrInvalidToken)
+	}
+	now := v.now()
+	exp, ok := numericDate(claims["exp"])
+	if !ok {
+		return Identity{}, fmt.Errorf("%w: no expiry", ErrInvalidToken)
+	}
+	if !now.Before(exp.Add(v.opts.Leeway)) {
+		return Identity{}, fmt.Errorf("%w: expired", ErrInvalidToken)
+	}
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Add(v.opts.Leeway).Before(nbf) {
+		return Identity{}, fmt.Errorf("%w: not yet valid", ErrInvalidToken)
+	}
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return Identity{}, fmt.Errorf("%w: no subject", ErrInvalidToken)
+	}
+
+	id := Identity{Subject: sub, Groups: stringList(claims[v.opts.GroupsClaim]), Expiry: exp}
+	if scope, ok := claims["scope"].(string); ok {
+		id.Scopes = strings.Fields(scope)
+	} else {
+		id.Scopes = stringList(claims["scp"])
+	}
+	id.Tenant, _ = claims[v.opts.TenantClaim].(string)
+	return id, nil
+}
+
+type algorithm struct {
+	hash  crypto.Hash
+	kind  string // "rsa", "pss" or "ecdsa"
+	curve int    // Curve size in bits ECDSA keys must have
+}
+
+// algorithms are the JWS algorithms accepted; "none" and the HMAC family are
+// deliberately absent.
+var algorithms = map[string]algorithm{
+	"RS256": {crypto.SHA256, "rsa", 0},
+	"RS384": {crypto.SHA384, "rsa", 0},
+	"RS512": {crypto.SHA512, "rsa", 0},
+	"PS256": {crypto.SHA256, "pss", 0},
+	"PS384": {crypto.SHA384, "pss", 0},
+	"PS512": {crypto.SHA512, "pss", 0},
+	"ES256": {crypto.SHA256, "ecdsa", 256},
+	"ES384": {crypto.SHA384, "ecdsa", 384},
+	"ES512": {crypto.SHA512, "ecdsa", 521},
+}
+
+func verifiesAny(alg algorithm, keys []crypto.PublicKey, digest, sig []byte) bool {
+	for _, key := range keys {
+		switch key := key.(type) {
+		case *rsa.PublicKey:
+			switch alg.kind {
+			case "rsa":
+				if rsa.VerifyPKCS1v15(key, alg.hash, digest, sig) == nil {
+					return true
+				}
+			case "pss":
+				if rsa.VerifyPSS(key, alg.hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil {
+					return true
+				}
+			}
+		case *ecdsa.PublicKey:
+			// JWS ECDSA signatures are r and s, each padded to the curve size
+			bits := key.Curve.Params().BitSize
+			size := (bits + 7) / 8
+			if alg.kind != "ecdsa" || bits != alg.curve || len(sig) != 2*size {
+				continue
+			}
+			r := new(big.Int).SetBytes(sig[:size])
+			s := new(big.Int).SetBytes(sig[size:])
+			if ecdsa.Verify(key, digest, r, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(strings.NewReader(string(b)))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// numericDate reads a JWT NumericDate: seconds since the epoch.
+func numericDate(v any) (time.Time, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(f), 0), true
+}
+
+// stringList reads a claim that is either a string or an array of strings.
+func stringList(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
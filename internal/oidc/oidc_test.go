@@ -0,0 +1,289 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const audience = "doc-agents"
+
+// testIssuer serves a discovery document and a JWKS whose keys can be
+// rotated, and signs tokens with them.
+type testIssuer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	keys    map[string]crypto.Signer
+	fetches atomic.Int32
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	iss := &testIssuer{keys: make(map[string]crypto.Signer)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": iss.URL, "jwks_uri": iss.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		iss.fetches.Add(1)
+		iss.mu.Lock()
+		defer iss.mu.Unlock()
+		var keys []map[string]string
+		for kid, key := range iss.keys {
+			keys = append(keys, publicJWK(kid, key.Public()))
+		}
+		json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	})
+	iss.Server = httptest.NewServer(mux)
+	t.Cleanup(iss.Close)
+	return iss
+}
+
+func (iss *testIssuer) addRSAKey(t *testing.T, kid string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss.mu.Lock()
+	iss.keys[kid] = key
+	iss.mu.Unlock()
+}
+
+func (iss *testIssuer) addECKey(t *testing.T, kid string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss.mu.Lock()
+	iss.keys[kid] = key
+	iss.mu.Unlock()
+}
+
+// sign returns a token signed with key kid, RS256 for RSA keys and ES256
+// for EC keys.
+func (iss *testIssuer) sign(t *testing.T, kid string, claims map[string]any) string {
+	t.Helper()
+	iss.mu.Lock()
+	key := iss.keys[kid]
+	iss.mu.Unlock()
+	alg := "RS256"
+	if _, ok := key.(*ecdsa.PrivateKey); ok {
+		alg = "ES256"
+	}
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(input))
+
+	var sig []byte
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return input + "." + b64(sig)
+}
+
+func (iss *testIssuer) claims(now time.Time) map[string]any {
+	return map[string]any{
+		"iss":    iss.URL,
+		"aud":    []string{audience, "other"},
+		"sub":    "user-123",
+		"exp":    now.Add(time.Hour).Unix(),
+		"groups": []string{"eng", "search"},
+		"scope":  "openid read query",
+		"tenant": "acme",
+	}
+}
+
+func publicJWK(kid string, pub crypto.PublicKey) map[string]string {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		return map[string]string{"kty": "RSA", "kid": kid, "use": "sig",
+			"n": b64(pub.N.Bytes()), "e": b64(big.NewInt(int64(pub.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		return map[string]string{"kty": "EC", "kid": kid, "crv": "P-256",
+			"x": b64(pub.X.FillBytes(make([]byte, 32))), "y": b64(pub.Y.FillBytes(make([]byte, 32)))}
+	}
+	return nil
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func newTestVerifier(t *testing.T, iss *testIssuer, now *time.Time) *Verifier {
+	t.Helper()
+	v, err := NewVerifier(Options{Issuer: iss.URL, Audience: audience, Client: iss.Client()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.now = func() time.Time { return *now }
+	return v
+}
+
+func TestVerify(t *testing.T) {
+	iss := newTestIssuer(t)
+	iss.addRSAKey(t, "rsa-1")
+	iss.addECKey(t, "ec-1")
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		kid     string
+		modify  func(map[string]any)
+		token   func(string) string
+		wantErr bool
+	}{
+		{name: "rs256", kid: "rsa-1"},
+		{name: "es256", kid: "ec-1"},
+		{name: "single audience string", kid: "rsa-1", modify: func(c map[string]any) { c["aud"] = audience }},
+		{name: "expired", kid: "rsa-1", modify: func(c map[string]any) { c["exp"] = now.Add(-2 * time.Minute).Unix() }, wantErr: true},
+		{name: "within leeway", kid: "rsa-1", modify: func(c map[string]any) { c["exp"] = now.Add(-30 * time.Second).Unix() }},
+		{name: "no expiry", kid: "rsa-1", modify: func(c map[string]any) { delete(c, "exp") }, wantErr: true},
+		{name: "not yet valid", kid: "rsa-1", modify: func(c map[string]any) { c["nbf"] = now.Add(time.Hour).Unix() }, wantErr: true},
+		{name: "wrong audience", kid: "rsa-1", modify: func(c map[string]any) { c["aud"] = "someone-else" }, wantErr: true},
+		{name: "wrong issuer", kid: "rsa-1", modify: func(c map[string]any) { c["iss"] = "https://evil.example.com" }, wantErr: true},
+		{name: "no subject", kid: "rsa-1", modify: func(c map[string]any) { delete(c, "sub") }, wantErr: true},
+		{
+			name: "signature from another token",
+			kid:  "rsa-1",
+			token: func(tok string) string {
+				other := iss.sign(t, "rsa-1", map[string]any{"sub": "admin"})
+				return tok[:strings.LastIndex(tok, ".")] + other[strings.LastIndex(other, "."):]
+			},
+			wantErr: true,
+		},
+		{
+			name: "alg none",
+			kid:  "rsa-1",
+			token: func(tok string) string {
+				header := b64([]byte(`{"alg":"none","kid":"rsa-1"}`))
+				return header + tok[strings.Index(tok, "."):strings.LastIndex(tok, ".")] + "."
+			},
+			wantErr: true,
+		},
+		{name: "malformed", kid: "rsa-1", token: func(string) string { return "not-a-jwt" }, wantErr: true},
+	}
+
+	v := newTestVerifier(t, iss, &now)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := iss.claims(now)
+			if tt.modify != nil {
+				tt.modify(claims)
+			}
+			token := iss.sign(t, tt.kid, claims)
+			if tt.token != nil {
+				token = tt.token(token)
+			}
+
+			id, err := v.Verify(context.Background(), token)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("Verify() error = %v, want ErrInvalidToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if id.Subject != "user-123" || id.Tenant != "acme" ||
+				!slices.Equal(id.Groups, []string{"eng", "search"}) ||
+				!slices.Equal(id.Scopes, []string{"openid", "read", "query"}) {
+				t.Errorf("Verify() = %+v", id)
+			}
+		})
+	}
+}
+
+func TestVerifyKeyRotation(t *testing.T) {
+	iss := newTestIssuer(t)
+	iss.addRSAKey(t, "old")
+	now := time.Now()
+	v := newTestVerifier(t, iss, &now)
+
+	if _, err := v.Verify(context.Background(), iss.sign(t, "old", iss.claims(now))); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if _, err := v.Verify(context.Background(), iss.sign(t, "old", iss.claims(now))); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if got := iss.fetches.Load(); got != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1 (cached)", got)
+	}
+
+	// A token signed with a new key triggers a refresh
+	iss.addRSAKey(t, "new")
+	now = now.Add(minRefreshInterval)
+	if _, err := v.Verify(context.Background(), iss.sign(t, "new", iss.claims(now))); err != nil {
+		t.Fatalf("Verify() with rotated key error = %v", err)
+	}
+	if got := iss.fetches.Load(); got != 2 {
+		t.Fatalf("JWKS fetched %d times, want 2", got)
+	}
+
+	// Unknown key IDs do not refetch more than once per interval
+	iss.addRSAKey(t, "unpublished")
+	token := iss.sign(t, "unpublished", iss.claims(now))
+	iss.mu.Lock()
+	delete(iss.keys, "unpublished")
+	iss.mu.Unlock()
+	for range 3 {
+		if _, err := v.Verify(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("Verify() with unknown key error = %v, want ErrInvalidToken", err)
+		}
+	}
+	if got := iss.fetches.Load(); got != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", got)
+	}
+}
+
+func TestVerifyIssuerUnavailable(t *testing.T) {
+	iss := newTestIssuer(t)
+	iss.addRSAKey(t, "rsa-1")
+	now := time.Now()
+	token := iss.sign(t, "rsa-1", iss.claims(now))
+	iss.Close()
+
+	v := newTestVerifier(t, iss, &now)
+	_, err := v.Verify(context.Background(), token)
+	if err == nil || errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Verify() error = %v, want a fetch error", err)
+	}
+}
+
+func TestNewVerifierRequiresIssuerAndAudience(t *testing.T) {
+	if _, err := NewVerifier(Options{Audience: audience}); err == nil {
+		t.Error("NewVerifier() without issuer succeeded")
+	}
+	if _, err := NewVerifier(Options{Issuer: "https://sso.example.com"}); err == nil {
+		t.Error("NewVerifier() without audience succeeded")
+	}
+}
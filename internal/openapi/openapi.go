@@ -0,0 +1,269 @@
+// Package openapi builds an OpenAPI 3 document from the Go types of a
+// service's requests and responses, and validates requests against it.
+//
+// Routes are mounted through Spec.Route, which records the operation in the
+// document and wraps the handler in validation, so a route cannot exist
+// without its description. Schemas come from the same json and validate
+// struct tags the handlers decode and validate with.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Version is the OpenAPI version of generated documents.
+const Version = "3.0.3"
+
+// Spec is an OpenAPI document. Build it with New and Route, and serve it
+// with Handler.
+type Spec struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []map[string][]string `json:"security,omitempty"`
+
+	schemas      *schemaBuilder
+	invalid      InvalidFunc
+	errorBody    any
+	errorBodySet bool
+}
+
+// Info describes the API.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations of one path, keyed by lowercase method.
+type PathItem map[string]*Operation
+
+// Operation describes one route.
+type Operation struct {
+	OperationID string                 `json:"operationId"`
+	Summary     string                 `json:"summary,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Tags        []string               `json:"tags,omitempty"`
+	Parameters  []Parameter            `json:"parameters,omitempty"`
+	RequestBody *RequestBody           `json:"requestBody,omitempty"`
+	Responses   map[string]Response    `json:"responses"`
+	Security    *[]map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter is a path or query parameter.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes the body of an operation.
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// MediaType is the schema of a body in one content type.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Response describes one response of an operation.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Components holds the named schemas and the security schemes.
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme is an authentication method of the API.
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	In          string `json:"in,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// File marks a multipart form field that carries a file.
+type File struct{}
+
+// Text marks a plain-text response body.
+type Text string
+
+// EventStream marks a Server-Sent Events response body.
+type EventStream string
+
+// Op describes a route passed to Spec.Route. Query, Body and Form are
+// zero values of the structs that describe the query parameters, the JSON
+// body and the multipart form; Responses maps each status to a zero value
+// of its body type, or nil for an empty body.
+type Op struct {
+	ID          string
+	Summary     string
+	Description string
+	Tag         string
+	// Scope is the API key scope the route requires, if any.
+	Scope string
+	// Public routes need no credentials.
+	Public bool
+
+	Query        any
+	Body         any
+	BodyOptional bool
+	Form         any
+	Responses    map[int]any
+}
+
+// New returns an empty document. Every operation that is not Public accepts
+// any of schemes.
+func New(info Info, schemes map[string]SecurityScheme) *Spec {
+	s := &Spec{
+		OpenAPI:    Version,
+		Info:       info,
+		Paths:      map[string]PathItem{},
+		Components: Components{SecuritySchemes: schemes},
+		schemas:    newSchemaBuilder(),
+		invalid: func(w http.ResponseWriter, r *http.Request, err *ValidationError) {
+			http.Error(w, err.Error(), err.Status)
+		},
+	}
+	names := make([]string, 0, len(schemes))
+	for name := range schemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s.Security = append(s.Security, map[string][]string{name: {}})
+	}
+	s.Components.Schemas = s.schemas.components
+	return s
+}
+
+// OnInvalid sets how requests that fail validation are answered. By default
+// they get err.Status with the messages as plain text.
+func (s *Spec) OnInvalid(f InvalidFunc) {
+	s.invalid = f
+}
+
+// ErrorResponse documents body as the "default" response of the operations
+// routed after the call, the shape of their error responses.
+func (s *Spec) ErrorResponse(body any) {
+	s.errorBody, s.errorBodySet = body, true
+}
+
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// Route records op for method and path in the document and mounts h on r,
+// behind a middleware that rejects requests that do not match the described
+// path parameters, query parameters and JSON body with 400.
+func (s *Spec) Route(r chi.Router, method, path string, op Op, h http.Handler) {
+	operation, v := s.operation(path, op)
+	item := s.Paths[path]
+	if item == nil {
+		item = PathItem{}
+		s.Paths[path] = item
+	}
+	key := strings.ToLower(method)
+	if _, dup := item[key]; dup {
+		panic(fmt.Sprintf("openapi: %s %s registered twice", method, path))
+	}
+	item[key] = operation
+	r.With(v.middleware).Method(method, path, h)
+}
+
+func (s *Spec) operation(path string, op Op) (*Operation, *validator) {
+	operation := &Operation{
+		OperationID: op.ID,
+		Summary:     op.Summary,
+		Description: op.Description,
+		Responses:   map[string]Response{},
+	}
+	if op.Tag != "" {
+		operation.Tags = []string{op.Tag}
+	}
+	if op.Scope != "" {
+		scope := fmt.Sprintf("Requires the `%s` scope.", op.Scope)
+		operation.Description = strings.TrimSpace(operation.Description + "\n\n" + scope)
+	}
+	if op.Public {
+		operation.Security = &[]map[string][]string{}
+	}
+
+	v := &validator{invalid: &s.invalid}
+	for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+		p := Parameter{Name: m[1], In: "path", Required: true, Schema: &Schema{Type: "string", Format: "uuid"}}
+		operation.Parameters = append(operation.Parameters, p)
+		v.path = append(v.path, p)
+	}
+	if op.Query != nil {
+		for _, p := range s.schemas.parameters(reflect.TypeOf(op.Query)) {
+			operation.Parameters = append(operation.Parameters, p)
+			v.query = append(v.query, p)
+		}
+	}
+	if op.Body != nil {
+		schema := s.schemas.schema(reflect.TypeOf(op.Body))
+		operation.RequestBody = &RequestBody{
+			Required: !op.BodyOptional,
+			Content:  map[string]MediaType{"application/json": {Schema: schema}},
+		}
+		v.body = schema
+		v.bodyOptional = op.BodyOptional
+		v.schemas = s.schemas.components
+	}
+	if op.Form != nil {
+		operation.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"multipart/form-data": {Schema: s.schemas.inline(reflect.TypeOf(op.Form))}},
+		}
+	}
+
+	for status, body := range op.Responses {
+		operation.Responses[strconv.Itoa(status)] = s.response(http.StatusText(status), body)
+	}
+	if s.errorBodySet {
+		operation.Responses["default"] = s.response("Error", s.errorBody)
+	}
+	return operation, v
+}
+
+func (s *Spec) response(description string, body any) Response {
+	resp := Response{Description: description}
+	switch body.(type) {
+	case nil:
+	case Text:
+		resp.Content = map[string]MediaType{"text/plain": {Schema: &Schema{Type: "string"}}}
+	case EventStream:
+		resp.Content = map[string]MediaType{"text/event-stream": {Schema: &Schema{Type: "string"}}}
+	default:
+		resp.Content = map[string]MediaType{"application/json": {Schema: s.schemas.schema(reflect.TypeOf(body))}}
+	}
+	return resp
+}
+
+// Handler serves the document as JSON.
+func (s *Spec) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(s)
+	}
+}
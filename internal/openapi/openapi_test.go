@@ -0,0 +1,149 @@
+package openapi
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type page struct {
+	Limit int `json:"limit"`
+}
+
+type item struct {
+	page
+	ID      string            `json:"id" validate:"required,uuid4"`
+	Name    string            `json:"name" validate:"required,min=3,max=50" doc:"Display name."`
+	Tags    []string          `json:"tags,omitempty" validate:"omitempty,min=1,dive,oneof=a b"`
+	Labels  map[string]string `json:"labels"`
+	Score   float64           `json:"score" validate:"min=0,max=1"`
+	Parent  *item             `json:"parent,omitempty"`
+	Created time.Time         `json:"created_at"`
+	Note    *string           `json:"note"`
+	secret  string
+	Skipped string `json:"-"`
+}
+
+func TestSchemaFromTags(t *testing.T) {
+	b := newSchemaBuilder()
+	ref := b.schema(reflect.TypeFor[item]())
+	if ref.Ref != refPrefix+"Item" {
+		t.Fatalf("ref = %q", ref.Ref)
+	}
+	s := b.components["Item"]
+
+	var names []string
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	if len(names) != 9 {
+		t.Errorf("properties = %v, want the 9 JSON fields including the embedded limit", names)
+	}
+	if !reflect.DeepEqual(s.Required, []string{"id", "name"}) {
+		t.Errorf("required = %v", s.Required)
+	}
+	if p := s.Properties["id"]; p.Format != "uuid" {
+		t.Errorf("id format = %q", p.Format)
+	}
+	if p := s.Properties["name"]; *p.MinLength != 3 || *p.MaxLength != 50 || p.Description != "Display name." {
+		t.Errorf("name = %+v", p)
+	}
+	if p := s.Properties["tags"]; *p.MinItems != 1 || !reflect.DeepEqual(p.Items.Enum, []string{"a", "b"}) {
+		t.Errorf("tags = %+v, items %+v", p, p.Items)
+	}
+	if p := s.Properties["labels"]; p.Type != "object" || p.AdditionalProperties.Type != "string" {
+		t.Errorf("labels = %+v", p)
+	}
+	if p := s.Properties["score"]; p.Type != "number" || *p.Minimum != 0 || *p.Maximum != 1 {
+		t.Errorf("score = %+v", p)
+	}
+	if p := s.Properties["parent"]; p.Ref != refPrefix+"Item" {
+		t.Errorf("parent = %+v, want a reference to Item", p)
+	}
+	if p := s.Properties["created_at"]; p.Type != "string" || p.Format != "date-time" {
+		t.Errorf("created_at = %+v", p)
+	}
+	if p := s.Properties["note"]; !p.Nullable {
+		t.Errorf("note = %+v, want nullable", p)
+	}
+}
+
+func TestRouteValidatesAndDocuments(t *testing.T) {
+	type createRequest struct {
+		Name  string   `json:"name" validate:"required,min=3"`
+		Count int      `json:"count" validate:"omitempty,min=1,max=10"`
+		IDs   []string `json:"ids" validate:"omitempty,dive,uuid4"`
+	}
+	type listQuery struct {
+		Limit int  `json:"limit" validate:"omitempty,min=1,max=100"`
+		All   bool `json:"all"`
+	}
+
+	spec := New(Info{Title: "test", Version: "1"}, map[string]SecurityScheme{"bearer": {Type: "http", Scheme: "bearer"}})
+	r := chi.NewRouter()
+	var got string
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = string(body)
+		w.WriteHeader(http.StatusCreated)
+	})
+	spec.Route(r, http.MethodPost, "/things/{id}", Op{ID: "createThing", Query: listQuery{}, Body: createRequest{}, Responses: map[int]any{http.StatusCreated: nil}}, echo)
+	spec.Route(r, http.MethodGet, "/healthz", Op{ID: "health", Public: true, Responses: map[int]any{http.StatusOK: Text("")}}, echo)
+
+	tests := []struct {
+		name       string
+		target     string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"valid", "/things/6f1c2b7e-3a0d-4c5e-9f8a-1b2c3d4e5f60?limit=5", `{"name":"widget","count":2}`, http.StatusCreated, ""},
+		{"bad path parameter", "/things/7", `{"name":"widget"}`, http.StatusBadRequest, "id must be a valid UUID"},
+		{"bad query parameter", "/things/6f1c2b7e-3a0d-4c5e-9f8a-1b2c3d4e5f60?limit=500&all=maybe", `{"name":"widget"}`, http.StatusBadRequest, "limit must be at most 100; all must be a boolean"},
+		{"every body error", "/things/6f1c2b7e-3a0d-4c5e-9f8a-1b2c3d4e5f60", `{"name":"ab","count":1.5,"ids":["x"]}`, http.StatusBadRequest, "count must be an integer; ids[0] must be a valid UUID; name must be at least 3 characters"},
+		{"wrong type", "/things/6f1c2b7e-3a0d-4c5e-9f8a-1b2c3d4e5f60", `[]`, http.StatusBadRequest, "body must be an object"},
+		{"null required field", "/things/6f1c2b7e-3a0d-4c5e-9f8a-1b2c3d4e5f60", `{"name":null}`, http.StatusBadRequest, "name is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = ""
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body)))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %q)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusCreated {
+				if body := strings.TrimSpace(w.Body.String()); body != tt.wantBody {
+					t.Errorf("body = %q, want %q", body, tt.wantBody)
+				}
+			} else if got != tt.body {
+				t.Errorf("handler read %q, want the original body", got)
+			}
+		})
+	}
+
+	var doc map[string]any
+	w := httptest.NewRecorder()
+	spec.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	op := doc["paths"].(map[string]any)["/things/{id}"].(map[string]any)["post"].(map[string]any)
+	if params := op["parameters"].([]any); len(params) != 3 {
+		t.Errorf("parameters = %v, want id, limit and all", params)
+	}
+	if _, ok := op["security"]; ok {
+		t.Error("protected operation overrides the document's security")
+	}
+	health := doc["paths"].(map[string]any)["/healthz"].(map[string]any)["get"].(map[string]any)
+	if sec, ok := health["security"].([]any); !ok || len(sec) != 0 {
+		t.Errorf("public operation security = %v, want []", health["security"])
+	}
+}
@@ -0,0 +1,309 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Schema is a JSON schema in the OpenAPI 3.0 dialect.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	MinProperties        *int               `json:"minProperties,omitempty"`
+	MaxProperties        *int               `json:"maxProperties,omitempty"`
+}
+
+var (
+	timeType = reflect.TypeFor[time.Time]()
+	rawType  = reflect.TypeFor[json.RawMessage]()
+	fileType = reflect.TypeFor[File]()
+)
+
+const refPrefix = "#/components/schemas/"
+
+// Namer is implemented by types whose Go name would be ambiguous as a
+// component name outside their package.
+type Namer interface {
+	OpenAPIName() string
+}
+
+var namerType = reflect.TypeFor[Namer]()
+
+// schemaBuilder turns Go types into schemas, collecting named structs into
+// components so each is described once.
+type schemaBuilder struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{components: map[string]*Schema{}, names: map[reflect.Type]string{}}
+}
+
+// schema describes t, referring to named structs by $ref.
+func (b *schemaBuilder) schema(t reflect.Type) *Schema {
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		nullable = true
+	}
+	s := b.describe(t)
+	if nullable && s.Ref == "" {
+		s.Nullable = true
+	}
+	return s
+}
+
+// inline describes the struct t without registering it as a component.
+func (b *schemaBuilder) inline(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return b.object(t)
+}
+
+func (b *schemaBuilder) describe(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawType:
+		return &Schema{}
+	case t == fileType:
+		return &Schema{Type: "string", Format: "binary"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: b.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+		return &Schema{Ref: refPrefix + b.component(t)}
+	default:
+		// Interfaces hold any JSON value.
+		return &Schema{}
+	}
+}
+
+// component registers the named struct t and returns its component name.
+func (b *schemaBuilder) component(t reflect.Type) string {
+	if name, ok := b.names[t]; ok {
+		return name
+	}
+	name := exportedName(t.Name())
+	if t.Implements(namerType) {
+		name = reflect.Zero(t).Interface().(Namer).OpenAPIName()
+	}
+	for _, taken := range b.names {
+		if taken == name {
+			name = exportedName(lastElem(t.PkgPath())) + name
+			break
+		}
+	}
+	b.names[t] = name
+	b.components[name] = &Schema{} // placeholder for recursive types
+	*b.components[name] = *b.object(t)
+	return name
+}
+
+// object describes the fields of struct t, flattening embedded structs the
+// way encoding/json does.
+func (b *schemaBuilder) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for _, f := range fields(t) {
+		prop := b.schema(f.Type)
+		if doc := f.Tag.Get("doc"); doc != "" {
+			if prop.Ref != "" {
+				// Siblings of $ref are ignored in OpenAPI 3.0.
+				prop = &Schema{Description: doc, Ref: prop.Ref}
+			} else {
+				prop.Description = doc
+			}
+		}
+		required := applyValidateTag(prop, f.Tag.Get("validate"))
+		name := jsonName(f)
+		s.Properties[name] = prop
+		if required {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return s
+}
+
+// parameters describes the fields of struct t as query parameters.
+func (b *schemaBuilder) parameters(t reflect.Type) []Parameter {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var params []Parameter
+	for _, f := range fields(t) {
+		schema := b.schema(f.Type)
+		required := applyValidateTag(schema, f.Tag.Get("validate"))
+		params = append(params, Parameter{
+			Name:        jsonName(f),
+			In:          "query",
+			Description: f.Tag.Get("doc"),
+			Required:    required,
+			Schema:      schema,
+		})
+	}
+	return params
+}
+
+// fields lists the JSON-visible fields of struct t.
+func fields(t reflect.Type) []reflect.StructField {
+	var out []reflect.StructField
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if f.Tag.Get("json") == "-" {
+			continue
+		}
+		if f.Anonymous && f.Tag.Get("json") == "" {
+			et := f.Type
+			if et.Kind() == reflect.Pointer {
+				et = et.Elem()
+			}
+			if et.Kind() == reflect.Struct {
+				out = append(out, fields(et)...)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// applyValidateTag translates the go-playground/validator rules in tag into
+// schema keywords and reports whether the field is required. Rules after
+// "dive" apply to the items of a slice or the values of a map; rules with
+// no schema equivalent, such as required_without, are left to the handler.
+func applyValidateTag(s *Schema, tag string) bool {
+	if tag == "" {
+		return false
+	}
+	required := false
+	target := s
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "dive":
+			switch {
+			case target.Items != nil:
+				target = target.Items
+			case target.AdditionalProperties != nil:
+				target = target.AdditionalProperties
+			}
+		case "required":
+			if target == s {
+				required = true
+			}
+		case "min", "max", "gte", "lte":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				panic(fmt.Sprintf("openapi: bad %s rule %q", name, rule))
+			}
+			setBound(target, name == "min" || name == "gte", n)
+		case "oneof":
+			target.Enum = strings.Fields(param)
+		case "uuid", "uuid4":
+			target.Format = "uuid"
+		case "url", "http_url", "uri":
+			target.Format = "uri"
+		case "email":
+			target.Format = "email"
+		}
+	}
+	return required
+}
+
+func setBound(s *Schema, lower bool, n float64) {
+	switch s.Type {
+	case "string":
+		i := int(n)
+		if lower {
+			s.MinLength = &i
+		} else {
+			s.MaxLength = &i
+		}
+	case "array":
+		i := int(n)
+		if lower {
+			s.MinItems = &i
+		} else {
+			s.MaxItems = &i
+		}
+	case "object":
+		i := int(n)
+		if lower {
+			s.MinProperties = &i
+		} else {
+			s.MaxProperties = &i
+		}
+	default:
+		if lower {
+			s.Minimum = &n
+		} else {
+			s.Maximum = &n
+		}
+	}
+}
+
+func exportedName(name string) string {
+	if name == "" {
+		return name
+	}
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+func lastElem(path string) string {
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
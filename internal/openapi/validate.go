@@ -0,0 +1,320 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// MaxBody bounds the JSON bodies the validation middleware reads.
+const MaxBody = 1 << 20
+
+// FieldError is one way a request does not match its operation.
+type FieldError struct {
+	// Field is the parameter name or the path of the body field, such as
+	// "document_ids[2]" or "metadata.author".
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every way a request does not match its operation.
+type ValidationError struct {
+	// Status is the response status: 400, or 413 for a body over MaxBody.
+	Status int
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		messages[i] = f.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// InvalidFunc writes the response to a request that failed validation.
+type InvalidFunc func(w http.ResponseWriter, r *http.Request, err *ValidationError)
+
+// validator checks requests against one operation.
+type validator struct {
+	path         []Parameter
+	query        []Parameter
+	body         *Schema
+	bodyOptional bool
+	schemas      map[string]*Schema
+	invalid      *InvalidFunc
+}
+
+func (v *validator) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var errs []FieldError
+		for _, p := range v.path {
+			if raw := chi.URLParam(r, p.Name); raw != "" {
+				errs = v.param(errs, p, []string{raw})
+			}
+		}
+		query := r.URL.Query()
+		for _, p := range v.query {
+			raw, ok := query[p.Name]
+			if !ok || len(raw) == 0 || raw[0] == "" {
+				if p.Required {
+					errs = append(errs, FieldError{p.Name, p.Name + " is required"})
+				}
+				continue
+			}
+			errs = v.param(errs, p, raw)
+		}
+		if v.body != nil {
+			var err error
+			if errs, err = v.checkBody(errs, r); err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					(*v.invalid)(w, r, &ValidationError{
+						Status: http.StatusRequestEntityTooLarge,
+						Fields: []FieldError{{"body", "request body too large"}},
+					})
+					return
+				}
+				errs = append(errs, FieldError{"body", "invalid JSON body"})
+			}
+		}
+		if len(errs) > 0 {
+			(*v.invalid)(w, r, &ValidationError{Status: http.StatusBadRequest, Fields: errs})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// param converts the raw values of p to JSON values and checks them.
+func (v *validator) param(errs []FieldError, p Parameter, raw []string) []FieldError {
+	if p.Schema.Type == "array" {
+		items := make([]any, 0, len(raw))
+		for _, s := range raw {
+			val, ok := paramValue(p.Schema.Items, s)
+			if !ok {
+				return append(errs, FieldError{p.Name, fmt.Sprintf("%s must be %s", p.Name, typeName(p.Schema.Items))})
+			}
+			items = append(items, val)
+		}
+		return v.check(errs, p.Name, p.Schema, items)
+	}
+	val, ok := paramValue(p.Schema, raw[0])
+	if !ok {
+		return append(errs, FieldError{p.Name, fmt.Sprintf("%s must be %s", p.Name, typeName(p.Schema))})
+	}
+	return v.check(errs, p.Name, p.Schema, val)
+}
+
+func paramValue(s *Schema, raw string) (any, bool) {
+	switch s.Type {
+	case "integer":
+		if _, err := strconv.ParseInt(raw, 10, 64); err != nil {
+			return nil, false
+		}
+		return json.Number(raw), true
+	case "number":
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return nil, false
+		}
+		return json.Number(raw), true
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		return b, err == nil
+	default:
+		return raw, true
+	}
+}
+
+// checkBody reads and checks the JSON body, then puts it back for the handler.
+func (v *validator) checkBody(errs []FieldError, r *http.Request) ([]FieldError, error) {
+	if r.Body == nil {
+		r.Body = http.NoBody
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, MaxBody))
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return errs, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		if !v.bodyOptional {
+			errs = append(errs, FieldError{"body", "request body is required"})
+		}
+		return errs, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var body any
+	if err := dec.Decode(&body); err != nil {
+		return errs, err
+	}
+	return v.check(errs, "", v.body, body), nil
+}
+
+// check appends the ways val does not match s to errs. field is the path of
+// val within the body, empty for the body itself.
+func (v *validator) check(errs []FieldError, field string, s *Schema, val any) []FieldError {
+	if s.Ref != "" {
+		s = v.schemas[strings.TrimPrefix(s.Ref, refPrefix)]
+	}
+	if val == nil || s.Type == "" {
+		// encoding/json decodes null into the zero value, so null only
+		// fails a required field, which its parent object reports.
+		return errs
+	}
+	label := field
+	if label == "" {
+		label = "body"
+	}
+	fail := func(format string, args ...any) []FieldError {
+		return append(errs, FieldError{label, label + " " + fmt.Sprintf(format, args...)})
+	}
+
+	switch s.Type {
+	case "string":
+		str, ok := val.(string)
+		if !ok {
+			return fail("must be a string")
+		}
+		n := utf8.RuneCountInString(str)
+		if s.MinLength != nil && n < *s.MinLength {
+			return fail("must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			return fail("must be at most %d characters", *s.MaxLength)
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+			return fail("must be one of %s", strings.Join(s.Enum, ", "))
+		}
+		if msg := checkFormat(s.Format, str); msg != "" {
+			return fail("%s", msg)
+		}
+	case "integer", "number":
+		num, ok := val.(json.Number)
+		if !ok {
+			return fail("must be %s", typeName(s))
+		}
+		if s.Type == "integer" {
+			if _, err := num.Int64(); err != nil {
+				return fail("must be an integer")
+			}
+		}
+		f, err := num.Float64()
+		if err != nil {
+			return fail("must be a number")
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			return fail("must be at least %s", formatNumber(*s.Minimum))
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			return fail("must be at most %s", formatNumber(*s.Maximum))
+		}
+	case "boolean":
+		if _, ok := val.(bool); !ok {
+			return fail("must be a boolean")
+		}
+	case "array":
+		items, ok := val.([]any)
+		if !ok {
+			return fail("must be an array")
+		}
+		if s.MinItems != nil && len(items) < *s.MinItems {
+			return fail("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(items) > *s.MaxItems {
+			return fail("must have at most %d items", *s.MaxItems)
+		}
+		for i, item := range items {
+			errs = v.check(errs, fmt.Sprintf("%s[%d]", field, i), s.Items, item)
+		}
+	case "object":
+		obj, ok := val.(map[string]any)
+		if !ok {
+			return fail("must be an object")
+		}
+		if s.MinProperties != nil && len(obj) < *s.MinProperties {
+			return fail("must have at least %d entries", *s.MinProperties)
+		}
+		if s.MaxProperties != nil && len(obj) > *s.MaxProperties {
+			return fail("must have at most %d entries", *s.MaxProperties)
+		}
+		for _, name := range s.Required {
+			if prop, ok := obj[name]; !ok || prop == nil {
+				errs = append(errs, FieldError{join(field, name), join(field, name) + " is required"})
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			prop := obj[name]
+			if ps, ok := s.Properties[name]; ok {
+				errs = v.check(errs, join(field, name), ps, prop)
+			} else if s.AdditionalProperties != nil {
+				errs = v.check(errs, join(field, name), s.AdditionalProperties, prop)
+			}
+		}
+	}
+	return errs
+}
+
+func checkFormat(format, s string) string {
+	switch format {
+	case "uuid":
+		if _, err := uuid.Parse(s); err != nil {
+			return "must be a valid UUID"
+		}
+	case "uri":
+		if u, err := url.ParseRequestURI(s); err != nil || u.Scheme == "" || u.Host == "" {
+			return "must be an absolute URL"
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, s); err != nil {
+			return "must be an RFC 3339 timestamp"
+		}
+	}
+	return ""
+}
+
+func typeName(s *Schema) string {
+	switch s.Type {
+	case "integer":
+		return "an integer"
+	case "number":
+		return "a number"
+	case "boolean":
+		return "a boolean"
+	case "array":
+		return "an array"
+	case "object":
+		return "an object"
+	default:
+		return "a string"
+	}
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func join(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
@@ -0,0 +1,119 @@
+// Package progress records per-stage pipeline progress for documents so a
+// stuck or failed document shows which stage is to blame and why, and
+// announces each transition to clients following the document.
+package progress
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/google/uuid"
+
+	"doc-agents/internal/events"
+	"doc-agents/internal/queue"
+	"doc-agents/internal/store"
+	"doc-agents/internal/webhook"
+)
+
+// StageEvent returns the event announcing that stage started.
+func StageEvent(stage store.Stage) events.Type {
+	switch stage {
+	case store.StageParse:
+		return events.TypeParsing
+	case store.StageSummarize:
+		return events.TypeSummarizing
+	case store.StageEmbed:
+		return events.TypeEmbedding
+	default:
+		return events.Type(stage)
+	}
+}
+
+// Tracker records the stages run by one task for one document. Recording is
+// best effort: a failure to record or publish is logged and never fails the
+// stage itself.
+type Tracker struct {
+	store    store.Store
+	events   events.Publisher
+	webhooks webhook.Notifier
+	log      *slog.Logger
+	doc      store.Document
+	docID    uuid.UUID
+	task     queue.Task
+	version  int
+}
+
+// New creates a Tracker for the task currently being handled.
+func New(st store.Store, pub events.Publisher, hooks webhook.Notifier, log *slog.Logger, doc store.Document, task queue.Task) *Tracker {
+	return &Tracker{
+		store:    st,
+		events:   pub,
+		webhooks: hooks,
+		log:      log.With("document_id", doc.ID),
+		doc:      doc,
+		docID:    doc.ID,
+		task:     task,
+	}
+}
+
+// ForVersion makes t track a run processing a new version of the document
+// rather than the live one; zero leaves it tracking the live version.
+func (t *Tracker) ForVersion(version int) *Tracker {
+	t.version = version
+	return t
+}
+
+// Run executes fn as stage, recording it as running and then done or failed.
+// When the task has no retries left, the document is marked failed too and
+// the document.failed webhook fires. A new version's run fails the version
+// instead, leaving the document on its live version.
+func (t *Tracker) Run(ctx context.Context, stage store.Stage, fn func() error) error {
+	attempt := t.task.Attempt()
+	t.record(ctx, stage, store.StageRunning, attempt, "")
+	t.Publish(ctx, events.Event{Type: StageEvent(stage), Attempt: attempt})
+
+	if err := fn(); err != nil {
+		t.record(ctx, stage, store.StageFailed, attempt, err.Error())
+		if t.task.IsLastAttempt() {
+			t.fail(ctx, stage, attempt, err)
+		}
+		return err
+	}
+
+	t.record(ctx, stage, store.StageDone, attempt, "")
+	return nil
+}
+
+// fail records that the tracked run failed for good.
+func (t *Tracker) fail(ctx context.Context, stage store.Stage, attempt int, err error) {
+	if t.version != 0 {
+		t.log.Error("version failed permanently", "version", t.version, "stage", stage, "attempt", attempt, "err", err)
+		if upErr := t.store.FailVersion(ctx, t.docID, t.version, err.Error()); upErr != nil {
+			t.log.Error("failed to mark version failed", "version", t.version, "err", upErr)
+		}
+		t.Publish(ctx, events.Event{Type: events.TypeVersionFailed, Attempt: attempt, Version: t.version, Error: err.Error()})
+		return
+	}
+	t.log.Error("stage failed permanently", "stage", stage, "attempt", attempt, "err", err)
+	if upErr := t.store.UpdateDocumentStatus(ctx, t.docID, store.StatusFailed); upErr != nil {
+		t.log.Error("failed to mark document failed", "err", upErr)
+	}
+	t.Publish(ctx, events.Event{Type: events.TypeFailed, Attempt: attempt, Error: err.Error()})
+	failed := t.doc
+	failed.Status = store.StatusFailed
+	t.webhooks.Notify(ctx, webhook.EventDocumentFailed, failed, err.Error())
+}
+
+// Publish announces ev for the tracked document.
+func (t *Tracker) Publish(ctx context.Context, ev events.Event) {
+	ev.DocumentID = t.docID
+	if err := t.events.Publish(ctx, ev); err != nil {
+		t.log.Warn("failed to publish document event", "type", ev.Type, "err", err)
+	}
+}
+
+func (t *Tracker) record(ctx context.Context, stage store.Stage, state store.StageState, attempt int, errMsg string) {
+	if err := t.store.RecordStage(ctx, t.docID, stage, state, attempt, errMsg); err != nil {
+		t.log.Warn("failed to record stage progress", "stage", stage, "state", state, "err", err)
+	}
+}
@@ -0,0 +1,124 @@
+package progress
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+
+	"doc-agents/internal/events"
+	"doc-agents/internal/queue"
+	"doc-agents/internal/store"
+	"doc-agents/internal/webhook"
+)
+
+func TestTrackerRun(t *testing.T) {
+	docID := uuid.New()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		name       string
+		task       queue.Task
+		version    int
+		fnErr      error
+		setup      func(*store.MockStore)
+		wantErr    bool
+		wantEvents []events.Type
+		wantHook   bool
+	}{
+		{
+			name: "success records running then done",
+			task: queue.Task{},
+			setup: func(s *store.MockStore) {
+				s.On("RecordStage", mock.Anything, docID, store.StageParse, store.StageRunning, 1, "").Return(nil).Once()
+				s.On("RecordStage", mock.Anything, docID, store.StageParse, store.StageDone, 1, "").Return(nil).Once()
+			},
+			wantEvents: []events.Type{events.TypeParsing},
+		},
+		{
+			name:  "retryable failure keeps document processing",
+			task:  queue.Task{Attempts: 1},
+			fnErr: errors.New("llm timeout"),
+			setup: func(s *store.MockStore) {
+				s.On("RecordStage", mock.Anything, docID, store.StageParse, store.StageRunning, 2, "").Return(nil).Once()
+				s.On("RecordStage", mock.Anything, docID, store.StageParse, store.StageFailed, 2, "llm timeout").Return(nil).Once()
+			},
+			wantErr:    true,
+			wantEvents: []events.Type{events.TypeParsing},
+		},
+		{
+			name:  "last attempt marks document failed",
+			task:  queue.Task{Attempts: 2, MaxAttempts: 3},
+			fnErr: errors.New("corrupt pdf"),
+			setup: func(s *store.MockStore) {
+				s.On("RecordStage", mock.Anything, docID, store.StageParse, store.StageRunning, 3, "").Return(nil).Once()
+				s.On("RecordStage", mock.Anything, docID, store.StageParse, store.StageFailed, 3, "corrupt pdf").Return(nil).Once()
+				s.On("UpdateDocumentStatus", mock.Anything, docID, store.StatusFailed).Return(nil).Once()
+			},
+			wantErr:    true,
+			wantEvents: []events.Type{events.TypeParsing, events.TypeFailed},
+			wantHook:   true,
+		},
+		{
+			name:    "last attempt of a new version fails only the version",
+			task:    queue.Task{Attempts: 2, MaxAttempts: 3},
+			version: 2,
+			fnErr:   errors.New("corrupt pdf"),
+			setup: func(s *store.MockStore) {
+				s.On("RecordStage", mock.Anything, docID, store.StageParse, store.StageRunning, 3, "").Return(nil).Once()
+				s.On("RecordStage", mock.Anything, docID, store.StageParse, store.StageFailed, 3, "corrupt pdf").Return(nil).Once()
+				s.On("FailVersion", mock.Anything, docID, 2, "corrupt pdf").Return(nil).Once()
+			},
+			wantErr:    true,
+			wantEvents: []events.Type{events.TypeParsing, events.TypeVersionFailed},
+		},
+		{
+			name: "recording failure does not fail the stage",
+			task: queue.Task{},
+			setup: func(s *store.MockStore) {
+				s.On("RecordStage", mock.Anything, docID, store.StageParse, mock.Anything, 1, "").Return(errors.New("db error")).Twice()
+			},
+			wantEvents: []events.Type{events.TypeParsing},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(store.MockStore)
+			tt.setup(mockStore)
+
+			hub := events.NewHub()
+			sub, cancel := hub.Subscribe(docID)
+			defer cancel()
+
+			hooks := new(webhook.MockNotifier)
+			if tt.wantHook {
+				hooks.On("Notify", mock.Anything, webhook.EventDocumentFailed, mock.MatchedBy(func(doc store.Document) bool {
+					return doc.ID == docID && doc.Status == store.StatusFailed
+				}), tt.fnErr.Error()).Once()
+			}
+
+			tracker := New(mockStore, hub, hooks, log, store.Document{ID: docID}, tt.task).ForVersion(tt.version)
+			err := tracker.Run(context.Background(), store.StageParse, func() error { return tt.fnErr })
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			mockStore.AssertExpectations(t)
+			hooks.AssertExpectations(t)
+
+			var got []events.Type
+			for len(sub) > 0 {
+				got = append(got, (<-sub).Type)
+			}
+			if !slices.Equal(got, tt.wantEvents) {
+				t.Errorf("published %v, want %v", got, tt.wantEvents)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+// Package queryapi defines the JSON contract of POST /api/query, shared by
+// the query service that answers it and the gateway that forwards it and
+// describes it in its OpenAPI document.
+package queryapi
+
+import (
+	"encoding/json"
+
+	"doc-agents/internal/cache"
+)
+
+// Request selects the documents to search by ID, by metadata or both; at
+// least one of document_ids and metadata is required.
+type Request struct {
+	Question    string   `json:"question" validate:"required,min=3,max=500"`
+	DocumentIDs []string `json:"document_ids,omitempty" validate:"required_without=Metadata,omitempty,min=1,dive,uuid4"`
+	// Metadata maps each key to a value, an array of values, or one of
+	// {"ne": v}, {"in": [...]}, {"exists": bool}.
+	Metadata map[string]json.RawMessage `json:"metadata,omitempty" validate:"required_without=DocumentIDs" doc:"Conditions on document metadata, all of which must hold: a value, an array of values, or one of {\"ne\": v}, {\"in\": [...]}, {\"exists\": bool}."`
+	TopK     int                        `json:"top_k,omitempty" validate:"omitempty,min=1,max=20" doc:"Number of chunks to answer from; defaults to 5."`
+	// Versions pins documents, by ID, to the version searched; the others
+	// are searched at their live version.
+	Versions map[string]int `json:"versions,omitempty" validate:"omitempty,dive,min=1" doc:"Version to search, keyed by document ID; other documents are searched at their live version."`
+}
+
+// OpenAPIName names the schema of Request in OpenAPI documents.
+func (Request) OpenAPIName() string { return "QueryRequest" }
+
+// Response is an answer with the chunks it is based on.
+type Response struct {
+	Answer     string         `json:"answer"`
+	Sources    []cache.Source `json:"sources"`
+	Confidence float32        `json:"confidence"`
+	// Cached is set when the answer came from the result cache.
+	Cached bool `json:"cached"`
+}
+
+// OpenAPIName names the schema of Response in OpenAPI documents.
+func (Response) OpenAPIName() string { return "QueryResponse" }
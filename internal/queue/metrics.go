@@ -0,0 +1,28 @@
+package queue
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Task outcomes recorded by taskDuration.
+const (
+	outcomeOK     = "ok"     // handled
+	outcomeRetry  = "retry"  // failed and re-enqueued with a backoff
+	outcomeFailed = "failed" // failed on its last attempt
+)
+
+var (
+	taskDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "docagents",
+		Name:      "task_duration_seconds",
+		Help:      "Time taken by task handlers, by task type and outcome.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"type", "outcome"})
+
+	tasksHandedBack = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "docagents",
+		Name:      "tasks_handed_back_total",
+		Help:      "Tasks a stopping worker returned to the queue without running them.",
+	}, []string{"type"})
+)
@@ -5,71 +5,259 @@ import (
 	"encoding/json"
 	"errors"
 	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 
 	"doc-agents/internal/retry"
+	"doc-agents/internal/tenant"
+	"doc-agents/internal/tracing"
 )
 
-// NewNATS constructs a thin NATS-based queue.
-func NewNATS(log *slog.Logger, nc *nats.Conn) Queue {
-	return &natsQueue{log: log, nc: nc}
+var tracer = otel.Tracer("doc-agents/internal/queue")
+
+// NewNATS constructs a thin NATS-based queue. A worker that is stopped waits
+// up to drainTimeout for the tasks it is running before cancelling them.
+func NewNATS(log *slog.Logger, nc *nats.Conn, drainTimeout time.Duration) Queue {
+	return &natsQueue{log: log, nc: nc, drainTimeout: drainTimeout}
 }
 
 type natsQueue struct {
-	log *slog.Logger
-	nc  *nats.Conn
+	log          *slog.Logger
+	nc           *nats.Conn
+	drainTimeout time.Duration
 }
 
-func (q *natsQueue) Enqueue(_ context.Context, task Task) error {
+func (q *natsQueue) Enqueue(ctx context.Context, task Task) error {
 	if task.ID == uuid.Nil {
 		task.ID = uuid.New()
 	}
+	if task.Tenant == "" {
+		task.Tenant, _ = tenant.FromContext(ctx)
+	}
 	if task.Type == "" {
 		return errors.New("task type required")
 	}
+	subject := "tasks." + string(task.Type)
+	ctx, span := tracer.Start(ctx, "publish "+subject, trace.WithSpanKind(trace.SpanKindProducer), trace.WithAttributes(taskAttributes(subject, task)...))
+	if task.Trace == nil {
+		task.Trace = tracing.Inject(ctx)
+	}
 	body, err := json.Marshal(task)
-	if err != nil {
-		return err
+	if err == nil {
+		err = q.nc.Publish(subject, body)
 	}
-	return q.nc.Publish("tasks."+string(task.Type), body)
+	tracing.End(span, err)
+	return err
+}
+
+// concurrency is how many tasks of a type one worker handles at once.
+// Webhook deliveries wait on external receivers, so a slow or dead endpoint
+// must not hold up everyone else's; pipeline tasks run one at a time per
+// worker so that replicas share the load.
+var concurrency = map[TaskType]int{
+	TaskTypeWebhook: 16,
 }
 
+// Worker handles tasks until ctx is done. It then stops taking tasks, hands
+// back the ones not started yet and waits for the running ones.
 func (q *natsQueue) Worker(ctx context.Context, taskType TaskType, handler Handler) error {
 	subject := "tasks." + string(taskType)
 	group := "workers-" + string(taskType)
-	sub, err := q.nc.QueueSubscribe(subject, group, func(msg *nats.Msg) {
-		q.handleMessage(ctx, msg, handler)
-	})
+	w := q.newWorker(ctx, taskType, handler)
+	defer w.cancel()
+	sub, err := q.nc.QueueSubscribe(subject, group, w.receive)
 	if err != nil {
 		return err
 	}
 	<-ctx.Done()
-	return sub.Unsubscribe()
+	err = sub.Unsubscribe()
+	w.drain(q.drainTimeout)
+	return err
+}
+
+// worker runs the tasks of one subscription. Tasks run in a context of their
+// own, so that stopping the worker lets them finish; drain cancels it only
+// once the drain timeout has passed.
+type worker struct {
+	q       *natsQueue
+	handler Handler
+	slots   chan struct{}
+	stop    <-chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	mu      sync.Mutex
+	stopped bool
+	running sync.WaitGroup
 }
 
-func (q *natsQueue) handleMessage(ctx context.Context, msg *nats.Msg, handler Handler) {
+func (q *natsQueue) newWorker(ctx context.Context, taskType TaskType, handler Handler) *worker {
+	taskCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	return &worker{
+		q:       q,
+		handler: handler,
+		slots:   make(chan struct{}, max(concurrency[taskType], 1)),
+		stop:    ctx.Done(),
+		ctx:     taskCtx,
+		cancel:  cancel,
+	}
+}
+
+// receive runs the task in msg once a slot is free. The subscription
+// callback never sleeps: a task retried with a backoff waits on a timer
+// instead, so it does not delay the tasks behind it.
+func (w *worker) receive(msg *nats.Msg) {
 	var task Task
 	if err := json.Unmarshal(msg.Data, &task); err != nil {
-		q.log.Error("failed to decode task", "err", err)
+		w.q.log.Error("failed to decode task", "err", err)
+		return
+	}
+	if !w.begin() {
+		// Delivered after the worker was told to stop
+		w.handBack(task)
 		return
 	}
 
-	if task.NotBefore.After(time.Now()) {
-		time.Sleep(time.Until(task.NotBefore))
+	if delay := time.Until(task.NotBefore); delay > 0 {
+		go w.runAfter(delay, task)
+		return
+	}
+	if !w.acquire(task) {
+		return
 	}
+	go func() {
+		defer w.running.Done()
+		defer func() { <-w.slots }()
+		w.q.run(w.ctx, task, w.handler)
+	}()
+}
+
+// begin counts a task as running, unless the worker is stopping.
+func (w *worker) begin() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	select {
+	case <-w.stop:
+		return false
+	default:
+	}
+	if w.stopped {
+		return false
+	}
+	w.running.Add(1)
+	return true
+}
+
+// acquire waits for a free slot. If the worker stops first, the task is
+// handed back and no longer counted as running.
+func (w *worker) acquire(task Task) bool {
+	select {
+	case w.slots <- struct{}{}:
+		return true
+	case <-w.stop:
+		w.handBack(task)
+		w.running.Done()
+		return false
+	}
+}
+
+// runAfter runs a task whose retry is not due yet once delay has passed. If
+// the worker stops first, the task is handed back to the queue so another
+// worker retries it.
+func (w *worker) runAfter(delay time.Duration, task Task) {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-w.stop:
+		w.handBack(task)
+		w.running.Done()
+		return
+	}
+	if !w.acquire(task) {
+		return
+	}
+	defer w.running.Done()
+	defer func() { <-w.slots }()
+	w.q.run(w.ctx, task, w.handler)
+}
+
+// handBack returns a task the worker will not run to the queue as it was.
+func (w *worker) handBack(task Task) {
+	tasksHandedBack.WithLabelValues(string(task.Type)).Inc()
+	if err := w.q.Enqueue(w.ctx, task); err != nil {
+		w.q.log.Error("failed to hand back task", "id", task.ID, "type", task.Type, "err", err)
+	}
+}
+
+// drain waits for the running tasks. Those still running after timeout are
+// cancelled; their handlers fail and the tasks are retried like any other
+// failure.
+func (w *worker) drain(timeout time.Duration) {
+	w.mu.Lock()
+	w.stopped = true
+	w.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		w.running.Wait()
+		close(done)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return
+	case <-timer.C:
+	}
+	w.q.log.Warn("tasks still running after drain timeout; cancelling them", "timeout", timeout)
+	w.cancel()
+	<-done
+}
+
+func (q *natsQueue) run(ctx context.Context, task Task, handler Handler) {
+	if task.Tenant == "" {
+		// Enqueued before tasks carried a tenant; the rows it refers to were
+		// migrated to the default tenant.
+		task.Tenant = tenant.Default
+	}
+	ctx = tenant.WithID(ctx, task.Tenant)
+	subject := "tasks." + string(task.Type)
+	ctx, span := tracer.Start(tracing.Extract(ctx, task.Trace), "process "+subject, trace.WithSpanKind(trace.SpanKindConsumer), trace.WithAttributes(taskAttributes(subject, task)...))
+	start := time.Now()
+	outcome := outcomeOK
+	err := handler(ctx, task)
+	if err != nil {
+		outcome = q.retryTask(ctx, task, err)
+	}
+	taskDuration.WithLabelValues(string(task.Type), outcome).Observe(time.Since(start).Seconds())
+	span.SetAttributes(attribute.String("task.outcome", outcome))
+	tracing.End(span, err)
+}
 
-	if err := handler(ctx, task); err != nil {
-		q.retryTask(ctx, task, err)
+func taskAttributes(subject string, task Task) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		semconv.MessagingSystemKey.String("nats"),
+		semconv.MessagingDestinationName(subject),
+		semconv.MessagingMessageID(task.ID.String()),
+		attribute.Int("task.attempt", task.Attempt()),
+		attribute.String("tenant", task.Tenant),
 	}
 }
 
-func (q *natsQueue) retryTask(ctx context.Context, task Task, handlerErr error) {
+// retryTask re-enqueues a failed task unless it was its last attempt, and
+// returns which of the two happened.
+func (q *natsQueue) retryTask(ctx context.Context, task Task, handlerErr error) string {
 	task.Attempts++
 	if task.MaxAttempts == 0 {
-		task.MaxAttempts = 5
+		task.MaxAttempts = DefaultMaxAttempts
 	}
 
 	if task.Attempts < task.MaxAttempts {
@@ -77,7 +265,8 @@ func (q *natsQueue) retryTask(ctx context.Context, task Task, handlerErr error)
 		if err := q.Enqueue(ctx, task); err != nil {
 			q.log.Error("failed to re-enqueue task after failure", "id", task.ID, "type", task.Type, "original_err", handlerErr, "enqueue_err", err)
 		}
-	} else {
-		q.log.Error("task permanently failed", "id", task.ID, "type", task.Type, "original_err", handlerErr)
+		return outcomeRetry
 	}
+	q.log.Error("task permanently failed", "id", task.ID, "type", task.Type, "original_err", handlerErr)
+	return outcomeFailed
 }
@@ -0,0 +1,152 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+
+	"doc-agents/internal/tracing"
+	"doc-agents/internal/tracing/tracingtest"
+)
+
+func testQueue() *natsQueue {
+	return &natsQueue{log: slog.New(slog.NewTextHandler(io.Discard, nil))}
+}
+
+func taskMsg(t *testing.T, task Task) *nats.Msg {
+	t.Helper()
+	data, err := json.Marshal(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &nats.Msg{Data: data}
+}
+
+func TestDelayedTaskDoesNotBlockOthers(t *testing.T) {
+	handled := make(chan string, 2)
+	w := testQueue().newWorker(context.Background(), TaskTypeParse, func(_ context.Context, task Task) error {
+		handled <- string(task.Payload)
+		return nil
+	})
+	defer w.cancel()
+
+	// A retry due in an hour must not hold up the task behind it
+	done := make(chan struct{})
+	go func() {
+		w.receive(taskMsg(t, Task{Type: TaskTypeParse, Payload: []byte(`"later"`), NotBefore: time.Now().Add(time.Hour)}))
+		w.receive(taskMsg(t, Task{Type: TaskTypeParse, Payload: []byte(`"now"`)}))
+		close(done)
+	}()
+
+	select {
+	case got := <-handled:
+		if got != `"now"` {
+			t.Errorf("expected the due task to run first, got %s", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("due task was blocked by the delayed one")
+	}
+	<-done
+}
+
+func TestDrainWaitsForRunningTasks(t *testing.T) {
+	ctx, stop := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var handlerErr error
+	w := testQueue().newWorker(ctx, TaskTypeParse, func(ctx context.Context, _ Task) error {
+		close(started)
+		<-release
+		handlerErr = ctx.Err()
+		return nil
+	})
+	defer w.cancel()
+
+	w.receive(taskMsg(t, Task{Type: TaskTypeParse}))
+	<-started
+	stop()
+
+	drained := make(chan struct{})
+	go func() {
+		w.drain(time.Minute)
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		t.Fatal("drain returned while a task was running")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-drained:
+	case <-time.After(time.Second):
+		t.Fatal("drain did not return after the task finished")
+	}
+	if handlerErr != nil {
+		t.Errorf("stopping the worker cancelled the running task: %v", handlerErr)
+	}
+}
+
+func TestDrainCancelsTasksAfterTimeout(t *testing.T) {
+	ctx, stop := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	w := testQueue().newWorker(ctx, TaskTypeParse, func(ctx context.Context, _ Task) error {
+		close(started)
+		<-ctx.Done()
+		return nil
+	})
+	defer w.cancel()
+
+	w.receive(taskMsg(t, Task{Type: TaskTypeParse}))
+	<-started
+	stop()
+
+	drained := make(chan struct{})
+	go func() {
+		w.drain(10 * time.Millisecond)
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(time.Second):
+		t.Fatal("drain did not cancel the task after the timeout")
+	}
+}
+
+func TestHandlerJoinsEnqueuersTrace(t *testing.T) {
+	spans := tracingtest.Record(t)
+	ctx, upload := otel.Tracer("test").Start(context.Background(), "upload")
+	task := Task{Type: TaskTypeParse, Trace: tracing.Inject(ctx)}
+	upload.End()
+
+	handled := make(chan trace.SpanContext, 1)
+	w := testQueue().newWorker(context.Background(), TaskTypeParse, func(ctx context.Context, _ Task) error {
+		handled <- trace.SpanContextFromContext(ctx)
+		return nil
+	})
+	defer w.cancel()
+	w.receive(taskMsg(t, task))
+
+	got := <-handled
+	if got.TraceID() != upload.SpanContext().TraceID() {
+		t.Fatalf("handler trace = %s, want the upload's %s", got.TraceID(), upload.SpanContext().TraceID())
+	}
+	w.drain(time.Second)
+	for _, s := range spans.GetSpans() {
+		if s.Name == "process tasks.parse" {
+			if s.Parent.SpanID() != upload.SpanContext().SpanID() {
+				t.Errorf("process span parent = %s, want the upload span", s.Parent.SpanID())
+			}
+			return
+		}
+	}
+	t.Error("no process span recorded")
+}
@@ -15,6 +15,7 @@ type TaskType string
 const (
 	TaskTypeParse   TaskType = "parse"
 	TaskTypeAnalyze TaskType = "analyze"
+	TaskTypeWebhook TaskType = "webhook"
 )
 
 // Task represents a unit of work shared across agents.
@@ -25,11 +26,35 @@ type Task struct {
 	Attempts    int
 	MaxAttempts int
 	NotBefore   time.Time
+	// Tenant the task acts for; Enqueue takes it from the context when
+	// empty, and workers handle the task in a context scoped to it.
+	Tenant string
+	// Trace is the trace context of the code that first enqueued the task;
+	// handler spans join that trace, retries included.
+	Trace map[string]string `json:",omitempty"`
+}
+
+// DefaultMaxAttempts applies when a task does not set MaxAttempts.
+const DefaultMaxAttempts = 5
+
+// Attempt returns the 1-based number of the current delivery of the task.
+func (t Task) Attempt() int {
+	return t.Attempts + 1
+}
+
+// IsLastAttempt reports whether a failure now will not be retried.
+func (t Task) IsLastAttempt() bool {
+	maxAttempts := t.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	return t.Attempt() >= maxAttempts
 }
 
 type Handler func(context.Context, Task) error
 
 // Queue exposes a minimal contract to enqueue and consume tasks.
+// Tasks carry the tenant they were enqueued for (see Task.Tenant).
 type Queue interface {
 	Enqueue(ctx context.Context, task Task) error
 	Worker(ctx context.Context, taskType TaskType, handler Handler) error
@@ -0,0 +1,146 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"doc-agents/internal/errcode"
+)
+
+// Limits on user-defined document metadata.
+const (
+	MaxMetadataKeys        = 32
+	MaxMetadataValueLength = 512
+)
+
+var metadataKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// ValidateMetadataKey rejects keys that are empty, too long or contain
+// characters other than letters, digits, '_', '.' and '-'.
+func ValidateMetadataKey(key string) error {
+	if !metadataKeyPattern.MatchString(key) {
+		return fmt.Errorf("invalid metadata key %q (1-64 letters, digits, '_', '.' or '-')", key)
+	}
+	return nil
+}
+
+// ValidateMetadata checks user-supplied metadata against the limits above.
+func ValidateMetadata(md map[string]string) error {
+	if len(md) > MaxMetadataKeys {
+		return fmt.Errorf("too many metadata keys (max %d)", MaxMetadataKeys)
+	}
+	for key, value := range md {
+		if err := ValidateMetadataKey(key); err != nil {
+			return err
+		}
+		if len(value) > MaxMetadataValueLength {
+			return fmt.Errorf("metadata value of %q too long (max %d bytes)", key, MaxMetadataValueLength)
+		}
+	}
+	return nil
+}
+
+// MetadataOp is a comparison applied to one metadata key.
+type MetadataOp string
+
+const (
+	MetadataEq      MetadataOp = "eq"      // Key equals the single value
+	MetadataNe      MetadataOp = "ne"      // Key is absent or differs from the single value
+	MetadataIn      MetadataOp = "in"      // Key equals one of the values
+	MetadataExists  MetadataOp = "exists"  // Key is present
+	MetadataMissing MetadataOp = "missing" // Key is absent
+)
+
+// MetadataCondition is one test on a document's metadata.
+type MetadataCondition struct {
+	Key    string
+	Op     MetadataOp
+	Values []string
+}
+
+// MetadataFilter selects documents whose metadata satisfies every condition.
+type MetadataFilter []MetadataCondition
+
+// Validate checks keys and the number of values each operator takes.
+func (f MetadataFilter) Validate() error {
+	for _, c := range f {
+		if err := ValidateMetadataKey(c.Key); err != nil {
+			return err
+		}
+		switch c.Op {
+		case MetadataEq, MetadataNe:
+			if len(c.Values) != 1 {
+				return fmt.Errorf("metadata %s on %q takes one value", c.Op, c.Key)
+			}
+		case MetadataIn:
+			if len(c.Values) == 0 {
+				return fmt.Errorf("metadata in on %q needs at least one value", c.Key)
+			}
+		case MetadataExists, MetadataMissing:
+		default:
+			return fmt.Errorf("unknown metadata operator %q", c.Op)
+		}
+	}
+	return nil
+}
+
+// sql renders the filter as SQL conditions on the JSONB column col, adding
+// parameters through arg. Equality uses containment so the GIN index applies.
+func (f MetadataFilter) sql(col string, arg func(any) string) ([]string, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	var where []string
+	for _, c := range f {
+		switch c.Op {
+		case MetadataEq:
+			doc, err := json.Marshal(map[string]string{c.Key: c.Values[0]})
+			if err != nil {
+				return nil, err
+			}
+			where = append(where, fmt.Sprintf("%s @> %s::jsonb", col, arg(string(doc))))
+		case MetadataNe:
+			where = append(where, fmt.Sprintf("%s->>%s IS DISTINCT FROM %s", col, arg(c.Key), arg(c.Values[0])))
+		case MetadataIn:
+			where = append(where, fmt.Sprintf("%s->>%s = ANY(%s::text[])", col, arg(c.Key), arg(pq.Array(c.Values))))
+		case MetadataExists:
+			where = append(where, fmt.Sprintf("%s ? %s", col, arg(c.Key)))
+		case MetadataMissing:
+			where = append(where, fmt.Sprintf("NOT (%s ? %s)", col, arg(c.Key)))
+		}
+	}
+	return where, nil
+}
+
+// ErrEmptySearchScope is returned by TopK when neither document IDs nor a
+// metadata filter restrict the search.
+var ErrEmptySearchScope = errcode.New("empty_search_scope", "search scope needs document ids or a metadata filter")
+
+// SearchScope restricts TopK to documents. When both fields are set a
+// document must satisfy both.
+type SearchScope struct {
+	DocumentIDs []uuid.UUID
+	Metadata    MetadataFilter
+	// Versions pins documents to the version searched instead of their live
+	// one. Pinning does not add a document to the scope.
+	Versions map[uuid.UUID]int
+}
+
+func (s SearchScope) String() string {
+	var parts []string
+	if len(s.DocumentIDs) > 0 {
+		parts = append(parts, fmt.Sprintf("%d documents", len(s.DocumentIDs)))
+	}
+	if len(s.Metadata) > 0 {
+		parts = append(parts, fmt.Sprintf("%d metadata conditions", len(s.Metadata)))
+	}
+	if len(s.Versions) > 0 {
+		parts = append(parts, fmt.Sprintf("%d pinned versions", len(s.Versions)))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,150 @@
+package store
+
+import (
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestMetadataFilterSQL(t *testing.T) {
+	tests := []struct {
+		name      string
+		filter    MetadataFilter
+		wantWhere []string
+		wantArgs  []any
+		wantErr   bool
+	}{
+		{
+			name:      "eq uses containment",
+			filter:    MetadataFilter{{Key: "project", Op: MetadataEq, Values: []string{"apollo"}}},
+			wantWhere: []string{"d.metadata @> $2::jsonb"},
+			wantArgs:  []any{`{"project":"apollo"}`},
+		},
+		{
+			name:      "ne matches absent keys too",
+			filter:    MetadataFilter{{Key: "confidentiality", Op: MetadataNe, Values: []string{"secret"}}},
+			wantWhere: []string{"d.metadata->>$2 IS DISTINCT FROM $3"},
+			wantArgs:  []any{"confidentiality", "secret"},
+		},
+		{
+			name:      "in compares against an array",
+			filter:    MetadataFilter{{Key: "team", Op: MetadataIn, Values: []string{"search", "ingest"}}},
+			wantWhere: []string{"d.metadata->>$2 = ANY($3::text[])"},
+			wantArgs:  []any{"team", pq.Array([]string{"search", "ingest"})},
+		},
+		{
+			name: "exists and missing",
+			filter: MetadataFilter{
+				{Key: "owner", Op: MetadataExists},
+				{Key: "draft", Op: MetadataMissing},
+			},
+			wantWhere: []string{"d.metadata ? $2", "NOT (d.metadata ? $3)"},
+			wantArgs:  []any{"owner", "draft"},
+		},
+		{
+			name: "placeholders continue across conditions",
+			filter: MetadataFilter{
+				{Key: "project", Op: MetadataEq, Values: []string{"apollo"}},
+				{Key: "team", Op: MetadataNe, Values: []string{"ops"}},
+				{Key: "region", Op: MetadataIn, Values: []string{"eu"}},
+			},
+			wantWhere: []string{
+				"d.metadata @> $2::jsonb",
+				"d.metadata->>$3 IS DISTINCT FROM $4",
+				"d.metadata->>$5 = ANY($6::text[])",
+			},
+			wantArgs: []any{`{"project":"apollo"}`, "team", "ops", "region", pq.Array([]string{"eu"})},
+		},
+		{
+			name:      "hostile values stay parameters",
+			filter:    MetadataFilter{{Key: "project", Op: MetadataEq, Values: []string{`x"}'; DROP TABLE documents; --`}}},
+			wantWhere: []string{"d.metadata @> $2::jsonb"},
+			wantArgs:  []any{`{"project":"x\"}'; DROP TABLE documents; --"}`},
+		},
+		{
+			name:    "hostile key is rejected",
+			filter:  MetadataFilter{{Key: "project'; DROP TABLE documents; --", Op: MetadataExists}},
+			wantErr: true,
+		},
+		{
+			name:    "key with JSON path syntax is rejected",
+			filter:  MetadataFilter{{Key: "a->b", Op: MetadataNe, Values: []string{"c"}}},
+			wantErr: true,
+		},
+		{
+			name:    "eq takes one value",
+			filter:  MetadataFilter{{Key: "project", Op: MetadataEq, Values: []string{"a", "b"}}},
+			wantErr: true,
+		},
+		{
+			name:    "in needs a value",
+			filter:  MetadataFilter{{Key: "team", Op: MetadataIn}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown operator",
+			filter:  MetadataFilter{{Key: "team", Op: "like", Values: []string{"s%"}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// An argument taken before the filter, as the callers' queries do
+			args := []any{"earlier"}
+			arg := func(v any) string {
+				args = append(args, v)
+				return "$" + strconv.Itoa(len(args))
+			}
+
+			where, err := tt.filter.sql("d.metadata", arg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sql() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if len(args) != 1 {
+					t.Errorf("rejected filter added args %v", args[1:])
+				}
+				return
+			}
+			if !slices.Equal(where, tt.wantWhere) {
+				t.Errorf("where = %q, want %q", where, tt.wantWhere)
+			}
+			if !reflect.DeepEqual(args[1:], tt.wantArgs) {
+				t.Errorf("args = %#v, want %#v", args[1:], tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestValidateMetadata(t *testing.T) {
+	tooMany := map[string]string{}
+	for i := range MaxMetadataKeys + 1 {
+		tooMany["k"+strconv.Itoa(i)] = "v"
+	}
+
+	tests := []struct {
+		name    string
+		md      map[string]string
+		wantErr bool
+	}{
+		{name: "valid", md: map[string]string{"project": "apollo", "team.name": "search", "cost-center_1": "42"}},
+		{name: "empty key", md: map[string]string{"": "v"}, wantErr: true},
+		{name: "key too long", md: map[string]string{strings.Repeat("k", 65): "v"}, wantErr: true},
+		{name: "key with a quote", md: map[string]string{"a'b": "v"}, wantErr: true},
+		{name: "value too long", md: map[string]string{"k": strings.Repeat("v", MaxMetadataValueLength+1)}, wantErr: true},
+		{name: "too many keys", md: tooMany, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateMetadata(tt.md); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateMetadata() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
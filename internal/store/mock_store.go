@@ -14,8 +14,8 @@ type MockStore struct {
 	mock.Mock
 }
 
-func (m *MockStore) CreateDocument(ctx context.Context, filename string) (Document, error) {
-	args := m.Called(ctx, filename)
+func (m *MockStore) CreateDocument(ctx context.Context, doc Document) (Document, error) {
+	args := m.Called(ctx, doc)
 	return args.Get(0).(Document), args.Error(1)
 }
 
@@ -24,11 +24,36 @@ func (m *MockStore) GetDocument(ctx context.Context, id uuid.UUID) (Document, er
 	return args.Get(0).(Document), args.Error(1)
 }
 
+func (m *MockStore) FindDocumentByHash(ctx context.Context, hash string) (Document, error) {
+	args := m.Called(ctx, hash)
+	return args.Get(0).(Document), args.Error(1)
+}
+
+func (m *MockStore) ListDocuments(ctx context.Context, filter DocumentFilter) ([]Document, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]Document), args.Error(1)
+}
+
 func (m *MockStore) UpdateDocumentStatus(ctx context.Context, id uuid.UUID, status DocumentStatus) error {
 	args := m.Called(ctx, id, status)
 	return args.Error(0)
 }
 
+func (m *MockStore) MarkProcessing(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockStore) UpdateDocumentMetadata(ctx context.Context, id uuid.UUID, set map[string]string, remove []string) (Document, error) {
+	args := m.Called(ctx, id, set, remove)
+	return args.Get(0).(Document), args.Error(1)
+}
+
+func (m *MockStore) DeleteDocument(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
 func (m *MockStore) SaveChunks(ctx context.Context, docID uuid.UUID, chunks []Chunk) ([]Chunk, error) {
 	args := m.Called(ctx, docID, chunks)
 	if args.Get(0) == nil {
@@ -37,7 +62,15 @@ func (m *MockStore) SaveChunks(ctx context.Context, docID uuid.UUID, chunks []Ch
 	return args.Get(0).([]Chunk), args.Error(1)
 }
 
-func (m *MockStore) ListChunks(ctx context.Context, docID uuid.UUID) ([]Chunk, error) {
+func (m *MockStore) StageChunks(ctx context.Context, docID uuid.UUID, chunks []Chunk) ([]Chunk, error) {
+	args := m.Called(ctx, docID, chunks)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]Chunk), args.Error(1)
+}
+
+func (m *MockStore) ListStagedChunks(ctx context.Context, docID uuid.UUID) ([]Chunk, error) {
 	args := m.Called(ctx, docID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -45,6 +78,19 @@ func (m *MockStore) ListChunks(ctx context.Context, docID uuid.UUID) ([]Chunk, e
 	return args.Get(0).([]Chunk), args.Error(1)
 }
 
+func (m *MockStore) PromoteStagedChunks(ctx context.Context, docID uuid.UUID) error {
+	args := m.Called(ctx, docID)
+	return args.Error(0)
+}
+
+func (m *MockStore) ListChunks(ctx context.Context, docID uuid.UUID, version int) ([]Chunk, error) {
+	args := m.Called(ctx, docID, version)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]Chunk), args.Error(1)
+}
+
 func (m *MockStore) SaveSummary(ctx context.Context, docID uuid.UUID, summary Summary) error {
 	args := m.Called(ctx, docID, summary)
 	return args.Error(0)
@@ -55,15 +101,120 @@ func (m *MockStore) SaveEmbeddings(ctx context.Context, embs []Embedding) error
 	return args.Error(0)
 }
 
-func (m *MockStore) GetSummary(ctx context.Context, docID uuid.UUID) (Summary, error) {
-	args := m.Called(ctx, docID)
+func (m *MockStore) GetSummary(ctx context.Context, docID uuid.UUID, version int) (Summary, error) {
+	args := m.Called(ctx, docID, version)
 	return args.Get(0).(Summary), args.Error(1)
 }
 
-func (m *MockStore) TopK(ctx context.Context, docIDs []uuid.UUID, vector embeddings.Vector, k int) ([]SearchResult, error) {
-	args := m.Called(ctx, docIDs, vector, k)
+func (m *MockStore) CreateVersion(ctx context.Context, docID uuid.UUID, v Version) (Version, error) {
+	args := m.Called(ctx, docID, v)
+	return args.Get(0).(Version), args.Error(1)
+}
+
+func (m *MockStore) ListVersions(ctx context.Context, docID uuid.UUID) ([]Version, error) {
+	args := m.Called(ctx, docID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]Version), args.Error(1)
+}
+
+func (m *MockStore) PublishVersion(ctx context.Context, docID uuid.UUID, version int) error {
+	args := m.Called(ctx, docID, version)
+	return args.Error(0)
+}
+
+func (m *MockStore) FailVersion(ctx context.Context, docID uuid.UUID, version int, errMsg string) error {
+	args := m.Called(ctx, docID, version, errMsg)
+	return args.Error(0)
+}
+
+func (m *MockStore) TopK(ctx context.Context, scope SearchScope, vector embeddings.Vector, k int) ([]SearchResult, error) {
+	args := m.Called(ctx, scope, vector, k)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).([]SearchResult), args.Error(1)
 }
+
+func (m *MockStore) RecordStage(ctx context.Context, docID uuid.UUID, stage Stage, state StageState, attempt int, errMsg string) error {
+	args := m.Called(ctx, docID, stage, state, attempt, errMsg)
+	return args.Error(0)
+}
+
+func (m *MockStore) ListStages(ctx context.Context, docID uuid.UUID) ([]StageStatus, error) {
+	args := m.Called(ctx, docID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]StageStatus), args.Error(1)
+}
+
+func (m *MockStore) CreateBatch(ctx context.Context, docIDs []uuid.UUID) (Batch, error) {
+	args := m.Called(ctx, docIDs)
+	return args.Get(0).(Batch), args.Error(1)
+}
+
+func (m *MockStore) GetBatch(ctx context.Context, id uuid.UUID) (Batch, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(Batch), args.Error(1)
+}
+
+func (m *MockStore) CreateWebhook(ctx context.Context, hook Webhook) (Webhook, error) {
+	args := m.Called(ctx, hook)
+	return args.Get(0).(Webhook), args.Error(1)
+}
+
+func (m *MockStore) GetWebhook(ctx context.Context, id uuid.UUID) (Webhook, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(Webhook), args.Error(1)
+}
+
+func (m *MockStore) ListWebhooks(ctx context.Context, event string) ([]Webhook, error) {
+	args := m.Called(ctx, event)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]Webhook), args.Error(1)
+}
+
+func (m *MockStore) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockStore) RecordWebhookDelivery(ctx context.Context, delivery WebhookDelivery) error {
+	args := m.Called(ctx, delivery)
+	return args.Error(0)
+}
+
+func (m *MockStore) ListWebhookDeliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]WebhookDelivery, error) {
+	args := m.Called(ctx, webhookID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]WebhookDelivery), args.Error(1)
+}
+
+func (m *MockStore) CreateAPIKey(ctx context.Context, key APIKey) (APIKey, error) {
+	args := m.Called(ctx, key)
+	return args.Get(0).(APIKey), args.Error(1)
+}
+
+func (m *MockStore) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]APIKey), args.Error(1)
+}
+
+func (m *MockStore) RevokeAPIKey(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockStore) UseAPIKey(ctx context.Context, hash string) (APIKey, error) {
+	args := m.Called(ctx, hash)
+	return args.Get(0).(APIKey), args.Error(1)
+}
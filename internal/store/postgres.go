@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strconv"
@@ -14,6 +15,7 @@ import (
 	"github.com/lib/pq"
 
 	"doc-agents/internal/embeddings"
+	"doc-agents/internal/tenant"
 )
 
 type PostgresStore struct {
@@ -32,6 +34,16 @@ func NewPostgres(dsn string) (*PostgresStore, error) {
 	return s, nil
 }
 
+// Ping checks that the database answers.
+func (s *PostgresStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Close closes the connection pool once queries in progress have finished.
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
+
 func (s *PostgresStore) migrate(ctx context.Context) error {
 	// Use advisory lock to prevent concurrent migrations from multiple services.
 	// Note: In production, use dedicated migration tools (e.g., golang-migrate/migrate)
@@ -84,12 +96,115 @@ func (s *PostgresStore) migrate(ctx context.Context) error {
 			vector vector(3072),
 			model TEXT
 		);`,
+		`CREATE TABLE IF NOT EXISTS document_stages (
+			document_id UUID REFERENCES documents(id) ON DELETE CASCADE,
+			stage TEXT,
+			state TEXT NOT NULL,
+			attempts INT NOT NULL DEFAULT 0,
+			last_error TEXT,
+			started_at TIMESTAMPTZ,
+			completed_at TIMESTAMPTZ,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			PRIMARY KEY (document_id, stage)
+		);`,
+		`CREATE TABLE IF NOT EXISTS batches (
+			id UUID PRIMARY KEY,
+			created_at TIMESTAMPTZ DEFAULT now()
+		);`,
+		`CREATE TABLE IF NOT EXISTS batch_documents (
+			batch_id UUID REFERENCES batches(id) ON DELETE CASCADE,
+			document_id UUID REFERENCES documents(id) ON DELETE CASCADE,
+			PRIMARY KEY (batch_id, document_id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS webhooks (
+			id UUID PRIMARY KEY,
+			url TEXT NOT NULL,
+			secret TEXT NOT NULL,
+			events TEXT[] NOT NULL,
+			created_at TIMESTAMPTZ DEFAULT now()
+		);`,
+		// No FK on document_id: the log outlives deleted documents
+		`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id UUID PRIMARY KEY,
+			webhook_id UUID REFERENCES webhooks(id) ON DELETE CASCADE,
+			event_id UUID NOT NULL,
+			event TEXT NOT NULL,
+			document_id UUID,
+			attempt INT NOT NULL,
+			status_code INT,
+			error TEXT,
+			duration_ms INT,
+			created_at TIMESTAMPTZ DEFAULT now()
+		);`,
+		`CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_created_idx ON webhook_deliveries(webhook_id, created_at DESC);`,
 	}
+	// Columns added after the initial schema
+	stmts = append(stmts,
+		`ALTER TABLE documents ADD COLUMN IF NOT EXISTS source_url TEXT;`,
+		`ALTER TABLE documents ADD COLUMN IF NOT EXISTS content_hash TEXT;`,
+		`CREATE INDEX IF NOT EXISTS documents_content_hash_idx ON documents(content_hash);`,
+		`ALTER TABLE documents ADD COLUMN IF NOT EXISTS content_type TEXT;`,
+		`ALTER TABLE chunks ADD COLUMN IF NOT EXISTS staged BOOLEAN NOT NULL DEFAULT false;`,
+		`ALTER TABLE documents ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}';`,
+		`CREATE INDEX IF NOT EXISTS documents_metadata_idx ON documents USING gin (metadata jsonb_path_ops);`,
+	)
+	// Versions: chunks and summaries belong to one version of a document, and
+	// documents.version is the live one. Rows from before versioning are all
+	// version 1.
+	stmts = append(stmts,
+		`CREATE TABLE IF NOT EXISTS document_versions (
+			document_id UUID REFERENCES documents(id) ON DELETE CASCADE,
+			version INT NOT NULL,
+			filename TEXT,
+			source_url TEXT,
+			content_hash TEXT,
+			content_type TEXT,
+			created_at TIMESTAMPTZ DEFAULT now(),
+			PRIMARY KEY (document_id, version)
+		);`,
+		`ALTER TABLE document_versions ADD COLUMN IF NOT EXISTS prior_status TEXT;`,
+		`ALTER TABLE document_versions ADD COLUMN IF NOT EXISTS error TEXT;`,
+		`ALTER TABLE documents ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;`,
+		`ALTER TABLE chunks ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;`,
+		`CREATE INDEX IF NOT EXISTS chunks_document_version_idx ON chunks(document_id, version);`,
+		`ALTER TABLE summaries ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;`,
+		`ALTER TABLE summaries DROP CONSTRAINT IF EXISTS summaries_pkey;`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS summaries_document_version_idx ON summaries(document_id, version);`,
+	)
+	// Indexes backing ListDocuments: keyset pagination, status filter and filename search
+	stmts = append(stmts,
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm;`,
+		`CREATE INDEX IF NOT EXISTS documents_created_at_id_idx ON documents(created_at, id);`,
+		`CREATE INDEX IF NOT EXISTS documents_status_created_at_id_idx ON documents(status, created_at, id);`,
+		`CREATE INDEX IF NOT EXISTS documents_filename_trgm_idx ON documents USING gin (filename gin_trgm_ops);`,
+	)
+	// API keys are looked up by hash before the tenant is known, so the table
+	// is outside row-level security and queries filter on tenant_id themselves
+	stmts = append(stmts,
+		`CREATE TABLE IF NOT EXISTS api_keys (
+			id UUID PRIMARY KEY,
+			tenant_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			prefix TEXT NOT NULL,
+			key_hash TEXT NOT NULL UNIQUE,
+			scopes TEXT[] NOT NULL,
+			rate_limit INT NOT NULL DEFAULT 0,
+			requests BIGINT NOT NULL DEFAULT 0,
+			created_at TIMESTAMPTZ DEFAULT now(),
+			last_used_at TIMESTAMPTZ,
+			revoked_at TIMESTAMPTZ
+		);`,
+		`CREATE INDEX IF NOT EXISTS api_keys_tenant_created_at_idx ON api_keys(tenant_id, created_at, id);`,
+	)
+	stmts = append(stmts, tenantIsolationStmts()...)
 	for _, stmt := range stmts {
 		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
 			return err
 		}
 	}
+	if err := s.backfillVersions(ctx); err != nil {
+		return fmt.Errorf("failed to backfill document versions: %w", err)
+	}
 
 	// Create IVFFlat index for fast similarity search
 	_, err = s.db.ExecContext(ctx, `
@@ -104,52 +219,410 @@ func (s *PostgresStore) migrate(ctx context.Context) error {
 	return nil
 }
 
-func (s *PostgresStore) CreateDocument(ctx context.Context, filename string) (Document, error) {
-	id := uuid.New()
-	_, err := s.db.ExecContext(ctx, `INSERT INTO documents(id, filename, status) VALUES($1,$2,$3)`,
-		id, filename, StatusProcessing)
+// backfillVersions records version 1 of the documents created before
+// versioning. Row-level security is forced on the tables' owner too, which
+// the migration runs as without a tenant, so it would see no rows; it is
+// lifted for the copy, in a transaction that restores it.
+func (s *PostgresStore) backfillVersions(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, stmt := range []string{
+		`ALTER TABLE documents NO FORCE ROW LEVEL SECURITY;`,
+		`ALTER TABLE document_versions NO FORCE ROW LEVEL SECURITY;`,
+		`INSERT INTO document_versions(document_id, version, filename, source_url, content_hash, content_type, created_at, tenant_id)
+		SELECT d.id, d.version, d.filename, d.source_url, d.content_hash, d.content_type, d.created_at, d.tenant_id
+		FROM documents d
+		WHERE NOT EXISTS (SELECT 1 FROM document_versions v WHERE v.document_id = d.id);`,
+		`ALTER TABLE documents FORCE ROW LEVEL SECURITY;`,
+		`ALTER TABLE document_versions FORCE ROW LEVEL SECURITY;`,
+	} {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStore) CreateDocument(ctx context.Context, doc Document) (Document, error) {
+	doc.ID = uuid.New()
+	doc.Status = StatusProcessing
+	metadata, err := marshalMetadata(doc.Metadata)
+	if err != nil {
+		return Document{}, err
+	}
+	tx, err := s.begin(ctx)
 	if err != nil {
 		return Document{}, err
 	}
-	return Document{ID: id, Filename: filename, Status: StatusProcessing, CreatedAt: time.Now()}, nil
+	defer tx.Rollback()
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO documents(id, filename, status, source_url, content_hash, content_type, metadata)
+		VALUES($1,$2,$3,NULLIF($4,''),NULLIF($5,''),NULLIF($6,''),$7) RETURNING created_at, version`,
+		doc.ID, doc.Filename, doc.Status, doc.SourceURL, doc.ContentHash, doc.ContentType, string(metadata)).Scan(&doc.CreatedAt, &doc.Version)
+	if err != nil {
+		return Document{}, err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO document_versions(document_id, version, filename, source_url, content_hash, content_type, created_at)
+		VALUES($1,$2,$3,NULLIF($4,''),NULLIF($5,''),NULLIF($6,''),$7)`,
+		doc.ID, doc.Version, doc.Filename, doc.SourceURL, doc.ContentHash, doc.ContentType, doc.CreatedAt)
+	if err != nil {
+		return Document{}, err
+	}
+	return doc, tx.Commit()
 }
 
 func (s *PostgresStore) GetDocument(ctx context.Context, id uuid.UUID) (Document, error) {
+	return s.queryDocument(ctx, `SELECT `+documentColumns+` FROM documents WHERE id=$1`, id)
+}
+
+func (s *PostgresStore) FindDocumentByHash(ctx context.Context, hash string) (Document, error) {
+	return s.queryDocument(ctx, `SELECT `+documentColumns+` FROM documents
+		WHERE content_hash=$1 AND status <> $2
+		ORDER BY created_at
+		LIMIT 1`, hash, StatusFailed)
+}
+
+func (s *PostgresStore) ListDocuments(ctx context.Context, filter DocumentFilter) ([]Document, error) {
+	var where []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if filter.Status != "" {
+		where = append(where, "status = "+arg(filter.Status))
+	}
+	if filter.Filename != "" {
+		where = append(where, "filename ILIKE "+arg("%"+escapeLike(filter.Filename)+"%"))
+	}
+	if !filter.CreatedAfter.IsZero() {
+		where = append(where, "created_at >= "+arg(filter.CreatedAfter))
+	}
+	if !filter.CreatedBefore.IsZero() {
+		where = append(where, "created_at < "+arg(filter.CreatedBefore))
+	}
+
+	order, cmp := "DESC", "<"
+	if filter.Ascending {
+		order, cmp = "ASC", ">"
+	}
+	metadata, err := filter.Metadata.sql("metadata", arg)
+	if err != nil {
+		return nil, err
+	}
+	where = append(where, metadata...)
+	if filter.After != nil {
+		where = append(where, fmt.Sprintf("(created_at, id) %s (%s, %s)", cmp, arg(filter.After.CreatedAt), arg(filter.After.ID)))
+	}
+
+	query := `SELECT ` + documentColumns + ` FROM documents`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY created_at %s, id %s", order, order)
+	if filter.Limit > 0 {
+		query += " LIMIT " + arg(filter.Limit)
+	}
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Document
+	for rows.Next() {
+		doc, err := s.scanDocument(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, doc)
+	}
+	return out, rows.Err()
+}
+
+// escapeLike escapes LIKE wildcards so user input matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+const documentColumns = `id, filename, status, created_at, COALESCE(source_url, ''), COALESCE(content_hash, ''), COALESCE(content_type, ''), metadata, version`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanDocument reads a row selected with documentColumns.
+func (s *PostgresStore) scanDocument(row rowScanner) (Document, error) {
 	var doc Document
-	err := s.db.QueryRowContext(ctx,
-		`SELECT id, filename, status, created_at FROM documents WHERE id=$1`, id).
-		Scan(&doc.ID, &doc.Filename, &doc.Status, &doc.CreatedAt)
+	var metadata []byte
+	err := row.Scan(&doc.ID, &doc.Filename, &doc.Status, &doc.CreatedAt, &doc.SourceURL, &doc.ContentHash, &doc.ContentType, &metadata, &doc.Version)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return Document{}, errors.New("document not found")
+			return Document{}, ErrDocumentNotFound
 		}
 		return Document{}, err
 	}
+	if err := json.Unmarshal(metadata, &doc.Metadata); err != nil {
+		return Document{}, fmt.Errorf("invalid metadata of document %s: %w", doc.ID, err)
+	}
 	return doc, nil
 }
 
+// queryDocument runs a query returning one row of documentColumns in its own
+// tenant transaction, committed so that it can also be an UPDATE ... RETURNING.
+func (s *PostgresStore) queryDocument(ctx context.Context, query string, args ...any) (Document, error) {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return Document{}, err
+	}
+	defer tx.Rollback()
+	doc, err := s.scanDocument(tx.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		return Document{}, err
+	}
+	return doc, tx.Commit()
+}
+
+// marshalMetadata encodes metadata for the JSONB column; nil becomes {}.
+func marshalMetadata(md map[string]string) ([]byte, error) {
+	if md == nil {
+		md = map[string]string{}
+	}
+	return json.Marshal(md)
+}
+
+func (s *PostgresStore) UpdateDocumentMetadata(ctx context.Context, id uuid.UUID, set map[string]string, remove []string) (Document, error) {
+	patch, err := marshalMetadata(set)
+	if err != nil {
+		return Document{}, err
+	}
+	if remove == nil {
+		remove = []string{}
+	}
+	return s.queryDocument(ctx, `UPDATE documents SET metadata = (metadata || $2::jsonb) - $3::text[]
+		WHERE id=$1
+		RETURNING `+documentColumns, id, string(patch), pq.Array(remove))
+}
+
 func (s *PostgresStore) UpdateDocumentStatus(ctx context.Context, id uuid.UUID, status DocumentStatus) error {
-	res, err := s.db.ExecContext(ctx, `UPDATE documents SET status=$1 WHERE id=$2`, status, id)
+	res, err := s.exec(ctx, `UPDATE documents SET status=$1 WHERE id=$2`, status, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrDocumentNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStore) MarkProcessing(ctx context.Context, id uuid.UUID) error {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := markProcessing(ctx, tx, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// markProcessing implements MarkProcessing within tx. The update holds the
+// document's row lock until tx ends.
+func markProcessing(ctx context.Context, tx *sql.Tx, id uuid.UUID) error {
+	res, err := tx.ExecContext(ctx, `UPDATE documents SET status=$1 WHERE id=$2 AND status IS DISTINCT FROM $1`, StatusProcessing, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM documents WHERE id=$1)`, id).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrDocumentNotFound
+		}
+		return ErrDocumentProcessing
+	}
+	return nil
+}
+
+func (s *PostgresStore) CreateVersion(ctx context.Context, docID uuid.UUID, v Version) (Version, error) {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return Version{}, err
+	}
+	defer tx.Rollback()
+	// The status is kept so FailVersion can return to it
+	var prior DocumentStatus
+	err = tx.QueryRowContext(ctx, `SELECT status FROM documents WHERE id=$1 FOR UPDATE`, docID).Scan(&prior)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Version{}, ErrDocumentNotFound
+	}
+	if err != nil {
+		return Version{}, err
+	}
+	// Only one run at a time, which also keeps concurrent uploads from
+	// claiming the same number
+	if err := markProcessing(ctx, tx, docID); err != nil {
+		return Version{}, err
+	}
+	v.DocumentID = docID
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO document_versions(document_id, version, filename, source_url, content_hash, content_type, prior_status)
+		SELECT $1, COALESCE(MAX(version), 0) + 1, $2, NULLIF($3,''), NULLIF($4,''), NULLIF($5,''), $6
+		FROM document_versions WHERE document_id=$1
+		RETURNING version, created_at`,
+		docID, v.Filename, v.SourceURL, v.ContentHash, v.ContentType, prior).Scan(&v.Number, &v.CreatedAt)
+	if err != nil {
+		return Version{}, err
+	}
+	return v, tx.Commit()
+}
+
+func (s *PostgresStore) ListVersions(ctx context.Context, docID uuid.UUID) ([]Version, error) {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	rows, err := tx.QueryContext(ctx, `
+		SELECT version, COALESCE(filename, ''), COALESCE(source_url, ''), COALESCE(content_hash, ''), COALESCE(content_type, ''), created_at, COALESCE(error, '')
+		FROM document_versions WHERE document_id=$1
+		ORDER BY version`, docID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Version
+	for rows.Next() {
+		v := Version{DocumentID: docID}
+		if err := rows.Scan(&v.Number, &v.Filename, &v.SourceURL, &v.ContentHash, &v.ContentType, &v.CreatedAt, &v.Error); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// Every document has at least its first version
+	if len(out) == 0 {
+		return nil, ErrDocumentNotFound
+	}
+	return out, nil
+}
+
+func (s *PostgresStore) PublishVersion(ctx context.Context, docID uuid.UUID, version int) error {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, `
+		UPDATE documents d SET version=v.version, filename=v.filename, source_url=v.source_url,
+			content_hash=v.content_hash, content_type=v.content_type
+		FROM document_versions v
+		WHERE d.id=$1 AND v.document_id=d.id AND v.version=$2 AND d.version < v.version`, docID, version)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		var exists bool
+		err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM document_versions WHERE document_id=$1 AND version=$2)`, docID, version).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrVersionNotFound
+		}
+		// Already live, or superseded by a later version
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStore) FailVersion(ctx context.Context, docID uuid.UUID, version int, errMsg string) error {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, `UPDATE document_versions SET error=$3 WHERE document_id=$1 AND version=$2`, docID, version, errMsg)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrVersionNotFound
+	}
+	// Versions from before prior_status was recorded had a live, ready document
+	_, err = tx.ExecContext(ctx, `
+		UPDATE documents d SET status=COALESCE(v.prior_status, $3)
+		FROM document_versions v
+		WHERE d.id=$1 AND v.document_id=d.id AND v.version=$2 AND d.version < v.version`, docID, version, StatusReady)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStore) DeleteDocument(ctx context.Context, id uuid.UUID) error {
+	// chunks, summaries, embeddings and batch_documents go with it via ON DELETE CASCADE
+	res, err := s.exec(ctx, `DELETE FROM documents WHERE id=$1`, id)
 	if err != nil {
 		return err
 	}
 	if n, _ := res.RowsAffected(); n == 0 {
-		return errors.New("document not found")
+		return ErrDocumentNotFound
 	}
 	return nil
 }
 
 func (s *PostgresStore) SaveChunks(ctx context.Context, docID uuid.UUID, chunks []Chunk) ([]Chunk, error) {
-	tx, err := s.db.BeginTx(ctx, nil)
+	return s.insertChunks(ctx, docID, chunks, false)
+}
+
+func (s *PostgresStore) StageChunks(ctx context.Context, docID uuid.UUID, chunks []Chunk) ([]Chunk, error) {
+	return s.insertChunks(ctx, docID, chunks, true)
+}
+
+// insertChunks writes chunks in one transaction. Staging first clears any
+// chunks left staged by a previous run, and staged chunks always belong to
+// the live version.
+func (s *PostgresStore) insertChunks(ctx context.Context, docID uuid.UUID, chunks []Chunk, staged bool) ([]Chunk, error) {
+	tx, err := s.begin(ctx)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
+	if staged {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM chunks WHERE document_id=$1 AND staged`, docID); err != nil {
+			return nil, err
+		}
+	}
+	live := 0
 	out := make([]Chunk, 0, len(chunks))
 	for _, c := range chunks {
+		if c.Version == 0 || staged {
+			if live == 0 {
+				if live, err = liveVersion(ctx, tx, docID); err != nil {
+					return nil, err
+				}
+			}
+			c.Version = live
+		}
 		cid := uuid.New()
-		_, err := tx.ExecContext(ctx, `INSERT INTO chunks(id, document_id, ord, text, token_count) VALUES($1,$2,$3,$4,$5)`,
-			cid, docID, c.Index, c.Text, c.TokenCount)
+		_, err := tx.ExecContext(ctx, `INSERT INTO chunks(id, document_id, version, ord, text, token_count, staged) VALUES($1,$2,$3,$4,$5,$6,$7)`,
+			cid, docID, c.Version, c.Index, c.Text, c.TokenCount, staged)
 		if err != nil {
 			return nil, err
 		}
@@ -163,12 +636,31 @@ func (s *PostgresStore) SaveChunks(ctx context.Context, docID uuid.UUID, chunks
 	return out, nil
 }
 
+func (s *PostgresStore) PromoteStagedChunks(ctx context.Context, docID uuid.UUID) error {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// Old embeddings go with their chunks via ON DELETE CASCADE; other
+	// versions keep theirs
+	_, err = tx.ExecContext(ctx, `DELETE FROM chunks WHERE document_id=$1 AND NOT staged
+		AND version IN (SELECT version FROM chunks WHERE document_id=$1 AND staged)`, docID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE chunks SET staged=false WHERE document_id=$1 AND staged`, docID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 func (s *PostgresStore) SaveSummary(ctx context.Context, docID uuid.UUID, summary Summary) error {
-	_, err := s.db.ExecContext(ctx, `
-		INSERT INTO summaries(document_id, summary, key_points)
-		VALUES($1,$2,$3)
-		ON CONFLICT (document_id) DO UPDATE SET summary=excluded.summary, key_points=excluded.key_points`,
-		docID, summary.Summary, pqStringArray(summary.KeyPoints))
+	_, err := s.exec(ctx, `
+		INSERT INTO summaries(document_id, version, summary, key_points)
+		SELECT d.id, COALESCE(NULLIF($2, 0), d.version), $3, $4 FROM documents d WHERE d.id=$1
+		ON CONFLICT (document_id, version) DO UPDATE SET summary=excluded.summary, key_points=excluded.key_points`,
+		docID, summary.Version, summary.Summary, pqStringArray(summary.KeyPoints))
 	return err
 }
 
@@ -196,17 +688,25 @@ func (s *PostgresStore) SaveEmbeddings(ctx context.Context, embs []Embedding) er
 
 	query += ` ON CONFLICT (chunk_id) DO UPDATE SET vector = EXCLUDED.vector, model = EXCLUDED.model`
 
-	_, err := s.db.ExecContext(ctx, query, values...)
+	_, err := s.exec(ctx, query, values...)
 	return err
 }
 
-func (s *PostgresStore) GetSummary(ctx context.Context, docID uuid.UUID) (Summary, error) {
+func (s *PostgresStore) GetSummary(ctx context.Context, docID uuid.UUID, version int) (Summary, error) {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return Summary{}, err
+	}
+	defer tx.Rollback()
 	var sum Summary
 	var keyPoints []string
-	row := s.db.QueryRowContext(ctx, `SELECT summary, key_points FROM summaries WHERE document_id=$1`, docID)
-	if err := row.Scan(&sum.Summary, pq.Array(&keyPoints)); err != nil {
+	row := tx.QueryRowContext(ctx, `
+		SELECT s.version, s.summary, s.key_points
+		FROM summaries s JOIN documents d ON d.id = s.document_id
+		WHERE s.document_id=$1 AND s.version = COALESCE(NULLIF($2, 0), d.version)`, docID, version)
+	if err := row.Scan(&sum.Version, &sum.Summary, pq.Array(&keyPoints)); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return Summary{}, ErrSummaryNotFound
+			return Summary{}, missingSummary(ctx, tx, docID, version)
 		}
 		return Summary{}, fmt.Errorf("failed to get summary for doc %s: %w", docID, err)
 	}
@@ -215,32 +715,94 @@ func (s *PostgresStore) GetSummary(ctx context.Context, docID uuid.UUID) (Summar
 	return sum, nil
 }
 
-func (s *PostgresStore) TopK(ctx context.Context, docIDs []uuid.UUID, vector embeddings.Vector, k int) ([]SearchResult, error) {
-	// Convert query vector to pgvector format
-	queryVec := vectorToString(vector)
+// missingSummary tells why GetSummary found no summary: the document or
+// version does not exist, or it has not been summarized yet.
+func missingSummary(ctx context.Context, tx *sql.Tx, docID uuid.UUID, version int) error {
+	var docExists, versionExists bool
+	err := tx.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM documents WHERE id=$1),
+			$2 = 0 OR EXISTS(SELECT 1 FROM document_versions WHERE document_id=$1 AND version=$2)`,
+		docID, version).Scan(&docExists, &versionExists)
+	switch {
+	case err != nil:
+		return fmt.Errorf("failed to get summary for doc %s: %w", docID, err)
+	case !docExists:
+		return ErrDocumentNotFound
+	case !versionExists:
+		return ErrVersionNotFound
+	}
+	return ErrSummaryNotFound
+}
+
+func (s *PostgresStore) TopK(ctx context.Context, scope SearchScope, vector embeddings.Vector, k int) ([]SearchResult, error) {
+	if len(scope.DocumentIDs) == 0 && len(scope.Metadata) == 0 {
+		return nil, ErrEmptySearchScope
+	}
 
 	// Minimum similarity threshold for relevant results (0.7 = 70% similarity)
 	const minSimilarity = 0.7
 
-	rows, err := s.db.QueryContext(ctx, `
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	// Convert query vector to pgvector format
+	queryVec := arg(vectorToString(vector)) + "::vector"
+
+	where := []string{
+		"NOT c.staged",
+		fmt.Sprintf("(1 - (e.vector <=> %s)) >= %s", queryVec, arg(minSimilarity)),
+	}
+	// Each document is searched at its live version unless pinned
+	var pins string
+	if len(scope.Versions) > 0 {
+		ids := make([]uuid.UUID, 0, len(scope.Versions))
+		versions := make([]int, 0, len(scope.Versions))
+		for id, v := range scope.Versions {
+			ids = append(ids, id)
+			versions = append(versions, v)
+		}
+		pins = fmt.Sprintf("LEFT JOIN unnest(%s::uuid[], %s::int[]) AS p(document_id, version) ON p.document_id = c.document_id",
+			arg(ids), arg(versions))
+		where = append(where, "c.version = COALESCE(p.version, d.version)")
+	} else {
+		where = append(where, "c.version = d.version")
+	}
+	if len(scope.DocumentIDs) > 0 {
+		where = append(where, "c.document_id = ANY("+arg(pqUUIDArray(scope.DocumentIDs))+")")
+	}
+	metadata, err := scope.Metadata.sql("d.metadata", arg)
+	if err != nil {
+		return nil, err
+	}
+	where = append(where, metadata...)
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	rows, err := tx.QueryContext(ctx, `
 		SELECT 
 			c.id, 
 			c.document_id, 
+			c.version,
 			c.ord, 
 			c.text, 
 			c.token_count,
 			e.model,
-			1 - (e.vector <=> $1::vector) as similarity,
+			1 - (e.vector <=> `+queryVec+`) as similarity,
 			COALESCE(s.summary, ''), 
 			COALESCE(s.key_points, ARRAY[]::TEXT[])
 		FROM embeddings e
 		JOIN chunks c ON c.id = e.chunk_id
-		LEFT JOIN summaries s ON s.document_id = c.document_id
-		WHERE c.document_id = ANY($2)
-		  AND (1 - (e.vector <=> $1::vector)) >= $4
-		ORDER BY e.vector <=> $1::vector
-		LIMIT $3
-	`, queryVec, pqUUIDArray(docIDs), k, minSimilarity)
+		JOIN documents d ON d.id = c.document_id
+		`+pins+`
+		LEFT JOIN summaries s ON s.document_id = c.document_id AND s.version = c.version
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY e.vector <=> `+queryVec+`
+		LIMIT `+arg(k), args...)
 
 	if err != nil {
 		return nil, err
@@ -252,6 +814,7 @@ func (s *PostgresStore) TopK(ctx context.Context, docIDs []uuid.UUID, vector emb
 		var (
 			chunkID    uuid.UUID
 			docID      uuid.UUID
+			version    int
 			ord        int
 			text       string
 			tokens     int
@@ -260,7 +823,7 @@ func (s *PostgresStore) TopK(ctx context.Context, docIDs []uuid.UUID, vector emb
 			summaryTxt string
 			keyPoints  []string
 		)
-		if err := rows.Scan(&chunkID, &docID, &ord, &text, &tokens, &model, &similarity, &summaryTxt, pq.Array(&keyPoints)); err != nil {
+		if err := rows.Scan(&chunkID, &docID, &version, &ord, &text, &tokens, &model, &similarity, &summaryTxt, pq.Array(&keyPoints)); err != nil {
 			return nil, err
 		}
 
@@ -268,6 +831,7 @@ func (s *PostgresStore) TopK(ctx context.Context, docIDs []uuid.UUID, vector emb
 			Chunk: Chunk{
 				ID:         chunkID,
 				DocumentID: docID,
+				Version:    version,
 				Index:      ord,
 				Text:       text,
 				TokenCount: tokens,
@@ -275,6 +839,7 @@ func (s *PostgresStore) TopK(ctx context.Context, docIDs []uuid.UUID, vector emb
 			Score: similarity,
 			Summary: Summary{
 				DocumentID: docID,
+				Version:    version,
 				Summary:    summaryTxt,
 				KeyPoints:  keyPoints,
 			},
@@ -284,8 +849,330 @@ func (s *PostgresStore) TopK(ctx context.Context, docIDs []uuid.UUID, vector emb
 	return results, nil
 }
 
-func (s *PostgresStore) ListChunks(ctx context.Context, docID uuid.UUID) ([]Chunk, error) {
-	rows, err := s.db.QueryContext(ctx, `SELECT id, ord, text, token_count FROM chunks WHERE document_id=$1`, docID)
+func (s *PostgresStore) RecordStage(ctx context.Context, docID uuid.UUID, stage Stage, state StageState, attempt int, errMsg string) error {
+	var query string
+	args := []any{docID, stage, state}
+	switch state {
+	case StagePending:
+		query = `INSERT INTO document_stages(document_id, stage, state) VALUES($1,$2,$3)
+			ON CONFLICT (document_id, stage) DO UPDATE SET state=$3, attempts=0, last_error=NULL,
+				started_at=NULL, completed_at=NULL, updated_at=now()`
+	case StageRunning:
+		query = `INSERT INTO document_stages(document_id, stage, state, attempts, started_at) VALUES($1,$2,$3,$4,now())
+			ON CONFLICT (document_id, stage) DO UPDATE SET state=$3, attempts=$4, started_at=now(),
+				completed_at=NULL, updated_at=now()`
+		args = append(args, attempt)
+	case StageDone:
+		query = `INSERT INTO document_stages(document_id, stage, state, attempts, completed_at) VALUES($1,$2,$3,$4,now())
+			ON CONFLICT (document_id, stage) DO UPDATE SET state=$3, attempts=$4, last_error=NULL,
+				completed_at=now(), updated_at=now()`
+		args = append(args, attempt)
+	case StageFailed:
+		query = `INSERT INTO document_stages(document_id, stage, state, attempts, last_error) VALUES($1,$2,$3,$4,$5)
+			ON CONFLICT (document_id, stage) DO UPDATE SET state=$3, attempts=$4, last_error=$5, updated_at=now()`
+		args = append(args, attempt, errMsg)
+	default:
+		return fmt.Errorf("unknown stage state %q", state)
+	}
+	_, err := s.exec(ctx, query, args...)
+	return err
+}
+
+func (s *PostgresStore) ListStages(ctx context.Context, docID uuid.UUID) ([]StageStatus, error) {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	rows, err := tx.QueryContext(ctx, `
+		SELECT stage, state, attempts, COALESCE(last_error, ''), started_at, completed_at, updated_at
+		FROM document_stages WHERE document_id=$1`, docID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []StageStatus
+	for rows.Next() {
+		var st StageStatus
+		var started, completed sql.NullTime
+		if err := rows.Scan(&st.Stage, &st.State, &st.Attempts, &st.LastError, &started, &completed, &st.UpdatedAt); err != nil {
+			return nil, err
+		}
+		st.StartedAt = started.Time
+		st.CompletedAt = completed.Time
+		out = append(out, st)
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresStore) CreateBatch(ctx context.Context, docIDs []uuid.UUID) (Batch, error) {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return Batch{}, err
+	}
+	defer tx.Rollback()
+
+	batch := Batch{ID: uuid.New()}
+	if err := tx.QueryRowContext(ctx, `INSERT INTO batches(id) VALUES($1) RETURNING created_at`, batch.ID).
+		Scan(&batch.CreatedAt); err != nil {
+		return Batch{}, err
+	}
+	for _, docID := range docIDs {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO batch_documents(batch_id, document_id) VALUES($1,$2)`, batch.ID, docID); err != nil {
+			return Batch{}, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return Batch{}, err
+	}
+	return batch, nil
+}
+
+// GetBatch returns the batch with the current state of each of its documents.
+func (s *PostgresStore) GetBatch(ctx context.Context, id uuid.UUID) (Batch, error) {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return Batch{}, err
+	}
+	defer tx.Rollback()
+
+	batch := Batch{ID: id}
+	err = tx.QueryRowContext(ctx, `SELECT created_at FROM batches WHERE id=$1`, id).Scan(&batch.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Batch{}, ErrBatchNotFound
+		}
+		return Batch{}, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT d.id, d.filename, d.status, d.created_at
+		FROM batch_documents bd
+		JOIN documents d ON d.id = bd.document_id
+		WHERE bd.batch_id = $1
+		ORDER BY d.created_at, d.filename`, id)
+	if err != nil {
+		return Batch{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var doc Document
+		if err := rows.Scan(&doc.ID, &doc.Filename, &doc.Status, &doc.CreatedAt); err != nil {
+			return Batch{}, err
+		}
+		batch.Documents = append(batch.Documents, doc)
+	}
+	return batch, rows.Err()
+}
+
+func (s *PostgresStore) CreateWebhook(ctx context.Context, hook Webhook) (Webhook, error) {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return Webhook{}, err
+	}
+	defer tx.Rollback()
+	hook.ID = uuid.New()
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO webhooks(id, url, secret, events) VALUES($1,$2,$3,$4) RETURNING created_at`,
+		hook.ID, hook.URL, hook.Secret, pq.Array(hook.Events)).Scan(&hook.CreatedAt)
+	if err != nil {
+		return Webhook{}, err
+	}
+	return hook, tx.Commit()
+}
+
+func (s *PostgresStore) GetWebhook(ctx context.Context, id uuid.UUID) (Webhook, error) {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return Webhook{}, err
+	}
+	defer tx.Rollback()
+	var hook Webhook
+	err = tx.QueryRowContext(ctx, `SELECT id, url, secret, events, created_at FROM webhooks WHERE id=$1`, id).
+		Scan(&hook.ID, &hook.URL, &hook.Secret, pq.Array(&hook.Events), &hook.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Webhook{}, ErrWebhookNotFound
+	}
+	return hook, err
+}
+
+func (s *PostgresStore) ListWebhooks(ctx context.Context, event string) ([]Webhook, error) {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, url, secret, events, created_at FROM webhooks
+		WHERE $1 = '' OR $1 = ANY(events)
+		ORDER BY created_at, id`, event)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Webhook
+	for rows.Next() {
+		var hook Webhook
+		if err := rows.Scan(&hook.ID, &hook.URL, &hook.Secret, pq.Array(&hook.Events), &hook.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, hook)
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresStore) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	res, err := s.exec(ctx, `DELETE FROM webhooks WHERE id=$1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStore) RecordWebhookDelivery(ctx context.Context, d WebhookDelivery) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	_, err := s.exec(ctx, `
+		INSERT INTO webhook_deliveries(id, webhook_id, event_id, event, document_id, attempt, status_code, error, duration_ms)
+		VALUES($1,$2,$3,$4,$5,$6,NULLIF($7, 0),NULLIF($8, ''),$9)`,
+		d.ID, d.WebhookID, d.EventID, d.Event, d.DocumentID, d.Attempt, d.StatusCode, d.Error, d.Duration.Milliseconds())
+	return err
+}
+
+func (s *PostgresStore) ListWebhookDeliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]WebhookDelivery, error) {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, webhook_id, event_id, event, document_id, attempt,
+			COALESCE(status_code, 0), COALESCE(error, ''), COALESCE(duration_ms, 0), created_at
+		FROM webhook_deliveries WHERE webhook_id=$1
+		ORDER BY created_at DESC, id
+		LIMIT $2`, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		var durationMS int64
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.Event, &d.DocumentID, &d.Attempt,
+			&d.StatusCode, &d.Error, &durationMS, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		d.Duration = time.Duration(durationMS) * time.Millisecond
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+const apiKeyColumns = `id, tenant_id, name, prefix, key_hash, scopes, rate_limit, requests, created_at, last_used_at, revoked_at`
+
+func scanAPIKey(row rowScanner) (APIKey, error) {
+	var key APIKey
+	var lastUsed, revoked sql.NullTime
+	err := row.Scan(&key.ID, &key.Tenant, &key.Name, &key.Prefix, &key.Hash, pq.Array(&key.Scopes),
+		&key.RateLimit, &key.Requests, &key.CreatedAt, &lastUsed, &revoked)
+	key.LastUsedAt = lastUsed.Time
+	key.RevokedAt = revoked.Time
+	return key, err
+}
+
+func (s *PostgresStore) CreateAPIKey(ctx context.Context, key APIKey) (APIKey, error) {
+	id, ok := tenant.FromContext(ctx)
+	if !ok {
+		return APIKey{}, tenant.ErrMissing
+	}
+	key.ID = uuid.New()
+	key.Tenant = id
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO api_keys(id, tenant_id, name, prefix, key_hash, scopes, rate_limit)
+		VALUES($1,$2,$3,$4,$5,$6,$7) RETURNING created_at`,
+		key.ID, key.Tenant, key.Name, key.Prefix, key.Hash, pq.Array(key.Scopes), key.RateLimit).Scan(&key.CreatedAt)
+	if err != nil {
+		return APIKey{}, err
+	}
+	return key, nil
+}
+
+func (s *PostgresStore) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	id, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, tenant.ErrMissing
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+apiKeyColumns+` FROM api_keys
+		WHERE tenant_id=$1
+		ORDER BY created_at, id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, key)
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresStore) RevokeAPIKey(ctx context.Context, keyID uuid.UUID) error {
+	id, ok := tenant.FromContext(ctx)
+	if !ok {
+		return tenant.ErrMissing
+	}
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE api_keys SET revoked_at = COALESCE(revoked_at, now())
+		WHERE id=$1 AND tenant_id=$2`, keyID, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStore) UseAPIKey(ctx context.Context, hash string) (APIKey, error) {
+	key, err := scanAPIKey(s.db.QueryRowContext(ctx, `
+		UPDATE api_keys SET requests = requests + 1, last_used_at = now()
+		WHERE key_hash=$1 AND revoked_at IS NULL
+		RETURNING `+apiKeyColumns, hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+func (s *PostgresStore) ListChunks(ctx context.Context, docID uuid.UUID, version int) ([]Chunk, error) {
+	return s.listChunks(ctx, docID, version, false)
+}
+
+func (s *PostgresStore) ListStagedChunks(ctx context.Context, docID uuid.UUID) ([]Chunk, error) {
+	return s.listChunks(ctx, docID, 0, true)
+}
+
+func (s *PostgresStore) listChunks(ctx context.Context, docID uuid.UUID, version int, staged bool) ([]Chunk, error) {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	rows, err := tx.QueryContext(ctx, `
+		SELECT c.id, c.version, c.ord, c.text, c.token_count
+		FROM chunks c JOIN documents d ON d.id = c.document_id
+		WHERE c.document_id=$1 AND c.staged=$2 AND c.version = COALESCE(NULLIF($3, 0), d.version)
+		ORDER BY c.ord`, docID, staged, version)
 	if err != nil {
 		return nil, err
 	}
@@ -293,7 +1180,7 @@ func (s *PostgresStore) ListChunks(ctx context.Context, docID uuid.UUID) ([]Chun
 	var out []Chunk
 	for rows.Next() {
 		var c Chunk
-		if err := rows.Scan(&c.ID, &c.Index, &c.Text, &c.TokenCount); err != nil {
+		if err := rows.Scan(&c.ID, &c.Version, &c.Index, &c.Text, &c.TokenCount); err != nil {
 			return nil, err
 		}
 		c.DocumentID = docID
@@ -302,6 +1189,16 @@ func (s *PostgresStore) ListChunks(ctx context.Context, docID uuid.UUID) ([]Chun
 	return out, nil
 }
 
+// liveVersion returns the live version of a document.
+func liveVersion(ctx context.Context, tx *sql.Tx, docID uuid.UUID) (int, error) {
+	var version int
+	err := tx.QueryRowContext(ctx, `SELECT version FROM documents WHERE id=$1`, docID).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrDocumentNotFound
+	}
+	return version, err
+}
+
 func pqStringArray(items []string) any {
 	if len(items) == 0 {
 		return []string{}
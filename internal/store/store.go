@@ -2,12 +2,12 @@ package store
 
 import (
 	"context"
-	"errors"
 	"time"
 
 	"github.com/google/uuid"
 
 	"doc-agents/internal/embeddings"
+	"doc-agents/internal/errcode"
 )
 
 type DocumentStatus string
@@ -18,18 +18,149 @@ const (
 	StatusFailed     DocumentStatus = "failed"
 )
 
-var ErrSummaryNotFound = errors.New("summary not found")
+var ErrSummaryNotFound = errcode.New("summary_not_ready", "summary not found")
+
+var ErrDocumentNotFound = errcode.New("document_not_found", "document not found")
+
+var ErrDocumentProcessing = errcode.New("document_processing", "document is already processing")
+
+var ErrBatchNotFound = errcode.New("batch_not_found", "batch not found")
+
+var ErrWebhookNotFound = errcode.New("webhook_not_found", "webhook not found")
+
+var ErrAPIKeyNotFound = errcode.New("api_key_not_found", "api key not found")
+
+var ErrVersionNotFound = errcode.New("version_not_found", "document version not found")
 
 type Document struct {
+	ID          uuid.UUID
+	Filename    string
+	Status      DocumentStatus
+	CreatedAt   time.Time
+	SourceURL   string            // Set when the document was fetched from a URL
+	ContentHash string            // Hex SHA-256 of the original file, used for deduplication
+	ContentType string            // Canonical MIME type of the original file
+	Metadata    map[string]string // User-defined key/value pairs, e.g. project or team
+	Version     int               // Live version, the one summaries and queries default to
+}
+
+// Version is one uploaded revision of a document. Each version keeps its own
+// chunks, summary and embeddings, so uploading a new one leaves the earlier
+// ones intact. The file details of the live version are also those of the
+// document.
+type Version struct {
+	DocumentID  uuid.UUID
+	Number      int // 1 for the original upload
+	Filename    string
+	SourceURL   string
+	ContentHash string
+	ContentType string
+	CreatedAt   time.Time
+	Error       string // Why processing failed; the version never became live
+}
+
+// Stage is one step of the processing pipeline.
+type Stage string
+
+const (
+	StageParse     Stage = "parse"
+	StageSummarize Stage = "summarize"
+	StageEmbed     Stage = "embed"
+)
+
+// Stages lists the pipeline stages in the order they run.
+var Stages = []Stage{StageParse, StageSummarize, StageEmbed}
+
+type StageState string
+
+const (
+	StagePending StageState = "pending"
+	StageRunning StageState = "running"
+	StageDone    StageState = "done"
+	StageFailed  StageState = "failed"
+)
+
+// StageStatus is the progress of one pipeline stage for a document.
+// Zero times mean the event has not happened (in the current run).
+type StageStatus struct {
+	Stage       Stage
+	State       StageState
+	Attempts    int
+	LastError   string
+	StartedAt   time.Time
+	CompletedAt time.Time
+	UpdatedAt   time.Time
+}
+
+// DocumentFilter narrows ListDocuments. Zero values leave a field unfiltered.
+type DocumentFilter struct {
+	Status        DocumentStatus
+	Filename      string    // Case-insensitive substring match
+	CreatedAfter  time.Time // Inclusive
+	CreatedBefore time.Time // Exclusive
+	Ascending     bool      // Oldest first; the default is newest first
+	Metadata      MetadataFilter
+	After         *DocumentCursor
+	Limit         int
+}
+
+// DocumentCursor is the position of the last document of a page, so the next
+// page can resume after it even while new documents are being created.
+type DocumentCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// Batch groups documents uploaded together so their progress can be tracked as one.
+type Batch struct {
 	ID        uuid.UUID
-	Filename  string
-	Status    DocumentStatus
 	CreatedAt time.Time
+	Documents []Document
+}
+
+// Webhook is a subscription that receives document lifecycle events.
+type Webhook struct {
+	ID        uuid.UUID
+	URL       string
+	Secret    string   // Key for the HMAC-SHA256 signature of each delivery
+	Events    []string // Subscribed event names, e.g. "document.ready"
+	CreatedAt time.Time
+}
+
+// WebhookDelivery is one attempt to deliver an event to a webhook.
+type WebhookDelivery struct {
+	ID         uuid.UUID
+	WebhookID  uuid.UUID
+	EventID    uuid.UUID // Shared by every attempt to deliver the same event
+	Event      string
+	DocumentID uuid.UUID
+	Attempt    int
+	StatusCode int    // Zero when no response was received
+	Error      string // Empty when the receiver accepted the event
+	Duration   time.Duration
+	CreatedAt  time.Time
+}
+
+// APIKey authenticates gateway requests for a tenant. Only a hash of the key
+// is stored; the key itself is shown once, when it is created.
+type APIKey struct {
+	ID         uuid.UUID
+	Tenant     string
+	Name       string
+	Prefix     string   // Leading characters of the key, to tell keys apart
+	Hash       string   // Hex SHA-256 of the key
+	Scopes     []string // e.g. "read", "query"
+	RateLimit  int      // Requests per minute; zero uses the gateway default
+	Requests   int64    // Requests authenticated with the key, rate-limited ones included
+	CreatedAt  time.Time
+	LastUsedAt time.Time // Zero if never used
+	RevokedAt  time.Time // Zero while the key is active
 }
 
 type Chunk struct {
 	ID         uuid.UUID
 	DocumentID uuid.UUID
+	Version    int // Zero when saving means the document's live version
 	Index      int
 	Text       string
 	TokenCount int
@@ -37,6 +168,7 @@ type Chunk struct {
 
 type Summary struct {
 	DocumentID uuid.UUID
+	Version    int // Zero when saving means the document's live version
 	Summary    string
 	KeyPoints  []string
 }
@@ -54,14 +186,110 @@ type SearchResult struct {
 }
 
 // Store defines persistence contract; an external DB implementation can replace this.
+// Every method acts for the tenant in ctx (see package tenant): it only sees
+// and writes that tenant's rows, and fails with tenant.ErrMissing without one.
 type Store interface {
-	CreateDocument(ctx context.Context, filename string) (Document, error)
+	// CreateDocument persists a new document in StatusProcessing. ID, Status and
+	// CreatedAt are assigned by the store; the remaining fields are taken from doc.
+	CreateDocument(ctx context.Context, doc Document) (Document, error)
 	GetDocument(ctx context.Context, id uuid.UUID) (Document, error)
+	// FindDocumentByHash returns the oldest non-failed document with the given
+	// content hash, or ErrDocumentNotFound.
+	FindDocumentByHash(ctx context.Context, hash string) (Document, error)
+	// ListDocuments returns up to filter.Limit documents ordered by
+	// (created_at, id), starting after filter.After when set.
+	ListDocuments(ctx context.Context, filter DocumentFilter) ([]Document, error)
 	UpdateDocumentStatus(ctx context.Context, id uuid.UUID, status DocumentStatus) error
+	// MarkProcessing moves a document that is not already processing to
+	// StatusProcessing, atomically, so concurrent callers cannot both start a
+	// run. Returns ErrDocumentProcessing if it already is and
+	// ErrDocumentNotFound if absent.
+	MarkProcessing(ctx context.Context, id uuid.UUID) error
+	// UpdateDocumentMetadata merges set into a document's metadata, removes the
+	// keys in remove and returns the updated document. Returns
+	// ErrDocumentNotFound if absent.
+	UpdateDocumentMetadata(ctx context.Context, id uuid.UUID, set map[string]string, remove []string) (Document, error)
+	// DeleteDocument removes a document together with its chunks, summary,
+	// embeddings and batch memberships. Returns ErrDocumentNotFound if absent.
+	DeleteDocument(ctx context.Context, id uuid.UUID) error
+	// SaveChunks saves chunks for the version each names.
 	SaveChunks(ctx context.Context, docID uuid.UUID, chunks []Chunk) ([]Chunk, error)
-	ListChunks(ctx context.Context, docID uuid.UUID) ([]Chunk, error)
+	// ListChunks returns the chunks of a version of a document; version 0
+	// means the live one.
+	ListChunks(ctx context.Context, docID uuid.UUID, version int) ([]Chunk, error)
+	// StageChunks saves chunks for a reprocessing run of the live version
+	// without exposing them: staged chunks are hidden from ListChunks and TopK
+	// until promoted. Chunks left staged by an earlier, abandoned run are
+	// replaced.
+	StageChunks(ctx context.Context, docID uuid.UUID, chunks []Chunk) ([]Chunk, error)
+	ListStagedChunks(ctx context.Context, docID uuid.UUID) ([]Chunk, error)
+	// PromoteStagedChunks atomically swaps the live version's chunks (and
+	// their embeddings) for the staged ones.
+	PromoteStagedChunks(ctx context.Context, docID uuid.UUID) error
+	// SaveSummary saves the summary of the version it names, replacing any
+	// earlier summary of that version.
 	SaveSummary(ctx context.Context, docID uuid.UUID, summary Summary) error
 	SaveEmbeddings(ctx context.Context, embs []Embedding) error
-	GetSummary(ctx context.Context, docID uuid.UUID) (Summary, error)
-	TopK(ctx context.Context, docIDs []uuid.UUID, vector embeddings.Vector, k int) ([]SearchResult, error)
+	// GetSummary returns the summary of a version of a document; version 0
+	// means the live one. Returns ErrSummaryNotFound if there is none yet,
+	// ErrDocumentNotFound if the document is absent and ErrVersionNotFound if
+	// it has no such version.
+	GetSummary(ctx context.Context, docID uuid.UUID, version int) (Summary, error)
+	// CreateVersion adds the next version of a document and moves the
+	// document to StatusProcessing, atomically like MarkProcessing. Number
+	// and CreatedAt are assigned by the store; the live version is unchanged
+	// until PublishVersion. Returns ErrDocumentProcessing if the document is
+	// already processing and ErrDocumentNotFound if absent.
+	CreateVersion(ctx context.Context, docID uuid.UUID, v Version) (Version, error)
+	// ListVersions returns the versions of a document, oldest first. Returns
+	// ErrDocumentNotFound if absent.
+	ListVersions(ctx context.Context, docID uuid.UUID) ([]Version, error)
+	// PublishVersion makes version the live version of a document, together
+	// with its file details, unless a later version already is. Returns
+	// ErrVersionNotFound if the document has no such version.
+	PublishVersion(ctx context.Context, docID uuid.UUID, version int) error
+	// FailVersion records why version of a document failed to process and,
+	// unless it is already live, returns the document to the status it had
+	// before the version was created: the live version is still there.
+	// Returns ErrVersionNotFound if the document has no such version.
+	FailVersion(ctx context.Context, docID uuid.UUID, version int, errMsg string) error
+	// TopK returns the k chunks most similar to vector among the documents in
+	// scope, searching the live version of each document unless scope pins
+	// another. Returns ErrEmptySearchScope if scope restricts nothing.
+	TopK(ctx context.Context, scope SearchScope, vector embeddings.Vector, k int) ([]SearchResult, error)
+	// RecordStage upserts a stage's state. Running sets the attempt number and
+	// start time, done the completion time, failed the error message; pending
+	// resets the stage for a new run.
+	RecordStage(ctx context.Context, docID uuid.UUID, stage Stage, state StageState, attempt int, errMsg string) error
+	// ListStages returns the recorded stages of a document; stages that never
+	// started are absent.
+	ListStages(ctx context.Context, docID uuid.UUID) ([]StageStatus, error)
+	CreateBatch(ctx context.Context, docIDs []uuid.UUID) (Batch, error)
+	GetBatch(ctx context.Context, id uuid.UUID) (Batch, error)
+	// CreateWebhook registers a subscription. ID and CreatedAt are assigned by the store.
+	CreateWebhook(ctx context.Context, hook Webhook) (Webhook, error)
+	// GetWebhook returns ErrWebhookNotFound if absent.
+	GetWebhook(ctx context.Context, id uuid.UUID) (Webhook, error)
+	// ListWebhooks returns the subscriptions to event, or all of them when
+	// event is empty, oldest first.
+	ListWebhooks(ctx context.Context, event string) ([]Webhook, error)
+	// DeleteWebhook removes a subscription and its delivery log. Returns
+	// ErrWebhookNotFound if absent.
+	DeleteWebhook(ctx context.Context, id uuid.UUID) error
+	RecordWebhookDelivery(ctx context.Context, delivery WebhookDelivery) error
+	// ListWebhookDeliveries returns up to limit of a webhook's latest delivery
+	// attempts, newest first.
+	ListWebhookDeliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]WebhookDelivery, error)
+	// CreateAPIKey stores a key for the tenant in ctx. ID and CreatedAt are
+	// assigned by the store.
+	CreateAPIKey(ctx context.Context, key APIKey) (APIKey, error)
+	// ListAPIKeys returns the keys of the tenant in ctx, revoked ones
+	// included, oldest first.
+	ListAPIKeys(ctx context.Context) ([]APIKey, error)
+	// RevokeAPIKey disables one of the tenant's keys; revoking it again is a
+	// no-op. Returns ErrAPIKeyNotFound if absent.
+	RevokeAPIKey(ctx context.Context, id uuid.UUID) error
+	// UseAPIKey looks up an active key by hash across all tenants and records
+	// its use. Returns ErrAPIKeyNotFound if no active key has that hash.
+	UseAPIKey(ctx context.Context, hash string) (APIKey, error)
 }
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"doc-agents/internal/tenant"
+)
+
+// tenantRole is the role every store transaction runs as. It is subject to
+// row-level security even when the service connects as a superuser or as the
+// tables' owner, both of which would otherwise bypass the policies.
+const tenantRole = "doc_agents_tenant"
+
+// tenantTables hold per-tenant rows, each guarded by a tenant_isolation policy.
+var tenantTables = []string{
+	"documents", "document_versions", "chunks", "summaries", "embeddings",
+	"document_stages", "batches", "batch_documents", "webhooks", "webhook_deliveries",
+}
+
+// tenantIsolationStmts adds tenant_id to every tenant table and restricts
+// rows to the tenant set by begin. Rows that predate multi-tenancy belong to
+// tenant.Default; new rows take the transaction's tenant, so inserts need no
+// explicit tenant_id and cannot name another tenant's.
+func tenantIsolationStmts() []string {
+	stmts := []string{
+		fmt.Sprintf(`DO $$ BEGIN
+			IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = '%s') THEN
+				CREATE ROLE %s NOLOGIN NOBYPASSRLS;
+			END IF;
+		END $$;`, tenantRole, tenantRole),
+		`GRANT ` + tenantRole + ` TO CURRENT_USER;`,
+		`GRANT USAGE ON SCHEMA public TO ` + tenantRole + `;`,
+	}
+	for _, table := range tenantTables {
+		stmts = append(stmts,
+			fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT '%s';`, table, tenant.Default),
+			fmt.Sprintf(`ALTER TABLE %s ALTER COLUMN tenant_id SET DEFAULT current_setting('app.tenant_id');`, table),
+			fmt.Sprintf(`GRANT SELECT, INSERT, UPDATE, DELETE ON %s TO %s;`, table, tenantRole),
+			fmt.Sprintf(`ALTER TABLE %s ENABLE ROW LEVEL SECURITY;`, table),
+			fmt.Sprintf(`ALTER TABLE %s FORCE ROW LEVEL SECURITY;`, table),
+			fmt.Sprintf(`DROP POLICY IF EXISTS tenant_isolation ON %s;`, table),
+			fmt.Sprintf(`CREATE POLICY tenant_isolation ON %s
+				USING (tenant_id = current_setting('app.tenant_id', true))
+				WITH CHECK (tenant_id = current_setting('app.tenant_id', true));`, table),
+		)
+	}
+	// Listing and deduplication filter on the tenant first
+	stmts = append(stmts,
+		`CREATE INDEX IF NOT EXISTS documents_tenant_created_at_id_idx ON documents(tenant_id, created_at, id);`,
+		`CREATE INDEX IF NOT EXISTS documents_tenant_content_hash_idx ON documents(tenant_id, content_hash);`,
+	)
+	return stmts
+}
+
+// begin starts a transaction scoped to the tenant in ctx: it runs as
+// tenantRole with app.tenant_id set, so the row-level security policies only
+// expose and accept that tenant's rows. Without a tenant it fails with
+// tenant.ErrMissing rather than running unscoped.
+func (s *PostgresStore) begin(ctx context.Context) (*sql.Tx, error) {
+	id, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, tenant.ErrMissing
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `SELECT set_config('app.tenant_id', $1, true)`, id); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `SET LOCAL ROLE `+tenantRole); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return tx, nil
+}
+
+// exec runs a single statement in its own tenant transaction.
+func (s *PostgresStore) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return res, tx.Commit()
+}
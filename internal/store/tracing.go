@@ -0,0 +1,290 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"doc-agents/internal/embeddings"
+	"doc-agents/internal/tracing"
+)
+
+var tracer = otel.Tracer("doc-agents/internal/store")
+
+// WithTracing wraps every call to s in a client span named after the method,
+// such as "store.GetDocument".
+func WithTracing(s Store) Store {
+	return &tracedStore{next: s}
+}
+
+type tracedStore struct {
+	next Store
+}
+
+func (s *tracedStore) start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "store."+method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// end ends span. Looking up a row that does not exist is an answer, not a
+// failure, so it leaves the span's status alone.
+func end(span trace.Span, err error) {
+	if errors.Is(err, ErrDocumentNotFound) || errors.Is(err, ErrSummaryNotFound) || errors.Is(err, ErrBatchNotFound) ||
+		errors.Is(err, ErrWebhookNotFound) || errors.Is(err, ErrAPIKeyNotFound) || errors.Is(err, ErrVersionNotFound) {
+		span.SetAttributes(attribute.Bool("not_found", true))
+		err = nil
+	}
+	tracing.End(span, err)
+}
+
+func documentID(id uuid.UUID) attribute.KeyValue {
+	return attribute.String("document_id", id.String())
+}
+
+func (s *tracedStore) CreateDocument(ctx context.Context, doc Document) (Document, error) {
+	ctx, span := s.start(ctx, "CreateDocument")
+	out, err := s.next.CreateDocument(ctx, doc)
+	end(span, err)
+	return out, err
+}
+
+func (s *tracedStore) GetDocument(ctx context.Context, id uuid.UUID) (Document, error) {
+	ctx, span := s.start(ctx, "GetDocument", documentID(id))
+	out, err := s.next.GetDocument(ctx, id)
+	end(span, err)
+	return out, err
+}
+
+func (s *tracedStore) FindDocumentByHash(ctx context.Context, hash string) (Document, error) {
+	ctx, span := s.start(ctx, "FindDocumentByHash")
+	out, err := s.next.FindDocumentByHash(ctx, hash)
+	end(span, err)
+	return out, err
+}
+
+func (s *tracedStore) ListDocuments(ctx context.Context, filter DocumentFilter) ([]Document, error) {
+	ctx, span := s.start(ctx, "ListDocuments")
+	out, err := s.next.ListDocuments(ctx, filter)
+	end(span, err)
+	return out, err
+}
+
+func (s *tracedStore) UpdateDocumentStatus(ctx context.Context, id uuid.UUID, status DocumentStatus) error {
+	ctx, span := s.start(ctx, "UpdateDocumentStatus", documentID(id))
+	err := s.next.UpdateDocumentStatus(ctx, id, status)
+	end(span, err)
+	return err
+}
+
+func (s *tracedStore) MarkProcessing(ctx context.Context, id uuid.UUID) error {
+	ctx, span := s.start(ctx, "MarkProcessing")
+	err := s.next.MarkProcessing(ctx, id)
+	end(span, err)
+	return err
+}
+
+func (s *tracedStore) UpdateDocumentMetadata(ctx context.Context, id uuid.UUID, set map[string]string, remove []string) (Document, error) {
+	ctx, span := s.start(ctx, "UpdateDocumentMetadata", documentID(id))
+	out, err := s.next.UpdateDocumentMetadata(ctx, id, set, remove)
+	end(span, err)
+	return out, err
+}
+
+func (s *tracedStore) DeleteDocument(ctx context.Context, id uuid.UUID) error {
+	ctx, span := s.start(ctx, "DeleteDocument", documentID(id))
+	err := s.next.DeleteDocument(ctx, id)
+	end(span, err)
+	return err
+}
+
+func (s *tracedStore) SaveChunks(ctx context.Context, docID uuid.UUID, chunks []Chunk) ([]Chunk, error) {
+	ctx, span := s.start(ctx, "SaveChunks", documentID(docID))
+	out, err := s.next.SaveChunks(ctx, docID, chunks)
+	end(span, err)
+	return out, err
+}
+
+func (s *tracedStore) ListChunks(ctx context.Context, docID uuid.UUID, version int) ([]Chunk, error) {
+	ctx, span := s.start(ctx, "ListChunks", documentID(docID), attribute.Int("version", version))
+	out, err := s.next.ListChunks(ctx, docID, version)
+	end(span, err)
+	return out, err
+}
+
+func (s *tracedStore) StageChunks(ctx context.Context, docID uuid.UUID, chunks []Chunk) ([]Chunk, error) {
+	ctx, span := s.start(ctx, "StageChunks", documentID(docID))
+	out, err := s.next.StageChunks(ctx, docID, chunks)
+	end(span, err)
+	return out, err
+}
+
+func (s *tracedStore) ListStagedChunks(ctx context.Context, docID uuid.UUID) ([]Chunk, error) {
+	ctx, span := s.start(ctx, "ListStagedChunks", documentID(docID))
+	out, err := s.next.ListStagedChunks(ctx, docID)
+	end(span, err)
+	return out, err
+}
+
+func (s *tracedStore) PromoteStagedChunks(ctx context.Context, docID uuid.UUID) error {
+	ctx, span := s.start(ctx, "PromoteStagedChunks", documentID(docID))
+	err := s.next.PromoteStagedChunks(ctx, docID)
+	end(span, err)
+	return err
+}
+
+func (s *tracedStore) SaveSummary(ctx context.Context, docID uuid.UUID, summary Summary) error {
+	ctx, span := s.start(ctx, "SaveSummary", documentID(docID))
+	err := s.next.SaveSummary(ctx, docID, summary)
+	end(span, err)
+	return err
+}
+
+func (s *tracedStore) SaveEmbeddings(ctx context.Context, embs []Embedding) error {
+	ctx, span := s.start(ctx, "SaveEmbeddings")
+	err := s.next.SaveEmbeddings(ctx, embs)
+	end(span, err)
+	return err
+}
+
+func (s *tracedStore) GetSummary(ctx context.Context, docID uuid.UUID, version int) (Summary, error) {
+	ctx, span := s.start(ctx, "GetSummary", documentID(docID), attribute.Int("version", version))
+	out, err := s.next.GetSummary(ctx, docID, version)
+	end(span, err)
+	return out, err
+}
+
+func (s *tracedStore) CreateVersion(ctx context.Context, docID uuid.UUID, v Version) (Version, error) {
+	ctx, span := s.start(ctx, "CreateVersion", documentID(docID))
+	out, err := s.next.CreateVersion(ctx, docID, v)
+	end(span, err)
+	return out, err
+}
+
+func (s *tracedStore) ListVersions(ctx context.Context, docID uuid.UUID) ([]Version, error) {
+	ctx, span := s.start(ctx, "ListVersions", documentID(docID))
+	out, err := s.next.ListVersions(ctx, docID)
+	end(span, err)
+	return out, err
+}
+
+func (s *tracedStore) PublishVersion(ctx context.Context, docID uuid.UUID, version int) error {
+	ctx, span := s.start(ctx, "PublishVersion", documentID(docID), attribute.Int("version", version))
+	err := s.next.PublishVersion(ctx, docID, version)
+	end(span, err)
+	return err
+}
+
+func (s *tracedStore) FailVersion(ctx context.Context, docID uuid.UUID, version int, errMsg string) error {
+	ctx, span := s.start(ctx, "FailVersion", documentID(docID), attribute.Int("version", version))
+	err := s.next.FailVersion(ctx, docID, version, errMsg)
+	end(span, err)
+	return err
+}
+
+func (s *tracedStore) TopK(ctx context.Context, scope SearchScope, vector embeddings.Vector, k int) ([]SearchResult, error) {
+	ctx, span := s.start(ctx, "TopK")
+	out, err := s.next.TopK(ctx, scope, vector, k)
+	end(span, err)
+	return out, err
+}
+
+func (s *tracedStore) RecordStage(ctx context.Context, docID uuid.UUID, stage Stage, state StageState, attempt int, errMsg string) error {
+	ctx, span := s.start(ctx, "RecordStage", documentID(docID))
+	err := s.next.RecordStage(ctx, docID, stage, state, attempt, errMsg)
+	end(span, err)
+	return err
+}
+
+func (s *tracedStore) ListStages(ctx context.Context, docID uuid.UUID) ([]StageStatus, error) {
+	ctx, span := s.start(ctx, "ListStages", documentID(docID))
+	out, err := s.next.ListStages(ctx, docID)
+	end(span, err)
+	return out, err
+}
+
+func (s *tracedStore) CreateBatch(ctx context.Context, docIDs []uuid.UUID) (Batch, error) {
+	ctx, span := s.start(ctx, "CreateBatch")
+	out, err := s.next.CreateBatch(ctx, docIDs)
+	end(span, err)
+	return out, err
+}
+
+func (s *tracedStore) GetBatch(ctx context.Context, id uuid.UUID) (Batch, error) {
+	ctx, span := s.start(ctx, "GetBatch")
+	out, err := s.next.GetBatch(ctx, id)
+	end(span, err)
+	return out, err
+}
+
+func (s *tracedStore) CreateWebhook(ctx context.Context, hook Webhook) (Webhook, error) {
+	ctx, span := s.start(ctx, "CreateWebhook")
+	out, err := s.next.CreateWebhook(ctx, hook)
+	end(span, err)
+	return out, err
+}
+
+func (s *tracedStore) GetWebhook(ctx context.Context, id uuid.UUID) (Webhook, error) {
+	ctx, span := s.start(ctx, "GetWebhook")
+	out, err := s.next.GetWebhook(ctx, id)
+	end(span, err)
+	return out, err
+}
+
+func (s *tracedStore) ListWebhooks(ctx context.Context, event string) ([]Webhook, error) {
+	ctx, span := s.start(ctx, "ListWebhooks")
+	out, err := s.next.ListWebhooks(ctx, event)
+	end(span, err)
+	return out, err
+}
+
+func (s *tracedStore) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	ctx, span := s.start(ctx, "DeleteWebhook")
+	err := s.next.DeleteWebhook(ctx, id)
+	end(span, err)
+	return err
+}
+
+func (s *tracedStore) RecordWebhookDelivery(ctx context.Context, delivery WebhookDelivery) error {
+	ctx, span := s.start(ctx, "RecordWebhookDelivery")
+	err := s.next.RecordWebhookDelivery(ctx, delivery)
+	end(span, err)
+	return err
+}
+
+func (s *tracedStore) ListWebhookDeliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]WebhookDelivery, error) {
+	ctx, span := s.start(ctx, "ListWebhookDeliveries")
+	out, err := s.next.ListWebhookDeliveries(ctx, webhookID, limit)
+	end(span, err)
+	return out, err
+}
+
+func (s *tracedStore) CreateAPIKey(ctx context.Context, key APIKey) (APIKey, error) {
+	ctx, span := s.start(ctx, "CreateAPIKey")
+	out, err := s.next.CreateAPIKey(ctx, key)
+	end(span, err)
+	return out, err
+}
+
+func (s *tracedStore) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	ctx, span := s.start(ctx, "ListAPIKeys")
+	out, err := s.next.ListAPIKeys(ctx)
+	end(span, err)
+	return out, err
+}
+
+func (s *tracedStore) RevokeAPIKey(ctx context.Context, id uuid.UUID) error {
+	ctx, span := s.start(ctx, "RevokeAPIKey")
+	err := s.next.RevokeAPIKey(ctx, id)
+	end(span, err)
+	return err
+}
+
+func (s *tracedStore) UseAPIKey(ctx context.Context, hash string) (APIKey, error) {
+	ctx, span := s.start(ctx, "UseAPIKey")
+	out, err := s.next.UseAPIKey(ctx, hash)
+	end(span, err)
+	return out, err
+}
@@ -0,0 +1,53 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"doc-agents/internal/httputil"
+)
+
+// Middleware resolves the tenant of each request from the X-Tenant-ID header,
+// falling back to fallback when the header is absent. With an empty fallback
+// requests without a tenant are rejected with 401. A tenant already in the
+// context, bound by authentication, is kept.
+//
+// The header is trusted as is: expose a service only behind a proxy that
+// authenticates callers and sets it, or behind the gateway.
+func Middleware(log *slog.Logger, fallback string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, err := Resolve(r.Context(), r.Header.Get(Header), fallback)
+			switch {
+			case errors.Is(err, ErrMissing):
+				httputil.Fail(log, w, r, "tenant required", err, http.StatusUnauthorized)
+			case err != nil:
+				httputil.Fail(log, w, r, err.Error(), err, http.StatusBadRequest)
+			default:
+				next.ServeHTTP(w, r.WithContext(ctx))
+			}
+		})
+	}
+}
+
+// Resolve returns ctx acting for the tenant named in header, or fallback when
+// header is empty, unless ctx already has a tenant. It returns ErrMissing
+// when neither names one, and a validation error for a malformed tenant.
+func Resolve(ctx context.Context, header, fallback string) (context.Context, error) {
+	if _, ok := FromContext(ctx); ok {
+		return ctx, nil
+	}
+	id := header
+	if id == "" {
+		id = fallback
+	}
+	if id == "" {
+		return nil, ErrMissing
+	}
+	if err := Validate(id); err != nil {
+		return nil, err
+	}
+	return WithID(ctx, id), nil
+}
@@ -0,0 +1,45 @@
+// Package tenant carries the tenant a request or task acts for. The store,
+// cache and queue read it from the context, so every layer stays scoped to
+// the tenant the gateway resolved for the request.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// Header carries the tenant from the gateway to internal services, and from
+// a trusted authenticating proxy to the gateway.
+const Header = "X-Tenant-ID"
+
+// Default owns the rows that existed before multi-tenancy was introduced.
+const Default = "default"
+
+// ErrMissing is returned when an operation needs a tenant and the context has none.
+var ErrMissing = errors.New("no tenant in context")
+
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Validate checks that id is 1-63 lowercase letters, digits, '_' or '-',
+// starting with a letter or digit.
+func Validate(id string) error {
+	if !idPattern.MatchString(id) {
+		return fmt.Errorf("invalid tenant id %q", id)
+	}
+	return nil
+}
+
+type ctxKey struct{}
+
+// WithID returns a copy of ctx that acts for tenant id.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the tenant of ctx, if any.
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(ctxKey{}).(string)
+	return id, ok && id != ""
+}
@@ -0,0 +1,79 @@
+package tenant
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	for _, id := range []string{"default", "team-a", "acme_42", "0"} {
+		if err := Validate(id); err != nil {
+			t.Errorf("Validate(%q) = %v, want nil", id, err)
+		}
+	}
+	for _, id := range []string{"", "Team", "-lead", "a b", "a/b", string(make([]byte, 64))} {
+		if err := Validate(id); err == nil {
+			t.Errorf("Validate(%q) = nil, want error", id)
+		}
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Error("empty context has a tenant")
+	}
+	if id, ok := FromContext(WithID(context.Background(), "acme")); !ok || id != "acme" {
+		t.Errorf("FromContext() = %q, %v, want acme, true", id, ok)
+	}
+	if _, ok := FromContext(WithID(context.Background(), "")); ok {
+		t.Error("empty tenant id counts as a tenant")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		name       string
+		header     string
+		bound      string
+		fallback   string
+		wantStatus int
+		wantTenant string
+	}{
+		{name: "header", header: "acme", fallback: Default, wantStatus: http.StatusOK, wantTenant: "acme"},
+		{name: "fallback", fallback: Default, wantStatus: http.StatusOK, wantTenant: Default},
+		{name: "no tenant", wantStatus: http.StatusUnauthorized},
+		{name: "invalid header", header: "ACME/1", fallback: Default, wantStatus: http.StatusBadRequest},
+		{name: "bound tenant wins", header: "other", bound: "acme", fallback: Default, wantStatus: http.StatusOK, wantTenant: "acme"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := Middleware(log, tt.fallback)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = FromContext(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.bound != "" {
+				req = req.WithContext(WithID(req.Context(), tt.bound))
+			}
+			if tt.header != "" {
+				req.Header.Set(Header, tt.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got != tt.wantTenant {
+				t.Errorf("tenant = %q, want %q", got, tt.wantTenant)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+// Package textdiff compares texts sentence by sentence, to show how a
+// document's summary changed from one version to the next.
+package textdiff
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Kind says what happened to a sentence.
+type Kind string
+
+const (
+	Equal   Kind = "equal"
+	Added   Kind = "added"
+	Removed Kind = "removed"
+)
+
+// Op is one sentence of a diff.
+type Op struct {
+	Kind Kind
+	Text string
+}
+
+// Sentences splits text after each '.', '!' or '?' followed by whitespace,
+// and at line breaks. Surrounding whitespace is trimmed and empty sentences
+// are dropped.
+func Sentences(text string) []string {
+	var out []string
+	runes := []rune(text)
+	start := 0
+	flush := func(end int) {
+		if s := strings.TrimSpace(string(runes[start:end])); s != "" {
+			out = append(out, s)
+		}
+		start = end
+	}
+	for i, r := range runes {
+		switch {
+		case r == '\n':
+			flush(i + 1)
+		case strings.ContainsRune(".!?", r) && (i+1 == len(runes) || unicode.IsSpace(runes[i+1])):
+			flush(i + 1)
+		}
+	}
+	flush(len(runes))
+	return out
+}
+
+// Diff returns the edits turning a into b, keeping their longest common
+// subsequence as Equal. Where both sides change, removals come first.
+func Diff(a, b []string) []Op {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []Op
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, Op{Equal, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, Op{Removed, a[i]})
+			i++
+		default:
+			ops = append(ops, Op{Added, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, Op{Removed, a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, Op{Added, b[j]})
+	}
+	return ops
+}
@@ -0,0 +1,56 @@
+package textdiff
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSentences(t *testing.T) {
+	got := Sentences("Staff get 25 days of leave. Unused days expire!\nRequests go to HR.  Version 2.1 applies")
+	want := []string{"Staff get 25 days of leave.", "Unused days expire!", "Requests go to HR.", "Version 2.1 applies"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Sentences() = %q, want %q", got, want)
+	}
+	if got := Sentences("  \n "); len(got) != 0 {
+		t.Errorf("Sentences(blank) = %q, want none", got)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		want []Op
+	}{
+		{
+			name: "unchanged",
+			a:    []string{"A.", "B."},
+			b:    []string{"A.", "B."},
+			want: []Op{{Equal, "A."}, {Equal, "B."}},
+		},
+		{
+			name: "sentence replaced",
+			a:    []string{"A.", "B.", "C."},
+			b:    []string{"A.", "X.", "C."},
+			want: []Op{{Equal, "A."}, {Removed, "B."}, {Added, "X."}, {Equal, "C."}},
+		},
+		{
+			name: "sentences added and removed at the ends",
+			a:    []string{"A.", "B."},
+			b:    []string{"B.", "C."},
+			want: []Op{{Removed, "A."}, {Equal, "B."}, {Added, "C."}},
+		},
+		{
+			name: "from nothing",
+			b:    []string{"A."},
+			want: []Op{{Added, "A."}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(tt.a, tt.b); !slices.Equal(got, tt.want) {
+				t.Errorf("Diff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+// Package tracing sets up OpenTelemetry tracing and carries trace context
+// between services, so that one upload can be followed from the gateway
+// through the queue to the parser and analysis.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"doc-agents/internal/config"
+)
+
+// Propagator carries W3C trace context and baggage in HTTP headers and
+// queue tasks.
+var Propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Setup installs the tracer provider of service, exporting spans as
+// TRACING_EXPORTER says: "otlp" sends them over OTLP/HTTP, "stdout" prints
+// them, and "none" records nothing but still passes incoming trace context
+// on. The returned function flushes the spans not exported yet.
+func Setup(cfg config.Config, service string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(Propagator)
+
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+	switch cfg.TracingExporter {
+	case "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		// Without TRACING_OTLP_ENDPOINT the exporter follows the standard
+		// OTEL_EXPORTER_OTLP_* variables
+		var opts []otlptracehttp.Option
+		if cfg.TracingOTLPEndpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.TracingOTLPEndpoint))
+		}
+		exporter, err = otlptracehttp.New(context.Background(), opts...)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("invalid TRACING_EXPORTER: %s (valid options: none, otlp, stdout)", cfg.TracingExporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s span exporter: %w", cfg.TracingExporter, err)
+	}
+
+	tp := NewProvider(service, sdktrace.NewBatchSpanProcessor(exporter), cfg.TracingSampleRatio)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// NewProvider returns a tracer provider for service that hands spans to
+// processor. It samples ratio of new traces and follows the caller's
+// decision for the rest.
+func NewProvider(service string, processor sdktrace.SpanProcessor, ratio float64) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName("doc-agents-"+service))),
+	)
+}
+
+// Inject returns the trace context of ctx as a map that travels with a
+// queue task; nil when ctx is not traced.
+func Inject(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+// Extract returns ctx continuing the trace that Inject recorded in carrier.
+func Extract(ctx context.Context, carrier map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(carrier))
+}
+
+// End ends span, marking it failed when err is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,32 @@
+// Package tracingtest records the spans a test produces.
+package tracingtest
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"doc-agents/internal/tracing"
+)
+
+// Record installs a tracer provider that keeps every span in memory for the
+// rest of the test, and returns where they are kept. Tests that use it must
+// not run in parallel, as the provider is global.
+func Record(t testing.TB) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	tp := tracing.NewProvider("test", sdktrace.NewSimpleSpanProcessor(exporter), 1)
+
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(tracing.Propagator)
+	t.Cleanup(func() {
+		_ = tp.Shutdown(context.Background())
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return exporter
+}
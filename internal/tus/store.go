@@ -0,0 +1,211 @@
+package tus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"doc-agents/internal/errcode"
+)
+
+// ErrNotFound is returned when an upload ID is unknown.
+var ErrNotFound = errcode.New("upload_not_found", "upload not found")
+
+// ErrOffsetMismatch is returned when a PATCH does not resume at the current offset.
+var ErrOffsetMismatch = errcode.New("upload_offset_mismatch", "upload offset mismatch")
+
+// Upload describes an in-progress or completed resumable upload.
+type Upload struct {
+	ID        string            `json:"id"`
+	Size      int64             `json:"size"`
+	Offset    int64             `json:"offset"`
+	Metadata  map[string]string `json:"metadata"`
+	CreatedAt time.Time         `json:"created_at"`
+	// Owner is set from Options.Owner at creation; other owners cannot see the upload.
+	Owner string `json:"owner,omitempty"`
+
+	// DocumentID is set once the completed upload has been handed off.
+	DocumentID string `json:"document_id,omitempty"`
+}
+
+// Complete reports whether every byte has been received.
+func (u Upload) Complete() bool {
+	return u.Offset == u.Size
+}
+
+// diskStore assembles uploads as <id>.bin files next to <id>.info JSON descriptors.
+type diskStore struct {
+	dir   string
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func newDiskStore(dir string) (*diskStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	return &diskStore{dir: dir, locks: make(map[string]*sync.Mutex)}, nil
+}
+
+// lock serializes operations on a single upload so concurrent PATCH
+// requests cannot interleave bytes.
+func (s *diskStore) lock(id string) func() {
+	s.mu.Lock()
+	l, ok := s.locks[id]
+	if !ok {
+		l = &sync.Mutex{}
+		s.locks[id] = l
+	}
+	s.mu.Unlock()
+	l.Lock()
+	return l.Unlock
+}
+
+func (s *diskStore) create(size int64, metadata map[string]string, owner string) (Upload, error) {
+	u := Upload{
+		ID:        uuid.NewString(),
+		Size:      size,
+		Metadata:  metadata,
+		CreatedAt: time.Now().UTC(),
+		Owner:     owner,
+	}
+	f, err := os.OpenFile(s.binPath(u.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return Upload{}, err
+	}
+	if err := f.Close(); err != nil {
+		return Upload{}, err
+	}
+	if err := s.saveInfo(u); err != nil {
+		return Upload{}, err
+	}
+	return u, nil
+}
+
+func (s *diskStore) get(id string) (Upload, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return Upload{}, ErrNotFound
+	}
+	data, err := os.ReadFile(s.infoPath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Upload{}, ErrNotFound
+	}
+	if err != nil {
+		return Upload{}, err
+	}
+	var u Upload
+	if err := json.Unmarshal(data, &u); err != nil {
+		return Upload{}, err
+	}
+	return u, nil
+}
+
+// appendAt writes r at offset, never past the declared upload size.
+// The stored offset only advances by what was actually persisted, so a
+// dropped connection leaves a resumable upload behind.
+func (s *diskStore) appendAt(id string, offset int64, r io.Reader) (Upload, error) {
+	u, err := s.get(id)
+	if err != nil {
+		return Upload{}, err
+	}
+	if offset != u.Offset {
+		return u, ErrOffsetMismatch
+	}
+
+	f, err := os.OpenFile(s.binPath(id), os.O_WRONLY, 0o644)
+	if err != nil {
+		return u, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return u, err
+	}
+
+	n, copyErr := io.Copy(f, io.LimitReader(r, u.Size-u.Offset))
+	if n > 0 {
+		if err := f.Sync(); err != nil {
+			return u, err
+		}
+		u.Offset += n
+		if err := s.saveInfo(u); err != nil {
+			return u, err
+		}
+	}
+	return u, copyErr
+}
+
+// list returns every upload with a readable descriptor.
+func (s *diskStore) list() ([]Upload, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var uploads []Upload
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".info")
+		if !ok || e.IsDir() {
+			continue
+		}
+		u, err := s.get(id)
+		if err != nil {
+			continue
+		}
+		uploads = append(uploads, u)
+	}
+	return uploads, nil
+}
+
+func (s *diskStore) open(id string) (*os.File, error) {
+	return os.Open(s.binPath(id))
+}
+
+func (s *diskStore) saveInfo(u Upload) error {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	tmp := s.infoPath(u.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.infoPath(u.ID))
+}
+
+// removeData drops the assembled bytes but keeps the descriptor so clients
+// can still HEAD a handed-off upload.
+func (s *diskStore) removeData(id string) error {
+	if err := os.Remove(s.binPath(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *diskStore) remove(id string) error {
+	if err := s.removeData(id); err != nil {
+		return err
+	}
+	if err := os.Remove(s.infoPath(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	s.mu.Lock()
+	delete(s.locks, id)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *diskStore) binPath(id string) string {
+	return filepath.Join(s.dir, id+".bin")
+}
+
+func (s *diskStore) infoPath(id string) string {
+	return filepath.Join(s.dir, id+".info")
+}
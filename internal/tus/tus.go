@@ -0,0 +1,379 @@
+// Package tus implements the server side of the tus 1.0.0 resumable upload
+// protocol (core, creation, termination and expiration extensions). Parts are
+// assembled on local disk and handed off once the final byte arrives.
+// See https://tus.io/protocols/resumable-upload.
+package tus
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"doc-agents/internal/httputil"
+)
+
+const (
+	// Version is the only protocol version this server speaks.
+	Version = "1.0.0"
+
+	offsetContentType = "application/offset+octet-stream"
+
+	// DocumentIDHeader carries the created document's ID once an upload has completed.
+	DocumentIDHeader = "Upload-Document-Id"
+
+	// defaultCompleteTimeout bounds OnComplete when Options.CompleteTimeout is unset.
+	defaultCompleteTimeout = 10 * time.Minute
+)
+
+// Options configures a Handler.
+type Options struct {
+	// BasePath is the route the handler is mounted at, e.g. "/api/uploads".
+	BasePath string
+	// Dir is where partial uploads are assembled.
+	Dir string
+	// MaxSize caps Upload-Length; advertised via Tus-Max-Size.
+	MaxSize int64
+	// Validate is called when an upload is created and may reject it with
+	// an HTTP status code (e.g. unsupported file type). Optional.
+	Validate func(u Upload) (status int, err error)
+	// OnComplete hands off a fully received upload and returns the ID of the
+	// resulting document. If it fails the bytes are kept and the client can
+	// retry by re-sending the final (empty) PATCH.
+	OnComplete func(ctx context.Context, u Upload, content io.Reader) (documentID string, err error)
+	// CompleteTimeout bounds OnComplete. The hand-off runs to completion even
+	// if the client disconnects; defaults to 10 minutes.
+	CompleteTimeout time.Duration
+	// Expiry is how long after creation an upload, finished or not, is kept;
+	// zero keeps uploads until they are terminated. Advertised to clients via
+	// Upload-Expires; expired uploads are removed by ExpireUploads.
+	Expiry time.Duration
+	// Owner identifies who a request acts for, e.g. its tenant. An upload
+	// can only be resumed, inspected or terminated by the owner that created
+	// it; others get 404. Optional.
+	Owner func(r *http.Request) string
+	Log   *slog.Logger
+}
+
+// Handler serves tus requests under Options.BasePath.
+type Handler struct {
+	opts  Options
+	store *diskStore
+}
+
+// NewHandler creates a Handler, creating the upload directory if needed.
+func NewHandler(opts Options) (*Handler, error) {
+	if opts.OnComplete == nil {
+		return nil, errors.New("tus: OnComplete is required")
+	}
+	if opts.Log == nil {
+		opts.Log = slog.Default()
+	}
+	opts.BasePath = strings.TrimSuffix(opts.BasePath, "/")
+	st, err := newDiskStore(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{opts: opts, store: st}, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", Version)
+
+	if r.Method == http.MethodOptions {
+		h.options(w)
+		return
+	}
+	if r.Header.Get("Tus-Resumable") != Version {
+		w.Header().Set("Tus-Version", Version)
+		httputil.Fail(h.opts.Log, w, r, "unsupported tus version", nil, http.StatusPreconditionFailed)
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, h.opts.BasePath), "/")
+	switch {
+	case id == "" && r.Method == http.MethodPost:
+		h.create(w, r)
+	case id != "" && r.Method == http.MethodHead:
+		h.head(w, r, id)
+	case id != "" && r.Method == http.MethodPatch:
+		h.patch(w, r, id)
+	case id != "" && r.Method == http.MethodDelete:
+		h.terminate(w, r, id)
+	default:
+		httputil.Fail(h.opts.Log, w, r, http.StatusText(http.StatusMethodNotAllowed), nil, http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) options(w http.ResponseWriter) {
+	w.Header().Set("Tus-Version", Version)
+	extensions := "creation,termination"
+	if h.opts.Expiry > 0 {
+		extensions += ",expiration"
+	}
+	w.Header().Set("Tus-Extension", extensions)
+	if h.opts.MaxSize > 0 {
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(h.opts.MaxSize, 10))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	size, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || size < 0 {
+		httputil.Fail(h.opts.Log, w, r, "Upload-Length header is required", err, http.StatusBadRequest)
+		return
+	}
+	if h.opts.MaxSize > 0 && size > h.opts.MaxSize {
+		httputil.Fail(h.opts.Log, w, r, fmt.Sprintf("upload too large (max %d bytes)", h.opts.MaxSize), nil, http.StatusRequestEntityTooLarge)
+		return
+	}
+	metadata, err := parseMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		httputil.Fail(h.opts.Log, w, r, "invalid Upload-Metadata header", err, http.StatusBadRequest)
+		return
+	}
+
+	if h.opts.Validate != nil {
+		if status, err := h.opts.Validate(Upload{Size: size, Metadata: metadata}); err != nil {
+			httputil.Fail(h.opts.Log, w, r, err.Error(), err, status)
+			return
+		}
+	}
+
+	u, err := h.store.create(size, metadata, h.owner(r))
+	if err != nil {
+		httputil.Fail(h.opts.Log, w, r, "failed to create upload", err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Location", h.opts.BasePath+"/"+u.ID)
+	w.Header().Set("Upload-Offset", "0")
+	h.setExpires(w, u)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *Handler) head(w http.ResponseWriter, r *http.Request, id string) {
+	u, err := h.lookup(r, id)
+	if err != nil {
+		h.failLookup(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(u.Size, 10))
+	h.setExpires(w, u)
+	if u.DocumentID != "" {
+		w.Header().Set(DocumentIDHeader, u.DocumentID)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) patch(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Header.Get("Content-Type") != offsetContentType {
+		httputil.Fail(h.opts.Log, w, r, "Content-Type must be "+offsetContentType, nil, http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		httputil.Fail(h.opts.Log, w, r, "Upload-Offset header is required", err, http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.lookup(r, id); err != nil {
+		h.failLookup(w, r, err)
+		return
+	}
+	unlock := h.store.lock(id)
+	defer unlock()
+
+	u, err := h.store.appendAt(id, offset, r.Body)
+	if errors.Is(err, ErrOffsetMismatch) {
+		httputil.Fail(h.opts.Log, w, r, fmt.Sprintf("upload offset mismatch (current offset %d)", u.Offset), err, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		if u.ID == "" {
+			h.failLookup(w, r, err)
+			return
+		}
+		// Bytes received before the interruption are kept for resumption.
+		httputil.Fail(h.opts.Log.With("upload_id", id), w, r, "failed to write upload", err, http.StatusInternalServerError)
+		return
+	}
+
+	if u.Complete() && u.DocumentID == "" {
+		// The hand-off may outlast the request (a large file going to S3),
+		// so it neither inherits the request's cancellation nor its deadline
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), h.completeTimeout())
+		u, err = h.complete(ctx, u)
+		cancel()
+		if err != nil {
+			httputil.Fail(h.opts.Log.With("upload_id", id), w, r, "failed to process completed upload; retry the final request", err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	h.setExpires(w, u)
+	if u.DocumentID != "" {
+		w.Header().Set(DocumentIDHeader, u.DocumentID)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) completeTimeout() time.Duration {
+	if h.opts.CompleteTimeout > 0 {
+		return h.opts.CompleteTimeout
+	}
+	return defaultCompleteTimeout
+}
+
+// expires returns when u expires; zero when uploads never expire.
+func (h *Handler) expires(u Upload) time.Time {
+	if h.opts.Expiry <= 0 {
+		return time.Time{}
+	}
+	return u.CreatedAt.Add(h.opts.Expiry)
+}
+
+func (h *Handler) setExpires(w http.ResponseWriter, u Upload) {
+	if expires := h.expires(u); !expires.IsZero() {
+		w.Header().Set("Upload-Expires", expires.UTC().Format(http.TimeFormat))
+	}
+}
+
+// ExpireUploads removes expired uploads every interval until ctx is done.
+// It does nothing when Options.Expiry is zero.
+func (h *Handler) ExpireUploads(ctx context.Context, interval time.Duration) error {
+	if h.opts.Expiry <= 0 {
+		return nil
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		h.removeExpired(time.Now())
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// removeExpired deletes the uploads that expired before now.
+func (h *Handler) removeExpired(now time.Time) {
+	uploads, err := h.store.list()
+	if err != nil {
+		h.opts.Log.Warn("failed to list uploads for expiry", "err", err)
+		return
+	}
+	for _, u := range uploads {
+		if !now.After(h.expires(u)) {
+			continue
+		}
+		unlock := h.store.lock(u.ID)
+		err := h.store.remove(u.ID)
+		unlock()
+		if err != nil {
+			h.opts.Log.Warn("failed to remove expired upload", "upload_id", u.ID, "err", err)
+			continue
+		}
+		h.opts.Log.Info("expired upload removed", "upload_id", u.ID, "complete", u.DocumentID != "")
+	}
+}
+
+// complete hands the assembled file to OnComplete and records the document ID.
+func (h *Handler) complete(ctx context.Context, u Upload) (Upload, error) {
+	f, err := h.store.open(u.ID)
+	if err != nil {
+		return u, err
+	}
+	defer f.Close()
+
+	docID, err := h.opts.OnComplete(ctx, u, f)
+	if err != nil {
+		return u, err
+	}
+	u.DocumentID = docID
+	if err := h.store.saveInfo(u); err != nil {
+		return u, err
+	}
+	if err := h.store.removeData(u.ID); err != nil {
+		h.opts.Log.Warn("failed to remove assembled upload", "upload_id", u.ID, "err", err)
+	}
+	return u, nil
+}
+
+func (h *Handler) terminate(w http.ResponseWriter, r *http.Request, id string) {
+	if _, err := h.lookup(r, id); err != nil {
+		h.failLookup(w, r, err)
+		return
+	}
+	unlock := h.store.lock(id)
+	defer unlock()
+
+	if err := h.store.remove(id); err != nil {
+		httputil.Fail(h.opts.Log, w, r, "failed to terminate upload", err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) owner(r *http.Request) string {
+	if h.opts.Owner == nil {
+		return ""
+	}
+	return h.opts.Owner(r)
+}
+
+// lookup loads an upload, hiding those created by another owner and those
+// that expired but have not been removed yet.
+func (h *Handler) lookup(r *http.Request, id string) (Upload, error) {
+	u, err := h.store.get(id)
+	if err != nil {
+		return Upload{}, err
+	}
+	if u.Owner != h.owner(r) {
+		return Upload{}, ErrNotFound
+	}
+	if expires := h.expires(u); !expires.IsZero() && time.Now().After(expires) {
+		return Upload{}, ErrNotFound
+	}
+	return u, nil
+}
+
+func (h *Handler) failLookup(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, ErrNotFound) {
+		httputil.Fail(h.opts.Log, w, r, "upload not found", err, http.StatusNotFound)
+		return
+	}
+	httputil.Fail(h.opts.Log, w, r, "failed to load upload", err, http.StatusInternalServerError)
+}
+
+// parseMetadata decodes an Upload-Metadata header: comma-separated
+// "key base64value" pairs, where the value may be omitted.
+func parseMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("empty metadata key")
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("metadata %q: %w", key, err)
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
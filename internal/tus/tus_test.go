@@ -0,0 +1,245 @@
+package tus
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestHandler(t *testing.T, onComplete func(context.Context, Upload, io.Reader) (string, error)) *Handler {
+	t.Helper()
+	h, err := NewHandler(Options{
+		BasePath: "/api/uploads",
+		Dir:      t.TempDir(),
+		MaxSize:  1024,
+		Validate: func(u Upload) (int, error) {
+			if u.Metadata["filename"] == "" {
+				return http.StatusBadRequest, errors.New("filename metadata is required")
+			}
+			return 0, nil
+		},
+		OnComplete: onComplete,
+		Log:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err != nil {
+		t.Fatalf("NewHandler failed: %v", err)
+	}
+	return h
+}
+
+func doRequest(h http.Handler, method, path string, headers map[string]string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Tus-Resumable", Version)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func createUpload(t *testing.T, h http.Handler, size int) string {
+	t.Helper()
+	w := doRequest(h, http.MethodPost, "/api/uploads", map[string]string{
+		"Upload-Length":   strconv.Itoa(size),
+		"Upload-Metadata": "filename " + base64.StdEncoding.EncodeToString([]byte("manual.txt")) + ",filetype " + base64.StdEncoding.EncodeToString([]byte("text/plain")),
+	}, "")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	return w.Header().Get("Location")
+}
+
+func patch(h http.Handler, location string, offset int, body string) *httptest.ResponseRecorder {
+	return doRequest(h, http.MethodPatch, location, map[string]string{
+		"Content-Type":  offsetContentType,
+		"Upload-Offset": strconv.Itoa(offset),
+	}, body)
+}
+
+func TestResumableUploadFlow(t *testing.T) {
+	var received string
+	var gotMetadata map[string]string
+	h := newTestHandler(t, func(_ context.Context, u Upload, content io.Reader) (string, error) {
+		data, err := io.ReadAll(content)
+		if err != nil {
+			return "", err
+		}
+		received = string(data)
+		gotMetadata = u.Metadata
+		return "doc-123", nil
+	})
+
+	location := createUpload(t, h, 11)
+	if !strings.HasPrefix(location, "/api/uploads/") {
+		t.Fatalf("unexpected Location %q", location)
+	}
+
+	// First part
+	w := patch(h, location, 0, "hello ")
+	if w.Code != http.StatusNoContent || w.Header().Get("Upload-Offset") != "6" {
+		t.Fatalf("expected 204 with offset 6, got %d offset %q", w.Code, w.Header().Get("Upload-Offset"))
+	}
+
+	// Client reconnects and asks where to resume
+	w = doRequest(h, http.MethodHead, location, nil, "")
+	if w.Code != http.StatusOK || w.Header().Get("Upload-Offset") != "6" || w.Header().Get("Upload-Length") != "11" {
+		t.Fatalf("unexpected HEAD response: %d %v", w.Code, w.Header())
+	}
+
+	// Resuming at the wrong offset is rejected
+	if w := patch(h, location, 3, "xx"); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for offset mismatch, got %d", w.Code)
+	}
+
+	// Final part completes the upload
+	w = patch(h, location, 6, "world")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get(DocumentIDHeader); got != "doc-123" {
+		t.Errorf("expected document id header doc-123, got %q", got)
+	}
+	if received != "hello world" {
+		t.Errorf("expected assembled content %q, got %q", "hello world", received)
+	}
+	if gotMetadata["filename"] != "manual.txt" || gotMetadata["filetype"] != "text/plain" {
+		t.Errorf("unexpected metadata %v", gotMetadata)
+	}
+
+	w = doRequest(h, http.MethodHead, location, nil, "")
+	if w.Header().Get(DocumentIDHeader) != "doc-123" {
+		t.Errorf("expected HEAD to report document id after completion")
+	}
+}
+
+func TestResumableUploadRetriesFailedCompletion(t *testing.T) {
+	calls := 0
+	h := newTestHandler(t, func(_ context.Context, _ Upload, _ io.Reader) (string, error) {
+		calls++
+		if calls == 1 {
+			return "", errors.New("queue down")
+		}
+		return "doc-456", nil
+	})
+
+	location := createUpload(t, h, 4)
+	if w := patch(h, location, 0, "data"); w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 when completion fails, got %d", w.Code)
+	}
+
+	// Re-sending the final empty PATCH retries the hand-off
+	w := patch(h, location, 4, "")
+	if w.Code != http.StatusNoContent || w.Header().Get(DocumentIDHeader) != "doc-456" {
+		t.Fatalf("expected retry to succeed, got %d %v", w.Code, w.Header())
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 completion attempts, got %d", calls)
+	}
+}
+
+func TestResumableUploadValidation(t *testing.T) {
+	h := newTestHandler(t, func(context.Context, Upload, io.Reader) (string, error) { return "", nil })
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		headers    map[string]string
+		skipTus    bool
+		wantStatus int
+	}{
+		{"missing Tus-Resumable", http.MethodPost, "/api/uploads", map[string]string{"Upload-Length": "1"}, true, http.StatusPreconditionFailed},
+		{"missing Upload-Length", http.MethodPost, "/api/uploads", nil, false, http.StatusBadRequest},
+		{"too large", http.MethodPost, "/api/uploads", map[string]string{"Upload-Length": "4096", "Upload-Metadata": "filename YS50eHQ="}, false, http.StatusRequestEntityTooLarge},
+		{"rejected by validator", http.MethodPost, "/api/uploads", map[string]string{"Upload-Length": "10"}, false, http.StatusBadRequest},
+		{"unknown upload", http.MethodHead, "/api/uploads/6f1c1f9e-5b7a-4d8e-9f0a-000000000000", nil, false, http.StatusNotFound},
+		{"wrong patch content type", http.MethodPatch, "/api/uploads/6f1c1f9e-5b7a-4d8e-9f0a-000000000000", map[string]string{"Upload-Offset": "0"}, false, http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if !tt.skipTus {
+				req.Header.Set("Tus-Resumable", Version)
+			}
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestTerminateUpload(t *testing.T) {
+	h := newTestHandler(t, func(context.Context, Upload, io.Reader) (string, error) { return "", nil })
+	location := createUpload(t, h, 10)
+
+	if w := doRequest(h, http.MethodDelete, location, nil, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if w := doRequest(h, http.MethodHead, location, nil, ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 after termination, got %d", w.Code)
+	}
+}
+
+func TestUploadOwner(t *testing.T) {
+	h := newTestHandler(t, func(context.Context, Upload, io.Reader) (string, error) { return "doc-1", nil })
+	h.opts.Owner = func(r *http.Request) string { return r.Header.Get("X-Owner") }
+	location := createUpload(t, h, 5)
+
+	other := map[string]string{"X-Owner": "other"}
+	if w := doRequest(h, http.MethodHead, location, other, ""); w.Code != http.StatusNotFound {
+		t.Errorf("HEAD by another owner: expected 404, got %d", w.Code)
+	}
+	if w := doRequest(h, http.MethodPatch, location, map[string]string{
+		"X-Owner":       "other",
+		"Content-Type":  offsetContentType,
+		"Upload-Offset": "0",
+	}, "hello"); w.Code != http.StatusNotFound {
+		t.Errorf("PATCH by another owner: expected 404, got %d", w.Code)
+	}
+	if w := doRequest(h, http.MethodDelete, location, other, ""); w.Code != http.StatusNotFound {
+		t.Errorf("DELETE by another owner: expected 404, got %d", w.Code)
+	}
+	if w := patch(h, location, 0, "hello"); w.Code != http.StatusNoContent || w.Header().Get(DocumentIDHeader) != "doc-1" {
+		t.Errorf("PATCH by the owner: expected 204 with document ID, got %d", w.Code)
+	}
+}
+
+func TestUploadExpiry(t *testing.T) {
+	h := newTestHandler(t, func(context.Context, Upload, io.Reader) (string, error) { return "doc-1", nil })
+	h.opts.Expiry = time.Hour
+	location := createUpload(t, h, 10)
+
+	w := doRequest(h, http.MethodHead, location, nil, "")
+	expires, err := http.ParseTime(w.Header().Get("Upload-Expires"))
+	if err != nil || time.Until(expires) <= 0 || time.Until(expires) > time.Hour {
+		t.Fatalf("expected Upload-Expires within the hour, got %q", w.Header().Get("Upload-Expires"))
+	}
+
+	// Not expired yet: the sweep keeps it
+	h.removeExpired(time.Now())
+	if w := doRequest(h, http.MethodHead, location, nil, ""); w.Code != http.StatusOK {
+		t.Fatalf("expected upload to survive the sweep, got %d", w.Code)
+	}
+
+	h.removeExpired(time.Now().Add(2 * time.Hour))
+	if w := doRequest(h, http.MethodHead, location, nil, ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 after expiry, got %d", w.Code)
+	}
+	if uploads, err := h.store.list(); err != nil || len(uploads) != 0 {
+		t.Errorf("expected expired upload files to be removed, got %v (err %v)", uploads, err)
+	}
+}
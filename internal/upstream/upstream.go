@@ -0,0 +1,207 @@
+// Package upstream balances requests across the replicas of an internal
+// service. Replicas are health checked against their /readyz endpoint and
+// picked round-robin among the healthy ones; a request that fails before the
+// replica answered is retried on the next one.
+package upstream
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// ErrUnavailable is returned when no replica is healthy, or every attempt failed.
+var ErrUnavailable = errors.New("no healthy upstream replica")
+
+// Options configures a Pool.
+type Options struct {
+	// Client sends both proxied requests and health checks; its timeout bounds
+	// a whole attempt. Defaults to http.DefaultClient.
+	Client *http.Client
+	// Attempts is how many replicas a request is tried on; defaults to 1.
+	Attempts int
+	// HealthTimeout bounds a single /readyz probe; defaults to 2s.
+	HealthTimeout time.Duration
+	Log           *slog.Logger
+}
+
+// Pool is a set of replicas of one service.
+type Pool struct {
+	replicas      []*replica
+	next          atomic.Uint64
+	client        *http.Client
+	attempts      int
+	healthTimeout time.Duration
+	log           *slog.Logger
+}
+
+type replica struct {
+	base    *url.URL
+	healthy atomic.Bool
+}
+
+// New creates a Pool over the replicas at baseURLs, such as
+// "http://query-1:8081". Replicas count as healthy until a check or a failed
+// request says otherwise, so requests flow before the first check completes.
+func New(baseURLs []string, opts Options) (*Pool, error) {
+	p := &Pool{
+		client:        opts.Client,
+		attempts:      max(opts.Attempts, 1),
+		healthTimeout: opts.HealthTimeout,
+		log:           opts.Log,
+	}
+	if p.client == nil {
+		p.client = http.DefaultClient
+	}
+	if p.healthTimeout <= 0 {
+		p.healthTimeout = 2 * time.Second
+	}
+	if p.log == nil {
+		p.log = slog.Default()
+	}
+	for _, raw := range baseURLs {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		u, err := url.Parse(strings.TrimSuffix(raw, "/"))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid upstream URL %q", raw)
+		}
+		r := &replica{base: u}
+		r.healthy.Store(true)
+		p.replicas = append(p.replicas, r)
+	}
+	if len(p.replicas) == 0 {
+		return nil, errors.New("no upstream URLs configured")
+	}
+	return p, nil
+}
+
+// Healthy reports how many replicas are currently healthy, out of how many.
+func (p *Pool) Healthy() (healthy, total int) {
+	for _, r := range p.replicas {
+		if r.healthy.Load() {
+			healthy++
+		}
+	}
+	return healthy, len(p.replicas)
+}
+
+// Do sends a request for path with body to a healthy replica. It moves on to
+// the next replica when one cannot be reached or answers 502 or 503, so it
+// must only be used for idempotent requests. The response of the last
+// attempt is returned as is; when no attempt got one, the error wraps
+// ErrUnavailable.
+func (p *Pool) Do(ctx context.Context, method, path string, header http.Header, body []byte) (*http.Response, error) {
+	candidates := p.pick()
+	if len(candidates) == 0 {
+		return nil, ErrUnavailable
+	}
+
+	var lastErr error
+	for i, r := range candidates[:min(p.attempts, len(candidates))] {
+		req, err := http.NewRequestWithContext(ctx, method, r.base.String()+path, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if header != nil {
+			req.Header = header.Clone()
+		}
+		// The replica's spans join the caller's trace
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+		resp, err := p.client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			// The replica is down until the next health check finds it up
+			r.healthy.Store(false)
+			p.log.Warn("upstream attempt failed", "upstream", r.base.String(), "attempt", i+1, "err", err)
+			lastErr = err
+			continue
+		}
+		last := i == p.attempts-1 || i == len(candidates)-1
+		if (resp.StatusCode == http.StatusBadGateway || resp.StatusCode == http.StatusServiceUnavailable) && !last {
+			p.log.Warn("upstream attempt failed", "upstream", r.base.String(), "attempt", i+1, "status", resp.StatusCode)
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			continue
+		}
+		return resp, nil
+	}
+	return nil, fmt.Errorf("%w: %v", ErrUnavailable, lastErr)
+}
+
+// pick returns the healthy replicas in the order to try them, starting at
+// the next one in round-robin order.
+func (p *Pool) pick() []*replica {
+	start := int(p.next.Add(1) - 1)
+	var out []*replica
+	for i := range p.replicas {
+		r := p.replicas[(start+i)%len(p.replicas)]
+		if r.healthy.Load() {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Check probes every replica's /readyz once and records which are up.
+func (p *Pool) Check(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, r := range p.replicas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			up := p.probe(ctx, r)
+			if was := r.healthy.Swap(up); was != up {
+				p.log.Info("upstream health changed", "upstream", r.base.String(), "healthy", up)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (p *Pool) probe(ctx context.Context, r *replica) bool {
+	ctx, cancel := context.WithTimeout(ctx, p.healthTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.base.String()+"/readyz", nil)
+	if err != nil {
+		return false
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return false
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// Run checks the replicas every interval until ctx is done.
+func (p *Pool) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		p.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// replicaServer answers /readyz with health and every other path with its name.
+func replicaServer(t *testing.T, name string, health *atomic.Int32, hits *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/readyz" {
+			w.WriteHeader(int(health.Load()))
+			return
+		}
+		hits.Add(1)
+		_, _ = io.WriteString(w, name)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newHealth(status int) *atomic.Int32 {
+	h := new(atomic.Int32)
+	h.Store(int32(status))
+	return h
+}
+
+func body(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestNewRejectsInvalidURLs(t *testing.T) {
+	for _, urls := range [][]string{nil, {""}, {"query:8081"}, {"ftp://query"}} {
+		if _, err := New(urls, Options{Log: discard}); err == nil {
+			t.Errorf("New(%q) succeeded, want error", urls)
+		}
+	}
+}
+
+func TestDoRoundRobin(t *testing.T) {
+	var hitsA, hitsB atomic.Int32
+	a := replicaServer(t, "a", newHealth(http.StatusOK), &hitsA)
+	b := replicaServer(t, "b", newHealth(http.StatusOK), &hitsB)
+	p, err := New([]string{a.URL, b.URL + "/"}, Options{Log: discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 4 {
+		resp, err := p.Do(context.Background(), http.MethodPost, "/api/query", http.Header{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if hitsA.Load() != 2 || hitsB.Load() != 2 {
+		t.Errorf("hits = %d/%d, want 2/2", hitsA.Load(), hitsB.Load())
+	}
+}
+
+func TestDoRetriesUnreachableReplica(t *testing.T) {
+	var hits atomic.Int32
+	up := replicaServer(t, "up", newHealth(http.StatusOK), &hits)
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	p, err := New([]string{down.URL, up.URL}, Options{Attempts: 2, Log: discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := p.Do(context.Background(), http.MethodPost, "/api/query", http.Header{}, []byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := body(t, resp); got != "up" {
+		t.Errorf("answered by %q, want up", got)
+	}
+	if healthy, total := p.Healthy(); healthy != 1 || total != 2 {
+		t.Errorf("Healthy() = %d/%d, want 1/2", healthy, total)
+	}
+}
+
+func TestDoRetriesServiceUnavailable(t *testing.T) {
+	var hits atomic.Int32
+	busy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(busy.Close)
+	up := replicaServer(t, "up", newHealth(http.StatusOK), &hits)
+
+	p, err := New([]string{busy.URL, up.URL}, Options{Attempts: 2, Log: discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := p.Do(context.Background(), http.MethodPost, "/api/query", http.Header{}, []byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := body(t, resp); got != "up" {
+		t.Errorf("answered by %q, want up", got)
+	}
+}
+
+func TestCheckSkipsUnhealthyReplicas(t *testing.T) {
+	var hitsA, hitsB atomic.Int32
+	healthA := newHealth(http.StatusServiceUnavailable)
+	healthB := newHealth(http.StatusServiceUnavailable)
+	a := replicaServer(t, "a", healthA, &hitsA)
+	b := replicaServer(t, "b", healthB, &hitsB)
+	p, err := New([]string{a.URL, b.URL}, Options{Log: discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.Check(context.Background())
+	if _, err := p.Do(context.Background(), http.MethodPost, "/api/query", http.Header{}, nil); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Do() with every replica down = %v, want ErrUnavailable", err)
+	}
+
+	healthB.Store(http.StatusOK)
+	p.Check(context.Background())
+	for range 3 {
+		resp, err := p.Do(context.Background(), http.MethodPost, "/api/query", http.Header{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := body(t, resp); got != "b" {
+			t.Errorf("answered by %q, want b", got)
+		}
+	}
+	if hitsA.Load() != 0 {
+		t.Errorf("unhealthy replica got %d requests", hitsA.Load())
+	}
+}
@@ -0,0 +1,245 @@
+// Package urlfetch downloads remote documents with SSRF protections: only
+// http(s), no private/internal destinations unless allowlisted, a capped
+// redirect chain, and size and time limits.
+package urlfetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrBlockedDestination is returned when a URL resolves to an address that is not allowed.
+	ErrBlockedDestination = errors.New("destination not allowed")
+	// ErrTooLarge is returned when the response body exceeds Options.MaxSize.
+	ErrTooLarge = errors.New("response too large")
+	// ErrTooManyRedirects is returned when the redirect chain exceeds Options.MaxRedirects.
+	ErrTooManyRedirects = errors.New("too many redirects")
+)
+
+// blockedPrefixes are never reachable unless explicitly allowlisted: loopback,
+// RFC 1918, link-local (incl. cloud metadata), CGNAT, ULA, and other
+// special-purpose ranges.
+var blockedPrefixes = mustParsePrefixes(
+	"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16",
+	"172.16.0.0/12", "192.0.0.0/24", "192.168.0.0/16", "198.18.0.0/15",
+	"224.0.0.0/4", "240.0.0.0/4",
+	"::/128", "::1/128", "fc00::/7", "fe80::/10", "ff00::/8", "64:ff9b::/96",
+)
+
+// Options configures a Fetcher.
+type Options struct {
+	// Allowlist entries are hostnames (exact match, e.g. "wiki.internal") or
+	// CIDR prefixes (e.g. "10.20.0.0/16") that may be reached even though
+	// they resolve to private addresses.
+	Allowlist    []string
+	MaxRedirects int
+	MaxSize      int64
+	Timeout      time.Duration
+}
+
+// Result is a successfully fetched resource.
+type Result struct {
+	Body        []byte
+	ContentType string // As reported by the server, parameters stripped
+	Filename    string // From Content-Disposition or the final URL path
+	FinalURL    string // After redirects
+}
+
+// Fetcher downloads URLs under the configured restrictions.
+type Fetcher struct {
+	opts      Options
+	hosts     map[string]bool
+	prefixes  []netip.Prefix
+	resolver  *net.Resolver
+	transport *http.Transport
+	client    *http.Client
+}
+
+// New creates a Fetcher. Invalid allowlist CIDRs are reported as errors.
+func New(opts Options) (*Fetcher, error) {
+	f := &Fetcher{opts: opts, hosts: make(map[string]bool), resolver: net.DefaultResolver}
+	for _, entry := range opts.Allowlist {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid allowlist CIDR %q: %w", entry, err)
+			}
+			f.prefixes = append(f.prefixes, prefix)
+			continue
+		}
+		f.hosts[strings.ToLower(entry)] = true
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	f.transport = &http.Transport{
+		Proxy: nil, // never route through environment proxies, which would bypass the checks
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			ip, err := f.resolve(ctx, addr)
+			if err != nil {
+				return nil, err
+			}
+			_, port, _ := net.SplitHostPort(addr)
+			// Dial the vetted IP itself so a second DNS lookup cannot rebind it.
+			return dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		},
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+	}
+	f.client = &http.Client{
+		Transport: f.transport,
+		Timeout:   opts.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > opts.MaxRedirects {
+				return ErrTooManyRedirects
+			}
+			return checkScheme(req.URL)
+		},
+	}
+	return f, nil
+}
+
+// Transport returns the destination-checked transport, for callers that make
+// requests other than downloads (e.g. webhook deliveries) under the same rules.
+func (f *Fetcher) Transport() http.RoundTripper {
+	return f.transport
+}
+
+// Fetch downloads rawURL and returns its body.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Result, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if err := checkScheme(u); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("remote server returned %s", resp.Status)
+	}
+	if f.opts.MaxSize > 0 && resp.ContentLength > f.opts.MaxSize {
+		return nil, ErrTooLarge
+	}
+
+	body := io.Reader(resp.Body)
+	if f.opts.MaxSize > 0 {
+		body = io.LimitReader(resp.Body, f.opts.MaxSize+1)
+	}
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	if f.opts.MaxSize > 0 && int64(len(content)) > f.opts.MaxSize {
+		return nil, ErrTooLarge
+	}
+
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return &Result{
+		Body:        content,
+		ContentType: contentType,
+		Filename:    filenameFor(resp),
+		FinalURL:    resp.Request.URL.String(),
+	}, nil
+}
+
+// resolve looks up the host in addr and returns the first address that may be dialed.
+func (f *Fetcher) resolve(ctx context.Context, addr string) (netip.Addr, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	hostAllowed := f.hosts[strings.ToLower(host)]
+
+	var candidates []netip.Addr
+	if ip, err := netip.ParseAddr(host); err == nil {
+		candidates = []netip.Addr{ip}
+	} else {
+		ips, err := f.resolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			return netip.Addr{}, err
+		}
+		candidates = ips
+	}
+
+	for _, ip := range candidates {
+		if hostAllowed || f.allowed(ip.Unmap()) {
+			return ip.Unmap(), nil
+		}
+	}
+	return netip.Addr{}, fmt.Errorf("%w: %s", ErrBlockedDestination, host)
+}
+
+// allowed reports whether ip is public or covered by an allowlisted CIDR.
+func (f *Fetcher) allowed(ip netip.Addr) bool {
+	for _, prefix := range f.prefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func checkScheme(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: unsupported scheme %q", ErrBlockedDestination, u.Scheme)
+	}
+	if u.Host == "" {
+		return errors.New("url host is required")
+	}
+	return nil
+}
+
+// filenameFor prefers the server-suggested filename and falls back to the
+// last segment of the final URL path, then the host name.
+func filenameFor(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		if name := path.Base(strings.ReplaceAll(params["filename"], `\`, "/")); name != "" && name != "." && name != "/" {
+			return name
+		}
+	}
+	if name := path.Base(resp.Request.URL.Path); name != "" && name != "." && name != "/" {
+		if unescaped, err := url.PathUnescape(name); err == nil {
+			return unescaped
+		}
+		return name
+	}
+	return resp.Request.URL.Hostname()
+}
+
+func mustParsePrefixes(cidrs ...string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, len(cidrs))
+	for i, cidr := range cidrs {
+		prefixes[i] = netip.MustParsePrefix(cidr)
+	}
+	return prefixes
+}
@@ -0,0 +1,151 @@
+package urlfetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFetchBlocksPrivateDestinations(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "secret")
+	}))
+	defer srv.Close()
+
+	f, err := New(Options{MaxRedirects: 3})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for _, target := range []string{
+		srv.URL,
+		"http://169.254.169.254/latest/meta-data/",
+		"http://[::1]/",
+		"http://10.0.0.1/",
+	} {
+		if _, err := f.Fetch(context.Background(), target); !errors.Is(err, ErrBlockedDestination) {
+			t.Errorf("Fetch(%s): expected ErrBlockedDestination, got %v", target, err)
+		}
+	}
+}
+
+func TestFetchRejectsUnsupportedSchemes(t *testing.T) {
+	f, err := New(Options{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for _, target := range []string{"file:///etc/passwd", "gopher://example.com/", "ftp://example.com/a.txt"} {
+		if _, err := f.Fetch(context.Background(), target); !errors.Is(err, ErrBlockedDestination) {
+			t.Errorf("Fetch(%s): expected ErrBlockedDestination, got %v", target, err)
+		}
+	}
+}
+
+func TestFetchAllowlisted(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/start" {
+			http.Redirect(w, r, "/docs/report%20final.txt", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprint(w, "hello")
+	}))
+	defer srv.Close()
+
+	f, err := New(Options{Allowlist: []string{"127.0.0.0/8"}, MaxRedirects: 3})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	res, err := f.Fetch(context.Background(), srv.URL+"/start")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if string(res.Body) != "hello" {
+		t.Errorf("expected body hello, got %q", res.Body)
+	}
+	if res.ContentType != "text/plain" {
+		t.Errorf("expected content type text/plain, got %q", res.ContentType)
+	}
+	if res.Filename != "report final.txt" {
+		t.Errorf("expected filename from final URL, got %q", res.Filename)
+	}
+	if !strings.HasSuffix(res.FinalURL, "/docs/report%20final.txt") {
+		t.Errorf("expected final URL after redirect, got %q", res.FinalURL)
+	}
+}
+
+func TestFetchAllowlistedHostname(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", `attachment; filename="../manual.pdf"`)
+		fmt.Fprint(w, "%PDF")
+	}))
+	defer srv.Close()
+
+	f, err := New(Options{Allowlist: []string{"localhost"}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	res, err := f.Fetch(context.Background(), strings.Replace(srv.URL, "127.0.0.1", "localhost", 1))
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if res.Filename != "manual.pdf" {
+		t.Errorf("expected sanitized Content-Disposition filename, got %q", res.Filename)
+	}
+}
+
+func TestFetchRedirectLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	f, err := New(Options{Allowlist: []string{"127.0.0.0/8"}, MaxRedirects: 2})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := f.Fetch(context.Background(), srv.URL); !errors.Is(err, ErrTooManyRedirects) {
+		t.Errorf("expected ErrTooManyRedirects, got %v", err)
+	}
+}
+
+func TestFetchRedirectToBlockedDestination(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	f, err := New(Options{Allowlist: []string{"127.0.0.0/8"}, MaxRedirects: 3})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := f.Fetch(context.Background(), srv.URL); !errors.Is(err, ErrBlockedDestination) {
+		t.Errorf("expected ErrBlockedDestination, got %v", err)
+	}
+}
+
+func TestFetchSizeLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Flush before writing so no Content-Length is sent and the body limit applies.
+		w.(http.Flusher).Flush()
+		fmt.Fprint(w, strings.Repeat("a", 64))
+	}))
+	defer srv.Close()
+
+	f, err := New(Options{Allowlist: []string{"127.0.0.0/8"}, MaxSize: 16})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := f.Fetch(context.Background(), srv.URL); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+}
+
+func TestNewRejectsInvalidCIDR(t *testing.T) {
+	if _, err := New(Options{Allowlist: []string{"10.0.0.0/99"}}); err == nil {
+		t.Error("expected error for invalid CIDR")
+	}
+}
@@ -0,0 +1,119 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"doc-agents/internal/queue"
+	"doc-agents/internal/store"
+)
+
+// Deliverer sends webhook queue tasks to their endpoints and logs every attempt.
+type Deliverer struct {
+	store  store.Store
+	client *http.Client
+	log    *slog.Logger
+}
+
+// NewDeliverer creates a Deliverer. Redirects are not followed: a receiver
+// must answer at the registered URL.
+func NewDeliverer(st store.Store, transport http.RoundTripper, timeout time.Duration, log *slog.Logger) *Deliverer {
+	return &Deliverer{
+		store: st,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		log: log,
+	}
+}
+
+// Handle is a queue.Handler that makes one delivery attempt. A failed attempt
+// is returned as an error, so the queue retries it after
+// retry.ExponentialBackoff until the task's MaxAttempts is reached.
+func (d *Deliverer) Handle(ctx context.Context, task queue.Task) error {
+	var dt deliveryTask
+	if err := json.Unmarshal(task.Payload, &dt); err != nil {
+		return err
+	}
+	var payload Payload
+	if err := json.Unmarshal(dt.Payload, &payload); err != nil {
+		return err
+	}
+	log := d.log.With("webhook_id", dt.WebhookID, "event", payload.Event, "event_id", payload.ID)
+
+	hook, err := d.store.GetWebhook(ctx, dt.WebhookID)
+	if errors.Is(err, store.ErrWebhookNotFound) {
+		log.Info("webhook deleted, dropping delivery")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load webhook: %w", err)
+	}
+
+	start := time.Now()
+	status, sendErr := d.send(ctx, hook, payload, dt.Payload)
+	delivery := store.WebhookDelivery{
+		WebhookID:  hook.ID,
+		EventID:    payload.ID,
+		Event:      string(payload.Event),
+		DocumentID: payload.Document.ID,
+		Attempt:    task.Attempt(),
+		StatusCode: status,
+		Duration:   time.Since(start),
+	}
+	if sendErr != nil {
+		delivery.Error = sendErr.Error()
+	}
+	if err := d.store.RecordWebhookDelivery(ctx, delivery); err != nil {
+		log.Warn("failed to record webhook delivery", "err", err)
+	}
+
+	if sendErr != nil {
+		if task.IsLastAttempt() {
+			log.Error("webhook delivery failed permanently", "attempt", delivery.Attempt, "err", sendErr)
+		} else {
+			log.Warn("webhook delivery failed, will retry", "attempt", delivery.Attempt, "err", sendErr)
+		}
+		return sendErr
+	}
+	log.Info("webhook delivered", "attempt", delivery.Attempt, "status", status)
+	return nil
+}
+
+// send posts body to hook and returns the response status. Any status
+// outside 2xx is an error.
+func (d *Deliverer) send(ctx context.Context, hook store.Webhook, payload Payload, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "doc-agents-webhook/1")
+	req.Header.Set(EventHeader, string(payload.Event))
+	req.Header.Set(IDHeader, payload.ID.String())
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, time.Now(), body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Drain a little so the connection can be reused; the body is not used
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver returned %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,18 @@
+package webhook
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+
+	"doc-agents/internal/store"
+)
+
+// MockNotifier is a mock implementation of Notifier using testify/mock.
+type MockNotifier struct {
+	mock.Mock
+}
+
+func (m *MockNotifier) Notify(ctx context.Context, event Event, doc store.Document, reason string) {
+	m.Called(ctx, event, doc, reason)
+}
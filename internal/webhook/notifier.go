@@ -0,0 +1,92 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+
+	"doc-agents/internal/queue"
+	"doc-agents/internal/store"
+)
+
+// Notifier announces document lifecycle events to the subscribed webhooks.
+// Notifying is best effort: failures are logged, never returned, so a
+// webhook problem cannot fail document processing.
+type Notifier interface {
+	// Notify announces event for doc; reason explains a failure.
+	Notify(ctx context.Context, event Event, doc store.Document, reason string)
+}
+
+// deliveryTask is the payload of a webhook queue task: one event for one webhook.
+type deliveryTask struct {
+	WebhookID uuid.UUID       `json:"webhook_id"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// QueueNotifier enqueues one delivery task per subscribed webhook, so each
+// endpoint is retried on its own.
+type QueueNotifier struct {
+	store       store.Store
+	queue       queue.Queue
+	log         *slog.Logger
+	maxAttempts int
+}
+
+// NewNotifier creates a QueueNotifier. maxAttempts bounds the deliveries of
+// each event to each webhook.
+func NewNotifier(st store.Store, q queue.Queue, log *slog.Logger, maxAttempts int) *QueueNotifier {
+	return &QueueNotifier{store: st, queue: q, log: log, maxAttempts: maxAttempts}
+}
+
+// Notify implements Notifier.
+func (n *QueueNotifier) Notify(ctx context.Context, event Event, doc store.Document, reason string) {
+	log := n.log.With("event", event, "document_id", doc.ID)
+	hooks, err := n.store.ListWebhooks(ctx, string(event))
+	if err != nil {
+		log.Error("failed to list webhooks", "err", err)
+		return
+	}
+	if len(hooks) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(Payload{
+		ID:        uuid.New(),
+		Event:     event,
+		CreatedAt: time.Now().UTC(),
+		Document: Document{
+			ID:       doc.ID,
+			Filename: doc.Filename,
+			Status:   string(doc.Status),
+			Error:    reason,
+		},
+	})
+	if err != nil {
+		log.Error("failed to marshal webhook payload", "err", err)
+		return
+	}
+	for _, hook := range hooks {
+		task, err := json.Marshal(deliveryTask{WebhookID: hook.ID, Payload: body})
+		if err != nil {
+			log.Error("failed to marshal webhook task", "webhook_id", hook.ID, "err", err)
+			continue
+		}
+		err = queue.EnqueueWithRetry(ctx, n.queue, queue.Task{
+			Type:        queue.TaskTypeWebhook,
+			Payload:     task,
+			MaxAttempts: n.maxAttempts,
+		}, 3, 200*time.Millisecond)
+		if err != nil {
+			log.Error("failed to enqueue webhook delivery", "webhook_id", hook.ID, "err", err)
+		}
+	}
+}
+
+// NoOpNotifier sends nothing; used where webhooks are not configured.
+type NoOpNotifier struct{}
+
+// Notify implements Notifier.
+func (NoOpNotifier) Notify(context.Context, Event, store.Document, string) {}
@@ -0,0 +1,202 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+
+	"doc-agents/internal/queue"
+	"doc-agents/internal/store"
+	"doc-agents/internal/urlfetch"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"event":"document.ready"}`)
+	now := time.Unix(1735810200, 0)
+	header := Sign("whsec_test", now, body)
+
+	tests := []struct {
+		name    string
+		secret  string
+		header  string
+		body    []byte
+		now     time.Time
+		wantErr error
+	}{
+		{name: "valid", secret: "whsec_test", header: header, body: body, now: now.Add(time.Minute)},
+		{name: "wrong secret", secret: "whsec_other", header: header, body: body, now: now, wantErr: ErrInvalidSignature},
+		{name: "tampered body", secret: "whsec_test", header: header, body: []byte(`{"event":"document.failed"}`), now: now, wantErr: ErrInvalidSignature},
+		{name: "expired", secret: "whsec_test", header: header, body: body, now: now.Add(10 * time.Minute), wantErr: ErrExpiredSignature},
+		{name: "malformed header", secret: "whsec_test", header: "garbage", body: body, now: now, wantErr: ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.header, tt.body, 5*time.Minute, tt.now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// inlineQueue runs webhook tasks immediately and retries failures the way the
+// NATS queue does, minus the backoff delay.
+type inlineQueue struct {
+	handler queue.Handler
+}
+
+func (q *inlineQueue) Enqueue(ctx context.Context, task queue.Task) error {
+	for {
+		if err := q.handler(ctx, task); err == nil || task.IsLastAttempt() {
+			return nil
+		}
+		task.Attempts++
+	}
+}
+
+func (q *inlineQueue) Worker(context.Context, queue.TaskType, queue.Handler) error {
+	return nil
+}
+
+// receiver records the deliveries it accepts after checking their signature.
+type receiver struct {
+	secret   string
+	failures int // Requests to reject with 503 before accepting
+	mu       sync.Mutex
+	calls    int
+	accepted []Payload
+}
+
+func (rcv *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	rcv.calls++
+	body, _ := io.ReadAll(r.Body)
+	if err := Verify(rcv.secret, r.Header.Get(SignatureHeader), body, time.Minute, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if rcv.calls <= rcv.failures {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var p Payload
+	_ = json.Unmarshal(body, &p)
+	if r.Header.Get(EventHeader) != string(p.Event) || r.Header.Get(IDHeader) != p.ID.String() {
+		http.Error(w, "header mismatch", http.StatusBadRequest)
+		return
+	}
+	rcv.accepted = append(rcv.accepted, p)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestNotifyDeliversEndToEnd(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	doc := store.Document{ID: uuid.New(), Filename: "report.pdf", Status: store.StatusFailed}
+
+	tests := []struct {
+		name         string
+		failures     int
+		secret       string
+		allowlist    []string
+		wantCalls    int
+		wantAccepted int
+		wantErrors   int
+	}{
+		{name: "delivered first time", secret: "whsec_a", allowlist: []string{"127.0.0.1/32"}, wantCalls: 1, wantAccepted: 1},
+		{name: "retried until accepted", failures: 2, secret: "whsec_a", allowlist: []string{"127.0.0.1/32"}, wantCalls: 3, wantAccepted: 1, wantErrors: 2},
+		{name: "gives up after max attempts", failures: 10, secret: "whsec_a", allowlist: []string{"127.0.0.1/32"}, wantCalls: 4, wantErrors: 4},
+		{name: "bad secret is rejected by receiver", secret: "whsec_wrong", allowlist: []string{"127.0.0.1/32"}, wantCalls: 4, wantErrors: 4},
+		{name: "private destination is blocked", secret: "whsec_a", wantErrors: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rcv := &receiver{secret: "whsec_a", failures: tt.failures}
+			srv := httptest.NewServer(rcv)
+			defer srv.Close()
+
+			hook := store.Webhook{ID: uuid.New(), URL: srv.URL + "/hooks", Secret: tt.secret, Events: []string{string(EventDocumentFailed)}}
+			mockStore := new(store.MockStore)
+			mockStore.On("ListWebhooks", mock.Anything, string(EventDocumentFailed)).Return([]store.Webhook{hook}, nil).Once()
+			mockStore.On("GetWebhook", mock.Anything, hook.ID).Return(hook, nil)
+			var logged []store.WebhookDelivery
+			mockStore.On("RecordWebhookDelivery", mock.Anything, mock.Anything).Return(nil).
+				Run(func(args mock.Arguments) { logged = append(logged, args.Get(1).(store.WebhookDelivery)) })
+
+			fetcher, err := urlfetch.New(urlfetch.Options{Allowlist: tt.allowlist})
+			if err != nil {
+				t.Fatal(err)
+			}
+			deliverer := NewDeliverer(mockStore, fetcher.Transport(), 5*time.Second, log)
+			notifier := NewNotifier(mockStore, &inlineQueue{handler: deliverer.Handle}, log, 4)
+
+			notifier.Notify(context.Background(), EventDocumentFailed, doc, "corrupt pdf")
+
+			if rcv.calls != tt.wantCalls {
+				t.Errorf("receiver got %d calls, want %d", rcv.calls, tt.wantCalls)
+			}
+			if len(rcv.accepted) != tt.wantAccepted {
+				t.Fatalf("receiver accepted %d deliveries, want %d", len(rcv.accepted), tt.wantAccepted)
+			}
+			if tt.wantAccepted > 0 {
+				got := rcv.accepted[0]
+				if got.Event != EventDocumentFailed || got.Document.ID != doc.ID || got.Document.Error != "corrupt pdf" {
+					t.Errorf("unexpected payload %+v", got)
+				}
+			}
+
+			var failed int
+			for i, d := range logged {
+				if d.Attempt != i+1 || d.WebhookID != hook.ID || d.DocumentID != doc.ID || d.EventID != logged[0].EventID {
+					t.Errorf("delivery %d logged as %+v", i, d)
+				}
+				if d.Error != "" {
+					failed++
+				}
+			}
+			if failed != tt.wantErrors {
+				t.Errorf("logged %d failed attempts, want %d", failed, tt.wantErrors)
+			}
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDeliverDropsDeletedWebhook(t *testing.T) {
+	hookID := uuid.New()
+	mockStore := new(store.MockStore)
+	mockStore.On("GetWebhook", mock.Anything, hookID).Return(store.Webhook{}, store.ErrWebhookNotFound).Once()
+
+	payload, _ := json.Marshal(Payload{ID: uuid.New(), Event: EventDocumentReady})
+	task, _ := json.Marshal(deliveryTask{WebhookID: hookID, Payload: payload})
+
+	d := NewDeliverer(mockStore, http.DefaultTransport, time.Second, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err := d.Handle(context.Background(), queue.Task{Type: queue.TaskTypeWebhook, Payload: task}); err != nil {
+		t.Errorf("Handle() error = %v, want nil", err)
+	}
+	mockStore.AssertExpectations(t)
+}
+
+func TestNotifyWithoutSubscribersEnqueuesNothing(t *testing.T) {
+	mockStore := new(store.MockStore)
+	mockQueue := new(queue.MockQueue)
+	mockStore.On("ListWebhooks", mock.Anything, string(EventDocumentCreated)).Return(nil, nil).Once()
+
+	n := NewNotifier(mockStore, mockQueue, slog.New(slog.NewTextHandler(io.Discard, nil)), 3)
+	n.Notify(context.Background(), EventDocumentCreated, store.Document{ID: uuid.New()}, "")
+
+	mockStore.AssertExpectations(t)
+	mockQueue.AssertNotCalled(t, "Enqueue", mock.Anything, mock.Anything)
+}